@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
-	"log"
+	"errors"
+	"flag"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
@@ -11,92 +13,353 @@ import (
 	"time"
 
 	"github.com/Gsupakin/back_end_test_challeng/internal/application"
+	"github.com/Gsupakin/back_end_test_challeng/internal/audit"
+	"github.com/Gsupakin/back_end_test_challeng/internal/auth"
+	"github.com/Gsupakin/back_end_test_challeng/internal/config"
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
 	grpcserver "github.com/Gsupakin/back_end_test_challeng/internal/grpc"
 	"github.com/Gsupakin/back_end_test_challeng/internal/infrastructure"
+	"github.com/Gsupakin/back_end_test_challeng/internal/lifecycle"
+	"github.com/Gsupakin/back_end_test_challeng/internal/privacy"
+	"github.com/Gsupakin/back_end_test_challeng/internal/requestlog"
+	"github.com/Gsupakin/back_end_test_challeng/internal/userimport"
 	"github.com/Gsupakin/back_end_test_challeng/middleware"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/health"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/jwt"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/logger"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/metrics"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/tracing"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/utils"
 	pb "github.com/Gsupakin/back_end_test_challeng/proto"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
 	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 func main() {
 	// Load .env file
-	if err := godotenv.Load(); err != nil {
-		log.Printf("Warning: .env file not found: %v", err)
+	envErr := godotenv.Load()
+
+	// การตั้งค่าทั้งหมดมาจาก ค่าเริ่มต้น → ไฟล์ YAML (-config หรือ CONFIG_FILE) → environment → flag
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		logger.Fatal(slog.Default(), "invalid configuration", "error", err)
+	}
+
+	// ลดข้อมูลส่วนบุคคลใน request log, audit log และ console (LOG_REDACT_*)
+	redactor := cfg.Redact.Redactor()
+
+	// log ของทั้ง service เป็น JSON หรือ text ตาม LOG_FORMAT และ LOG_LEVEL
+	// slog.SetDefault ทำให้ log จาก package log เดิมและ library ผ่าน handler เดียวกันด้วย
+	logConfig := cfg.Log.LoggerConfig()
+	logConfig.Redactor = redactor
+	appLogger := logger.New(os.Stdout, logConfig)
+	slog.SetDefault(appLogger)
+	if envErr != nil {
+		appLogger.Warn(".env file not found", "error", envErr)
 	}
 
-	// Get MongoDB URI from environment
-	mongoURI := os.Getenv("MONGODB_URI")
-	if mongoURI == "" {
-		log.Fatal("MONGODB_URI environment variable is not set")
+	// route ของ gin ถูก log ที่ระดับ debug แทนข้อความ [GIN-debug]
+	if os.Getenv(gin.EnvGinMode) == "" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	gin.DebugPrintRouteFunc = func(method, path, handler string, _ int) {
+		appLogger.Debug("route registered", "method", method, "route", path, "handler", handler)
 	}
 
+	// OpenTelemetry tracing (OTEL_TRACES_EXPORTER, OTEL_EXPORTER_OTLP_ENDPOINT, OTEL_SERVICE_NAME)
+	tracingConfig := cfg.Tracing.TracingConfig()
+	shutdownTracing, err := tracing.Setup(context.Background(), tracingConfig)
+	if err != nil {
+		logger.Fatal(appLogger, "failed to set up tracing", "error", err)
+	}
+
+	// secret ของ JWT, อายุ session และ bcrypt cost ใช้ร่วมกันทุก package
+	jwt.SetSecret(cfg.Auth.JWTSecret)
+	jwt.SessionTTL = cfg.Auth.SessionTTL
+	utils.BcryptCost = cfg.Auth.BcryptCost
+
 	// สร้าง context ที่สามารถยกเลิกได้
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// เชื่อมต่อ MongoDB
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoURI))
+	// เชื่อมต่อ MongoDB และ ping ให้ผ่านก่อนเปิดรับ request
+	// ทุก repository ใช้ client เดียวกัน จึง trace ทุกคำสั่งและ log คำสั่งที่ล้มเหลวหรือช้าเกิน MONGODB_SLOW_QUERY ได้จากที่เดียว
+	client, err := infrastructure.ConnectMongo(ctx, cfg.Mongo, infrastructure.Monitors(
+		otelmongo.NewMonitor(),
+		infrastructure.CommandMonitor(appLogger.With("component", "mongo"), cfg.Mongo.SlowQuery),
+	))
 	if err != nil {
-		log.Fatal(err)
+		logger.Fatal(appLogger, "failed to connect to MongoDB", "error", err)
 	}
 	defer func() {
 		if err := client.Disconnect(ctx); err != nil {
-			log.Printf("Error disconnecting from MongoDB: %v", err)
+			appLogger.Error("failed to disconnect from MongoDB", "error", err)
 		}
 	}()
 
-	db := client.Database("Test")
+	// readiness ของ /readyz และ gRPC health service
+	healthRegistry := health.NewRegistry(cfg.Health.CheckTimeout, pb.UserService_ServiceDesc.ServiceName)
+	healthRegistry.Register("mongodb", func(ctx context.Context) error {
+		return client.Ping(ctx, readpref.Primary())
+	})
+
+	db := client.Database(cfg.Mongo.Database)
 	userCollection := db.Collection("users")
 	logCollection := db.Collection("request_logs")
+	magicLinkCollection := db.Collection("magic_links")
+	oauthClientCollection := db.Collection("oauth_clients")
+	oauthCodeCollection := db.Collection("oauth_codes")
+	apiTokenCollection := db.Collection("api_tokens")
+	sessionCollection := db.Collection("sessions")
+	auditCollection := db.Collection("audit_log")
+	erasureCollection := db.Collection("erasure_requests")
 
 	// Initialize repositories
-	userRepo := infrastructure.NewMongoUserRepository(userCollection)
+	// การเปลี่ยนแปลงผู้ใช้และ session ทุกช่องทางถูกบันทึกลง audit log ผ่าน repository ที่ครอบไว้
+	auditRepo := infrastructure.NewMongoAuditRepository(auditCollection)
+	auditRecorder := audit.NewRecorder(auditRepo).WithRedactor(redactor).WithLogger(appLogger.With("component", "audit"))
+	userRepo := audit.NewUserRepository(infrastructure.NewMongoUserRepository(userCollection), auditRecorder)
 	logRepo := infrastructure.NewMongoLogRepository(logCollection)
+	if err := logRepo.EnsureIndexes(ctx); err != nil {
+		appLogger.Warn("failed to create request log indexes", "error", err)
+	}
+	// TTL และการ archive log เก่า (LOG_RETENTION_DAYS, LOG_ARCHIVE_AFTER_DAYS, LOG_ARCHIVE_DIR)
+	logArchiveConfig := cfg.Log.ArchiveConfig()
+	if err := logRepo.EnsureRetention(ctx, logArchiveConfig.Retention); err != nil {
+		appLogger.Warn("failed to apply request log retention", "error", err)
+	}
+	// request log ทั้งหมดผ่านคิวเดียวและถูกบันทึกเป็นชุด (LOG_QUEUE_SIZE, LOG_BATCH_SIZE, LOG_FLUSH_INTERVAL, LOG_QUEUE_POLICY)
+	logWriterConfig := cfg.Log.WriterConfig()
+	logWriterConfig.Redactor = redactor
+	logWriterConfig.Logger = appLogger.With("component", "requestlog")
+	logWriter := requestlog.NewWriter(logRepo, logWriterConfig)
+	healthRegistry.Register("request_log", func(context.Context) error {
+		return logWriter.Healthy(0.9)
+	})
+	magicLinkRepo := infrastructure.NewMongoMagicLinkRepository(magicLinkCollection)
+	if err := magicLinkRepo.EnsureIndexes(ctx); err != nil {
+		appLogger.Warn("failed to create magic link indexes", "error", err)
+	}
+	oauthClientRepo := infrastructure.NewMongoOAuthClientRepository(oauthClientCollection)
+	oauthCodeRepo := infrastructure.NewMongoAuthorizationCodeRepository(oauthCodeCollection)
+	if err := oauthCodeRepo.EnsureIndexes(ctx); err != nil {
+		appLogger.Warn("failed to create authorization code indexes", "error", err)
+	}
+	apiTokenRepo := infrastructure.NewMongoAPITokenRepository(apiTokenCollection)
+	sessionRepo := audit.NewSessionRepository(infrastructure.NewMongoSessionRepository(sessionCollection), auditRecorder)
+	erasureRepo := infrastructure.NewMongoErasureRepository(erasureCollection)
+	if err := erasureRepo.EnsureIndexes(ctx); err != nil {
+		appLogger.Warn("failed to create erasure request indexes", "error", err)
+	}
+
+	// ใช้ตรวจสอบ JWT, personal access token และ API key ทั้ง HTTP และ gRPC
+	authenticator := auth.NewAuthenticator(apiTokenRepo, sessionRepo).WithUsers(userRepo).WithLogger(appLogger.With("component", "auth"))
+
+	// การส่ง JWT ผ่าน cookie สำหรับ browser (AUTH_COOKIE_MODE)
+	cookieConfig := cfg.Cookie.CookieConfig()
+	if cookieConfig.Enabled() && !cookieConfig.Secure {
+		appLogger.Warn("AUTH_COOKIE_SECURE=false, session cookies will be sent over plain HTTP")
+	}
 
 	// Initialize handler
-	userHandler := application.NewUserHandler(userRepo, logRepo)
+	sessionHandler := application.NewSessionHandler(userRepo, sessionRepo, cookieConfig).WithLogger(appLogger.With("component", "sessions"))
+	userHandler := application.NewUserHandler(userRepo, sessionHandler)
+
+	mfaHandler := application.NewMFAHandler(userRepo, sessionHandler, cfg.MFA.Issuer)
+
+	mailLogger := appLogger.With("component", "mailer")
+	magicLinkHandler := application.NewMagicLinkHandler(userRepo, magicLinkRepo, sessionHandler, cfg.SMTP.Mailer(mailLogger), cfg.MagicLink.URL).
+		WithLogger(mailLogger)
+
+	// โหลด key สำหรับเซ็น ID token ถ้าไม่ได้ตั้งค่าจะสร้าง key ชั่วคราว (token เดิมจะใช้ไม่ได้หลัง restart)
+	var signingKeys *jwt.KeySet
+	if cfg.OIDC.SigningKeyFile != "" {
+		signingKeys, err = jwt.LoadKeySet(cfg.OIDC.SigningKeyFile)
+	} else {
+		appLogger.Warn("OIDC_SIGNING_KEY_FILE not set, generating an ephemeral signing key")
+		signingKeys, err = jwt.GenerateKeySet()
+	}
+	if err != nil {
+		logger.Fatal(appLogger, "failed to load OIDC signing key", "error", err)
+	}
+
+	healthRegistry.Register("keys", func(context.Context) error {
+		if _, err := jwt.Secret(); err != nil {
+			return err
+		}
+		if signingKeys == nil {
+			return errors.New("OIDC signing key not loaded")
+		}
+		return nil
+	})
+
+	oidcHandler := application.NewOIDCHandler(userRepo, oauthClientRepo, oauthCodeRepo, authenticator, signingKeys, cfg.OIDC.Issuer)
 
-	router := gin.Default()
-	router.Use(middleware.RequestLoggerToMongo(logCollection))
+	// รายการ IdP ภายนอกในรูปแบบ JSON array
+	upstreamProviders, err := application.ParseUpstreamProviders(cfg.OIDC.Providers)
+	if err != nil {
+		logger.Fatal(appLogger, "invalid OIDC_PROVIDERS", "error", err)
+	}
+	identityHandler := application.NewIdentityHandler(userRepo, sessionHandler, upstreamProviders)
+	tokenHandler := application.NewTokenHandler(userRepo, apiTokenRepo)
+	auditHandler := application.NewAuditHandler(auditRepo)
+	userImportHandler := application.NewUserImportHandler(userimport.NewImporter(userRepo), 0)
+	userAdminHandler := application.NewUserAdminHandler(application.NewUserAdmin(userRepo, apiTokenRepo))
+	userExportHandler := application.NewUserExportHandler(userRepo).WithLogger(appLogger.With("component", "export"))
+	eraser := infrastructure.NewMongoPersonalDataEraser(db).WithRedactor(redactor)
+	privacyHandler := application.NewPrivacyHandler(userRepo, logRepo, erasureRepo, eraser).
+		WithRedactor(redactor).
+		WithLogger(appLogger.With("component", "privacy"))
+	logHandler := application.NewLogHandler(logRepo)
+	healthHandler := application.NewHealthHandler(healthRegistry)
+
+	router := gin.New()
+	// probe ลงทะเบียนก่อน middleware จึงไม่ถูก trace, log หรือนับใน metrics
+	router.GET("/healthz", healthHandler.Liveness)
+	router.GET("/readyz", healthHandler.Readiness)
+	router.Use(middleware.Tracing(tracingConfig.ServiceName))
+	router.Use(middleware.RequestID())
+	router.Use(middleware.ConsoleLogger(appLogger, redactor), middleware.Recovery(appLogger))
+	router.Use(middleware.Metrics())
+	router.Use(middleware.RequestLogger(logWriter))
+	router.Use(middleware.AuditOrigin())
 
 	router.POST("/register", userHandler.Register)
 	router.POST("/login", userHandler.Login)
+	router.POST("/login/mfa", mfaHandler.VerifyLogin)
+	router.POST("/login/magic-link", magicLinkHandler.Request)
+	router.POST("/login/magic-link/verify", magicLinkHandler.Verify)
+
+	router.GET("/.well-known/openid-configuration", oidcHandler.Discovery)
+	router.GET("/.well-known/jwks.json", oidcHandler.JWKS)
+	router.GET("/authorize", oidcHandler.Authorize)
+	router.POST("/authorize", oidcHandler.Approve)
+	router.POST("/token", oidcHandler.Token)
+	router.GET("/userinfo", oidcHandler.UserInfo)
+	router.POST("/userinfo", oidcHandler.UserInfo)
 
-	auth := router.Group("/", middleware.JWTAuth())
+	router.GET("/auth/providers", identityHandler.ListProviders)
+	router.GET("/auth/:provider/login", identityHandler.Login)
+	router.GET("/auth/:provider/callback", identityHandler.Callback)
+
+	authed := router.Group("/", middleware.JWTAuth(authenticator, cookieConfig))
 	{
-		auth.GET("/users", userHandler.ListUsers)
-		auth.GET("/users/:id", userHandler.GetUserByID)
-		auth.PUT("/users/:id", userHandler.UpdateUser)
-		auth.DELETE("/users/:id", userHandler.DeleteUser)
+		authed.GET("/users", middleware.RequireScope(domain.ScopeUsersRead), userHandler.ListUsers)
+		authed.GET("/users/:id", middleware.RequireScope(domain.ScopeUsersRead), userHandler.GetUserByID)
+		authed.PUT("/users/:id", middleware.RequireScope(domain.ScopeUsersWrite), userHandler.UpdateUser)
+		authed.DELETE("/users/:id", middleware.RequireScope(domain.ScopeUsersWrite), middleware.ForbidImpersonation(), userHandler.DeleteUser)
+
+		authed.POST("/logout", sessionHandler.Logout)
+	}
+
+	// endpoint ที่จัดการ credential ของผู้ใช้เองต้องล็อกอินจริง ไม่รับ token สำหรับเครื่องหรือ admin ที่สวมสิทธิ์อยู่
+	me := authed.Group("/me", middleware.RequireInteractive())
+	{
+		me.POST("/mfa/totp", mfaHandler.Enroll)
+		me.POST("/mfa/totp/confirm", mfaHandler.Confirm)
+		me.DELETE("/mfa/totp", mfaHandler.Disable)
+		me.POST("/mfa/recovery-codes", mfaHandler.RegenerateRecoveryCodes)
+
+		me.DELETE("/magic-links", magicLinkHandler.RevokeAll)
+
+		me.GET("/identities", identityHandler.ListIdentities)
+		me.POST("/identities/:provider", identityHandler.StartLink)
+		me.DELETE("/identities/:provider/:subject", identityHandler.Unlink)
+
+		me.GET("/sessions", sessionHandler.List)
+		me.DELETE("/sessions/:id", sessionHandler.Revoke)
+
+		me.POST("/tokens", tokenHandler.CreatePersonal)
+		me.GET("/tokens", tokenHandler.ListPersonal)
+		me.DELETE("/tokens/:id", tokenHandler.RevokePersonal)
+
+		me.GET("/data-export", privacyHandler.DataExport)
+		me.POST("/erasure", privacyHandler.RequestErasure)
+	}
+
+	admin := authed.Group("/admin", middleware.RequireScope(domain.ScopeAdmin), middleware.RequireAdmin(userRepo))
+	{
+		admin.POST("/oidc/clients", oidcHandler.CreateClient)
+		admin.GET("/oidc/clients", oidcHandler.ListClients)
+		admin.DELETE("/oidc/clients/:client_id", oidcHandler.DeleteClient)
+
+		admin.POST("/api-keys", tokenHandler.CreateServiceKey)
+		admin.GET("/api-keys", tokenHandler.ListServiceKeys)
+		admin.DELETE("/api-keys/:id", tokenHandler.RevokeServiceKey)
+
+		admin.GET("/users", userAdminHandler.List)
+		admin.POST("/users/:id/restore", userAdminHandler.Restore)
+		admin.POST("/users/:id/activate", userAdminHandler.Activate)
+		admin.POST("/users/:id/suspend", userAdminHandler.Suspend)
+		admin.POST("/users/:id/reactivate", userAdminHandler.Reactivate)
+		admin.POST("/users/:id/ban", userAdminHandler.Ban)
+		admin.POST("/users/import", userImportHandler.Import)
+		admin.GET("/users/export", userExportHandler.Export)
+		admin.POST("/users/:id/impersonate", middleware.RequireInteractive(), sessionHandler.Impersonate)
+		admin.DELETE("/impersonations/:id", sessionHandler.EndImpersonation)
+
+		admin.POST("/users/:id/erasure", privacyHandler.RequestUserErasure)
+		admin.GET("/erasures", privacyHandler.ListErasures)
+		admin.GET("/erasures/:id", privacyHandler.GetErasure)
+		admin.POST("/erasures/:id/approve", middleware.RequireInteractive(), privacyHandler.ApproveErasure)
+		admin.POST("/erasures/:id/reject", privacyHandler.RejectErasure)
+
+		admin.GET("/audit", auditHandler.List)
+
+		admin.GET("/logs", logHandler.List)
+		admin.GET("/logs/stats", logHandler.Stats)
 	}
 
 	// Create gRPC server
 	grpcServer := grpc.NewServer(
-		grpc.UnaryInterceptor(grpcserver.AuthInterceptor),
+		tracing.ServerOption(),
+		grpc.ChainUnaryInterceptor(
+			grpcserver.RequestIDInterceptor,
+			grpcserver.MetricsInterceptor,
+			grpcserver.LoggingInterceptor(logWriter, appLogger),
+			grpcserver.AuditInterceptor,
+			grpcserver.AuthInterceptor(authenticator),
+		),
 	)
 	userServer := grpcserver.NewUserServer(userRepo)
 	pb.RegisterUserServiceServer(grpcServer, userServer)
+	healthpb.RegisterHealthServer(grpcServer, healthRegistry.GRPCServer())
 
 	// สร้าง HTTP server
 	srv := &http.Server{
-		Addr:    ":8080",
+		Addr:    cfg.Server.HTTPAddr,
 		Handler: router,
 	}
 
-	// เริ่ม background goroutine สำหรับนับจำนวนผู้ใช้
+	// Prometheus scrape endpoint อยู่บน listener แยก (METRICS_ADDR) จึงไม่เปิดให้ผู้ใช้ทั่วไปผ่าน HTTP_ADDR
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", metrics.Handler())
+	metricsSrv := &http.Server{
+		Addr:    cfg.Server.MetricsAddr,
+		Handler: metricsMux,
+	}
+
+	// เริ่ม background goroutine สำหรับนับจำนวนผู้ใช้ทุก USER_COUNT_INTERVAL ผลลัพธ์อยู่ใน gauge backend_registered_users
 	go func() {
-		ticker := time.NewTicker(10 * time.Second)
+		countLogger := appLogger.With("component", "user_count")
+		ticker := time.NewTicker(cfg.Server.UserCountInterval)
 		defer ticker.Stop()
 
 		for {
 			select {
 			case <-ctx.Done():
-				log.Println("Stopping user count goroutine...")
+				countLogger.Info("stopping user count goroutine")
 				return
 			case <-ticker.C:
 				countCtx, countCancel := context.WithTimeout(ctx, 5*time.Second)
@@ -104,31 +367,57 @@ func main() {
 				countCancel()
 
 				if err != nil {
-					log.Printf("❌ Failed to count users: %v", err)
+					countLogger.Error("failed to count users", "error", err)
 				} else {
-					log.Printf("👥 Total users in DB: %d", count)
+					metrics.RegisteredUsers.Set(float64(count))
 				}
 			}
 		}
 	}()
 
+	// ตรวจ readiness เป็นระยะเพื่ออัพเดทสถานะของ gRPC health service
+	go healthRegistry.Watch(ctx, cfg.Health.CheckInterval)
+
+	if logArchiveConfig.ArchiveAfter > 0 {
+		archiver := requestlog.NewArchiver(logRepo, logArchiveConfig.Dir, logArchiveConfig.ArchiveAfter).
+			WithLogger(appLogger.With("component", "archiver"))
+		go archiver.RunEvery(ctx, logArchiveConfig.Interval)
+	}
+
+	if cfg.Users.PurgeAfterDays > 0 {
+		purger := privacy.NewPurger(userRepo, erasureRepo, eraser, time.Duration(cfg.Users.PurgeAfterDays)*24*time.Hour).
+			WithLogger(appLogger.With("component", "purge"))
+		go purger.RunEvery(ctx, cfg.Users.PurgeInterval)
+	}
+
+	// เปิดใช้งานผู้ใช้ที่การระงับชั่วคราวหมดเวลาแล้ว
+	reactivator := lifecycle.NewReactivator(userRepo).WithLogger(appLogger.With("component", "lifecycle"))
+	go reactivator.RunEvery(ctx, cfg.Users.ReactivateInterval)
+
 	// เริ่ม gRPC server ใน goroutine
 	go func() {
-		log.Println("Starting gRPC server on :50051...")
-		grpcListener, err := net.Listen("tcp", ":50051")
+		appLogger.Info("starting gRPC server", "addr", cfg.Server.GRPCAddr)
+		grpcListener, err := net.Listen("tcp", cfg.Server.GRPCAddr)
 		if err != nil {
-			log.Fatalf("Failed to start gRPC server: %v", err)
+			logger.Fatal(appLogger, "failed to start gRPC server", "error", err)
 		}
 		if err := grpcServer.Serve(grpcListener); err != nil {
-			log.Fatalf("Failed to serve gRPC: %v", err)
+			logger.Fatal(appLogger, "failed to serve gRPC", "error", err)
 		}
 	}()
 
 	// เริ่ม HTTP server ใน goroutine
 	go func() {
-		log.Println("Starting server on :8080...")
+		appLogger.Info("starting HTTP server", "addr", cfg.Server.HTTPAddr)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start server: %v", err)
+			logger.Fatal(appLogger, "failed to start HTTP server", "error", err)
+		}
+	}()
+
+	go func() {
+		appLogger.Info("starting metrics server", "addr", cfg.Server.MetricsAddr)
+		if err := metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Fatal(appLogger, "failed to start metrics server", "error", err)
 		}
 	}()
 
@@ -137,10 +426,18 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("Shutting down server...")
+	appLogger.Info("shutting down server")
+
+	// readiness เป็น false ทันทีเพื่อให้ load balancer หยุดส่ง traffic ใหม่ระหว่างปิด server
+	healthRegistry.Drain()
+	// รอให้ load balancer ตรวจ readiness รอบถัดไปก่อน ระหว่างนี้ request ที่ยังเข้ามาถูกตอบตามปกติ
+	if cfg.Server.DrainDelay > 0 {
+		appLogger.Info("waiting for load balancers to stop routing traffic", "drain_delay", cfg.Server.DrainDelay)
+		time.Sleep(cfg.Server.DrainDelay)
+	}
 
 	// สร้าง context สำหรับการปิด server
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer shutdownCancel()
 
 	// ยกเลิก context หลัก
@@ -148,11 +445,28 @@ func main() {
 
 	// ปิด HTTP server
 	if err := srv.Shutdown(shutdownCtx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		logger.Fatal(appLogger, "server forced to shutdown", "error", err)
 	}
 
 	// ปิด gRPC server
 	grpcServer.GracefulStop()
 
-	log.Println("Server exited properly")
+	// ปิด metrics server หลังสุดเพื่อให้ scrape ได้ระหว่างปิด server อื่น
+	if err := metricsSrv.Shutdown(shutdownCtx); err != nil {
+		appLogger.Error("metrics server did not shut down cleanly", "error", err)
+	}
+
+	// บันทึก request log ที่ค้างอยู่ในคิวก่อนปิดการเชื่อมต่อฐานข้อมูล
+	if err := logWriter.Close(shutdownCtx); err != nil {
+		appLogger.Error("request log writer did not flush in time", "error", err)
+	}
+	stats := logWriter.Stats()
+	appLogger.Info("request log writer closed", "written", stats.Written, "dropped", stats.Dropped, "failed", stats.Failed)
+
+	// ส่ง span ที่ค้างอยู่ก่อนจบโปรแกรม
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		appLogger.Error("failed to flush traces", "error", err)
+	}
+
+	appLogger.Info("server exited properly")
 }
@@ -0,0 +1,153 @@
+// logctl จัดการ request log: archive log เก่าลงไฟล์, นำไฟล์ archive กลับเข้าฐานข้อมูล และปรับ TTL
+//
+//	logctl archive [-after-days N] [-dir DIR]
+//	logctl restore [-collection NAME] FILE...
+//	logctl retention [-days N]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/config"
+	"github.com/Gsupakin/back_end_test_challeng/internal/infrastructure"
+	"github.com/Gsupakin/back_end_test_challeng/internal/requestlog"
+
+	"github.com/joho/godotenv"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const usage = `usage:
+  logctl archive [-after-days N] [-dir DIR]     archive request logs older than N days to gzip NDJSON files
+  logctl restore [-collection NAME] FILE...     insert archived logs back into MongoDB
+  logctl retention [-days N]                    set the TTL of request_logs (0 keeps logs forever)`
+
+func main() {
+	log.SetFlags(0)
+	if err := godotenv.Load(); err != nil {
+		log.Printf("Warning: .env file not found: %v", err)
+	}
+	if len(os.Args) < 2 {
+		log.Fatal(usage)
+	}
+
+	// อ่านค่าเดียวกับ server (CONFIG_FILE และ environment) แต่ไม่รับ flag ของ server
+	appConfig, err := config.Load(nil)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if err := appConfig.Log.Validate(); err != nil {
+		log.Fatalf("Invalid log configuration: %v", err)
+	}
+	cfg := settings{mongo: appConfig.Mongo, archive: appConfig.Log.ArchiveConfig()}
+
+	ctx := context.Background()
+	switch os.Args[1] {
+	case "archive":
+		err = archive(ctx, cfg, os.Args[2:])
+	case "restore":
+		err = restore(ctx, cfg, os.Args[2:])
+	case "retention":
+		err = retention(ctx, cfg, os.Args[2:])
+	default:
+		log.Fatal(usage)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// settings คือค่าที่ทุกคำสั่งใช้ร่วมกัน
+type settings struct {
+	mongo   config.Mongo
+	archive requestlog.ArchiveConfig
+}
+
+func connect(ctx context.Context, cfg config.Mongo, collection string) (*mongo.Client, *infrastructure.MongoLogRepository, error) {
+	if cfg.URI == "" {
+		return nil, nil, fmt.Errorf("MONGODB_URI is not set (set MONGODB_URI, MONGODB_URI_FILE or mongo.uri in CONFIG_FILE)")
+	}
+	client, err := infrastructure.ConnectMongo(ctx, cfg, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, infrastructure.NewMongoLogRepository(client.Database(cfg.Database).Collection(collection)), nil
+}
+
+func archive(ctx context.Context, cfg settings, args []string) error {
+	fs := flag.NewFlagSet("archive", flag.ExitOnError)
+	afterDays := fs.Int("after-days", int(cfg.archive.ArchiveAfter/(24*time.Hour)), "archive logs older than this many days")
+	dir := fs.String("dir", cfg.archive.Dir, "directory for archive files")
+	fs.Parse(args)
+	if *afterDays < 1 {
+		return fmt.Errorf("-after-days (or LOG_ARCHIVE_AFTER_DAYS) must be at least 1")
+	}
+
+	client, repo, err := connect(ctx, cfg.mongo, "request_logs")
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect(ctx)
+
+	results, err := requestlog.NewArchiver(repo, *dir, time.Duration(*afterDays)*24*time.Hour).Run(ctx, time.Now())
+	for _, r := range results {
+		fmt.Printf("%s\t%d logs\t%s\n", r.Day.Format("2006-01-02"), r.Count, r.File)
+	}
+	if err == nil && len(results) == 0 {
+		fmt.Println("nothing to archive")
+	}
+	return err
+}
+
+func restore(ctx context.Context, cfg settings, args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	collection := fs.String("collection", "request_logs", "collection to restore into")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return fmt.Errorf("restore needs at least one archive file")
+	}
+	if *collection == "request_logs" && cfg.archive.Retention > 0 {
+		log.Printf("Warning: logs older than %d days will be removed again by the TTL index; use -collection to restore elsewhere",
+			int(cfg.archive.Retention/(24*time.Hour)))
+	}
+
+	client, repo, err := connect(ctx, cfg.mongo, *collection)
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect(ctx)
+
+	for _, path := range fs.Args() {
+		read, inserted, err := requestlog.RestoreFile(ctx, repo, path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		fmt.Printf("%s\t%d read\t%d inserted\t%d already present\n", path, read, inserted, read-inserted)
+	}
+	return nil
+}
+
+func retention(ctx context.Context, cfg settings, args []string) error {
+	fs := flag.NewFlagSet("retention", flag.ExitOnError)
+	days := fs.Int("days", int(cfg.archive.Retention/(24*time.Hour)), "delete logs older than this many days (0 keeps logs forever)")
+	fs.Parse(args)
+	if *days < 0 {
+		return fmt.Errorf("-days must not be negative")
+	}
+
+	client, repo, err := connect(ctx, cfg.mongo, "request_logs")
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect(ctx)
+
+	if err := repo.EnsureRetention(ctx, time.Duration(*days)*24*time.Hour); err != nil {
+		return err
+	}
+	fmt.Printf("request_logs retention set to %d days\n", *days)
+	return nil
+}
@@ -0,0 +1,456 @@
+// userctl ดูแลผู้ใช้โดยตรงผ่าน repository เดียวกับ server แทนการแก้เอกสารใน MongoDB เอง
+// ทุกการเปลี่ยนแปลงถูกตรวจสอบด้วยกฎเดียวกับ API และบันทึกลง audit log โดยมีที่มาเป็น cli
+//
+//	userctl create [-role ROLE] [-generate-password] NAME EMAIL
+//	userctl set-role USER ROLE
+//	userctl set-status -reason TEXT [-until TIME] USER STATUS
+//	userctl reset-password [-generate] USER
+//	userctl delete USER
+//	userctl restore USER
+//	userctl list [-q TEXT] [-role ROLE] [-status STATUS] [-deleted] [-limit N] [-json]
+//	userctl import [-format FORMAT] [-mode MODE] [-dry-run] [-workers N] [-json] FILE
+//	userctl migrate [-status]
+//	userctl token [-name NAME] [-scopes LIST] [-days N] USER
+//
+// USER คือ ID หรืออีเมลของผู้ใช้ รหัสผ่านอ่านจาก stdin บรรทัดแรกเพื่อไม่ให้โผล่ใน process list หรือ shell history
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/application"
+	"github.com/Gsupakin/back_end_test_challeng/internal/audit"
+	"github.com/Gsupakin/back_end_test_challeng/internal/config"
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/internal/infrastructure"
+	"github.com/Gsupakin/back_end_test_challeng/internal/userimport"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/utils"
+
+	"github.com/joho/godotenv"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const usage = `usage:
+  userctl create [-role ROLE] [-generate-password] NAME EMAIL   create a user (password from stdin)
+  userctl set-role USER ROLE                                    set the role (user or admin)
+  userctl set-status -reason TEXT [-until TIME] USER STATUS    change the status (pending, active, suspended or banned)
+  userctl reset-password [-generate] USER                       set a new password (from stdin)
+  userctl delete USER                                           soft-delete a user
+  userctl restore USER                                          restore a soft-deleted user
+  userctl list [-q TEXT] [-role ROLE] [-status STATUS] [-deleted] [-limit N] [-json]
+                                                                list or search users
+  userctl import [-format FORMAT] [-mode MODE] [-dry-run] [-workers N] [-json] FILE
+                                                                import users from CSV or NDJSON (- reads stdin)
+  userctl migrate [-status]                                     run pending database migrations
+  userctl token [-name NAME] [-scopes LIST] [-days N] USER      issue an API key for a user
+
+USER is a user ID or email address.`
+
+var commands = map[string]bool{
+	"create": true, "set-role": true, "set-status": true, "reset-password": true,
+	"delete": true, "restore": true, "list": true, "import": true, "migrate": true, "token": true,
+}
+
+func main() {
+	log.SetFlags(0)
+	if err := godotenv.Load(); err != nil {
+		log.Printf("Warning: .env file not found: %v", err)
+	}
+	if len(os.Args) < 2 || !commands[os.Args[1]] {
+		log.Fatal(usage)
+	}
+
+	// อ่านค่าเดียวกับ server (CONFIG_FILE และ environment) แต่ไม่รับ flag ของ server
+	cfg, err := config.Load(nil)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	utils.BcryptCost = cfg.Auth.BcryptCost
+
+	ctx := audit.WithOrigin(context.Background(), audit.Origin{Source: domain.AuditSourceCLI})
+	client, err := connect(ctx, cfg.Mongo)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Disconnect(ctx)
+	db := client.Database(cfg.Mongo.Database)
+
+	switch os.Args[1] {
+	case "migrate":
+		err = migrate(ctx, db, os.Args[2:])
+	case "import":
+		err = importUsers(ctx, cfg, db, os.Args[2:])
+	default:
+		var admin *application.UserAdmin
+		admin, err = newUserAdmin(cfg, db)
+		if err == nil {
+			err = run(ctx, admin, os.Args[1], os.Args[2:])
+		}
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func connect(ctx context.Context, cfg config.Mongo) (*mongo.Client, error) {
+	if cfg.URI == "" {
+		return nil, fmt.Errorf("MONGODB_URI is not set (set MONGODB_URI, MONGODB_URI_FILE or mongo.uri in CONFIG_FILE)")
+	}
+	return infrastructure.ConnectMongo(ctx, cfg, nil)
+}
+
+// userRepository ครอบ repository ด้วย audit เหมือน server เพื่อให้การเปลี่ยนแปลงจาก CLI ถูกบันทึกด้วย
+func userRepository(cfg *config.Config, db *mongo.Database) (domain.UserRepository, error) {
+	if err := cfg.Redact.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid log redaction configuration: %w", err)
+	}
+	recorder := audit.NewRecorder(infrastructure.NewMongoAuditRepository(db.Collection("audit_log"))).WithRedactor(cfg.Redact.Redactor())
+	return audit.NewUserRepository(infrastructure.NewMongoUserRepository(db.Collection("users")), recorder), nil
+}
+
+func newUserAdmin(cfg *config.Config, db *mongo.Database) (*application.UserAdmin, error) {
+	userRepo, err := userRepository(cfg, db)
+	if err != nil {
+		return nil, err
+	}
+	tokenRepo := infrastructure.NewMongoAPITokenRepository(db.Collection("api_tokens"))
+	return application.NewUserAdmin(userRepo, tokenRepo), nil
+}
+
+func run(ctx context.Context, admin *application.UserAdmin, command string, args []string) error {
+	switch command {
+	case "create":
+		return create(ctx, admin, args)
+	case "set-role":
+		return setField(args, "set-role USER ROLE", func(ref, value string) (domain.User, error) {
+			return admin.SetRole(ctx, ref, value)
+		})
+	case "set-status":
+		return setStatus(ctx, admin, args)
+	case "reset-password":
+		return resetPassword(ctx, admin, args)
+	case "delete":
+		return single(args, "delete USER", func(ref string) (domain.User, error) {
+			return admin.Delete(ctx, ref)
+		})
+	case "restore":
+		return single(args, "restore USER", func(ref string) (domain.User, error) {
+			return admin.Restore(ctx, ref)
+		})
+	case "list":
+		return list(ctx, admin, args)
+	case "token":
+		return token(ctx, admin, args)
+	default:
+		return errors.New(usage)
+	}
+}
+
+func create(ctx context.Context, admin *application.UserAdmin, args []string) error {
+	fs := flag.NewFlagSet("create", flag.ExitOnError)
+	role := fs.String("role", domain.RoleUser, "role of the new user ("+strings.Join(domain.Roles, " or ")+")")
+	generate := fs.Bool("generate-password", false, "generate a random password and print it instead of reading stdin")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: userctl create [-role ROLE] [-generate-password] NAME EMAIL")
+	}
+
+	password, err := readPassword(*generate)
+	if err != nil {
+		return err
+	}
+	user, err := admin.Create(ctx, application.NewUser{Name: fs.Arg(0), Email: fs.Arg(1), Password: password, Role: *role})
+	if err != nil {
+		return err
+	}
+	printUser(user)
+	return nil
+}
+
+func resetPassword(ctx context.Context, admin *application.UserAdmin, args []string) error {
+	fs := flag.NewFlagSet("reset-password", flag.ExitOnError)
+	generate := fs.Bool("generate", false, "generate a random password and print it instead of reading stdin")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: userctl reset-password [-generate] USER")
+	}
+
+	password, err := readPassword(*generate)
+	if err != nil {
+		return err
+	}
+	user, err := admin.ResetPassword(ctx, fs.Arg(0), password)
+	if err != nil {
+		return err
+	}
+	printUser(user)
+	return nil
+}
+
+func setStatus(ctx context.Context, admin *application.UserAdmin, args []string) error {
+	fs := flag.NewFlagSet("set-status", flag.ExitOnError)
+	reason := fs.String("reason", "", "why the status is changed (required)")
+	untilFlag := fs.String("until", "", "end of a suspension as RFC 3339, e.g. 2024-01-31T00:00:00Z (suspended only)")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: userctl set-status -reason TEXT [-until TIME] USER STATUS")
+	}
+
+	var until *time.Time
+	if *untilFlag != "" {
+		t, err := time.Parse(time.RFC3339, *untilFlag)
+		if err != nil {
+			return fmt.Errorf("-until must be an RFC 3339 time: %w", err)
+		}
+		until = &t
+	}
+	user, err := admin.SetStatus(ctx, fs.Arg(0), domain.UserStatus(fs.Arg(1)), *reason, until)
+	if err != nil {
+		return err
+	}
+	printUser(user)
+	return nil
+}
+
+func setField(args []string, syntax string, set func(ref, value string) (domain.User, error)) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: userctl %s", syntax)
+	}
+	user, err := set(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	printUser(user)
+	return nil
+}
+
+func single(args []string, syntax string, do func(ref string) (domain.User, error)) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: userctl %s", syntax)
+	}
+	user, err := do(args[0])
+	if err != nil {
+		return err
+	}
+	printUser(user)
+	return nil
+}
+
+func list(ctx context.Context, admin *application.UserAdmin, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	var filter domain.UserFilter
+	fs.StringVar(&filter.Query, "q", "", "search name or email (case-insensitive substring)")
+	fs.StringVar(&filter.Role, "role", "", "only users with this role")
+	fs.Func("status", "only users with this status", func(value string) error {
+		filter.Status = domain.UserStatus(value)
+		return nil
+	})
+	fs.BoolVar(&filter.IncludeDeleted, "deleted", false, "include soft-deleted users")
+	fs.IntVar(&filter.Limit, "limit", 0, "maximum number of users (0 means no limit)")
+	asJSON := fs.Bool("json", false, "print JSON instead of a table")
+	fs.Parse(args)
+	if filter.Limit < 0 {
+		return fmt.Errorf("-limit must not be negative")
+	}
+
+	users, err := admin.Search(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		for i := range users {
+			users[i].Password = "" // ซ่อน password
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(users)
+	}
+	printTable(os.Stdout, users)
+	return nil
+}
+
+func token(ctx context.Context, admin *application.UserAdmin, args []string) error {
+	fs := flag.NewFlagSet("token", flag.ExitOnError)
+	name := fs.String("name", "userctl", "name shown in the API key list")
+	scopes := fs.String("scopes", domain.ScopeUsersRead, "comma-separated scopes ("+strings.Join(domain.Scopes, ", ")+")")
+	days := fs.Int("days", 0, "expire after this many days (0 never expires)")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: userctl token [-name NAME] [-scopes LIST] [-days N] USER")
+	}
+
+	var scopeList []string
+	for _, s := range strings.Split(*scopes, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			scopeList = append(scopeList, s)
+		}
+	}
+	raw, details, err := admin.IssueToken(ctx, fs.Arg(0), *name, scopeList, *days)
+	if err != nil {
+		return err
+	}
+	// token จริงไปที่ stdout เพียงบรรทัดเดียวเพื่อให้ใช้ใน script ได้ รายละเอียดไปที่ stderr
+	expires := "never"
+	if details.ExpiresAt != nil {
+		expires = details.ExpiresAt.Format(time.RFC3339)
+	}
+	fmt.Fprintf(os.Stderr, "issued API key %s (%s) for user %s, scopes %s, expires %s; store it now, it will not be shown again\n",
+		details.ID.Hex(), details.Prefix, details.UserID.Hex(), strings.Join(details.Scopes, ","), expires)
+	fmt.Println(raw)
+	return nil
+}
+
+func importUsers(ctx context.Context, cfg *config.Config, db *mongo.Database, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	format := fs.String("format", "", "csv or ndjson (default: from the file extension)")
+	var opts userimport.Options
+	fs.StringVar(&opts.Mode, "mode", userimport.ModeSkip, "what to do with existing users: skip or upsert")
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "validate and report without writing anything")
+	fs.IntVar(&opts.Workers, "workers", 0, "rows hashed and written in parallel (0 means the number of CPUs)")
+	asJSON := fs.Bool("json", false, "print the full report as JSON")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: userctl import [-format FORMAT] [-mode MODE] [-dry-run] [-workers N] [-json] FILE")
+	}
+
+	path := fs.Arg(0)
+	in := io.Reader(os.Stdin)
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+	if *format == "" {
+		*format = userimport.FormatFromFilename(path)
+	}
+	rows, err := userimport.NewReader(in, *format)
+	if err != nil {
+		return err
+	}
+	userRepo, err := userRepository(cfg, db)
+	if err != nil {
+		return err
+	}
+
+	report, err := userimport.NewImporter(userRepo).Run(ctx, rows, opts)
+	if report != nil {
+		if *asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(report)
+		} else {
+			printImportReport(os.Stdout, report)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("%d of %d rows failed", report.Failed, report.Total)
+	}
+	return nil
+}
+
+// printImportReport พิมพ์สรุปและแถวที่ล้มเหลว
+func printImportReport(w io.Writer, report *userimport.Report) {
+	prefix := ""
+	if report.DryRun {
+		prefix = "dry run: "
+	}
+	fmt.Fprintf(w, "%s%d rows: %d created, %d updated, %d skipped, %d failed\n",
+		prefix, report.Total, report.Created, report.Updated, report.Skipped, report.Failed)
+	if report.Failed == 0 {
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "LINE\tEMAIL\tERROR")
+	for _, r := range report.Results {
+		if r.Action == userimport.ActionFailed {
+			fmt.Fprintf(tw, "%d\t%s\t%s\n", r.Line, r.Email, r.Error)
+		}
+	}
+	tw.Flush()
+}
+
+func migrate(ctx context.Context, db *mongo.Database, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	statusOnly := fs.Bool("status", false, "show which migrations have run without running any")
+	fs.Parse(args)
+
+	if *statusOnly {
+		statuses, err := infrastructure.MigrationStatuses(ctx, db)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%s\t%s\t%s\n", s.ID, applied, s.Description)
+		}
+		return nil
+	}
+
+	ran, err := infrastructure.Migrate(ctx, db)
+	for _, m := range ran {
+		fmt.Printf("applied %s\t%s\n", m.ID, m.Description)
+	}
+	if err == nil && len(ran) == 0 {
+		fmt.Println("nothing to migrate")
+	}
+	return err
+}
+
+// readPassword สร้างรหัสผ่านแบบสุ่มและพิมพ์ออกมา หรืออ่านบรรทัดแรกจาก stdin
+func readPassword(generate bool) (string, error) {
+	if generate {
+		password, err := utils.RandomToken(15)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(os.Stderr, "generated password (shown once): %s\n", password)
+		return password, nil
+	}
+
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		fmt.Fprint(os.Stderr, "Password: ")
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func printUser(user domain.User) {
+	printTable(os.Stdout, []domain.User{user})
+}
+
+func printTable(w io.Writer, users []domain.User) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tEMAIL\tROLE\tSTATUS\tCREATED\tDELETED")
+	for _, u := range users {
+		deleted := "-"
+		if u.DeletedAt != nil {
+			deleted = u.DeletedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			u.ID.Hex(), u.Name, u.Email, u.Role, u.Status, u.CreatedAt.Format(time.RFC3339), deleted)
+	}
+	tw.Flush()
+}
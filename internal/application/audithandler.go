@@ -0,0 +1,52 @@
+package application
+
+import (
+	"net/http"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 200
+)
+
+type AuditHandler struct {
+	auditRepo domain.AuditRepository
+}
+
+func NewAuditHandler(auditRepo domain.AuditRepository) *AuditHandler {
+	return &AuditHandler{
+		auditRepo: auditRepo,
+	}
+}
+
+// List ค้นหา audit log ด้วย ?user=&actor=&from=&to=&page=&limit=
+// from และ to อยู่ในรูปแบบ RFC 3339 เช่น 2024-01-31T00:00:00Z
+func (h *AuditHandler) List(c *gin.Context) {
+	filter := domain.AuditFilter{
+		UserID:  c.Query("user"),
+		ActorID: c.Query("actor"),
+		Page:    1,
+		Limit:   defaultAuditPageSize,
+	}
+
+	if !parseTimeRange(c, &filter.From, &filter.To) || !parsePagination(c, &filter.Page, &filter.Limit, maxAuditPageSize) {
+		return
+	}
+
+	events, total, err := h.auditRepo.Find(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events": events,
+		"total":  total,
+		"page":   filter.Page,
+		"limit":  filter.Limit,
+	})
+}
@@ -0,0 +1,35 @@
+package application
+
+import (
+	"net/http"
+
+	"github.com/Gsupakin/back_end_test_challeng/pkg/health"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthHandler struct {
+	registry *health.Registry
+}
+
+func NewHealthHandler(registry *health.Registry) *HealthHandler {
+	return &HealthHandler{
+		registry: registry,
+	}
+}
+
+// Liveness ตอบ 200 เสมอตราบที่โปรเซสยังตอบ request ได้ ไม่ตรวจ dependency ภายนอก
+// เพื่อไม่ให้ orchestrator restart service เพียงเพราะฐานข้อมูลล่ม
+func (h *HealthHandler) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": health.StatusUp})
+}
+
+// Readiness รันทุก check ใน registry แล้วตอบ 200 ถ้าผ่านทั้งหมด หรือ 503 พร้อมรายละเอียดราย check
+func (h *HealthHandler) Readiness(c *gin.Context) {
+	report := h.registry.Check(c.Request.Context())
+	if !report.Ready() {
+		c.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
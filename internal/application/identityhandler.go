@@ -0,0 +1,355 @@
+package application
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/jwt"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/metrics"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/utils"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/oauth2"
+)
+
+// upstreamLoginTTL คืออายุของ cookie ที่เก็บ state ระหว่างไปล็อกอินที่ IdP
+const upstreamLoginTTL = 10 * time.Minute
+
+// UpstreamProvider คือการตั้งค่า OIDC provider ภายนอกที่ใช้ล็อกอินได้
+type UpstreamProvider struct {
+	Name         string   `json:"name"`
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	RedirectURL  string   `json:"redirect_url"`
+	Scopes       []string `json:"scopes"`
+}
+
+// ParseUpstreamProviders อ่านรายการ provider จาก JSON array
+func ParseUpstreamProviders(raw string) ([]UpstreamProvider, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	var providers []UpstreamProvider
+	if err := json.Unmarshal([]byte(raw), &providers); err != nil {
+		return nil, err
+	}
+	for _, p := range providers {
+		if p.Name == "" || p.Issuer == "" || p.ClientID == "" || p.RedirectURL == "" {
+			return nil, errors.New("upstream provider requires name, issuer, client_id and redirect_url")
+		}
+	}
+	return providers, nil
+}
+
+// upstream เก็บ provider ที่ค้นพบแล้ว โดยจะ discovery ครั้งแรกตอนใช้งาน
+// เพื่อไม่ให้ service start ไม่ได้เพราะ IdP ล่ม
+type upstream struct {
+	config   UpstreamProvider
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+func (u *upstream) discover(ctx context.Context) (*oidc.Provider, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.provider != nil {
+		return u.provider, nil
+	}
+	provider, err := oidc.NewProvider(ctx, u.config.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	u.provider = provider
+	return provider, nil
+}
+
+func (u *upstream) oauth2Config(provider *oidc.Provider) *oauth2.Config {
+	scopes := u.config.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "profile", "email"}
+	}
+	return &oauth2.Config{
+		ClientID:     u.config.ClientID,
+		ClientSecret: u.config.ClientSecret,
+		RedirectURL:  u.config.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       scopes,
+	}
+}
+
+// IdentityHandler จัดการล็อกอินผ่าน OIDC provider ภายนอกและการผูกบัญชี
+type IdentityHandler struct {
+	userRepo  domain.UserRepository
+	sessions  *SessionHandler
+	providers map[string]*upstream
+}
+
+func NewIdentityHandler(userRepo domain.UserRepository, sessions *SessionHandler, providers []UpstreamProvider) *IdentityHandler {
+	h := &IdentityHandler{
+		userRepo:  userRepo,
+		sessions:  sessions,
+		providers: make(map[string]*upstream, len(providers)),
+	}
+	for _, p := range providers {
+		h.providers[p.Name] = &upstream{config: p}
+	}
+	return h
+}
+
+// ListProviders แสดงชื่อ provider ที่เปิดให้ล็อกอิน
+func (h *IdentityHandler) ListProviders(c *gin.Context) {
+	names := make([]string, 0, len(h.providers))
+	for name := range h.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	c.JSON(http.StatusOK, gin.H{"providers": names})
+}
+
+// Login redirect ผู้ใช้ไปล็อกอินที่ provider ภายนอก
+func (h *IdentityHandler) Login(c *gin.Context) {
+	authURL, ok := h.startFlow(c, "")
+	if !ok {
+		return
+	}
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// StartLink เริ่มผูกบัญชีภายนอกกับผู้ใช้ที่ล็อกอินอยู่ คืน URL ให้ client พาผู้ใช้ไป
+func (h *IdentityHandler) StartLink(c *gin.Context) {
+	authURL, ok := h.startFlow(c, c.GetString("user_id"))
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"authorization_url": authURL})
+}
+
+// Callback รับ authorization code จาก provider ตรวจ ID token แล้วล็อกอินหรือผูกบัญชี
+func (h *IdentityHandler) Callback(c *gin.Context) {
+	up, ok := h.providers[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown identity provider"})
+		return
+	}
+
+	if errCode := c.Query("error"); errCode != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Identity provider returned an error: " + errCode})
+		return
+	}
+
+	cookieName := upstreamCookieName(up.config.Name)
+	raw, err := c.Cookie(cookieName)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Login session not found or expired"})
+		return
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(cookieName, "", -1, upstreamCookiePath(up.config.Name), "", isSecureRequest(c), true)
+
+	flow, err := jwt.ValidatePurposeToken(raw, jwt.PurposeOIDCLogin)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Login session not found or expired"})
+		return
+	}
+	seed := flow.ID
+	if subtle.ConstantTimeCompare([]byte(c.Query("state")), []byte(utils.HashToken("state:"+seed))) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid state"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	provider, err := up.discover(ctx)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider is unavailable"})
+		return
+	}
+
+	token, err := up.oauth2Config(provider).Exchange(ctx, c.Query("code"), oauth2.VerifierOption(seed))
+	if err != nil {
+		metrics.RecordLogin(metrics.LoginIdentity, metrics.LoginFailure)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to exchange authorization code"})
+		return
+	}
+
+	rawIDToken, _ := token.Extra("id_token").(string)
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: up.config.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		metrics.RecordLogin(metrics.LoginIdentity, metrics.LoginFailure)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid ID token"})
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(utils.HashToken("nonce:"+seed))) != 1 {
+		metrics.RecordLogin(metrics.LoginIdentity, metrics.LoginFailure)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid ID token"})
+		return
+	}
+
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		metrics.RecordLogin(metrics.LoginIdentity, metrics.LoginFailure)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid ID token"})
+		return
+	}
+
+	identity := domain.LinkedIdentity{
+		Provider: up.config.Name,
+		Issuer:   idToken.Issuer,
+		Subject:  idToken.Subject,
+		Email:    claims.Email,
+		LinkedAt: time.Now(),
+	}
+
+	// โหมดผูกบัญชี: ผู้ใช้ล็อกอินอยู่แล้วและเริ่ม flow จาก /me/identities
+	if flow.UserID != "" {
+		userID, err := primitive.ObjectIDFromHex(flow.UserID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid login session"})
+			return
+		}
+		h.link(c, userID, identity)
+		return
+	}
+
+	if user, err := h.userRepo.FindByLinkedIdentity(ctx, identity.Issuer, identity.Subject); err == nil {
+		h.sessions.completeLogin(c, user, metrics.LoginIdentity)
+		return
+	}
+
+	// ผูกบัญชีอัตโนมัติเฉพาะเมื่อ IdP ยืนยันแล้วว่าเป็นเจ้าของอีเมลนี้จริง
+	if claims.EmailVerified && claims.Email != "" {
+		user, err := h.userRepo.FindByEmail(ctx, claims.Email)
+		if err == nil {
+			if err := h.userRepo.AddLinkedIdentity(ctx, user.ID, identity); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link identity"})
+				return
+			}
+			user.LinkedIdentities = append(user.LinkedIdentities, identity)
+			h.sessions.completeLogin(c, user, metrics.LoginIdentity)
+			return
+		}
+	}
+
+	metrics.RecordLogin(metrics.LoginIdentity, metrics.LoginFailure)
+	c.JSON(http.StatusForbidden, gin.H{"error": "No account is linked to this identity"})
+}
+
+// ListIdentities แสดงบัญชีภายนอกที่ผูกกับผู้ใช้ปัจจุบัน
+func (h *IdentityHandler) ListIdentities(c *gin.Context) {
+	user, ok := currentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	identities := user.LinkedIdentities
+	if identities == nil {
+		identities = []domain.LinkedIdentity{}
+	}
+	c.JSON(http.StatusOK, identities)
+}
+
+// Unlink ยกเลิกการผูกบัญชีภายนอก
+func (h *IdentityHandler) Unlink(c *gin.Context) {
+	user, ok := currentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	for _, identity := range user.LinkedIdentities {
+		if identity.Provider != c.Param("provider") || identity.Subject != c.Param("subject") {
+			continue
+		}
+		if err := h.userRepo.RemoveLinkedIdentity(c.Request.Context(), user.ID, identity.Issuer, identity.Subject); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Unlink failed"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Identity unlinked successfully"})
+		return
+	}
+
+	c.JSON(http.StatusNotFound, gin.H{"error": "Linked identity not found"})
+}
+
+// startFlow สร้าง state, nonce และ PKCE verifier จาก seed เดียวที่เก็บใน cookie แบบ HttpOnly
+func (h *IdentityHandler) startFlow(c *gin.Context, linkUserID string) (string, bool) {
+	up, ok := h.providers[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown identity provider"})
+		return "", false
+	}
+
+	provider, err := up.discover(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider is unavailable"})
+		return "", false
+	}
+
+	seed, err := utils.RandomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return "", false
+	}
+
+	cookie, err := jwt.GeneratePurposeToken(linkUserID, jwt.PurposeOIDCLogin, seed, upstreamLoginTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return "", false
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(upstreamCookieName(up.config.Name), cookie, int(upstreamLoginTTL.Seconds()), upstreamCookiePath(up.config.Name), "", isSecureRequest(c), true)
+
+	authURL := up.oauth2Config(provider).AuthCodeURL(
+		utils.HashToken("state:"+seed),
+		oidc.Nonce(utils.HashToken("nonce:"+seed)),
+		oauth2.S256ChallengeOption(seed),
+	)
+	return authURL, true
+}
+
+func (h *IdentityHandler) link(c *gin.Context, userID primitive.ObjectID, identity domain.LinkedIdentity) {
+	if _, err := h.userRepo.FindByID(c.Request.Context(), userID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	err := h.userRepo.AddLinkedIdentity(c.Request.Context(), userID, identity)
+	if errors.Is(err, domain.ErrIdentityLinked) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Identity is already linked to an account"})
+		return
+	}
+	if errors.Is(err, domain.ErrUserNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link identity"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Identity linked successfully", "identity": identity})
+}
+
+func upstreamCookieName(provider string) string {
+	return "oidc_" + provider
+}
+
+func upstreamCookiePath(provider string) string {
+	return "/auth/" + provider
+}
+
+// isSecureRequest ตรวจสอบว่า request มาทาง HTTPS (รวมถึงผ่าน reverse proxy)
+func isSecureRequest(c *gin.Context) bool {
+	return c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+}
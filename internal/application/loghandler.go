@@ -0,0 +1,131 @@
+package application
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultLogPageSize = 50
+	maxLogPageSize     = 500
+
+	defaultStatsWindow = 24 * time.Hour
+	// จำกัดจำนวน bucket ต่อ route ไม่ให้ aggregation ใหญ่เกินไป เช่น bucket รายนาทีได้ไม่เกิน 1 วัน
+	maxStatsBuckets = 1440
+)
+
+var bucketSizes = map[string]time.Duration{
+	domain.LogBucketMinute: time.Minute,
+	domain.LogBucketHour:   time.Hour,
+	domain.LogBucketDay:    24 * time.Hour,
+}
+
+type LogHandler struct {
+	logRepo domain.LogRepository
+}
+
+func NewLogHandler(logRepo domain.LogRepository) *LogHandler {
+	return &LogHandler{
+		logRepo: logRepo,
+	}
+}
+
+// List ค้นหา request log ด้วย ?path=&status_min=&status_max=&ip=&user=&request_id=&from=&to=&page=&limit=
+// path เป็นการค้นหาแบบ prefix
+func (h *LogHandler) List(c *gin.Context) {
+	filter := domain.LogFilter{
+		Path:      c.Query("path"),
+		IP:        c.Query("ip"),
+		UserID:    c.Query("user"),
+		RequestID: c.Query("request_id"),
+		Page:      1,
+		Limit:     defaultLogPageSize,
+	}
+
+	for param, target := range map[string]*int{"status_min": &filter.StatusMin, "status_max": &filter.StatusMax} {
+		raw := c.Query(param)
+		if raw == "" {
+			continue
+		}
+		status, err := strconv.Atoi(raw)
+		if err != nil || status < 100 || status > 599 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": param + " must be an HTTP status code"})
+			return
+		}
+		*target = status
+	}
+	if filter.StatusMin > 0 && filter.StatusMax > 0 && filter.StatusMin > filter.StatusMax {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status_min must not be greater than status_max"})
+		return
+	}
+
+	if !parseTimeRange(c, &filter.From, &filter.To) || !parsePagination(c, &filter.Page, &filter.Limit, maxLogPageSize) {
+		return
+	}
+
+	logs, total, err := h.logRepo.Find(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"logs":  logs,
+		"total": total,
+		"page":  filter.Page,
+		"limit": filter.Limit,
+	})
+}
+
+// Stats สรุปจำนวน request, อัตรา error และ latency p50/p95/p99 ต่อ route ด้วย ?from=&to=&bucket=
+// ค่าเริ่มต้นคือ 24 ชั่วโมงล่าสุด แบ่ง bucket รายชั่วโมง
+func (h *LogHandler) Stats(c *gin.Context) {
+	var from, to *time.Time
+	if !parseTimeRange(c, &from, &to) {
+		return
+	}
+
+	filter := domain.LogStatsFilter{
+		To:     time.Now(),
+		Bucket: c.DefaultQuery("bucket", domain.LogBucketHour),
+	}
+	if to != nil {
+		filter.To = *to
+	}
+	filter.From = filter.To.Add(-defaultStatsWindow)
+	if from != nil {
+		filter.From = *from
+	}
+
+	size, ok := bucketSizes[filter.Bucket]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bucket must be one of minute, hour, day"})
+		return
+	}
+	if !filter.From.Before(filter.To) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+	if filter.To.Sub(filter.From) > size*maxStatsBuckets {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Time window is too large for this bucket size"})
+		return
+	}
+
+	stats, err := h.logRepo.Stats(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":   filter.From,
+		"to":     filter.To,
+		"bucket": filter.Bucket,
+		"routes": stats,
+	})
+}
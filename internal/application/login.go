@@ -0,0 +1,103 @@
+package application
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/jwt"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/metrics"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// completeLogin ตอบกลับหลังยืนยันตัวตนขั้นแรกสำเร็จ (รหัสผ่าน, magic link หรือ IdP ภายนอก)
+// ผู้ใช้ที่เปิด MFA จะได้ challenge token ไปแลกกับรหัสที่ /login/mfa แทน
+// method คือช่องทางที่ใช้ล็อกอิน ใช้เป็น label ของ metrics.Logins
+func (h *SessionHandler) completeLogin(c *gin.Context, user domain.User, method string) {
+	if !loginAllowed(c, user, method) {
+		return
+	}
+	if user.RequiresMFA() {
+		mfaToken, err := mfaChallenge(c.Request.Context(), h.userRepo, user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+		metrics.RecordLogin(method, metrics.LoginMFARequired)
+		c.JSON(http.StatusOK, gin.H{"mfa_required": true, "mfa_token": mfaToken})
+		return
+	}
+
+	h.issueToken(c, user, method)
+}
+
+// issueToken สร้าง session ใหม่และออก JWT ที่ผูกกับ session ให้ผู้ใช้ที่ยืนยันตัวตนครบทุกขั้นตอนแล้ว
+// ถ้าเปิด cookie mode จะตั้ง cookie และส่ง CSRF token กลับไปให้ client ใช้ใน header
+func (h *SessionHandler) issueToken(c *gin.Context, user domain.User, method string) {
+	token, sessionID, err := h.startSession(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	response := gin.H{}
+	if h.cookies.Enabled() {
+		csrf, err := h.cookies.SetSession(c.Writer, token, sessionID, jwt.SessionTTL)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+		response["csrf_token"] = csrf
+	}
+	if h.cookies.TokenInBody() {
+		response["token"] = token
+	}
+	metrics.RecordLogin(method, metrics.LoginSuccess)
+	c.JSON(http.StatusOK, response)
+}
+
+// loginAllowed ตอบ 403 และคืน false ถ้าสถานะของผู้ใช้ไม่อนุญาตให้ล็อกอิน
+// ตรวจหลังยืนยันตัวตนแล้วเท่านั้น ผู้ที่ไม่รู้รหัสผ่านจึงไม่รู้สถานะของบัญชี
+func loginAllowed(c *gin.Context, user domain.User, method string) bool {
+	if user.IsActive() {
+		return true
+	}
+	metrics.RecordLogin(method, metrics.LoginFailure)
+	c.JSON(http.StatusForbidden, gin.H{"error": statusMessage(user), "status": user.EffectiveStatus(time.Now())})
+	return false
+}
+
+// statusMessage อธิบายว่าทำไมผู้ใช้ที่ไม่ active ใช้งานไม่ได้
+func statusMessage(user domain.User) string {
+	switch user.EffectiveStatus(time.Now()) {
+	case domain.StatusPending:
+		return "Account is pending activation"
+	case domain.StatusSuspended:
+		if user.StatusUntil != nil {
+			return "Account is suspended until " + user.StatusUntil.UTC().Format(time.RFC3339)
+		}
+		return "Account is suspended"
+	case domain.StatusBanned:
+		return "Account is banned"
+	default:
+		return "Account is not active"
+	}
+}
+
+// currentUser โหลดผู้ใช้ที่ล็อกอินอยู่จาก user_id ที่ JWTAuth ตั้งไว้
+func currentUser(c *gin.Context, userRepo domain.UserRepository) (domain.User, bool) {
+	objID, err := primitive.ObjectIDFromHex(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return domain.User{}, false
+	}
+
+	user, err := userRepo.FindByID(c.Request.Context(), objID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return domain.User{}, false
+	}
+	return user, true
+}
@@ -0,0 +1,193 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/jwt"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/mailer"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/metrics"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/utils"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/validator"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// magicLinkTTL คืออายุของลิงก์เข้าสู่ระบบ
+const magicLinkTTL = 15 * time.Minute
+
+type MagicLinkHandler struct {
+	userRepo domain.UserRepository
+	linkRepo domain.MagicLinkRepository
+	sessions *SessionHandler
+	mailer   mailer.Mailer
+	baseURL  string
+	logger   *slog.Logger
+}
+
+// NewMagicLinkHandler สร้าง handler สำหรับล็อกอินผ่านลิงก์ทางอีเมล
+// baseURL คือหน้าที่รับ token จากลิงก์แล้วส่งต่อมาที่ /login/magic-link/verify
+func NewMagicLinkHandler(userRepo domain.UserRepository, linkRepo domain.MagicLinkRepository, sessions *SessionHandler, m mailer.Mailer, baseURL string) *MagicLinkHandler {
+	return &MagicLinkHandler{
+		userRepo: userRepo,
+		linkRepo: linkRepo,
+		sessions: sessions,
+		mailer:   m,
+		baseURL:  baseURL,
+		logger:   slog.Default(),
+	}
+}
+
+// WithLogger เปลี่ยน logger ที่ใช้รายงานอีเมลที่ส่งไม่สำเร็จ
+func (h *MagicLinkHandler) WithLogger(l *slog.Logger) *MagicLinkHandler {
+	h.logger = l
+	return h
+}
+
+// Request ส่งลิงก์เข้าสู่ระบบไปยังอีเมล
+// ตอบกลับเหมือนกันเสมอไม่ว่าจะมีอีเมลนี้ในระบบหรือไม่ เพื่อไม่ให้ใช้ตรวจสอบบัญชีได้
+func (h *MagicLinkHandler) Request(c *gin.Context) {
+	if c.GetHeader("Content-Type") != "application/json" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Content-Type must be application/json"})
+		return
+	}
+
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := validator.ValidateEmail(req.Email); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	accepted := gin.H{"message": "If the email is registered, a login link has been sent"}
+
+	user, err := h.userRepo.FindByEmail(c.Request.Context(), req.Email)
+	if err != nil {
+		c.JSON(http.StatusAccepted, accepted)
+		return
+	}
+
+	now := time.Now()
+	linkID, err := h.linkRepo.Create(c.Request.Context(), domain.MagicLink{
+		UserID:      user.ID,
+		Fingerprint: utils.DeviceFingerprint(c.Request.UserAgent(), c.ClientIP()),
+		IP:          c.ClientIP(),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(magicLinkTTL),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create login link"})
+		return
+	}
+
+	token, err := jwt.GeneratePurposeToken(user.ID.Hex(), jwt.PurposeMagicLink, linkID.Hex(), magicLinkTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create login link"})
+		return
+	}
+
+	link := h.baseURL + "?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("Hello %s,\n\nUse the link below to sign in. It expires in %d minutes and can only be used once, from the same device and network that requested it.\n\n%s\n\nIf you did not request this, you can ignore this email.\n",
+		user.Name, int(magicLinkTTL.Minutes()), link)
+
+	// ส่งอีเมลแยกจาก request เพื่อไม่ให้เวลาตอบกลับบอกได้ว่ามีบัญชีนี้อยู่หรือไม่
+	// context ไม่ถูกยกเลิกตาม request แต่ยังมี request ID สำหรับ log
+	sendCtx := context.WithoutCancel(c.Request.Context())
+	go func(to string) {
+		ctx, cancel := context.WithTimeout(sendCtx, 30*time.Second)
+		defer cancel()
+		if err := h.mailer.Send(ctx, to, "Your login link", body); err != nil {
+			h.logger.ErrorContext(ctx, "failed to send magic link email", "error", err)
+		}
+	}(user.Email)
+
+	c.JSON(http.StatusAccepted, accepted)
+}
+
+// Verify แลก token จากลิงก์เป็น JWT ปกติ ลิงก์ต้องถูกใช้จากอุปกรณ์เดียวกับที่ขอ
+func (h *MagicLinkHandler) Verify(c *gin.Context) {
+	if c.GetHeader("Content-Type") != "application/json" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Content-Type must be application/json"})
+		return
+	}
+
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	claims, err := jwt.ValidatePurposeToken(req.Token, jwt.PurposeMagicLink)
+	if err != nil {
+		metrics.RecordLogin(metrics.LoginMagicLink, metrics.LoginFailure)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired login link"})
+		return
+	}
+
+	linkID, err := primitive.ObjectIDFromHex(claims.ID)
+	if err != nil {
+		metrics.RecordLogin(metrics.LoginMagicLink, metrics.LoginFailure)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired login link"})
+		return
+	}
+
+	fingerprint := utils.DeviceFingerprint(c.Request.UserAgent(), c.ClientIP())
+	link, err := h.linkRepo.Consume(c.Request.Context(), linkID, fingerprint)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidToken) {
+			metrics.RecordLogin(metrics.LoginMagicLink, metrics.LoginFailure)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired login link"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if link.UserID.Hex() != claims.UserID {
+		metrics.RecordLogin(metrics.LoginMagicLink, metrics.LoginFailure)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired login link"})
+		return
+	}
+
+	user, err := h.userRepo.FindByID(c.Request.Context(), link.UserID)
+	if err != nil {
+		metrics.RecordLogin(metrics.LoginMagicLink, metrics.LoginFailure)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired login link"})
+		return
+	}
+
+	// ลิงก์ทางอีเมลแทนรหัสผ่านเท่านั้น ผู้ใช้ที่เปิด MFA ยังต้องยืนยันรหัสต่อ
+	h.sessions.completeLogin(c, user, metrics.LoginMagicLink)
+}
+
+// RevokeAll ยกเลิกลิงก์เข้าสู่ระบบทั้งหมดของผู้ใช้ที่ยังไม่ได้ใช้
+func (h *MagicLinkHandler) RevokeAll(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	revoked, err := h.linkRepo.RevokeAllForUser(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Revoke failed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"revoked": revoked})
+}
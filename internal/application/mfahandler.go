@@ -0,0 +1,345 @@
+package application
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/jwt"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/metrics"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/totp"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/skip2/go-qrcode"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// mfaChallengeTTL คืออายุของ token ระหว่างรอยืนยันรหัส TOTP ตอนล็อกอิน
+const mfaChallengeTTL = 5 * time.Minute
+
+// recoveryCodeCount คือจำนวน recovery codes ที่ออกให้ในแต่ละครั้ง
+const recoveryCodeCount = 10
+
+// maxMFAFailures คือจำนวนครั้งที่กรอกรหัสผิดได้ต่อหนึ่ง challenge ก่อน challenge ถูกยกเลิก
+const maxMFAFailures = 5
+
+type MFAHandler struct {
+	userRepo domain.UserRepository
+	sessions *SessionHandler
+	issuer   string
+}
+
+func NewMFAHandler(userRepo domain.UserRepository, sessions *SessionHandler, issuer string) *MFAHandler {
+	return &MFAHandler{
+		userRepo: userRepo,
+		sessions: sessions,
+		issuer:   issuer,
+	}
+}
+
+// Enroll สร้าง secret ใหม่ที่ยังไม่เปิดใช้งาน จนกว่าผู้ใช้จะยืนยันด้วยรหัสแรก
+func (h *MFAHandler) Enroll(c *gin.Context) {
+	user, ok := currentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	if user.RequiresMFA() {
+		c.JSON(http.StatusConflict, gin.H{"error": "MFA is already enabled"})
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+
+	uri := totp.KeyURI(h.issuer, user.Email, secret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate QR code"})
+		return
+	}
+
+	err = h.userRepo.Update(c.Request.Context(), user.ID, map[string]interface{}{
+		"mfa.pending_secret": secret,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Update failed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": uri,
+		"qr_png":      base64.StdEncoding.EncodeToString(png),
+	})
+}
+
+// Confirm เปิดใช้งาน TOTP เมื่อรหัสจาก secret ที่รอยืนยันถูกต้อง และออก recovery codes
+func (h *MFAHandler) Confirm(c *gin.Context) {
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := currentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	if user.RequiresMFA() {
+		c.JSON(http.StatusConflict, gin.H{"error": "MFA is already enabled"})
+		return
+	}
+	if user.MFA.PendingSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "MFA enrollment has not been started"})
+		return
+	}
+
+	step, valid := totp.Validate(user.MFA.PendingSecret, req.Code, time.Now(), 0)
+	if !valid {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid MFA code"})
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	now := time.Now()
+	err = h.userRepo.Update(c.Request.Context(), user.ID, map[string]interface{}{
+		"mfa": domain.MFASettings{
+			Enabled:       true,
+			Secret:        user.MFA.PendingSecret,
+			RecoveryCodes: hashes,
+			LastUsedStep:  step,
+			EnrolledAt:    &now,
+		},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Update failed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// Disable ปิดการใช้งาน TOTP โดยต้องยืนยันทั้งรหัสผ่านและรหัส MFA
+func (h *MFAHandler) Disable(c *gin.Context) {
+	var req struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := currentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	if !user.RequiresMFA() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "MFA is not enabled"})
+		return
+	}
+
+	if !utils.CheckPasswordHashContext(c.Request.Context(), req.Password, user.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+		return
+	}
+
+	if err := verifyMFACode(c.Request.Context(), h.userRepo, &user, req.Code); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid MFA code"})
+		return
+	}
+
+	err := h.userRepo.Update(c.Request.Context(), user.ID, map[string]interface{}{
+		"mfa": domain.MFASettings{},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Update failed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "MFA disabled successfully"})
+}
+
+// RegenerateRecoveryCodes ออก recovery codes ชุดใหม่แทนชุดเดิมทั้งหมด
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := currentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	if !user.RequiresMFA() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "MFA is not enabled"})
+		return
+	}
+
+	if err := verifyMFACode(c.Request.Context(), h.userRepo, &user, req.Code); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid MFA code"})
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	err = h.userRepo.Update(c.Request.Context(), user.ID, map[string]interface{}{
+		"mfa.recovery_codes": hashes,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Update failed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// VerifyLogin แลก MFA challenge token กับรหัส TOTP หรือ recovery code เป็น JWT จริง
+func (h *MFAHandler) VerifyLogin(c *gin.Context) {
+	if c.GetHeader("Content-Type") != "application/json" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Content-Type must be application/json"})
+		return
+	}
+
+	var req struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.MFAToken == "" || req.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mfa_token and code are required"})
+		return
+	}
+
+	user, err := completeMFAChallenge(c.Request.Context(), h.userRepo, req.MFAToken, req.Code)
+	switch {
+	case errors.Is(err, domain.ErrInvalidToken):
+		metrics.RecordLogin(metrics.LoginMFA, metrics.LoginFailure)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return
+	case errors.Is(err, domain.ErrInvalidMFACode):
+		metrics.RecordLogin(metrics.LoginMFA, metrics.LoginFailure)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid MFA code"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify MFA code"})
+		return
+	}
+
+	// สถานะอาจถูกเปลี่ยนระหว่างขั้นแรกกับขั้นที่สองของการล็อกอิน
+	if !loginAllowed(c, user, metrics.LoginMFA) {
+		return
+	}
+	h.sessions.issueToken(c, user, metrics.LoginMFA)
+}
+
+// mfaChallenge สร้าง token ชั่วคราวสำหรับขั้นตอนที่สองของการล็อกอิน และบันทึก challenge ไว้กับผู้ใช้
+// challenge ใหม่ทำให้ token ของ challenge เดิมใช้ไม่ได้
+func mfaChallenge(ctx context.Context, repo domain.UserRepository, user domain.User) (string, error) {
+	challengeID := primitive.NewObjectID().Hex()
+	if err := repo.StartMFAChallenge(ctx, user.ID, challengeID); err != nil {
+		return "", err
+	}
+	return jwt.GeneratePurposeToken(user.ID.Hex(), jwt.PurposeMFA, challengeID, mfaChallengeTTL)
+}
+
+// completeMFAChallenge ตรวจ mfa_token และรหัสของขั้นตอนที่สองของการล็อกอิน ใช้ร่วมกันระหว่าง /login/mfa และหน้า consent ของ OIDC
+// challenge ใช้ได้ครั้งเดียว และถูกยกเลิกเมื่อมี challenge ใหม่หรือกรอกรหัสผิดครบ maxMFAFailures ครั้ง
+// คืน ErrInvalidToken ถ้า token ใช้ไม่ได้แล้ว หรือ ErrInvalidMFACode ถ้ารหัสผิด
+func completeMFAChallenge(ctx context.Context, repo domain.UserRepository, token, code string) (domain.User, error) {
+	claims, err := jwt.ValidatePurposeToken(token, jwt.PurposeMFA)
+	if err != nil {
+		return domain.User{}, domain.ErrInvalidToken
+	}
+	objID, err := primitive.ObjectIDFromHex(claims.UserID)
+	if err != nil {
+		return domain.User{}, domain.ErrInvalidToken
+	}
+	user, err := repo.FindByID(ctx, objID)
+	if err != nil || !user.RequiresMFA() || claims.ID == "" || claims.ID != user.MFA.ChallengeID {
+		return domain.User{}, domain.ErrInvalidToken
+	}
+
+	if err := verifyMFACode(ctx, repo, &user, code); err != nil {
+		if err := repo.FailMFAChallenge(ctx, user.ID, claims.ID, maxMFAFailures); err != nil && !errors.Is(err, domain.ErrInvalidToken) {
+			return domain.User{}, err
+		}
+		return domain.User{}, domain.ErrInvalidMFACode
+	}
+	if err := repo.EndMFAChallenge(ctx, user.ID, claims.ID); err != nil {
+		return domain.User{}, domain.ErrInvalidToken
+	}
+	return user, nil
+}
+
+// verifyMFACode ตรวจสอบรหัส TOTP (ป้องกันการใช้รหัสเดิมซ้ำ) หรือ recovery code
+// ซึ่งจะถูกลบทิ้งทันทีหลังใช้งาน การบันทึกทำแบบมีเงื่อนไขในฐานข้อมูล
+// request ที่มาพร้อมกันจึงใช้รหัสเดียวกันได้เพียงครั้งเดียว
+func verifyMFACode(ctx context.Context, repo domain.UserRepository, user *domain.User, code string) error {
+	if !user.RequiresMFA() {
+		return domain.ErrMFANotEnrolled
+	}
+
+	if step, ok := totp.Validate(user.MFA.Secret, code, time.Now(), user.MFA.LastUsedStep); ok {
+		if err := repo.UseTOTPStep(ctx, user.ID, step); err != nil {
+			return err
+		}
+		user.MFA.LastUsedStep = step
+		return nil
+	}
+
+	hash := utils.HashToken(utils.NormalizeRecoveryCode(code))
+	for i, stored := range user.MFA.RecoveryCodes {
+		if stored != hash {
+			continue
+		}
+		if err := repo.UseRecoveryCode(ctx, user.ID, hash); err != nil {
+			return err
+		}
+		user.MFA.RecoveryCodes = append(append([]string{}, user.MFA.RecoveryCodes[:i]...), user.MFA.RecoveryCodes[i+1:]...)
+		return nil
+	}
+
+	return domain.ErrInvalidMFACode
+}
+
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = utils.HashToken(utils.NormalizeRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
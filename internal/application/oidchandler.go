@@ -0,0 +1,557 @@
+package application
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"embed"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/auth"
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/jwt"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	authorizationCodeTTL = 2 * time.Minute
+	oidcAccessTokenTTL   = time.Hour
+	idTokenTTL           = time.Hour
+)
+
+// oidcScopes คือ scope ที่ provider นี้รองรับ
+var oidcScopes = []string{"openid", "profile", "email"}
+
+//go:embed templates/consent.html
+var templatesFS embed.FS
+
+var consentTemplate = template.Must(template.ParseFS(templatesFS, "templates/consent.html"))
+
+// authorizeParams คือพารามิเตอร์ของ authorization request
+type authorizeParams struct {
+	ClientID            string `form:"client_id"`
+	RedirectURI         string `form:"redirect_uri"`
+	ResponseType        string `form:"response_type"`
+	Scope               string `form:"scope"`
+	State               string `form:"state"`
+	Nonce               string `form:"nonce"`
+	CodeChallenge       string `form:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method"`
+}
+
+// OIDCHandler ทำหน้าที่เป็น OpenID Connect provider แบบ authorization code + PKCE
+type OIDCHandler struct {
+	userRepo   domain.UserRepository
+	clientRepo domain.OAuthClientRepository
+	codeRepo   domain.AuthorizationCodeRepository
+	authn      *auth.Authenticator
+	keys       *jwt.KeySet
+	issuer     string
+}
+
+// NewOIDCHandler สร้าง OIDCHandler authn ใช้ตรวจ JWT ที่ส่งมาแทนรหัสผ่านตอนอนุญาต client
+// ด้วยกฎเดียวกับ API (session ยังใช้งานได้ ไม่ใช่การสวมสิทธิ์ และผู้ใช้ยัง active)
+func NewOIDCHandler(userRepo domain.UserRepository, clientRepo domain.OAuthClientRepository, codeRepo domain.AuthorizationCodeRepository, authn *auth.Authenticator, keys *jwt.KeySet, issuer string) *OIDCHandler {
+	return &OIDCHandler{
+		userRepo:   userRepo,
+		clientRepo: clientRepo,
+		codeRepo:   codeRepo,
+		authn:      authn,
+		keys:       keys,
+		issuer:     strings.TrimSuffix(issuer, "/"),
+	}
+}
+
+// Discovery ตอบ /.well-known/openid-configuration
+func (h *OIDCHandler) Discovery(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"issuer":                                h.issuer,
+		"authorization_endpoint":                h.issuer + "/authorize",
+		"token_endpoint":                        h.issuer + "/token",
+		"userinfo_endpoint":                     h.issuer + "/userinfo",
+		"jwks_uri":                              h.issuer + "/.well-known/jwks.json",
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{"authorization_code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"scopes_supported":                      oidcScopes,
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"claims_supported":                      []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "name", "email"},
+	})
+}
+
+// JWKS ตอบ public keys สำหรับตรวจสอบ ID token
+func (h *OIDCHandler) JWKS(c *gin.Context) {
+	c.JSON(http.StatusOK, h.keys.JWKS())
+}
+
+// Authorize แสดงหน้า consent พร้อมช่องล็อกอิน
+func (h *OIDCHandler) Authorize(c *gin.Context) {
+	var params authorizeParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	client, ok := h.validateAuthorizeRequest(c, params)
+	if !ok {
+		return
+	}
+
+	h.renderConsent(c, http.StatusOK, client, params, "", "")
+}
+
+// Approve รับผลจากหน้า consent ตรวจสอบตัวตน แล้ว redirect กลับพร้อม authorization code
+func (h *OIDCHandler) Approve(c *gin.Context) {
+	var params authorizeParams
+	if err := c.ShouldBind(&params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	client, ok := h.validateAuthorizeRequest(c, params)
+	if !ok {
+		return
+	}
+
+	if c.PostForm("decision") != "approve" {
+		redirectWithError(c, params, "access_denied", "The user denied the request")
+		return
+	}
+
+	user, mfaToken, err := h.authenticate(c)
+	if err != nil {
+		h.renderConsent(c, http.StatusUnauthorized, client, params, mfaToken, err.Error())
+		return
+	}
+	// รหัสผ่านถูกต้องแต่ต้องยืนยัน MFA ก่อน แสดงฟอร์มกรอกรหัสพร้อม challenge เดียวกับ /login/mfa
+	if mfaToken != "" {
+		h.renderConsent(c, http.StatusOK, client, params, mfaToken, "")
+		return
+	}
+
+	code, err := utils.RandomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue authorization code"})
+		return
+	}
+
+	now := time.Now()
+	err = h.codeRepo.Create(c.Request.Context(), domain.AuthorizationCode{
+		CodeHash:            utils.HashToken(code),
+		ClientID:            client.ClientID,
+		UserID:              user.ID,
+		RedirectURI:         params.RedirectURI,
+		Scope:               params.Scope,
+		Nonce:               params.Nonce,
+		CodeChallenge:       params.CodeChallenge,
+		CodeChallengeMethod: params.CodeChallengeMethod,
+		AuthTime:            now,
+		CreatedAt:           now,
+		ExpiresAt:           now.Add(authorizationCodeTTL),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue authorization code"})
+		return
+	}
+
+	q := url.Values{}
+	q.Set("code", code)
+	if params.State != "" {
+		q.Set("state", params.State)
+	}
+	c.Redirect(http.StatusFound, appendQuery(params.RedirectURI, q))
+}
+
+// Token แลก authorization code เป็น access token และ ID token
+func (h *OIDCHandler) Token(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	if c.PostForm("grant_type") != "authorization_code" {
+		oauthError(c, http.StatusBadRequest, "unsupported_grant_type", "Only authorization_code is supported")
+		return
+	}
+
+	clientID, clientSecret, hasBasic := c.Request.BasicAuth()
+	if !hasBasic {
+		clientID = c.PostForm("client_id")
+		clientSecret = c.PostForm("client_secret")
+	}
+
+	client, err := h.clientRepo.FindByClientID(c.Request.Context(), clientID)
+	if err != nil {
+		oauthError(c, http.StatusUnauthorized, "invalid_client", "Unknown client")
+		return
+	}
+
+	if !client.Public {
+		hash := utils.HashToken(clientSecret)
+		if clientSecret == "" || subtle.ConstantTimeCompare([]byte(hash), []byte(client.SecretHash)) != 1 {
+			oauthError(c, http.StatusUnauthorized, "invalid_client", "Client authentication failed")
+			return
+		}
+	}
+
+	code, err := h.codeRepo.Consume(c.Request.Context(), utils.HashToken(c.PostForm("code")))
+	if err != nil {
+		oauthError(c, http.StatusBadRequest, "invalid_grant", "Invalid or expired authorization code")
+		return
+	}
+
+	if code.ClientID != client.ClientID || code.RedirectURI != c.PostForm("redirect_uri") {
+		oauthError(c, http.StatusBadRequest, "invalid_grant", "Authorization code was not issued to this client")
+		return
+	}
+
+	if !verifyPKCE(code.CodeChallenge, c.PostForm("code_verifier")) {
+		oauthError(c, http.StatusBadRequest, "invalid_grant", "PKCE verification failed")
+		return
+	}
+
+	user, err := h.userRepo.FindByID(c.Request.Context(), code.UserID)
+	if err != nil {
+		oauthError(c, http.StatusBadRequest, "invalid_grant", "User no longer exists")
+		return
+	}
+	if !user.IsActive() {
+		oauthError(c, http.StatusBadRequest, "invalid_grant", statusMessage(user))
+		return
+	}
+
+	accessToken, err := jwt.GenerateAccessToken(user.ID.Hex(), client.ClientID, code.Scope, oidcAccessTokenTTL)
+	if err != nil {
+		oauthError(c, http.StatusInternalServerError, "server_error", "Failed to issue access token")
+		return
+	}
+
+	idToken, err := h.keys.SignIDToken(h.issuer, user.ID.Hex(), client.ClientID, code.Nonce, code.AuthTime, idTokenTTL, userClaims(user, code.Scope))
+	if err != nil {
+		oauthError(c, http.StatusInternalServerError, "server_error", "Failed to issue ID token")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(oidcAccessTokenTTL.Seconds()),
+		"id_token":     idToken,
+		"scope":        code.Scope,
+	})
+}
+
+// UserInfo คืน claims ของผู้ใช้ตาม scope ของ access token
+func (h *OIDCHandler) UserInfo(c *gin.Context) {
+	authHeader := c.GetHeader("Authorization")
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token"})
+		return
+	}
+
+	claims, err := jwt.ValidatePurposeToken(strings.TrimPrefix(authHeader, "Bearer "), jwt.PurposeOIDCAccess)
+	if err != nil {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token"})
+		return
+	}
+
+	objID, err := primitive.ObjectIDFromHex(claims.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token"})
+		return
+	}
+
+	user, err := h.userRepo.FindByID(c.Request.Context(), objID)
+	if err != nil || !user.IsActive() {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token"})
+		return
+	}
+
+	info := userClaims(user, claims.Scope)
+	info["sub"] = user.ID.Hex()
+	c.JSON(http.StatusOK, info)
+}
+
+// CreateClient ลงทะเบียน client ใหม่ client_secret จะแสดงเพียงครั้งเดียว
+func (h *OIDCHandler) CreateClient(c *gin.Context) {
+	var req struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+		Scopes       []string `json:"scopes"`
+		Public       bool     `json:"public"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if strings.TrimSpace(req.Name) == "" || len(req.RedirectURIs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name and redirect_uris are required"})
+		return
+	}
+	for _, uri := range req.RedirectURIs {
+		parsed, err := url.Parse(uri)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" || parsed.Fragment != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid redirect URI: " + uri})
+			return
+		}
+	}
+
+	if len(req.Scopes) == 0 {
+		req.Scopes = oidcScopes
+	}
+	client := domain.OAuthClient{
+		Name:         req.Name,
+		RedirectURIs: req.RedirectURIs,
+		Scopes:       req.Scopes,
+		Public:       req.Public,
+		CreatedAt:    time.Now(),
+	}
+	if !client.AllowsScope("openid") {
+		client.Scopes = append(client.Scopes, "openid")
+	}
+	for _, scope := range client.Scopes {
+		if !contains(oidcScopes, scope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported scope: " + scope})
+			return
+		}
+	}
+
+	if adminID, err := primitive.ObjectIDFromHex(c.GetString("user_id")); err == nil {
+		client.CreatedBy = adminID
+	}
+
+	clientID, err := utils.RandomToken(16)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create client"})
+		return
+	}
+	client.ClientID = clientID
+
+	response := gin.H{"client_id": clientID}
+	if !client.Public {
+		secret, err := utils.RandomToken(32)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create client"})
+			return
+		}
+		client.SecretHash = utils.HashToken(secret)
+		response["client_secret"] = secret
+	}
+
+	if _, err := h.clientRepo.Create(c.Request.Context(), client); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// ListClients แสดง client ที่ลงทะเบียนไว้ทั้งหมด
+func (h *OIDCHandler) ListClients(c *gin.Context) {
+	clients, err := h.clientRepo.FindAll(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, clients)
+}
+
+// DeleteClient ลบ client ออกจากระบบ
+func (h *OIDCHandler) DeleteClient(c *gin.Context) {
+	err := h.clientRepo.Delete(c.Request.Context(), c.Param("client_id"))
+	if errors.Is(err, domain.ErrClientNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Delete failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Client deleted successfully"})
+}
+
+// validateAuthorizeRequest ตรวจสอบ client และ redirect URI ก่อน ถ้าผิดจะไม่ redirect
+// ข้อผิดพลาดอื่นๆ จะถูกส่งกลับไปที่ redirect URI ตามมาตรฐาน OAuth 2.0
+func (h *OIDCHandler) validateAuthorizeRequest(c *gin.Context, params authorizeParams) (domain.OAuthClient, bool) {
+	client, err := h.clientRepo.FindByClientID(c.Request.Context(), params.ClientID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown client_id"})
+		return client, false
+	}
+
+	if !client.HasRedirectURI(params.RedirectURI) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "redirect_uri is not registered for this client"})
+		return client, false
+	}
+
+	switch {
+	case params.ResponseType != "code":
+		redirectWithError(c, params, "unsupported_response_type", "Only response_type=code is supported")
+	case !contains(strings.Fields(params.Scope), "openid"):
+		redirectWithError(c, params, "invalid_scope", "The openid scope is required")
+	case !client.AllowsScope(params.Scope):
+		redirectWithError(c, params, "invalid_scope", "The client is not allowed to request this scope")
+	case params.CodeChallenge == "" || params.CodeChallengeMethod != "S256":
+		redirectWithError(c, params, "invalid_request", "PKCE with code_challenge_method=S256 is required")
+	default:
+		return client, true
+	}
+	return client, false
+}
+
+// authenticate ตรวจสอบตัวตนจาก JWT ของการล็อกอินที่มีอยู่แล้ว (Bearer) หรือจากฟอร์มล็อกอินในหน้า consent
+// ผู้ใช้ที่เปิด MFA ล็อกอินผ่านฟอร์มเป็นสองขั้นเหมือน /login และ /login/mfa
+// ขั้นแรกคืน mfaToken ของ challenge ใหม่ ขั้นที่สองส่ง mfa_token กลับมาพร้อม otp
+// และคืน mfaToken เดิมพร้อม error เมื่อรหัสผิด เพื่อให้ลองใหม่ได้จนกว่า challenge จะถูกยกเลิก
+func (h *OIDCHandler) authenticate(c *gin.Context) (user domain.User, mfaToken string, err error) {
+	user, mfaToken, err = h.authenticateUser(c)
+	if err != nil {
+		return domain.User{}, mfaToken, err
+	}
+	if mfaToken == "" && !user.IsActive() {
+		return domain.User{}, "", errors.New(statusMessage(user))
+	}
+	return user, mfaToken, nil
+}
+
+func (h *OIDCHandler) authenticateUser(c *gin.Context) (domain.User, string, error) {
+	ctx := c.Request.Context()
+
+	if authHeader := c.GetHeader("Authorization"); strings.HasPrefix(authHeader, "Bearer ") {
+		principal, err := h.authn.Authenticate(ctx, strings.TrimPrefix(authHeader, "Bearer "), c.ClientIP())
+		if errors.Is(err, domain.ErrUserInactive) {
+			return domain.User{}, "", errors.New("Account is not active")
+		}
+		if err != nil {
+			return domain.User{}, "", errors.New("Invalid token")
+		}
+		// token สำหรับเครื่องและ admin ที่สวมสิทธิ์ผู้ใช้อนุญาต client แทนผู้ใช้ไม่ได้
+		if !principal.IsInteractive() {
+			return domain.User{}, "", errors.New("Only the user's own login can approve this request")
+		}
+		objID, err := primitive.ObjectIDFromHex(principal.UserID)
+		if err != nil {
+			return domain.User{}, "", errors.New("Invalid token")
+		}
+		user, err := h.userRepo.FindByID(ctx, objID)
+		if err != nil {
+			return domain.User{}, "", errors.New("Invalid token")
+		}
+		return user, "", nil
+	}
+
+	if token := c.PostForm("mfa_token"); token != "" {
+		user, err := completeMFAChallenge(ctx, h.userRepo, token, c.PostForm("otp"))
+		switch {
+		case errors.Is(err, domain.ErrInvalidMFACode):
+			return domain.User{}, token, errors.New("Invalid MFA code")
+		case err != nil:
+			return domain.User{}, "", errors.New("Invalid or expired MFA code request, please sign in again")
+		}
+		return user, "", nil
+	}
+
+	invalid := errors.New("Invalid email or password")
+	user, err := h.userRepo.FindByEmail(ctx, c.PostForm("email"))
+	if err != nil {
+		return domain.User{}, "", invalid
+	}
+	if !utils.CheckPasswordHashContext(ctx, c.PostForm("password"), user.Password) {
+		return domain.User{}, "", invalid
+	}
+	if !user.IsActive() {
+		return domain.User{}, "", errors.New(statusMessage(user))
+	}
+
+	if user.RequiresMFA() {
+		token, err := mfaChallenge(ctx, h.userRepo, user)
+		if err != nil {
+			return domain.User{}, "", errors.New("Failed to start MFA verification")
+		}
+		return user, token, nil
+	}
+	return user, "", nil
+}
+
+func (h *OIDCHandler) renderConsent(c *gin.Context, status int, client domain.OAuthClient, params authorizeParams, mfaToken, errMsg string) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Header("X-Frame-Options", "DENY")
+	c.Status(status)
+	err := consentTemplate.Execute(c.Writer, gin.H{
+		"ClientName": client.Name,
+		"Scopes":     strings.Fields(params.Scope),
+		"Params":     params,
+		"MFAToken":   mfaToken,
+		"Error":      errMsg,
+	})
+	if err != nil {
+		c.Error(err)
+	}
+}
+
+// userClaims สร้าง claims ของผู้ใช้ตาม scope ที่ได้รับอนุญาต
+func userClaims(user domain.User, scope string) map[string]interface{} {
+	claims := map[string]interface{}{}
+	scopes := strings.Fields(scope)
+	if contains(scopes, "profile") {
+		claims["name"] = user.Name
+		if user.UpdatedAt != nil {
+			claims["updated_at"] = user.UpdatedAt.Unix()
+		}
+	}
+	if contains(scopes, "email") {
+		claims["email"] = user.Email
+	}
+	return claims
+}
+
+// verifyPKCE ตรวจสอบ code_verifier กับ code_challenge แบบ S256 (RFC 7636)
+func verifyPKCE(challenge, verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+func redirectWithError(c *gin.Context, params authorizeParams, code, description string) {
+	q := url.Values{}
+	q.Set("error", code)
+	q.Set("error_description", description)
+	if params.State != "" {
+		q.Set("state", params.State)
+	}
+	c.Redirect(http.StatusFound, appendQuery(params.RedirectURI, q))
+}
+
+func oauthError(c *gin.Context, status int, code, description string) {
+	c.JSON(status, gin.H{"error": code, "error_description": description})
+}
+
+func appendQuery(rawURL string, q url.Values) string {
+	if strings.Contains(rawURL, "?") {
+		return rawURL + "&" + q.Encode()
+	}
+	return rawURL + "?" + q.Encode()
+}
+
+func contains(values []string, target string) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,271 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/internal/privacy"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/redact"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PrivacyHandler ให้ผู้ใช้ดาวน์โหลดข้อมูลส่วนบุคคลของตัวเองและขอให้ลบข้อมูลอย่างถาวร
+// การลบจริงต้องมี admin ที่ไม่ใช่ผู้ยื่นคำขออนุมัติ
+type PrivacyHandler struct {
+	userRepo    domain.UserRepository
+	logRepo     domain.LogRepository
+	erasureRepo domain.ErasureRepository
+	eraser      domain.PersonalDataEraser
+	redactor    *redact.Redactor
+	logger      *slog.Logger
+}
+
+// NewPrivacyHandler สร้าง PrivacyHandler
+func NewPrivacyHandler(userRepo domain.UserRepository, logRepo domain.LogRepository, erasureRepo domain.ErasureRepository, eraser domain.PersonalDataEraser) *PrivacyHandler {
+	return &PrivacyHandler{
+		userRepo:    userRepo,
+		logRepo:     logRepo,
+		erasureRepo: erasureRepo,
+		eraser:      eraser,
+		redactor:    redact.New(redact.DefaultPolicy(), nil, 24*time.Hour),
+		logger:      slog.Default(),
+	}
+}
+
+// WithLogger เปลี่ยน logger ที่ใช้บันทึกการลบข้อมูลและความผิดพลาดระหว่างส่งไฟล์
+func (h *PrivacyHandler) WithLogger(l *slog.Logger) *PrivacyHandler {
+	h.logger = l
+	return h
+}
+
+// WithRedactor ใช้ redactor เดียวกับ request log เพื่อหา log ที่ user_id ถูกแฮชไว้
+func (h *PrivacyHandler) WithRedactor(r *redact.Redactor) *PrivacyHandler {
+	h.redactor = r
+	return h
+}
+
+// DataExport ส่ง ZIP ที่มีข้อมูลผู้ใช้และ request log ทั้งหมดของผู้ใช้ที่ล็อกอินอยู่
+func (h *PrivacyHandler) DataExport(c *gin.Context) {
+	user, ok := currentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", "attachment; filename=personal-data.zip")
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+	if err := privacy.WriteExport(c.Request.Context(), c.Writer, user, h.logRepo, h.redactor.UserIDKeys(user.ID.Hex()), time.Now()); err != nil {
+		h.logger.ErrorContext(c.Request.Context(), "personal data export failed", "user_id", user.ID.Hex(), "error", err)
+		// ถ้ายังไม่ได้ส่งอะไรออกไปก็ยังตอบ error ได้ ไม่อย่างนั้น ZIP ที่ไม่มี central directory จะเปิดไม่ได้
+		if !c.Writer.Written() {
+			c.Header("Content-Type", "")
+			c.Header("Content-Disposition", "")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export personal data"})
+		}
+	}
+}
+
+// RequestErasure ให้ผู้ใช้ขอลบข้อมูลของตัวเอง body {"reason": "..."} ไม่บังคับ
+func (h *PrivacyHandler) RequestErasure(c *gin.Context) {
+	user, ok := currentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.file(c, user.ID, user.ID, strings.TrimSpace(req.Reason))
+}
+
+// RequestUserErasure ให้ admin ยื่นคำขอลบข้อมูลแทนผู้ใช้ รวมถึงผู้ใช้ที่ถูก soft delete แล้ว ต้องระบุเหตุผล
+func (h *PrivacyHandler) RequestUserErasure(c *gin.Context) {
+	admin, ok := currentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+	userID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason is required"})
+		return
+	}
+
+	users, err := h.userRepo.Search(c.Request.Context(), domain.UserFilter{ID: userID, IncludeDeleted: true, Limit: 1})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find user"})
+		return
+	}
+	if len(users) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	h.file(c, userID, admin.ID, strings.TrimSpace(req.Reason))
+}
+
+func (h *PrivacyHandler) file(c *gin.Context, userID, requestedBy primitive.ObjectID, reason string) {
+	request := domain.ErasureRequest{
+		UserID:      userID,
+		RequestedBy: requestedBy,
+		Reason:      reason,
+		Status:      domain.ErasurePending,
+		RequestedAt: time.Now(),
+	}
+	id, err := h.erasureRepo.Create(c.Request.Context(), request)
+	if errors.Is(err, domain.ErrErasurePending) {
+		c.JSON(http.StatusConflict, gin.H{"error": "An erasure request for this user is already pending"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create erasure request"})
+		return
+	}
+	request.ID = id
+	h.logger.InfoContext(c.Request.Context(), "erasure requested",
+		"erasure_id", id.Hex(), "user_id", userID.Hex(), "requested_by", requestedBy.Hex())
+	c.JSON(http.StatusAccepted, request)
+}
+
+// ListErasures แสดงคำขอลบข้อมูลล่าสุดก่อน กรองด้วย ?status=pending|rejected|completed
+func (h *PrivacyHandler) ListErasures(c *gin.Context) {
+	status := c.Query("status")
+	if status != "" && !contains(domain.ErasureStatuses, status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be one of " + strings.Join(domain.ErasureStatuses, ", ")})
+		return
+	}
+	requests, err := h.erasureRepo.Find(c.Request.Context(), status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch erasure requests"})
+		return
+	}
+	c.JSON(http.StatusOK, requests)
+}
+
+// GetErasure แสดงคำขอลบข้อมูลหนึ่งรายการ รวมถึงใบยืนยันการลบถ้าลบแล้ว
+func (h *PrivacyHandler) GetErasure(c *gin.Context) {
+	request, ok := h.findRequest(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, request)
+}
+
+// ApproveErasure ลบผู้ใช้และข้อมูลส่วนบุคคลอย่างถาวร แล้วคืนคำขอพร้อมใบยืนยันการลบ
+// ผู้อนุมัติต้องไม่ใช่ผู้ยื่นคำขอและไม่ใช่เจ้าของข้อมูลเอง ถ้าลบไม่สำเร็จคำขอยังรออนุมัติอยู่และอนุมัติซ้ำได้
+func (h *PrivacyHandler) ApproveErasure(c *gin.Context) {
+	admin, ok := currentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+	request, ok := h.findRequest(c)
+	if !ok {
+		return
+	}
+	if request.Status != domain.ErasurePending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Erasure request is already " + request.Status})
+		return
+	}
+	if request.RequestedBy == admin.ID || request.UserID == admin.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Erasure must be approved by another admin"})
+		return
+	}
+
+	// การลบต้องทำให้จบแม้ client จะตัดการเชื่อมต่อไประหว่างทาง
+	ctx := context.WithoutCancel(c.Request.Context())
+	counts, err := h.eraser.Erase(ctx, request.UserID, domain.NewPseudonym())
+	if err != nil {
+		h.logger.ErrorContext(ctx, "personal data erasure failed", "erasure_id", request.ID.Hex(), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erasure failed, the request is still pending and can be approved again"})
+		return
+	}
+
+	receipt := domain.ErasureReceipt{ErasedAt: time.Now(), Collections: counts, Limitations: h.eraser.Limitations()}
+	if err := h.erasureRepo.Complete(ctx, request.ID, admin.ID, receipt); err != nil {
+		h.logger.ErrorContext(ctx, "failed to record erasure receipt", "erasure_id", request.ID.Hex(), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Data was erased but the receipt could not be saved"})
+		return
+	}
+	request.Status = domain.ErasureCompleted
+	request.DecidedBy = &admin.ID
+	request.DecidedAt = &receipt.ErasedAt
+	request.Receipt = &receipt
+
+	h.logger.InfoContext(ctx, "personal data erased",
+		"erasure_id", request.ID.Hex(), "approved_by", admin.ID.Hex(), "collections", counts)
+	c.JSON(http.StatusOK, request)
+}
+
+// RejectErasure ปฏิเสธคำขอลบข้อมูล ต้องระบุเหตุผล
+func (h *PrivacyHandler) RejectErasure(c *gin.Context) {
+	admin, ok := currentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid erasure request ID format"})
+		return
+	}
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason is required"})
+		return
+	}
+
+	switch err := h.erasureRepo.Reject(c.Request.Context(), id, admin.ID, strings.TrimSpace(req.Reason)); {
+	case errors.Is(err, domain.ErrErasureNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Erasure request not found"})
+	case errors.Is(err, domain.ErrErasureDecided):
+		c.JSON(http.StatusConflict, gin.H{"error": "Erasure request is no longer pending"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reject erasure request"})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "Erasure request rejected"})
+	}
+}
+
+func (h *PrivacyHandler) findRequest(c *gin.Context) (domain.ErasureRequest, bool) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid erasure request ID format"})
+		return domain.ErasureRequest{}, false
+	}
+	request, err := h.erasureRepo.FindByID(c.Request.Context(), id)
+	if errors.Is(err, domain.ErrErasureNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Erasure request not found"})
+		return request, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch erasure request"})
+		return request, false
+	}
+	return request, true
+}
@@ -0,0 +1,50 @@
+package application
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseTimeRange อ่าน ?from=&to= ในรูปแบบ RFC 3339 เช่น 2024-01-31T00:00:00Z
+// ถ้ารูปแบบไม่ถูกต้องจะตอบ 400 แล้วคืนค่า false
+func parseTimeRange(c *gin.Context, from, to **time.Time) bool {
+	for param, target := range map[string]**time.Time{"from": from, "to": to} {
+		raw := c.Query(param)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + " time, expected RFC 3339"})
+			return false
+		}
+		*target = &t
+	}
+	return true
+}
+
+// parsePagination อ่าน ?page=&limit= โดย limit ต้องไม่เกิน maxLimit
+// ถ้าค่าไม่ถูกต้องจะตอบ 400 แล้วคืนค่า false
+func parsePagination(c *gin.Context, page, limit *int, maxLimit int) bool {
+	if raw := c.Query("page"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "page must be a positive integer"})
+			return false
+		}
+		*page = n
+	}
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxLimit)})
+			return false
+		}
+		*limit = n
+	}
+	return true
+}
@@ -0,0 +1,251 @@
+package application
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/auth"
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/jwt"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// impersonationTTL คืออายุของ session ที่ admin สวมสิทธิ์ผู้ใช้
+const impersonationTTL = 30 * time.Minute
+
+// SessionHandler สร้าง session ตอนล็อกอิน และให้ผู้ใช้ดู/ยกเลิก session ของตัวเอง
+// handler ที่ล็อกอินผู้ใช้ทุกตัวออก token ผ่าน SessionHandler
+type SessionHandler struct {
+	userRepo    domain.UserRepository
+	sessionRepo domain.SessionRepository
+	cookies     auth.CookieConfig
+	logger      *slog.Logger
+}
+
+// NewSessionHandler สร้าง SessionHandler cookies กำหนดว่าจะส่ง JWT ผ่าน cookie ให้ browser หรือไม่
+func NewSessionHandler(userRepo domain.UserRepository, sessionRepo domain.SessionRepository, cookies auth.CookieConfig) *SessionHandler {
+	return &SessionHandler{
+		userRepo:    userRepo,
+		sessionRepo: sessionRepo,
+		cookies:     cookies,
+		logger:      slog.Default(),
+	}
+}
+
+// WithLogger เปลี่ยน logger ที่ใช้บันทึกการสวมสิทธิ์และความผิดพลาดที่ไม่ทำให้ request ล้มเหลว
+func (h *SessionHandler) WithLogger(l *slog.Logger) *SessionHandler {
+	h.logger = l
+	return h
+}
+
+// startSession บันทึก session ของอุปกรณ์ที่ล็อกอิน อัพเดทเวลาล็อกอินล่าสุด แล้วออก JWT ที่ผูกกับ session
+func (h *SessionHandler) startSession(c *gin.Context, user domain.User) (token, sessionID string, err error) {
+	now := time.Now()
+	userAgent := c.Request.UserAgent()
+	id, err := h.sessionRepo.Create(c.Request.Context(), domain.Session{
+		UserID:     user.ID,
+		Device:     utils.DeviceLabel(userAgent),
+		UserAgent:  userAgent,
+		IP:         c.ClientIP(),
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(jwt.SessionTTL),
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	user.UpdateLastLogin()
+	if err := h.userRepo.Update(c.Request.Context(), user.ID, map[string]interface{}{"last_login": user.LastLogin}); err != nil {
+		h.logger.WarnContext(c.Request.Context(), "failed to update last login", "error", err)
+	}
+
+	token, err = jwt.GenerateJWT(user.ID.Hex(), id.Hex())
+	return token, id.Hex(), err
+}
+
+// List แสดง session ที่ยังใช้งานได้ของผู้ใช้ที่ล็อกอินอยู่
+func (h *SessionHandler) List(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	sessions, err := h.sessionRepo.FindActiveByUser(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if principal, ok := auth.PrincipalFromContext(c.Request.Context()); ok {
+		for i := range sessions {
+			sessions[i].Current = sessions[i].ID.Hex() == principal.SessionID
+		}
+	}
+	c.JSON(http.StatusOK, sessions)
+}
+
+// Logout ยกเลิก session ปัจจุบันและลบ cookie
+func (h *SessionHandler) Logout(c *gin.Context) {
+	principal, ok := auth.PrincipalFromContext(c.Request.Context())
+	if !ok || principal.SessionID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Not logged in with a session"})
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(principal.SessionID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+	if err := h.sessionRepo.Revoke(c.Request.Context(), id); err != nil && !errors.Is(err, domain.ErrSessionNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Logout failed"})
+		return
+	}
+
+	// token ของการสวมสิทธิ์ส่งผ่าน header เสมอ ไม่ลบ cookie ของ session admin เอง
+	if h.cookies.Enabled() && !principal.IsImpersonated() {
+		h.cookies.Clear(c.Writer)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// Revoke ยกเลิก session ของผู้ใช้ token ที่ผูกกับ session นี้จะใช้ไม่ได้ทันที
+func (h *SessionHandler) Revoke(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	session, err := h.sessionRepo.FindByID(c.Request.Context(), id)
+	// ไม่บอกว่ามี session นี้อยู่ถ้าไม่ใช่ของผู้ใช้เอง
+	if err != nil || session.UserID.Hex() != c.GetString("user_id") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	if err := h.sessionRepo.Revoke(c.Request.Context(), id); err != nil {
+		if errors.Is(err, domain.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found or already revoked"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Revoke failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// Impersonate ให้ admin สวมสิทธิ์ผู้ใช้เพื่อตรวจสอบปัญหา ออก token อายุสั้นที่มี sub เป็นผู้ใช้และ act เป็น admin
+// ต้องระบุเหตุผล และสวมสิทธิ์ admin คนอื่นไม่ได้
+func (h *SessionHandler) Impersonate(c *gin.Context) {
+	admin, ok := currentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	targetID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+	if targetID == admin.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot impersonate yourself"})
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason is required"})
+		return
+	}
+
+	target, err := h.userRepo.FindByID(c.Request.Context(), targetID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if target.IsAdmin() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot impersonate another admin"})
+		return
+	}
+	if !target.IsActive() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot impersonate an inactive user"})
+		return
+	}
+
+	now := time.Now()
+	userAgent := c.Request.UserAgent()
+	session := domain.Session{
+		UserID:         target.ID,
+		Device:         utils.DeviceLabel(userAgent),
+		UserAgent:      userAgent,
+		IP:             c.ClientIP(),
+		CreatedAt:      now,
+		LastSeenAt:     now,
+		ExpiresAt:      now.Add(impersonationTTL),
+		ImpersonatorID: &admin.ID,
+		Reason:         strings.TrimSpace(req.Reason),
+	}
+	session.ID, err = h.sessionRepo.Create(c.Request.Context(), session)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start impersonation"})
+		return
+	}
+
+	token, err := jwt.GenerateImpersonationJWT(target.ID.Hex(), session.ID.Hex(), admin.ID.Hex(), impersonationTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start impersonation"})
+		return
+	}
+
+	h.logger.InfoContext(c.Request.Context(), "impersonation started",
+		"admin_id", admin.ID.Hex(), "target_user_id", target.ID.Hex(), "session_id", session.ID.Hex(), "reason", session.Reason)
+	c.JSON(http.StatusOK, gin.H{
+		"token":      token,
+		"session_id": session.ID.Hex(),
+		"expires_at": session.ExpiresAt,
+		"user":       gin.H{"id": target.ID.Hex(), "name": target.Name, "email": target.Email},
+	})
+}
+
+// EndImpersonation ให้ admin ยกเลิก session ที่สวมสิทธิ์ผู้ใช้ก่อนหมดอายุ
+func (h *SessionHandler) EndImpersonation(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	session, err := h.sessionRepo.FindByID(c.Request.Context(), id)
+	if err != nil || session.ImpersonatorID == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Impersonation session not found"})
+		return
+	}
+
+	if err := h.sessionRepo.Revoke(c.Request.Context(), id); err != nil {
+		if errors.Is(err, domain.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Impersonation session not found or already ended"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to end impersonation"})
+		return
+	}
+
+	h.logger.InfoContext(c.Request.Context(), "impersonation ended",
+		"admin_id", session.ImpersonatorID.Hex(), "target_user_id", session.UserID.Hex(), "session_id", id.Hex())
+	c.JSON(http.StatusOK, gin.H{"message": "Impersonation ended"})
+}
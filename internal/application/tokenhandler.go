@@ -0,0 +1,240 @@
+package application
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/auth"
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// defaultPersonalTokenDays คืออายุเริ่มต้นของ personal access token
+	defaultPersonalTokenDays = 90
+	// maxPersonalTokenDays คืออายุสูงสุดของ personal access token
+	maxPersonalTokenDays = 365
+)
+
+type TokenHandler struct {
+	userRepo  domain.UserRepository
+	tokenRepo domain.APITokenRepository
+}
+
+// NewTokenHandler สร้าง handler สำหรับจัดการ personal access token และ service API key
+func NewTokenHandler(userRepo domain.UserRepository, tokenRepo domain.APITokenRepository) *TokenHandler {
+	return &TokenHandler{
+		userRepo:  userRepo,
+		tokenRepo: tokenRepo,
+	}
+}
+
+type createTokenRequest struct {
+	Name          string   `json:"name"`
+	UserID        string   `json:"user_id"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays *int     `json:"expires_in_days"`
+}
+
+// CreatePersonal สร้าง personal access token ให้ผู้ใช้ที่ล็อกอินอยู่ token จะแสดงเพียงครั้งเดียว
+func (h *TokenHandler) CreatePersonal(c *gin.Context) {
+	user, ok := currentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	var req createTokenRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	days := defaultPersonalTokenDays
+	if req.ExpiresInDays != nil {
+		days = *req.ExpiresInDays
+	}
+	if days < 1 || days > maxPersonalTokenDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in_days must be between 1 and 365"})
+		return
+	}
+
+	if contains(req.Scopes, domain.ScopeAdmin) && !user.IsAdmin() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can create tokens with the admin scope"})
+		return
+	}
+
+	h.create(c, domain.TokenKindPersonal, user.ID, user.ID, req, days)
+}
+
+// ListPersonal แสดง personal access token ของผู้ใช้ที่ล็อกอินอยู่ (ไม่แสดงค่า token)
+func (h *TokenHandler) ListPersonal(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	tokens, err := h.tokenRepo.FindByUser(c.Request.Context(), userID, domain.TokenKindPersonal)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// RevokePersonal ยกเลิก personal access token ของผู้ใช้ที่ล็อกอินอยู่
+func (h *TokenHandler) RevokePersonal(c *gin.Context) {
+	token, ok := h.findToken(c, domain.TokenKindPersonal)
+	if !ok {
+		return
+	}
+
+	// ไม่บอกว่ามี token นี้อยู่ถ้าไม่ใช่ของผู้ใช้เอง
+	if token.UserID.Hex() != c.GetString("user_id") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+		return
+	}
+
+	h.revoke(c, token.ID)
+}
+
+// CreateServiceKey สร้าง API key ให้ service account สำหรับ admin
+func (h *TokenHandler) CreateServiceKey(c *gin.Context) {
+	var req createTokenRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := primitive.ObjectIDFromHex(req.UserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
+		return
+	}
+	if _, err := h.userRepo.FindByID(c.Request.Context(), userID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	// API key ไม่หมดอายุถ้าไม่ได้กำหนด
+	days := 0
+	if req.ExpiresInDays != nil {
+		days = *req.ExpiresInDays
+	}
+	if days < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in_days must not be negative"})
+		return
+	}
+
+	adminID, _ := primitive.ObjectIDFromHex(c.GetString("user_id"))
+	h.create(c, domain.TokenKindService, userID, adminID, req, days)
+}
+
+// ListServiceKeys แสดง API key ทั้งหมด (ไม่แสดงค่า key)
+func (h *TokenHandler) ListServiceKeys(c *gin.Context) {
+	tokens, err := h.tokenRepo.FindByKind(c.Request.Context(), domain.TokenKindService)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// RevokeServiceKey ยกเลิก API key
+func (h *TokenHandler) RevokeServiceKey(c *gin.Context) {
+	token, ok := h.findToken(c, domain.TokenKindService)
+	if !ok {
+		return
+	}
+	h.revoke(c, token.ID)
+}
+
+// create ตรวจสอบ request แล้วบันทึก token ใหม่ days เป็น 0 หมายถึงไม่หมดอายุ
+func (h *TokenHandler) create(c *gin.Context, kind string, userID, createdBy primitive.ObjectID, req createTokenRequest, days int) {
+	raw, token, err := newAPIToken(kind, userID, createdBy, req.Name, req.Scopes, days)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token.ID, err = h.tokenRepo.Create(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"token":   raw,
+		"details": token,
+		"message": "Store this token now, it will not be shown again",
+	})
+}
+
+// newAPIToken ตรวจสอบชื่อและ scope แล้วสร้าง token ที่ยังไม่ได้บันทึก คืนค่า token จริงที่ต้องแสดงให้ผู้ใช้
+// ใช้ร่วมกันระหว่าง API และ userctl เพื่อให้กฎเดียวกัน
+func newAPIToken(kind string, userID, createdBy primitive.ObjectID, name string, scopes []string, days int) (string, domain.APIToken, error) {
+	if strings.TrimSpace(name) == "" {
+		return "", domain.APIToken{}, errors.New("name is required")
+	}
+	if len(scopes) == 0 {
+		return "", domain.APIToken{}, errors.New("At least one scope is required")
+	}
+	for _, scope := range scopes {
+		if !domain.IsValidScope(scope) {
+			return "", domain.APIToken{}, errors.New("Unsupported scope: " + scope)
+		}
+	}
+
+	raw, prefix, hash, err := auth.NewAPIToken(kind)
+	if err != nil {
+		return "", domain.APIToken{}, err
+	}
+
+	now := time.Now()
+	token := domain.APIToken{
+		Kind:      kind,
+		Name:      strings.TrimSpace(name),
+		Prefix:    prefix,
+		Hash:      hash,
+		UserID:    userID,
+		CreatedBy: createdBy,
+		Scopes:    scopes,
+		CreatedAt: now,
+	}
+	if days > 0 {
+		expiresAt := now.AddDate(0, 0, days)
+		token.ExpiresAt = &expiresAt
+	}
+	return raw, token, nil
+}
+
+func (h *TokenHandler) findToken(c *gin.Context, kind string) (domain.APIToken, bool) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token ID"})
+		return domain.APIToken{}, false
+	}
+
+	token, err := h.tokenRepo.FindByID(c.Request.Context(), id)
+	if err != nil || token.Kind != kind {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+		return domain.APIToken{}, false
+	}
+	return token, true
+}
+
+func (h *TokenHandler) revoke(c *gin.Context, id primitive.ObjectID) {
+	if err := h.tokenRepo.Revoke(c.Request.Context(), id); err != nil {
+		if errors.Is(err, domain.ErrTokenNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Token not found or already revoked"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Revoke failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Token revoked successfully"})
+}
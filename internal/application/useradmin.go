@@ -0,0 +1,322 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/utils"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/validator"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrLastAdmin คือ error เมื่อการเปลี่ยนแปลงจะทำให้ไม่เหลือ admin ที่ใช้งานได้
+var ErrLastAdmin = errors.New("at least one active admin must remain")
+
+// UserAdmin รวมงานดูแลผู้ใช้ เช่นตั้ง role หรือกู้คืนผู้ใช้ที่ถูกลบ
+// ใช้โดย cmd/userctl และ UserAdminHandler จึงตรวจสอบข้อมูลด้วยกฎเดียวกับ API
+type UserAdmin struct {
+	userRepo  domain.UserRepository
+	tokenRepo domain.APITokenRepository
+}
+
+// NewUserAdmin สร้าง UserAdmin
+func NewUserAdmin(userRepo domain.UserRepository, tokenRepo domain.APITokenRepository) *UserAdmin {
+	return &UserAdmin{
+		userRepo:  userRepo,
+		tokenRepo: tokenRepo,
+	}
+}
+
+// NewUser คือข้อมูลของผู้ใช้ที่จะสร้าง Role ว่างหมายถึง domain.RoleUser
+type NewUser struct {
+	Name     string
+	Email    string
+	Password string
+	Role     string
+}
+
+// Create สร้างผู้ใช้ด้วยการตรวจสอบเดียวกับ /register แต่กำหนด role ได้
+func (a *UserAdmin) Create(ctx context.Context, input NewUser) (domain.User, error) {
+	if input.Role == "" {
+		input.Role = domain.RoleUser
+	}
+	if err := validateRole(input.Role); err != nil {
+		return domain.User{}, err
+	}
+	if err := validator.ValidateUserInput(input.Name, input.Email, input.Password); err != nil {
+		return domain.User{}, err
+	}
+	if err := a.ensureUnique(ctx, input.Name, input.Email); err != nil {
+		return domain.User{}, err
+	}
+
+	hashed, err := utils.HashPasswordContext(ctx, input.Password)
+	if err != nil {
+		return domain.User{}, err
+	}
+	user := *domain.NewUser(input.Name, input.Email, hashed)
+	user.Role = input.Role
+
+	user.ID, err = a.userRepo.Create(ctx, user)
+	if err != nil {
+		return domain.User{}, err
+	}
+	return user, nil
+}
+
+// Find ค้นหาผู้ใช้ที่ยังไม่ถูกลบจาก ID หรืออีเมล
+func (a *UserAdmin) Find(ctx context.Context, ref string) (domain.User, error) {
+	var (
+		user domain.User
+		err  error
+	)
+	if id, idErr := primitive.ObjectIDFromHex(ref); idErr == nil {
+		user, err = a.userRepo.FindByID(ctx, id)
+	} else {
+		user, err = a.userRepo.FindByEmail(ctx, ref)
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return domain.User{}, fmt.Errorf("%w: %s", domain.ErrUserNotFound, ref)
+	}
+	return user, err
+}
+
+// Search คืนผู้ใช้ตาม filter
+func (a *UserAdmin) Search(ctx context.Context, filter domain.UserFilter) ([]domain.User, error) {
+	if filter.Role != "" {
+		if err := validateRole(filter.Role); err != nil {
+			return nil, err
+		}
+	}
+	if filter.Status != "" {
+		if err := validateStatus(string(filter.Status)); err != nil {
+			return nil, err
+		}
+	}
+	return a.userRepo.Search(ctx, filter)
+}
+
+// SetRole เปลี่ยน role ของผู้ใช้ ไม่ยอมให้ลด role ของ admin คนสุดท้าย
+func (a *UserAdmin) SetRole(ctx context.Context, ref, role string) (domain.User, error) {
+	if err := validateRole(role); err != nil {
+		return domain.User{}, err
+	}
+	user, err := a.Find(ctx, ref)
+	if err != nil {
+		return domain.User{}, err
+	}
+	if role != domain.RoleAdmin {
+		if err := a.ensureOtherAdmin(ctx, user); err != nil {
+			return domain.User{}, err
+		}
+	}
+	return a.update(ctx, user, map[string]interface{}{"role": role})
+}
+
+// SetStatus เปลี่ยนสถานะของผู้ใช้ไปเป็น status ตามตารางการเปลี่ยนสถานะ ใช้โดย userctl set-status
+func (a *UserAdmin) SetStatus(ctx context.Context, ref string, status domain.UserStatus, reason string, until *time.Time) (domain.User, error) {
+	if err := validateStatus(string(status)); err != nil {
+		return domain.User{}, err
+	}
+	user, err := a.Find(ctx, ref)
+	if err != nil {
+		return domain.User{}, err
+	}
+	transition, err := domain.TransitionTo(user.EffectiveStatus(time.Now()), status)
+	if err != nil {
+		return domain.User{}, err
+	}
+	return a.changeStatus(ctx, user, transition, reason, until)
+}
+
+// Transition เปลี่ยนสถานะของผู้ใช้ด้วยชื่อการเปลี่ยนสถานะ เช่น suspend หรือ ban ต้องระบุเหตุผล
+// until ใช้กับ suspend เท่านั้น ไม่ยอมให้ระงับหรือแบน admin คนสุดท้าย
+func (a *UserAdmin) Transition(ctx context.Context, ref, action, reason string, until *time.Time) (domain.User, error) {
+	user, err := a.Find(ctx, ref)
+	if err != nil {
+		return domain.User{}, err
+	}
+	transition, err := domain.FindTransition(user.EffectiveStatus(time.Now()), action)
+	if err != nil {
+		return domain.User{}, err
+	}
+	return a.changeStatus(ctx, user, transition, reason, until)
+}
+
+func (a *UserAdmin) changeStatus(ctx context.Context, user domain.User, transition domain.Transition, reason string, until *time.Time) (domain.User, error) {
+	if transition.To == domain.StatusDeleted {
+		return domain.User{}, fmt.Errorf("%w: use delete to remove a user", domain.ErrInvalidInput)
+	}
+	change := domain.StatusChange{Transition: transition, Reason: strings.TrimSpace(reason), Until: until, At: time.Now()}
+	if err := change.Validate(); err != nil {
+		return domain.User{}, err
+	}
+	if transition.To != domain.StatusActive {
+		if err := a.ensureOtherAdmin(ctx, user); err != nil {
+			return domain.User{}, err
+		}
+	}
+	if err := a.userRepo.ChangeStatus(ctx, user.ID, change); err != nil {
+		return domain.User{}, err
+	}
+	return a.userRepo.FindByID(ctx, user.ID)
+}
+
+// ResetPassword ตั้งรหัสผ่านใหม่ด้วยกฎเดียวกับตอนสมัคร
+func (a *UserAdmin) ResetPassword(ctx context.Context, ref, password string) (domain.User, error) {
+	if err := validator.ValidatePassword(password); err != nil {
+		return domain.User{}, err
+	}
+	user, err := a.Find(ctx, ref)
+	if err != nil {
+		return domain.User{}, err
+	}
+	hashed, err := utils.HashPasswordContext(ctx, password)
+	if err != nil {
+		return domain.User{}, err
+	}
+	return a.update(ctx, user, map[string]interface{}{"password": hashed})
+}
+
+// Delete ลบผู้ใช้แบบ soft delete กู้คืนได้ด้วย Restore
+func (a *UserAdmin) Delete(ctx context.Context, ref string) (domain.User, error) {
+	user, err := a.Find(ctx, ref)
+	if err != nil {
+		return domain.User{}, err
+	}
+	if err := a.ensureOtherAdmin(ctx, user); err != nil {
+		return domain.User{}, err
+	}
+	if err := a.userRepo.Delete(ctx, user.ID); err != nil {
+		return domain.User{}, err
+	}
+	now := time.Now()
+	user.DeletedAt = &now
+	return user, nil
+}
+
+// Restore กู้คืนผู้ใช้ที่ถูก soft delete จาก ID หรืออีเมล
+// คืน ErrUserAlreadyExists ถ้าระหว่างที่ถูกลบมีผู้ใช้คนอื่นสมัครด้วยอีเมลหรือชื่อเดียวกันแล้ว
+func (a *UserAdmin) Restore(ctx context.Context, ref string) (domain.User, error) {
+	filter := domain.UserFilter{IncludeDeleted: true, Limit: 2}
+	if id, err := primitive.ObjectIDFromHex(ref); err == nil {
+		filter.ID = id
+	} else {
+		filter.Email = ref
+	}
+	users, err := a.userRepo.Search(ctx, filter)
+	if err != nil {
+		return domain.User{}, err
+	}
+	var deleted []domain.User
+	for _, u := range users {
+		if u.DeletedAt != nil {
+			deleted = append(deleted, u)
+		}
+	}
+	switch {
+	case len(deleted) == 0:
+		return domain.User{}, fmt.Errorf("%w: no deleted user %s", domain.ErrUserNotFound, ref)
+	case len(deleted) > 1:
+		return domain.User{}, fmt.Errorf("%w: more than one deleted user has email %s, restore by ID", domain.ErrInvalidInput, ref)
+	}
+
+	user := deleted[0]
+	if err := a.ensureUnique(ctx, user.Name, user.Email); err != nil {
+		return domain.User{}, err
+	}
+	if err := a.userRepo.Restore(ctx, user.ID); err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return domain.User{}, fmt.Errorf("%w: no deleted user %s", domain.ErrUserNotFound, ref)
+		}
+		return domain.User{}, err
+	}
+	return a.userRepo.FindByID(ctx, user.ID)
+}
+
+// IssueToken ออก API key ให้ผู้ใช้ที่ใช้งานอยู่ด้วยกฎเดียวกับ /admin/api-keys
+// scope admin ออกให้ได้เฉพาะผู้ใช้ที่เป็น admin เพราะผู้ใช้อื่นใช้ scope นี้ไม่ได้อยู่แล้ว
+// days เป็น 0 หมายถึงไม่หมดอายุ token จริงถูกคืนเพียงครั้งเดียว
+func (a *UserAdmin) IssueToken(ctx context.Context, ref, name string, scopes []string, days int) (string, domain.APIToken, error) {
+	if days < 0 {
+		return "", domain.APIToken{}, errors.New("expires_in_days must not be negative")
+	}
+	user, err := a.Find(ctx, ref)
+	if err != nil {
+		return "", domain.APIToken{}, err
+	}
+	if !user.IsActive() {
+		return "", domain.APIToken{}, domain.ErrUserInactive
+	}
+	for _, scope := range scopes {
+		if scope == domain.ScopeAdmin && !user.IsAdmin() {
+			return "", domain.APIToken{}, errors.New("Only admins can hold tokens with the admin scope")
+		}
+	}
+
+	raw, token, err := newAPIToken(domain.TokenKindService, user.ID, primitive.NilObjectID, name, scopes, days)
+	if err != nil {
+		return "", domain.APIToken{}, err
+	}
+	token.ID, err = a.tokenRepo.Create(ctx, token)
+	if err != nil {
+		return "", domain.APIToken{}, err
+	}
+	return raw, token, nil
+}
+
+func (a *UserAdmin) update(ctx context.Context, user domain.User, update map[string]interface{}) (domain.User, error) {
+	if err := a.userRepo.Update(ctx, user.ID, update); err != nil {
+		return domain.User{}, err
+	}
+	return a.userRepo.FindByID(ctx, user.ID)
+}
+
+// ensureUnique ตรวจชื่อและอีเมลซ้ำแบบเดียวกับ /register
+func (a *UserAdmin) ensureUnique(ctx context.Context, name, email string) error {
+	if _, err := a.userRepo.FindByEmail(ctx, email); err == nil {
+		return fmt.Errorf("%w: email %s", domain.ErrUserAlreadyExists, email)
+	}
+	if _, err := a.userRepo.FindByName(ctx, name); err == nil {
+		return fmt.Errorf("%w: name %s", domain.ErrUserAlreadyExists, name)
+	}
+	return nil
+}
+
+// ensureOtherAdmin คืน ErrLastAdmin ถ้า user เป็น admin ที่ใช้งานได้คนเดียวที่เหลืออยู่
+func (a *UserAdmin) ensureOtherAdmin(ctx context.Context, user domain.User) error {
+	if !user.IsAdmin() || !user.IsActive() {
+		return nil
+	}
+	admins, err := a.userRepo.Search(ctx, domain.UserFilter{Role: domain.RoleAdmin, Status: domain.StatusActive, Limit: 2})
+	if err != nil {
+		return err
+	}
+	// ผู้ใช้ที่การระงับหมดเวลาแล้วยังไม่อยู่ในผลค้นหาสถานะ active จึงต้องหา admin คนอื่นแทนการนับ
+	for _, admin := range admins {
+		if admin.ID != user.ID {
+			return nil
+		}
+	}
+	return ErrLastAdmin
+}
+
+func validateRole(role string) error {
+	if !domain.IsValidRole(role) {
+		return fmt.Errorf("%w: role must be one of %s", domain.ErrInvalidInput, strings.Join(domain.Roles, ", "))
+	}
+	return nil
+}
+
+func validateStatus(status string) error {
+	if !domain.IsValidStatus(status) {
+		return fmt.Errorf("%w: status must be one of %s", domain.ErrInvalidInput, strings.Join(domain.Statuses, ", "))
+	}
+	return nil
+}
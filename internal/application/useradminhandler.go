@@ -0,0 +1,155 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultUserListSize = 100
+	maxUserListSize     = 1000
+)
+
+// UserAdminHandler ให้ admin ค้นหาผู้ใช้รวมถึงคนที่ถูก soft delete กู้คืน และเปลี่ยนสถานะผู้ใช้ผ่าน API
+// ใช้กฎเดียวกับ cmd/userctl ผ่าน UserAdmin
+type UserAdminHandler struct {
+	admin *UserAdmin
+}
+
+// NewUserAdminHandler สร้าง UserAdminHandler
+func NewUserAdminHandler(admin *UserAdmin) *UserAdminHandler {
+	return &UserAdminHandler{
+		admin: admin,
+	}
+}
+
+// List ค้นหาผู้ใช้ด้วย ?q=&role=&status=&deleted=true&limit= เรียงตามวันที่สร้าง
+// deleted=true รวมผู้ใช้ที่ถูกลบแล้ว ซึ่งมี deleted_at ในผลลัพธ์
+func (h *UserAdminHandler) List(c *gin.Context) {
+	filter := domain.UserFilter{
+		Query:  c.Query("q"),
+		Role:   c.Query("role"),
+		Status: domain.UserStatus(c.Query("status")),
+		Limit:  defaultUserListSize,
+	}
+	if raw := c.Query("deleted"); raw != "" {
+		deleted, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "deleted must be true or false"})
+			return
+		}
+		filter.IncludeDeleted = deleted
+	}
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxUserListSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxUserListSize)})
+			return
+		}
+		filter.Limit = n
+	}
+
+	users, err := h.admin.Search(c.Request.Context(), filter)
+	if errors.Is(err, domain.ErrInvalidInput) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		return
+	}
+	for i := range users {
+		users[i].Password = ""
+	}
+	c.JSON(http.StatusOK, users)
+}
+
+// Restore กู้คืนผู้ใช้ที่ถูก soft delete ตอบ 409 ถ้าอีเมลหรือชื่อถูกผู้ใช้คนอื่นนำไปใช้แล้ว
+func (h *UserAdminHandler) Restore(c *gin.Context) {
+	if _, err := primitive.ObjectIDFromHex(c.Param("id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+	user, err := h.admin.Restore(c.Request.Context(), c.Param("id"))
+	switch {
+	case errors.Is(err, domain.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deleted user not found"})
+	case errors.Is(err, domain.ErrUserAlreadyExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "Email or name is already used by another user"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore user"})
+	default:
+		user.Password = ""
+		c.JSON(http.StatusOK, user)
+	}
+}
+
+// Activate เปิดใช้งานผู้ใช้ที่ยังรอเปิดใช้งาน (pending -> active)
+func (h *UserAdminHandler) Activate(c *gin.Context) {
+	h.transition(c, domain.TransitionActivate)
+}
+
+// Suspend ระงับผู้ใช้ body {"reason": "...", "until": "2026-01-02T15:04:05Z"}
+// until ไม่บังคับ ถ้าระบุ ผู้ใช้จะกลับเป็น active เองเมื่อถึงเวลานั้น
+func (h *UserAdminHandler) Suspend(c *gin.Context) {
+	h.transition(c, domain.TransitionSuspend)
+}
+
+// Reactivate ยกเลิกการระงับผู้ใช้ (suspended -> active)
+func (h *UserAdminHandler) Reactivate(c *gin.Context) {
+	h.transition(c, domain.TransitionReactivate)
+}
+
+// Ban แบนผู้ใช้ถาวร เปลี่ยนกลับไม่ได้นอกจากลบผู้ใช้
+func (h *UserAdminHandler) Ban(c *gin.Context) {
+	h.transition(c, domain.TransitionBan)
+}
+
+// transition เปลี่ยนสถานะผู้ใช้ตาม action ต้องมี reason ใน body
+// ตอบ 409 ถ้าสถานะปัจจุบันเปลี่ยนด้วย action นี้ไม่ได้ หรือจะทำให้ไม่เหลือ admin ที่ใช้งานได้
+func (h *UserAdminHandler) transition(c *gin.Context, action string) {
+	if _, err := primitive.ObjectIDFromHex(c.Param("id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+	if c.Param("id") == c.GetString("user_id") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admins cannot change their own status"})
+		return
+	}
+	var req struct {
+		Reason string     `json:"reason"`
+		Until  *time.Time `json:"until"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason is required"})
+		return
+	}
+
+	user, err := h.admin.Transition(c.Request.Context(), c.Param("id"), action, req.Reason, req.Until)
+	switch {
+	case errors.Is(err, domain.ErrInvalidInput):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, domain.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case errors.Is(err, domain.ErrInvalidTransition), errors.Is(err, ErrLastAdmin):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change user status"})
+	default:
+		user.Password = ""
+		c.JSON(http.StatusOK, user)
+	}
+}
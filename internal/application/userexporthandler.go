@@ -0,0 +1,135 @@
+package application
+
+import (
+	"compress/gzip"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/internal/userexport"
+
+	"github.com/gin-gonic/gin"
+)
+
+// trailer ที่บอกผลการส่งออก เพราะ status code ถูกส่งไปแล้วก่อนรู้ว่าอ่านครบหรือไม่
+const (
+	exportStatusTrailer = "X-Export-Status"
+	exportRowsTrailer   = "X-Export-Rows"
+)
+
+type UserExportHandler struct {
+	userRepo domain.UserRepository
+	logger   *slog.Logger
+}
+
+// NewUserExportHandler สร้าง handler ส่งออกผู้ใช้
+func NewUserExportHandler(userRepo domain.UserRepository) *UserExportHandler {
+	return &UserExportHandler{
+		userRepo: userRepo,
+		logger:   slog.Default(),
+	}
+}
+
+// WithLogger เปลี่ยน logger ที่ใช้บันทึกการส่งออก
+func (h *UserExportHandler) WithLogger(l *slog.Logger) *UserExportHandler {
+	h.logger = l
+	return h
+}
+
+// Export ส่งออกผู้ใช้ด้วย ?format=csv|ndjson|parquet&fields=id,email&role=&status=&from=&to=&deleted=true
+// อ่านจาก cursor และเขียนทีละคนจึงใช้หน่วยความจำคงที่ บีบอัดด้วย gzip ถ้า client ส่ง Accept-Encoding: gzip
+// ถ้าอ่านฐานข้อมูลล้มเหลวกลางทาง trailer X-Export-Status เป็น error และไฟล์จะไม่สมบูรณ์
+func (h *UserExportHandler) Export(c *gin.Context) {
+	format := c.DefaultQuery("format", userexport.FormatCSV)
+	contentType, ok := userexport.ContentTypes[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv, ndjson or parquet"})
+		return
+	}
+	var names []string
+	if raw := c.Query("fields"); raw != "" {
+		names = strings.Split(raw, ",")
+	}
+
+	filter := domain.UserFilter{Role: c.Query("role"), Status: domain.UserStatus(c.Query("status"))}
+	if filter.Role != "" && !domain.IsValidRole(filter.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be one of " + strings.Join(domain.Roles, ", ")})
+		return
+	}
+	if filter.Status != "" && !domain.IsValidStatus(string(filter.Status)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be one of " + strings.Join(domain.Statuses, ", ")})
+		return
+	}
+	if !parseTimeRange(c, &filter.CreatedFrom, &filter.CreatedTo) {
+		return
+	}
+	if raw := c.Query("deleted"); raw != "" {
+		deleted, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "deleted must be true or false"})
+			return
+		}
+		filter.IncludeDeleted = deleted
+	}
+
+	// ตรวจคอลัมน์ก่อนตั้ง header เพื่อให้ยังตอบ 400 ได้
+	body := &lazyWriter{c: c}
+	w, err := userexport.NewWriter(body, format, names)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Disposition", "attachment; filename=users."+format)
+	header.Set("Cache-Control", "no-store")
+	header.Set("Trailer", exportStatusTrailer+", "+exportRowsTrailer)
+	header.Add("Vary", "Accept-Encoding")
+	var gz *gzip.Writer
+	if strings.Contains(c.GetHeader("Accept-Encoding"), "gzip") {
+		header.Set("Content-Encoding", "gzip")
+		gz = gzip.NewWriter(c.Writer)
+		body.gz = gz
+	}
+	c.Status(http.StatusOK)
+
+	ctx := c.Request.Context()
+	rows := 0
+	err = h.userRepo.Each(ctx, filter, func(user domain.User) error {
+		rows++
+		return w.Write(user)
+	})
+	if err == nil {
+		err = w.Close()
+	}
+	if err == nil && gz != nil {
+		err = gz.Close()
+	}
+	// เมื่อผิดพลาดจะไม่ปิด writer ไฟล์ Parquet ที่ไม่มี footer และ gzip ที่ไม่มีส่วนท้ายจึงถูกตรวจพบได้ว่าไม่ครบ
+	status := "complete"
+	if err != nil {
+		status = "error"
+		h.logger.ErrorContext(ctx, "user export failed", "actor_id", c.GetString("user_id"), "format", format, "rows", rows, "error", err)
+	} else {
+		h.logger.InfoContext(ctx, "users exported", "actor_id", c.GetString("user_id"), "format", format, "rows", rows)
+	}
+	header.Set(exportStatusTrailer, status)
+	header.Set(exportRowsTrailer, strconv.Itoa(rows))
+}
+
+// lazyWriter ส่งข้อมูลไปยัง response ผ่าน gzip ถ้ามี
+// gz ถูกตั้งหลัง NewWriter จึงต้องเลือกตอนเขียนจริง
+type lazyWriter struct {
+	c  *gin.Context
+	gz *gzip.Writer
+}
+
+func (l *lazyWriter) Write(p []byte) (int, error) {
+	if l.gz != nil {
+		return l.gz.Write(p)
+	}
+	return l.c.Writer.Write(p)
+}
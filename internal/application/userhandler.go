@@ -4,8 +4,9 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/Gsupakin/back_end_test_challeng/internal/auth"
 	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
-	"github.com/Gsupakin/back_end_test_challeng/pkg/jwt"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/metrics"
 	"github.com/Gsupakin/back_end_test_challeng/pkg/utils"
 	"github.com/Gsupakin/back_end_test_challeng/pkg/validator"
 
@@ -15,55 +16,31 @@ import (
 
 type UserHandler struct {
 	userRepo domain.UserRepository
-	logRepo  domain.LogRepository
+	sessions *SessionHandler
 }
 
-func NewUserHandler(userRepo domain.UserRepository, logRepo domain.LogRepository) *UserHandler {
+func NewUserHandler(userRepo domain.UserRepository, sessions *SessionHandler) *UserHandler {
 	return &UserHandler{
 		userRepo: userRepo,
-		logRepo:  logRepo,
+		sessions: sessions,
 	}
 }
 
 func (h *UserHandler) Register(c *gin.Context) {
 	// ตรวจสอบ Content-Type
 	if c.GetHeader("Content-Type") != "application/json" {
-		h.logRepo.Create(c.Request.Context(), domain.RequestLog{
-			Method:    "POST",
-			Path:      "/register",
-			Status:    http.StatusBadRequest,
-			IP:        c.ClientIP(),
-			UserAgent: c.Request.UserAgent(),
-			Timestamp: time.Now(),
-		})
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Content-Type must be application/json"})
 		return
 	}
 
 	var user domain.User
 	if err := c.BindJSON(&user); err != nil {
-		h.logRepo.Create(c.Request.Context(), domain.RequestLog{
-			Method:    "POST",
-			Path:      "/register",
-			Status:    http.StatusBadRequest,
-			IP:        c.ClientIP(),
-			UserAgent: c.Request.UserAgent(),
-			Timestamp: time.Now(),
-		})
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	// ตรวจสอบข้อมูลที่รับเข้ามา
 	if err := validator.ValidateUserInput(user.Name, user.Email, user.Password); err != nil {
-		h.logRepo.Create(c.Request.Context(), domain.RequestLog{
-			Method:    "POST",
-			Path:      "/register",
-			Status:    http.StatusBadRequest,
-			IP:        c.ClientIP(),
-			UserAgent: c.Request.UserAgent(),
-			Timestamp: time.Now(),
-		})
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -71,14 +48,6 @@ func (h *UserHandler) Register(c *gin.Context) {
 	// ตรวจสอบ email ซ้ำ
 	_, err := h.userRepo.FindByEmail(c.Request.Context(), user.Email)
 	if err == nil {
-		h.logRepo.Create(c.Request.Context(), domain.RequestLog{
-			Method:    "POST",
-			Path:      "/register",
-			Status:    http.StatusInternalServerError,
-			IP:        c.ClientIP(),
-			UserAgent: c.Request.UserAgent(),
-			Timestamp: time.Now(),
-		})
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Email already exists"})
 		return
 	}
@@ -86,57 +55,28 @@ func (h *UserHandler) Register(c *gin.Context) {
 	// ตรวจสอบ name ซ้ำ
 	_, err = h.userRepo.FindByName(c.Request.Context(), user.Name)
 	if err == nil {
-		h.logRepo.Create(c.Request.Context(), domain.RequestLog{
-			Method:    "POST",
-			Path:      "/register",
-			Status:    http.StatusInternalServerError,
-			IP:        c.ClientIP(),
-			UserAgent: c.Request.UserAgent(),
-			Timestamp: time.Now(),
-		})
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Name already exists"})
 		return
 	}
 
-	hashedPass, err := utils.HashPassword(user.Password)
+	hashedPass, err := utils.HashPasswordContext(c.Request.Context(), user.Password)
 	if err != nil {
-		h.logRepo.Create(c.Request.Context(), domain.RequestLog{
-			Method:    "POST",
-			Path:      "/register",
-			Status:    http.StatusInternalServerError,
-			IP:        c.ClientIP(),
-			UserAgent: c.Request.UserAgent(),
-			Timestamp: time.Now(),
-		})
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
 		return
 	}
 	user.Password = hashedPass
+	user.Role = domain.RoleUser       // ผู้ใช้ที่สมัครเองเป็น admin ไม่ได้
+	user.Status = domain.StatusActive // ค่าเริ่มต้น
+	user.MFA = domain.MFASettings{}   // การลงทะเบียน MFA ต้องทำผ่าน /me/mfa เท่านั้น
+	user.LinkedIdentities = nil       // การผูกบัญชีภายนอกต้องทำผ่าน /me/identities เท่านั้น
 	user.CreatedAt = time.Now()
 
 	id, err := h.userRepo.Create(c.Request.Context(), user)
 	if err != nil {
-		h.logRepo.Create(c.Request.Context(), domain.RequestLog{
-			Method:    "POST",
-			Path:      "/register",
-			Status:    http.StatusInternalServerError,
-			IP:        c.ClientIP(),
-			UserAgent: c.Request.UserAgent(),
-			Timestamp: time.Now(),
-		})
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	h.logRepo.Create(c.Request.Context(), domain.RequestLog{
-		Method:    "POST",
-		Path:      "/register",
-		Status:    http.StatusCreated,
-		IP:        c.ClientIP(),
-		UserAgent: c.Request.UserAgent(),
-		Timestamp: time.Now(),
-	})
-
 	c.JSON(http.StatusCreated, gin.H{"id": id})
 }
 
@@ -166,17 +106,18 @@ func (h *UserHandler) Login(c *gin.Context) {
 
 	user, err := h.userRepo.FindByEmail(c.Request.Context(), creds.Email)
 	if err != nil {
+		metrics.RecordLogin(metrics.LoginPassword, metrics.LoginFailure)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 		return
 	}
 
-	if !utils.CheckPasswordHash(creds.Password, user.Password) {
+	if !utils.CheckPasswordHashContext(c.Request.Context(), creds.Password, user.Password) {
+		metrics.RecordLogin(metrics.LoginPassword, metrics.LoginFailure)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 		return
 	}
 
-	token, _ := jwt.GenerateJWT(user.ID.Hex())
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	h.sessions.completeLogin(c, user, metrics.LoginPassword)
 }
 
 func (h *UserHandler) ListUsers(c *gin.Context) {
@@ -263,6 +204,11 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		update["name"] = updateData.Name
 	}
 	if updateData.Email != "" {
+		// อีเมลใช้ล็อกอินผ่านลิงก์ได้ จึงห้าม admin ที่สวมสิทธิ์อยู่เปลี่ยน
+		if principal, ok := auth.PrincipalFromContext(c.Request.Context()); ok && principal.IsImpersonated() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This action is not allowed while impersonating a user"})
+			return
+		}
 		// ตรวจสอบ email ซ้ำ
 		_, err := h.userRepo.FindByEmail(c.Request.Context(), updateData.Email)
 		if err == nil {
@@ -0,0 +1,87 @@
+package application
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/userimport"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportSize คือขนาดสูงสุดของ request นำเข้าผู้ใช้ ไฟล์ที่ใหญ่กว่านี้ให้ใช้ userctl import
+const maxImportSize = 64 << 20
+
+type UserImportHandler struct {
+	importer *userimport.Importer
+	workers  int
+}
+
+// NewUserImportHandler สร้าง handler นำเข้าผู้ใช้ workers คือจำนวน worker ที่ hash รหัสผ่านพร้อมกันต่อ request
+func NewUserImportHandler(importer *userimport.Importer, workers int) *UserImportHandler {
+	return &UserImportHandler{
+		importer: importer,
+		workers:  workers,
+	}
+}
+
+// Import นำเข้าผู้ใช้จากไฟล์ใน multipart field "file" ด้วย ?mode=skip|upsert&dry_run=true&format=csv|ndjson
+// format เดาจากนามสกุลไฟล์ถ้าไม่ระบุ ไฟล์ถูกอ่านทีละแถวโดยไม่เก็บลงดิสก์
+// ตอบ 200 พร้อมผลรายแถวแม้บางแถวผิด ตอบ 400 ถ้าอ่านไฟล์ไม่ได้เลย
+func (h *UserImportHandler) Import(c *gin.Context) {
+	opts := userimport.Options{Mode: c.DefaultQuery("mode", userimport.ModeSkip), Workers: h.workers}
+	if raw := c.Query("dry_run"); raw != "" {
+		dryRun, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "dry_run must be true or false"})
+			return
+		}
+		opts.DryRun = dryRun
+	}
+	if opts.Mode != userimport.ModeSkip && opts.Mode != userimport.ModeUpsert {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be skip or upsert"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+	parts, err := c.Request.MultipartReader()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Content-Type must be multipart/form-data"})
+		return
+	}
+	for {
+		part, err := parts.NextPart()
+		if errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+
+		format := c.Query("format")
+		if format == "" {
+			format = userimport.FormatFromFilename(part.FileName())
+		}
+		rows, err := userimport.NewReader(part, format)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		report, err := h.importer.Run(c.Request.Context(), rows, opts)
+		if err != nil {
+			// แถวก่อนหน้าอาจถูกนำเข้าไปแล้ว จึงส่งผลที่ได้กลับไปด้วย
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "report": report})
+			return
+		}
+		c.JSON(http.StatusOK, report)
+		return
+	}
+}
@@ -0,0 +1,104 @@
+package audit
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/redact"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Redacted แทนค่าที่เป็นความลับใน audit log
+const Redacted = redact.Marker
+
+// ignoredFields คือ field ที่เปลี่ยนทุกครั้งและไม่มีความหมายในการตรวจสอบ
+var ignoredFields = map[string]bool{"updated_at": true, "last_login": true, "mfa.last_used_step": true}
+
+// Changes เทียบค่าเดิมของผู้ใช้กับ update ที่จะ $set แล้วคืนเฉพาะ field ที่เปลี่ยนจริง
+func Changes(before domain.User, update map[string]interface{}) []domain.FieldChange {
+	current := toMap(before)
+
+	var changes []domain.FieldChange
+	for field, value := range update {
+		if ignoredFields[field] {
+			continue
+		}
+		oldValue := lookup(current, field)
+		newValue := normalize(value)
+		if reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+		changes = append(changes, domain.FieldChange{
+			Field: field,
+			Old:   redactValue(field, oldValue),
+			New:   redactValue(field, newValue),
+		})
+	}
+	return changes
+}
+
+// Snapshot คืนข้อมูลผู้ใช้ในรูป field ที่ตั้งค่าไว้ ใช้ตอนสร้างผู้ใช้
+func Snapshot(user domain.User) []domain.FieldChange {
+	var changes []domain.FieldChange
+	for _, field := range []string{"name", "email", "role", "status"} {
+		changes = append(changes, domain.FieldChange{Field: field, New: lookup(toMap(user), field)})
+	}
+	return changes
+}
+
+// redactValue แทนค่าความลับด้วย Redacted ทั้งที่ระดับ field และใน document ย่อย
+func redactValue(field string, value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	if redact.SensitiveKey(field) {
+		return Redacted
+	}
+	if doc, ok := value.(bson.M); ok {
+		out := bson.M{}
+		for k, v := range doc {
+			out[k] = redactValue(k, v)
+		}
+		return out
+	}
+	return value
+}
+
+// normalize แปลงค่าให้อยู่ในรูปเดียวกับที่อ่านจาก toMap เพื่อเทียบกันได้
+func normalize(value interface{}) interface{} {
+	raw, err := bson.Marshal(bson.M{"v": value})
+	if err != nil {
+		return value
+	}
+	var doc bson.M
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return value
+	}
+	return doc["v"]
+}
+
+func toMap(user domain.User) bson.M {
+	raw, err := bson.Marshal(user)
+	if err != nil {
+		return bson.M{}
+	}
+	var doc bson.M
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return bson.M{}
+	}
+	return doc
+}
+
+// lookup อ่านค่าจาก path แบบ dot notation เช่น mfa.enabled
+func lookup(doc bson.M, path string) interface{} {
+	var current interface{} = doc
+	for _, part := range strings.Split(path, ".") {
+		m, ok := current.(bson.M)
+		if !ok {
+			return nil
+		}
+		current = m[part]
+	}
+	return current
+}
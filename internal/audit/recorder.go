@@ -0,0 +1,82 @@
+// Package audit บันทึกการเปลี่ยนแปลงข้อมูลผู้ใช้ลง audit log แบบเพิ่มได้อย่างเดียว
+// พร้อมผู้กระทำ ค่าก่อน/หลัง และที่มาของ request (HTTP, gRPC หรือ CLI)
+package audit
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/auth"
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/redact"
+)
+
+// Origin คือที่มาของการเปลี่ยนแปลง
+type Origin struct {
+	Source    string
+	RequestID string
+	IP        string
+}
+
+type originKey struct{}
+
+// WithOrigin แนบที่มาของ request ไว้ใน context
+func WithOrigin(ctx context.Context, origin Origin) context.Context {
+	return context.WithValue(ctx, originKey{}, origin)
+}
+
+// OriginFromContext อ่านที่มาของ request จาก context
+func OriginFromContext(ctx context.Context) (Origin, bool) {
+	origin, ok := ctx.Value(originKey{}).(Origin)
+	return origin, ok
+}
+
+// Recorder เติมผู้กระทำและที่มาของ request จาก context แล้วบันทึก event
+type Recorder struct {
+	store    domain.AuditRepository
+	redactor *redact.Redactor
+	logger   *slog.Logger
+}
+
+func NewRecorder(store domain.AuditRepository) *Recorder {
+	return &Recorder{store: store, logger: slog.Default()}
+}
+
+// WithRedactor ใช้ policy เดียวกับ request log กับ IP ที่บันทึกใน audit event
+func (r *Recorder) WithRedactor(redactor *redact.Redactor) *Recorder {
+	r.redactor = redactor
+	return r
+}
+
+// WithLogger เปลี่ยน logger ที่ใช้รายงาน event ที่บันทึกไม่สำเร็จ
+func (r *Recorder) WithLogger(l *slog.Logger) *Recorder {
+	r.logger = l
+	return r
+}
+
+// Record บันทึก event ความผิดพลาดจะถูก log ไว้โดยไม่ทำให้การเปลี่ยนแปลงที่สำเร็จแล้วล้มเหลว
+func (r *Recorder) Record(ctx context.Context, event domain.AuditEvent) {
+	event.Timestamp = time.Now()
+
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		event.ActorID = principal.UserID
+		event.ImpersonatorID = principal.ActorID
+	}
+
+	if origin, ok := OriginFromContext(ctx); ok {
+		event.Source = origin.Source
+		event.RequestID = origin.RequestID
+		event.IP = origin.IP
+	}
+	if r.redactor != nil {
+		event.IP = r.redactor.IP(event.IP)
+	}
+
+	// context ของ request อาจถูกยกเลิกแล้ว แต่ยังต้องบันทึก event ที่เกิดขึ้นจริง
+	writeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := r.store.Create(writeCtx, event); err != nil {
+		r.logger.ErrorContext(ctx, "failed to write audit event", "action", event.Action, "target_user_id", event.TargetUserID, "error", err)
+	}
+}
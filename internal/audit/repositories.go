@@ -0,0 +1,174 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UserRepository ครอบ domain.UserRepository และบันทึก audit event ทุกครั้งที่ข้อมูลผู้ใช้เปลี่ยน
+// ทำให้ทุกช่องทาง (HTTP, gRPC, CLI) ถูกบันทึกโดยไม่ต้องแก้ handler
+type UserRepository struct {
+	domain.UserRepository
+	recorder *Recorder
+}
+
+func NewUserRepository(inner domain.UserRepository, recorder *Recorder) *UserRepository {
+	return &UserRepository{UserRepository: inner, recorder: recorder}
+}
+
+// Create implements domain.UserRepository
+func (r *UserRepository) Create(ctx context.Context, user domain.User) (primitive.ObjectID, error) {
+	id, err := r.UserRepository.Create(ctx, user)
+	if err != nil {
+		return id, err
+	}
+	r.recorder.Record(ctx, domain.AuditEvent{
+		Action:       domain.AuditUserCreate,
+		TargetUserID: id.Hex(),
+		Changes:      Snapshot(user),
+	})
+	return id, nil
+}
+
+// Update implements domain.UserRepository
+func (r *UserRepository) Update(ctx context.Context, id primitive.ObjectID, update map[string]interface{}) error {
+	before, findErr := r.UserRepository.FindByID(ctx, id)
+	var changes []domain.FieldChange
+	if findErr == nil {
+		// คำนวณก่อนเรียก Update เพราะ repository อาจเพิ่ม field ลงใน map
+		changes = Changes(before, update)
+	}
+
+	if err := r.UserRepository.Update(ctx, id, update); err != nil {
+		return err
+	}
+	if findErr == nil && len(changes) > 0 {
+		r.recorder.Record(ctx, domain.AuditEvent{
+			Action:       domain.AuditUserUpdate,
+			TargetUserID: id.Hex(),
+			Changes:      changes,
+		})
+	}
+	return nil
+}
+
+// Delete implements domain.UserRepository
+func (r *UserRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	if err := r.UserRepository.Delete(ctx, id); err != nil {
+		return err
+	}
+	r.recorder.Record(ctx, domain.AuditEvent{
+		Action:       domain.AuditUserDelete,
+		TargetUserID: id.Hex(),
+	})
+	return nil
+}
+
+// Restore implements domain.UserRepository
+func (r *UserRepository) Restore(ctx context.Context, id primitive.ObjectID) error {
+	if err := r.UserRepository.Restore(ctx, id); err != nil {
+		return err
+	}
+	r.recorder.Record(ctx, domain.AuditEvent{
+		Action:       domain.AuditUserRestore,
+		TargetUserID: id.Hex(),
+	})
+	return nil
+}
+
+// ChangeStatus implements domain.UserRepository และบันทึก event user.<action> เช่น user.suspend
+// ผู้กระทำว่างถ้าระบบเปลี่ยนเอง เช่นเปิดใช้งานคืนเมื่อการระงับหมดเวลา
+func (r *UserRepository) ChangeStatus(ctx context.Context, id primitive.ObjectID, change domain.StatusChange) error {
+	if err := r.UserRepository.ChangeStatus(ctx, id, change); err != nil {
+		return err
+	}
+	changes := []domain.FieldChange{
+		{Field: "status", Old: string(change.From), New: string(change.To)},
+		{Field: "status_reason", New: change.Reason},
+	}
+	if change.Until != nil {
+		changes = append(changes, domain.FieldChange{Field: "status_until", New: *change.Until})
+	}
+	r.recorder.Record(ctx, domain.AuditEvent{
+		Action:       domain.AuditUserStatusPrefix + change.Action,
+		TargetUserID: id.Hex(),
+		Changes:      changes,
+	})
+	return nil
+}
+
+// AddLinkedIdentity implements domain.UserRepository
+func (r *UserRepository) AddLinkedIdentity(ctx context.Context, id primitive.ObjectID, identity domain.LinkedIdentity) error {
+	if err := r.UserRepository.AddLinkedIdentity(ctx, id, identity); err != nil {
+		return err
+	}
+	r.recorder.Record(ctx, domain.AuditEvent{
+		Action:       domain.AuditIdentityLink,
+		TargetUserID: id.Hex(),
+		Changes:      []domain.FieldChange{{Field: "linked_identities", New: identity.Provider + ":" + identity.Subject}},
+	})
+	return nil
+}
+
+// RemoveLinkedIdentity implements domain.UserRepository
+func (r *UserRepository) RemoveLinkedIdentity(ctx context.Context, id primitive.ObjectID, issuer, subject string) error {
+	if err := r.UserRepository.RemoveLinkedIdentity(ctx, id, issuer, subject); err != nil {
+		return err
+	}
+	r.recorder.Record(ctx, domain.AuditEvent{
+		Action:       domain.AuditIdentityUnlink,
+		TargetUserID: id.Hex(),
+		Changes:      []domain.FieldChange{{Field: "linked_identities", Old: issuer + ":" + subject}},
+	})
+	return nil
+}
+
+// SessionRepository ครอบ domain.SessionRepository เพื่อบันทึกการล็อกอิน การสวมสิทธิ์ และการยกเลิก session
+type SessionRepository struct {
+	domain.SessionRepository
+	recorder *Recorder
+}
+
+func NewSessionRepository(inner domain.SessionRepository, recorder *Recorder) *SessionRepository {
+	return &SessionRepository{SessionRepository: inner, recorder: recorder}
+}
+
+// Create implements domain.SessionRepository
+func (r *SessionRepository) Create(ctx context.Context, session domain.Session) (primitive.ObjectID, error) {
+	id, err := r.SessionRepository.Create(ctx, session)
+	if err != nil {
+		return id, err
+	}
+
+	// การล็อกอินเกิดก่อนมี principal ผู้กระทำจึงเป็นเจ้าของ session เอง
+	event := domain.AuditEvent{
+		Action:       domain.AuditUserLogin,
+		ActorID:      session.UserID.Hex(),
+		TargetUserID: session.UserID.Hex(),
+		Changes:      []domain.FieldChange{{Field: "session", New: id.Hex()}},
+	}
+	if session.ImpersonatorID != nil {
+		event.Action = domain.AuditImpersonationStart
+		event.Changes = append(event.Changes, domain.FieldChange{Field: "reason", New: session.Reason})
+	}
+	r.recorder.Record(ctx, event)
+	return id, nil
+}
+
+// Revoke implements domain.SessionRepository
+func (r *SessionRepository) Revoke(ctx context.Context, id primitive.ObjectID) error {
+	session, findErr := r.SessionRepository.FindByID(ctx, id)
+	if err := r.SessionRepository.Revoke(ctx, id); err != nil {
+		return err
+	}
+	if findErr == nil {
+		r.recorder.Record(ctx, domain.AuditEvent{
+			Action:       domain.AuditSessionRevoke,
+			TargetUserID: session.UserID.Hex(),
+			Changes:      []domain.FieldChange{{Field: "session", Old: id.Hex()}},
+		})
+	}
+	return nil
+}
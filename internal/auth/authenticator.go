@@ -0,0 +1,229 @@
+// Package auth ยืนยันตัวตนผู้เรียก API จาก credential ที่แนบมา ใช้ร่วมกันทั้ง HTTP และ gRPC
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/jwt"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	personalTokenPrefix = "bet_pat_"
+	serviceKeyPrefix    = "bet_key_"
+)
+
+// lastUsedInterval จำกัดความถี่ในการบันทึกเวลาใช้งานล่าสุดของ token และ session
+// เพื่อไม่ให้ทุก request ต้องเขียนฐานข้อมูล
+const lastUsedInterval = time.Minute
+
+// Authenticator แปลง credential เป็น domain.Principal
+type Authenticator struct {
+	tokens   domain.APITokenRepository
+	sessions domain.SessionRepository
+	users    domain.UserRepository
+	logger   *slog.Logger
+}
+
+// NewAuthenticator สร้าง Authenticator ที่รับได้ทั้ง JWT, personal access token และ API key
+func NewAuthenticator(tokens domain.APITokenRepository, sessions domain.SessionRepository) *Authenticator {
+	return &Authenticator{tokens: tokens, sessions: sessions, logger: slog.Default()}
+}
+
+// WithLogger เปลี่ยน logger ที่ใช้รายงานความผิดพลาดในการบันทึกการใช้งาน
+func (a *Authenticator) WithLogger(l *slog.Logger) *Authenticator {
+	a.logger = l
+	return a
+}
+
+// WithUsers ให้ Authenticate ตรวจสถานะของเจ้าของ credential และ admin ที่สวมสิทธิ์ทุกครั้ง
+// token ของผู้ใช้ที่ถูกระงับ แบน หรือยังไม่เปิดใช้งานจะใช้ไม่ได้ทันทีโดยไม่ต้องยกเลิก token ทีละตัว
+func (a *Authenticator) WithUsers(users domain.UserRepository) *Authenticator {
+	a.users = users
+	return a
+}
+
+// Authenticate ตรวจสอบ credential แล้วคืนค่าผู้เรียก ip ใช้บันทึกการใช้งานล่าสุดของ token
+// คืน domain.ErrUserInactive ถ้า credential ถูกต้องแต่ผู้ใช้ไม่ active (เมื่อตั้ง WithUsers)
+func (a *Authenticator) Authenticate(ctx context.Context, credential, ip string) (*domain.Principal, error) {
+	principal, err := a.authenticate(ctx, credential, ip)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.checkUsers(ctx, principal); err != nil {
+		return nil, err
+	}
+	return principal, nil
+}
+
+func (a *Authenticator) authenticate(ctx context.Context, credential, ip string) (*domain.Principal, error) {
+	if credential == "" {
+		return nil, domain.ErrInvalidToken
+	}
+
+	if kind, prefix, ok := parseAPIToken(credential); ok {
+		return a.authenticateAPIToken(ctx, credential, kind, prefix, ip)
+	}
+
+	claims, err := jwt.ValidateToken(credential)
+	if err != nil {
+		return nil, domain.ErrInvalidToken
+	}
+	session, err := a.checkSession(ctx, claims, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	principal := &domain.Principal{UserID: claims.UserID, Method: domain.AuthMethodJWT, SessionID: claims.SessionID}
+	if session.ImpersonatorID != nil {
+		principal.Method = domain.AuthMethodImpersonation
+		principal.ActorID = session.ImpersonatorID.Hex()
+	}
+	return principal, nil
+}
+
+// checkUsers ยืนยันว่าเจ้าของ credential และ admin ที่สวมสิทธิ์อยู่ยังใช้งานได้
+func (a *Authenticator) checkUsers(ctx context.Context, principal *domain.Principal) error {
+	if a.users == nil {
+		return nil
+	}
+	for _, id := range []string{principal.UserID, principal.ActorID} {
+		if id == "" {
+			continue
+		}
+		objID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return domain.ErrInvalidToken
+		}
+		user, err := a.users.FindByID(ctx, objID)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return domain.ErrInvalidToken
+		}
+		if err != nil {
+			return err
+		}
+		if !user.IsActive() {
+			return domain.ErrUserInactive
+		}
+	}
+	return nil
+}
+
+// checkSession ยืนยันว่า session ที่ JWT ผูกอยู่ยังใช้งานได้ JWT ที่ไม่มี sid จะถูกปฏิเสธ
+// claim act ต้องตรงกับ admin ที่บันทึกไว้ใน session
+func (a *Authenticator) checkSession(ctx context.Context, claims *jwt.Claims, ip string) (domain.Session, error) {
+	sessionID, err := primitive.ObjectIDFromHex(claims.SessionID)
+	if err != nil {
+		return domain.Session{}, domain.ErrInvalidToken
+	}
+
+	session, err := a.sessions.FindByID(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, domain.ErrSessionNotFound) {
+			return session, domain.ErrInvalidToken
+		}
+		return session, err
+	}
+
+	now := time.Now()
+	if !session.IsActive(now) || session.UserID.Hex() != claims.UserID {
+		return session, domain.ErrInvalidToken
+	}
+
+	var actor string
+	if claims.Actor != nil {
+		actor = claims.Actor.Subject
+	}
+	if session.ImpersonatorID != nil && session.ImpersonatorID.Hex() != actor ||
+		session.ImpersonatorID == nil && actor != "" {
+		return session, domain.ErrInvalidToken
+	}
+
+	if now.Sub(session.LastSeenAt) >= lastUsedInterval || session.IP != ip {
+		if err := a.sessions.Touch(ctx, session.ID, ip); err != nil {
+			a.logger.WarnContext(ctx, "failed to record session activity", "session_id", session.ID.Hex(), "error", err)
+		}
+	}
+	return session, nil
+}
+
+func (a *Authenticator) authenticateAPIToken(ctx context.Context, credential, kind, prefix, ip string) (*domain.Principal, error) {
+	token, err := a.tokens.FindByPrefix(ctx, prefix)
+	if err != nil {
+		if errors.Is(err, domain.ErrTokenNotFound) {
+			return nil, domain.ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if token.Kind != kind || !token.IsActive(now) ||
+		subtle.ConstantTimeCompare([]byte(token.Hash), []byte(utils.HashToken(credential))) != 1 {
+		return nil, domain.ErrInvalidToken
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= lastUsedInterval || token.LastUsedIP != ip {
+		if err := a.tokens.TouchLastUsed(ctx, token.ID, ip); err != nil {
+			a.logger.WarnContext(ctx, "failed to record token usage", "token_id", token.ID.Hex(), "error", err)
+		}
+	}
+
+	method := domain.AuthMethodPersonalToken
+	if kind == domain.TokenKindService {
+		method = domain.AuthMethodServiceAPIKey
+	}
+	return &domain.Principal{
+		UserID:  token.UserID.Hex(),
+		Method:  method,
+		TokenID: token.ID.Hex(),
+		Scopes:  token.Scopes,
+	}, nil
+}
+
+// NewAPIToken สร้าง token ใหม่ในรูปแบบ bet_pat_<id>_<secret> หรือ bet_key_<id>_<secret>
+// คืนค่า token จริง (แสดงให้ผู้ใช้ครั้งเดียว) prefix สำหรับค้นหา และ hash สำหรับเก็บ
+func NewAPIToken(kind string) (raw, prefix, hash string, err error) {
+	marker := personalTokenPrefix
+	if kind == domain.TokenKindService {
+		marker = serviceKeyPrefix
+	}
+
+	id, err := utils.RandomToken(5)
+	if err != nil {
+		return "", "", "", err
+	}
+	secret, err := utils.RandomToken(32)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	prefix = marker + id
+	raw = prefix + "_" + secret
+	return raw, prefix, utils.HashToken(raw), nil
+}
+
+// parseAPIToken แยกชนิดและ prefix ออกจาก token ถ้าไม่ใช่รูปแบบ API token จะคืนค่า ok เป็น false
+func parseAPIToken(credential string) (kind, prefix string, ok bool) {
+	switch {
+	case strings.HasPrefix(credential, personalTokenPrefix):
+		kind = domain.TokenKindPersonal
+	case strings.HasPrefix(credential, serviceKeyPrefix):
+		kind = domain.TokenKindService
+	default:
+		return "", "", false
+	}
+
+	i := strings.LastIndex(credential, "_")
+	if i <= len(personalTokenPrefix) {
+		return "", "", false
+	}
+	return kind, credential[:i], true
+}
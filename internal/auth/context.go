@@ -0,0 +1,24 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/logger"
+)
+
+type principalKey struct{}
+
+// WithPrincipal แนบผู้เรียกที่ยืนยันตัวตนแล้วไว้ใน context และให้ log ของ request นี้มี user_id
+func WithPrincipal(ctx context.Context, p *domain.Principal) context.Context {
+	if p != nil {
+		ctx = logger.WithUserID(ctx, p.UserID)
+	}
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext อ่านผู้เรียกจาก context
+func PrincipalFromContext(ctx context.Context) (*domain.Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*domain.Principal)
+	return p, ok && p != nil
+}
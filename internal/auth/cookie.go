@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/pkg/jwt"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/utils"
+)
+
+const (
+	// CookieModeOff ส่ง JWT กลับใน body เท่านั้น (ค่าเริ่มต้น)
+	CookieModeOff = "off"
+	// CookieModeOn ส่ง JWT ใน cookie แบบ HttpOnly เท่านั้น client ฝั่ง browser อ่าน token ไม่ได้
+	CookieModeOn = "on"
+	// CookieModeBoth ส่งทั้ง cookie และ body ใช้ระหว่างย้าย client ไปใช้ cookie
+	CookieModeBoth = "both"
+)
+
+// CSRFHeader คือ header ที่ client ต้องส่งค่าจาก CSRF cookie กลับมาใน request ที่เปลี่ยนแปลงข้อมูล
+const CSRFHeader = "X-CSRF-Token"
+
+// CookieConfig กำหนดการส่ง JWT ผ่าน cookie สำหรับ browser
+type CookieConfig struct {
+	Mode     string
+	Name     string
+	CSRFName string
+	Domain   string
+	Path     string
+	Secure   bool
+	SameSite http.SameSite
+}
+
+// Enabled ตรวจสอบว่าเปิดใช้ cookie หรือไม่
+func (cfg CookieConfig) Enabled() bool {
+	return cfg.Mode == CookieModeOn || cfg.Mode == CookieModeBoth
+}
+
+// TokenInBody ตรวจสอบว่ายังต้องส่ง JWT กลับใน body ของ response หรือไม่
+func (cfg CookieConfig) TokenInBody() bool {
+	return cfg.Mode != CookieModeOn
+}
+
+// SetSession ตั้ง cookie ของ JWT (HttpOnly) และ CSRF token (ให้ JavaScript อ่านได้) คืนค่า CSRF token
+func (cfg CookieConfig) SetSession(w http.ResponseWriter, token, sessionID string, ttl time.Duration) (string, error) {
+	csrf, err := NewCSRFToken(sessionID)
+	if err != nil {
+		return "", err
+	}
+
+	maxAge := int(ttl.Seconds())
+	http.SetCookie(w, cfg.cookie(cfg.Name, token, maxAge, true))
+	http.SetCookie(w, cfg.cookie(cfg.CSRFName, csrf, maxAge, false))
+	return csrf, nil
+}
+
+// Clear ลบ cookie ของ JWT และ CSRF token
+func (cfg CookieConfig) Clear(w http.ResponseWriter) {
+	http.SetCookie(w, cfg.cookie(cfg.Name, "", -1, true))
+	http.SetCookie(w, cfg.cookie(cfg.CSRFName, "", -1, false))
+}
+
+func (cfg CookieConfig) cookie(name, value string, maxAge int, httpOnly bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     cfg.Path,
+		Domain:   cfg.Domain,
+		MaxAge:   maxAge,
+		Secure:   cfg.Secure,
+		HttpOnly: httpOnly,
+		SameSite: cfg.SameSite,
+	}
+}
+
+// NewCSRFToken สร้าง CSRF token ที่ผูกกับ session ในรูปแบบ <nonce>.<hmac>
+// การผูกกับ session ป้องกันไม่ให้ผู้โจมตีที่ตั้ง cookie ได้ (เช่นจาก subdomain) ใช้ token ของตัวเอง
+func NewCSRFToken(sessionID string) (string, error) {
+	nonce, err := utils.RandomToken(16)
+	if err != nil {
+		return "", err
+	}
+	mac, err := csrfMAC(sessionID, nonce)
+	if err != nil {
+		return "", err
+	}
+	return nonce + "." + mac, nil
+}
+
+// ValidCSRFToken ตรวจสอบว่า CSRF token ออกให้ session นี้
+func ValidCSRFToken(token, sessionID string) bool {
+	nonce, mac, ok := strings.Cut(token, ".")
+	if !ok || nonce == "" || sessionID == "" {
+		return false
+	}
+	expected, err := csrfMAC(sessionID, nonce)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal([]byte(mac), []byte(expected))
+}
+
+func csrfMAC(sessionID, nonce string) (string, error) {
+	secretKey, err := jwt.Secret()
+	if err != nil {
+		return "", err
+	}
+	h := hmac.New(sha256.New, []byte(secretKey))
+	h.Write([]byte("csrf:" + sessionID + ":" + nonce))
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,394 @@
+// Package config รวมการตั้งค่าทั้งหมดของ service ไว้ใน struct เดียว
+// ค่าถูกโหลดตามลำดับ ค่าเริ่มต้น → ไฟล์ YAML → environment variable → command-line flag
+// โดยแหล่งหลังทับแหล่งก่อน ค่าที่เป็นความลับอ่านจากไฟล์ได้ด้วย <ENV>_FILE
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/auth"
+	"github.com/Gsupakin/back_end_test_challeng/internal/requestlog"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/logger"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/redact"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/tracing"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Config คือการตั้งค่าของ service แต่ละ field มี tag
+//
+//	yaml   ชื่อ key ในไฟล์ YAML และเป็นส่วนหนึ่งของชื่อ flag (เช่น -mongo.database)
+//	env    ชื่อ environment variable
+//	secret "true" ถ้าอ่านจาก <env>_FILE ได้และไม่มี flag เพื่อไม่ให้ค่าโผล่ใน process list
+type Config struct {
+	Server    Server    `yaml:"server"`
+	Mongo     Mongo     `yaml:"mongo"`
+	Auth      Auth      `yaml:"auth"`
+	Cookie    Cookie    `yaml:"cookie"`
+	OIDC      OIDC      `yaml:"oidc"`
+	MFA       MFA       `yaml:"mfa"`
+	MagicLink MagicLink `yaml:"magic_link"`
+	SMTP      SMTP      `yaml:"smtp"`
+	Log       Log       `yaml:"log"`
+	Redact    Redact    `yaml:"redact"`
+	Tracing   Tracing   `yaml:"tracing"`
+	Health    Health    `yaml:"health"`
+	Users     Users     `yaml:"users"`
+}
+
+type Server struct {
+	HTTPAddr string `yaml:"http_addr" env:"HTTP_ADDR"`
+	GRPCAddr string `yaml:"grpc_addr" env:"GRPC_ADDR"`
+	// MetricsAddr คือ address ของ listener แยกที่ให้ Prometheus scrape /metrics ไม่เปิดบน HTTP_ADDR
+	MetricsAddr     string        `yaml:"metrics_addr" env:"METRICS_ADDR"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	// DrainDelay คือเวลาที่รอหลัง readiness เป็น draining ก่อนเริ่มปิด server
+	// เพื่อให้ load balancer เห็นสถานะใหม่และหยุดส่ง traffic มาก่อน
+	DrainDelay time.Duration `yaml:"drain_delay" env:"SHUTDOWN_DRAIN_DELAY"`
+	// UserCountInterval คือรอบการอัพเดท gauge backend_registered_users
+	UserCountInterval time.Duration `yaml:"user_count_interval" env:"USER_COUNT_INTERVAL"`
+}
+
+type Mongo struct {
+	URI            string        `yaml:"uri" env:"MONGODB_URI" secret:"true"`
+	Database       string        `yaml:"database" env:"MONGODB_DATABASE"`
+	ConnectTimeout time.Duration `yaml:"connect_timeout" env:"MONGODB_CONNECT_TIMEOUT"`
+	// SlowQuery คือเวลาที่คำสั่งนานเกินแล้วถูก log ระดับ warn
+	SlowQuery time.Duration `yaml:"slow_query" env:"MONGODB_SLOW_QUERY"`
+}
+
+type Auth struct {
+	JWTSecret  string        `yaml:"jwt_secret" env:"JWT_SECRET_KEY" secret:"true"`
+	SessionTTL time.Duration `yaml:"session_ttl" env:"AUTH_SESSION_TTL"`
+	BcryptCost int           `yaml:"bcrypt_cost" env:"BCRYPT_COST"`
+}
+
+type Cookie struct {
+	Mode     string `yaml:"mode" env:"AUTH_COOKIE_MODE"`
+	Name     string `yaml:"name" env:"AUTH_COOKIE_NAME"`
+	CSRFName string `yaml:"csrf_name" env:"AUTH_CSRF_COOKIE_NAME"`
+	Domain   string `yaml:"domain" env:"AUTH_COOKIE_DOMAIN"`
+	Secure   bool   `yaml:"secure" env:"AUTH_COOKIE_SECURE"`
+	SameSite string `yaml:"same_site" env:"AUTH_COOKIE_SAMESITE"`
+}
+
+type OIDC struct {
+	Issuer         string `yaml:"issuer" env:"OIDC_ISSUER"`
+	SigningKeyFile string `yaml:"signing_key_file" env:"OIDC_SIGNING_KEY_FILE"`
+	// Providers คือ JSON array ของ IdP ภายนอก มี client secret อยู่ด้วย
+	Providers string `yaml:"providers" env:"OIDC_PROVIDERS" secret:"true"`
+}
+
+type MFA struct {
+	Issuer string `yaml:"issuer" env:"MFA_ISSUER"`
+}
+
+type MagicLink struct {
+	URL string `yaml:"url" env:"MAGIC_LINK_URL"`
+}
+
+type SMTP struct {
+	Host     string `yaml:"host" env:"SMTP_HOST"`
+	Port     string `yaml:"port" env:"SMTP_PORT"`
+	Username string `yaml:"username" env:"SMTP_USERNAME"`
+	Password string `yaml:"password" env:"SMTP_PASSWORD" secret:"true"`
+	From     string `yaml:"from" env:"SMTP_FROM"`
+}
+
+type Log struct {
+	Format           string        `yaml:"format" env:"LOG_FORMAT"`
+	Level            string        `yaml:"level" env:"LOG_LEVEL"`
+	QueueSize        int           `yaml:"queue_size" env:"LOG_QUEUE_SIZE"`
+	BatchSize        int           `yaml:"batch_size" env:"LOG_BATCH_SIZE"`
+	FlushInterval    time.Duration `yaml:"flush_interval" env:"LOG_FLUSH_INTERVAL"`
+	QueuePolicy      string        `yaml:"queue_policy" env:"LOG_QUEUE_POLICY"`
+	BlockTimeout     time.Duration `yaml:"block_timeout" env:"LOG_BLOCK_TIMEOUT"`
+	RetentionDays    int           `yaml:"retention_days" env:"LOG_RETENTION_DAYS"`
+	ArchiveAfterDays int           `yaml:"archive_after_days" env:"LOG_ARCHIVE_AFTER_DAYS"`
+	ArchiveDir       string        `yaml:"archive_dir" env:"LOG_ARCHIVE_DIR"`
+	ArchiveInterval  time.Duration `yaml:"archive_interval" env:"LOG_ARCHIVE_INTERVAL"`
+}
+
+type Redact struct {
+	IP           string        `yaml:"ip" env:"LOG_REDACT_IP"`
+	UserAgent    string        `yaml:"user_agent" env:"LOG_REDACT_USER_AGENT"`
+	Query        string        `yaml:"query" env:"LOG_REDACT_QUERY"`
+	Error        string        `yaml:"error" env:"LOG_REDACT_ERROR"`
+	UserID       string        `yaml:"user_id" env:"LOG_REDACT_USER_ID"`
+	Path         string        `yaml:"path" env:"LOG_REDACT_PATH"`
+	Secret       string        `yaml:"secret" env:"LOG_REDACT_SECRET" secret:"true"`
+	SaltRotation time.Duration `yaml:"salt_rotation" env:"LOG_REDACT_SALT_ROTATION"`
+}
+
+type Tracing struct {
+	Exporter    string `yaml:"exporter" env:"OTEL_TRACES_EXPORTER"`
+	ServiceName string `yaml:"service_name" env:"OTEL_SERVICE_NAME"`
+	File        string `yaml:"file" env:"OTEL_TRACES_FILE"`
+}
+
+type Health struct {
+	CheckTimeout  time.Duration `yaml:"check_timeout" env:"HEALTH_CHECK_TIMEOUT"`
+	CheckInterval time.Duration `yaml:"check_interval" env:"HEALTH_CHECK_INTERVAL"`
+}
+
+type Users struct {
+	// PurgeAfterDays คือจำนวนวันหลัง soft delete ที่ผู้ใช้ถูกลบถาวร 0 หมายถึงไม่ลบ
+	PurgeAfterDays int           `yaml:"purge_after_days" env:"USER_PURGE_AFTER_DAYS"`
+	PurgeInterval  time.Duration `yaml:"purge_interval" env:"USER_PURGE_INTERVAL"`
+	// ReactivateInterval คือความถี่ที่เปิดใช้งานผู้ใช้ที่การระงับชั่วคราวหมดเวลาแล้ว
+	ReactivateInterval time.Duration `yaml:"reactivate_interval" env:"USER_REACTIVATE_INTERVAL"`
+}
+
+// Default คืนค่าเริ่มต้นของทุก field ยกเว้นค่าที่ต้องตั้งเอง (MONGODB_URI และ JWT_SECRET_KEY)
+func Default() Config {
+	return Config{
+		Server: Server{
+			HTTPAddr:          ":8080",
+			GRPCAddr:          ":50051",
+			MetricsAddr:       "127.0.0.1:9090",
+			ShutdownTimeout:   10 * time.Second,
+			DrainDelay:        5 * time.Second,
+			UserCountInterval: 10 * time.Second,
+		},
+		Mongo: Mongo{
+			Database:       "Test",
+			ConnectTimeout: 10 * time.Second,
+			SlowQuery:      500 * time.Millisecond,
+		},
+		Auth: Auth{
+			SessionTTL: 24 * time.Hour,
+			BcryptCost: 14,
+		},
+		Cookie: Cookie{
+			Mode:     "off",
+			Name:     "access_token",
+			CSRFName: "csrf_token",
+			Secure:   true,
+			SameSite: "lax",
+		},
+		OIDC:      OIDC{Issuer: "http://localhost:8080"},
+		MFA:       MFA{Issuer: "Backend Test Challenge"},
+		MagicLink: MagicLink{URL: "http://localhost:8080/login/magic-link"},
+		SMTP:      SMTP{Port: "587"},
+		Log: Log{
+			Format:          "json",
+			Level:           "info",
+			QueueSize:       10000,
+			BatchSize:       500,
+			FlushInterval:   time.Second,
+			QueuePolicy:     "drop",
+			BlockTimeout:    100 * time.Millisecond,
+			ArchiveDir:      "./archive/request_logs",
+			ArchiveInterval: time.Hour,
+		},
+		Redact: Redact{
+			IP:           "truncate",
+			UserAgent:    "normalize",
+			Query:        "mask",
+			Error:        "mask",
+			UserID:       "keep",
+			Path:         "keep",
+			SaltRotation: 24 * time.Hour,
+		},
+		Tracing: Tracing{
+			Exporter:    "none",
+			ServiceName: "user-service",
+			File:        "traces.json",
+		},
+		Health: Health{
+			CheckTimeout:  2 * time.Second,
+			CheckInterval: 5 * time.Second,
+		},
+		Users: Users{PurgeInterval: time.Hour, ReactivateInterval: time.Minute},
+	}
+}
+
+// Validate ตรวจค่าที่ service ต้องใช้ตอนเริ่มโปรแกรม และรวม error ทุกตัวไว้ในครั้งเดียว
+// ค่าของ subsystem ตรวจด้วย Validate ของแต่ละส่วน ซึ่ง CLI ที่ใช้เพียงบางส่วนเรียกเองได้
+func (c *Config) Validate() error {
+	var v validator
+	if c.Server.HTTPAddr == "" {
+		v.add("HTTP_ADDR must not be empty")
+	}
+	if c.Server.GRPCAddr == "" {
+		v.add("GRPC_ADDR must not be empty")
+	}
+	if c.Server.MetricsAddr == "" {
+		v.add("METRICS_ADDR must not be empty")
+	} else if c.Server.MetricsAddr == c.Server.HTTPAddr {
+		v.add("METRICS_ADDR must differ from HTTP_ADDR so /metrics is not public")
+	}
+	v.positive("SHUTDOWN_TIMEOUT", c.Server.ShutdownTimeout)
+	if c.Server.DrainDelay < 0 {
+		v.add("SHUTDOWN_DRAIN_DELAY must not be negative")
+	}
+	v.positive("USER_COUNT_INTERVAL", c.Server.UserCountInterval)
+
+	v.required("MONGODB_URI", c.Mongo.URI)
+	if c.Mongo.Database == "" {
+		v.add("MONGODB_DATABASE must not be empty")
+	}
+	v.positive("MONGODB_CONNECT_TIMEOUT", c.Mongo.ConnectTimeout)
+	v.positive("MONGODB_SLOW_QUERY", c.Mongo.SlowQuery)
+
+	v.required("JWT_SECRET_KEY", c.Auth.JWTSecret)
+	v.positive("AUTH_SESSION_TTL", c.Auth.SessionTTL)
+	if c.Auth.BcryptCost < bcrypt.MinCost || c.Auth.BcryptCost > bcrypt.MaxCost {
+		v.add("BCRYPT_COST must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+
+	v.join(c.Cookie.Validate())
+	v.join(c.SMTP.Validate())
+	v.join(c.Log.Validate())
+	v.join(c.Redact.Validate())
+	v.join(c.Tracing.Validate())
+
+	v.positive("HEALTH_CHECK_TIMEOUT", c.Health.CheckTimeout)
+	v.positive("HEALTH_CHECK_INTERVAL", c.Health.CheckInterval)
+
+	if c.Users.PurgeAfterDays < 0 {
+		v.add("USER_PURGE_AFTER_DAYS must not be negative")
+	}
+	v.positive("USER_PURGE_INTERVAL", c.Users.PurgeInterval)
+	v.positive("USER_REACTIVATE_INTERVAL", c.Users.ReactivateInterval)
+	return v.err()
+}
+
+// Validate ตรวจการตั้งค่า cookie ของ session
+func (c Cookie) Validate() error {
+	var v validator
+	v.oneOf("AUTH_COOKIE_MODE", c.Mode, auth.CookieModeOff, auth.CookieModeOn, auth.CookieModeBoth)
+	if c.Name == "" {
+		v.add("AUTH_COOKIE_NAME must not be empty")
+	}
+	if c.CSRFName == "" {
+		v.add("AUTH_CSRF_COOKIE_NAME must not be empty")
+	}
+	v.oneOf("AUTH_COOKIE_SAMESITE", c.SameSite, "lax", "strict", "none")
+	if strings.EqualFold(c.SameSite, "none") && !c.Secure {
+		v.add("AUTH_COOKIE_SAMESITE=none requires AUTH_COOKIE_SECURE=true")
+	}
+	return v.err()
+}
+
+// Validate ตรวจ port ของ SMTP เมื่อตั้ง SMTP_HOST
+func (s SMTP) Validate() error {
+	if s.Host == "" {
+		return nil
+	}
+	if port, err := strconv.Atoi(s.Port); err != nil || port < 1 || port > 65535 {
+		return errors.New("SMTP_PORT must be a port number between 1 and 65535")
+	}
+	return nil
+}
+
+// Validate ตรวจการตั้งค่า log ของ service, คิวของ request log และการ archive
+func (l Log) Validate() error {
+	var v validator
+	v.oneOf("LOG_FORMAT", l.Format, logger.FormatJSON, logger.FormatText)
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(l.Level)); err != nil {
+		v.add("LOG_LEVEL must be debug, info, warn or error")
+	}
+	if l.QueueSize <= 0 {
+		v.add("LOG_QUEUE_SIZE must be positive")
+	}
+	if l.BatchSize <= 0 {
+		v.add("LOG_BATCH_SIZE must be positive")
+	}
+	v.positive("LOG_FLUSH_INTERVAL", l.FlushInterval)
+	v.oneOf("LOG_QUEUE_POLICY", l.QueuePolicy, requestlog.PolicyDrop, requestlog.PolicyBlock)
+	if l.BlockTimeout < 0 {
+		v.add("LOG_BLOCK_TIMEOUT must not be negative")
+	}
+	if l.RetentionDays < 0 {
+		v.add("LOG_RETENTION_DAYS must not be negative")
+	}
+	if l.ArchiveAfterDays < 0 {
+		v.add("LOG_ARCHIVE_AFTER_DAYS must not be negative")
+	}
+	// ถ้า TTL สั้นกว่าอายุที่ archive log จะถูกลบก่อนได้ archive
+	if l.RetentionDays > 0 && l.ArchiveAfterDays > 0 && l.RetentionDays <= l.ArchiveAfterDays {
+		v.add("LOG_RETENTION_DAYS must be greater than LOG_ARCHIVE_AFTER_DAYS")
+	}
+	if l.ArchiveDir == "" {
+		v.add("LOG_ARCHIVE_DIR must not be empty")
+	}
+	v.positive("LOG_ARCHIVE_INTERVAL", l.ArchiveInterval)
+	return v.err()
+}
+
+// Validate ตรวจว่าแต่ละ field ใช้ mode ที่ redact รองรับ
+func (r Redact) Validate() error {
+	var v validator
+	for _, f := range []struct{ env, mode string }{
+		{"LOG_REDACT_IP", r.IP},
+		{"LOG_REDACT_USER_AGENT", r.UserAgent},
+		{"LOG_REDACT_QUERY", r.Query},
+		{"LOG_REDACT_ERROR", r.Error},
+		{"LOG_REDACT_USER_ID", r.UserID},
+		{"LOG_REDACT_PATH", r.Path},
+	} {
+		v.oneOf(f.env, f.mode, redact.AllowedModes(f.env)...)
+	}
+	v.positive("LOG_REDACT_SALT_ROTATION", r.SaltRotation)
+	return v.err()
+}
+
+// Validate ตรวจ exporter และค่าที่ exporter นั้นต้องใช้
+func (t Tracing) Validate() error {
+	var v validator
+	v.oneOf("OTEL_TRACES_EXPORTER", t.Exporter, tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterStdout, tracing.ExporterFile)
+	if t.ServiceName == "" {
+		v.add("OTEL_SERVICE_NAME must not be empty")
+	}
+	if strings.EqualFold(t.Exporter, tracing.ExporterFile) && t.File == "" {
+		v.add("OTEL_TRACES_FILE is required when OTEL_TRACES_EXPORTER=file")
+	}
+	return v.err()
+}
+
+// validator สะสม error ของการตรวจค่าเพื่อรายงานทั้งหมดในครั้งเดียว
+type validator struct {
+	errs []error
+}
+
+func (v *validator) add(format string, args ...interface{}) {
+	v.errs = append(v.errs, fmt.Errorf(format, args...))
+}
+
+func (v *validator) join(err error) {
+	if err != nil {
+		v.errs = append(v.errs, err)
+	}
+}
+
+func (v *validator) required(env, value string) {
+	if value == "" {
+		v.add("%s is required (set %s or %s_FILE)", env, env, env)
+	}
+}
+
+func (v *validator) positive(env string, d time.Duration) {
+	if d <= 0 {
+		v.add("%s must be a positive duration", env)
+	}
+}
+
+// oneOf ตรวจค่าแบบไม่สนตัวพิมพ์เล็กใหญ่ เหมือนที่ subsystem อ่านค่า
+func (v *validator) oneOf(env, value string, allowed ...string) {
+	for _, a := range allowed {
+		if strings.EqualFold(value, a) {
+			return
+		}
+	}
+	v.add("%s must be one of %s", env, strings.Join(allowed, ", "))
+}
+
+func (v *validator) err() error {
+	return errors.Join(v.errs...)
+}
@@ -0,0 +1,177 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv คือ environment variable ที่ชี้ไปยังไฟล์ YAML ใช้แทน flag -config ได้
+const FileEnv = "CONFIG_FILE"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// field คือค่าหนึ่งค่าใน Config ที่ตั้งได้จากทุกแหล่ง
+type field struct {
+	flag   string // เช่น mongo.database
+	env    string
+	secret bool
+	value  reflect.Value
+}
+
+// fields คืนทุก field ที่มี tag env ของ cfg เรียงตามลำดับใน struct
+func fields(cfg *Config) []field {
+	var out []field
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			name := prefix + sf.Tag.Get("yaml")
+			if sf.Type.Kind() == reflect.Struct && sf.Type != durationType {
+				walk(v.Field(i), name+".")
+				continue
+			}
+			if env := sf.Tag.Get("env"); env != "" {
+				out = append(out, field{flag: name, env: env, secret: sf.Tag.Get("secret") == "true", value: v.Field(i)})
+			}
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem(), "")
+	return out
+}
+
+// Load โหลด Config จากค่าเริ่มต้น, ไฟล์ YAML (-config หรือ CONFIG_FILE), environment variable และ args ตามลำดับ
+// args คือ command-line flag เช่น os.Args[1:] ทุก field ที่ไม่ใช่ความลับมี flag ชื่อตาม path ใน YAML
+// เช่น -server.http_addr และ -mongo.database
+// Load ไม่ตรวจว่าค่าที่จำเป็นครบหรือไม่ ให้เรียก Validate ต่อ
+// ถ้ามี -h จะพิมพ์รายการ flag ลง stderr แล้วคืน flag.ErrHelp
+func Load(args []string) (*Config, error) {
+	cfg := Default()
+	all := fields(&cfg)
+
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	file := fs.String("config", os.Getenv(FileEnv), "path to a YAML config file ("+FileEnv+")")
+	for _, f := range all {
+		if !f.secret {
+			fs.String(f.flag, format(f.value), f.env)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *file != "" {
+		if err := loadFile(&cfg, *file); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, f := range all {
+		if err := fromEnv(f); err != nil {
+			return nil, err
+		}
+	}
+
+	var flagErr error
+	fs.Visit(func(fl *flag.Flag) {
+		for _, f := range all {
+			if f.flag == fl.Name && flagErr == nil {
+				if err := set(f.value, fl.Value.String()); err != nil {
+					flagErr = fmt.Errorf("-%s: %w", fl.Name, err)
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, flagErr
+	}
+	return &cfg, nil
+}
+
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	// key ที่สะกดผิดต้องเป็น error ไม่ใช่ถูกเงียบหายไป
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// fromEnv ตั้งค่าจาก <env> หรือจากเนื้อหาไฟล์ที่ <env>_FILE ชี้ไปสำหรับค่าที่เป็นความลับ
+func fromEnv(f field) error {
+	raw := os.Getenv(f.env)
+	if f.secret {
+		if path := os.Getenv(f.env + "_FILE"); path != "" {
+			if raw != "" {
+				return fmt.Errorf("%s and %s_FILE must not both be set", f.env, f.env)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("%s_FILE: %w", f.env, err)
+			}
+			// ไฟล์ secret ของ Docker/Kubernetes มักมีบรรทัดว่างท้ายไฟล์
+			raw = strings.TrimRight(string(data), "\r\n")
+		}
+	}
+	if raw == "" {
+		return nil
+	}
+	if err := set(f.value, raw); err != nil {
+		return fmt.Errorf("%s: %w", f.env, err)
+	}
+	return nil
+}
+
+func set(v reflect.Value, raw string) error {
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration such as 30s or 5m", raw)
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", raw)
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", raw)
+		}
+		v.SetBool(b)
+	default:
+		v.SetString(raw)
+	}
+	return nil
+}
+
+func format(v reflect.Value) string {
+	switch {
+	case v.Type() == durationType:
+		return time.Duration(v.Int()).String()
+	case v.Kind() == reflect.Int:
+		return strconv.FormatInt(v.Int(), 10)
+	case v.Kind() == reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	default:
+		return v.String()
+	}
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/auth"
+	"github.com/Gsupakin/back_end_test_challeng/internal/requestlog"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/logger"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/mailer"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/redact"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/tracing"
+)
+
+// ฟังก์ชันในไฟล์นี้แปลงแต่ละส่วนของ Config เป็นค่าที่ subsystem ใช้
+// ค่าต้องผ่าน Validate ของส่วนนั้นแล้ว ค่าที่ไม่ถูกต้องจะถูกแทนด้วยค่าเริ่มต้นของ subsystem
+
+// CookieConfig คืนการตั้งค่า cookie ของ session
+func (c Cookie) CookieConfig() auth.CookieConfig {
+	cfg := auth.CookieConfig{
+		Mode:     strings.ToLower(c.Mode),
+		Name:     c.Name,
+		CSRFName: c.CSRFName,
+		Domain:   c.Domain,
+		Path:     "/",
+		Secure:   c.Secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+	switch strings.ToLower(c.SameSite) {
+	case "strict":
+		cfg.SameSite = http.SameSiteStrictMode
+	case "none":
+		cfg.SameSite = http.SameSiteNoneMode
+	}
+	return cfg
+}
+
+// Mailer คืน Mailer ที่ส่งผ่าน SMTP หรือเขียนอีเมลลง l ถ้าไม่ได้ตั้ง SMTP_HOST
+func (s SMTP) Mailer(l *slog.Logger) mailer.Mailer {
+	return mailer.New(l, s.Host, s.Port, s.Username, s.Password, s.From)
+}
+
+// LoggerConfig คืนรูปแบบและระดับของ log ของ service
+func (l Log) LoggerConfig() logger.Config {
+	cfg := logger.DefaultConfig()
+	cfg.Format = strings.ToLower(l.Format)
+	if err := cfg.Level.UnmarshalText([]byte(l.Level)); err != nil {
+		cfg.Level = slog.LevelInfo
+	}
+	return cfg
+}
+
+// WriterConfig คืนการตั้งค่าคิวและการบันทึกเป็นชุดของ request log
+func (l Log) WriterConfig() requestlog.Config {
+	cfg := requestlog.DefaultConfig()
+	cfg.QueueSize = l.QueueSize
+	cfg.BatchSize = l.BatchSize
+	cfg.FlushInterval = l.FlushInterval
+	cfg.Policy = strings.ToLower(l.QueuePolicy)
+	cfg.BlockTimeout = l.BlockTimeout
+	return cfg
+}
+
+// ArchiveConfig คืนการตั้งค่าการเก็บรักษาและ archive request log
+func (l Log) ArchiveConfig() requestlog.ArchiveConfig {
+	return requestlog.ArchiveConfig{
+		Retention:    time.Duration(l.RetentionDays) * 24 * time.Hour,
+		ArchiveAfter: time.Duration(l.ArchiveAfterDays) * 24 * time.Hour,
+		Dir:          l.ArchiveDir,
+		Interval:     l.ArchiveInterval,
+	}
+}
+
+// Policy คืน policy ของการลดข้อมูลส่วนบุคคลราย field
+func (r Redact) Policy() redact.Policy {
+	return redact.Policy{
+		IP:        strings.ToLower(r.IP),
+		UserAgent: strings.ToLower(r.UserAgent),
+		Query:     strings.ToLower(r.Query),
+		Error:     strings.ToLower(r.Error),
+		UserID:    strings.ToLower(r.UserID),
+		Path:      strings.ToLower(r.Path),
+	}
+}
+
+// Redactor สร้าง Redactor ตาม Policy, LOG_REDACT_SECRET และ LOG_REDACT_SALT_ROTATION
+func (r Redact) Redactor() *redact.Redactor {
+	return redact.New(r.Policy(), []byte(r.Secret), r.SaltRotation)
+}
+
+// TracingConfig คืน exporter และชื่อ service ของ tracing
+func (t Tracing) TracingConfig() tracing.Config {
+	return tracing.Config{
+		Exporter:    strings.ToLower(t.Exporter),
+		ServiceName: t.ServiceName,
+		File:        t.File,
+	}
+}
@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// TokenKindPersonal คือ personal access token ที่ผู้ใช้สร้างให้ตัวเอง
+	TokenKindPersonal = "personal"
+	// TokenKindService คือ API key ที่ admin สร้างให้ service account
+	TokenKindService = "service"
+)
+
+const (
+	ScopeUsersRead  = "users:read"
+	ScopeUsersWrite = "users:write"
+	ScopeAdmin      = "admin"
+)
+
+// Scopes คือ scope ทั้งหมดที่กำหนดให้ token ได้
+var Scopes = []string{ScopeUsersRead, ScopeUsersWrite, ScopeAdmin}
+
+// IsValidScope ตรวจสอบว่าเป็น scope ที่ระบบรู้จัก
+func IsValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIToken แทน personal access token หรือ service API key
+// token จริงแสดงให้ผู้ใช้เห็นเพียงครั้งเดียว ฐานข้อมูลเก็บเฉพาะ prefix และ hash
+type APIToken struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Kind       string             `json:"kind" bson:"kind"`
+	Name       string             `json:"name" bson:"name"`
+	Prefix     string             `json:"prefix" bson:"prefix"` // ใช้ค้นหา token โดยไม่ต้องเก็บค่าจริง
+	Hash       string             `json:"-" bson:"hash"`
+	UserID     primitive.ObjectID `json:"user_id" bson:"user_id"` // ผู้ใช้ที่ token ทำงานแทน
+	CreatedBy  primitive.ObjectID `json:"created_by" bson:"created_by"`
+	Scopes     []string           `json:"scopes" bson:"scopes"`
+	ExpiresAt  *time.Time         `json:"expires_at,omitempty" bson:"expires_at,omitempty"`
+	LastUsedAt *time.Time         `json:"last_used_at,omitempty" bson:"last_used_at,omitempty"`
+	LastUsedIP string             `json:"last_used_ip,omitempty" bson:"last_used_ip,omitempty"`
+	CreatedAt  time.Time          `json:"created_at" bson:"created_at"`
+	RevokedAt  *time.Time         `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+}
+
+// IsActive ตรวจสอบว่า token ยังไม่ถูกยกเลิกและยังไม่หมดอายุ
+func (t *APIToken) IsActive(now time.Time) bool {
+	if t.RevokedAt != nil {
+		return false
+	}
+	return t.ExpiresAt == nil || now.Before(*t.ExpiresAt)
+}
@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	AuditUserCreate         = "user.create"
+	AuditUserUpdate         = "user.update"
+	AuditUserDelete         = "user.delete"
+	AuditUserRestore        = "user.restore"
+	AuditUserLogin          = "user.login"
+	AuditIdentityLink       = "user.identity_link"
+	AuditIdentityUnlink     = "user.identity_unlink"
+	AuditImpersonationStart = "impersonation.start"
+	AuditSessionRevoke      = "session.revoke"
+	// AuditUserStatusPrefix ต่อด้วยชื่อการเปลี่ยนสถานะ เช่น user.suspend หรือ user.ban
+	AuditUserStatusPrefix = "user."
+)
+
+const (
+	AuditSourceHTTP = "http"
+	AuditSourceGRPC = "grpc"
+	AuditSourceCLI  = "cli"
+)
+
+// AuditEvent คือบันทึกการเปลี่ยนแปลงข้อมูลผู้ใช้หนึ่งครั้ง บันทึกแล้วแก้ไขหรือลบไม่ได้
+type AuditEvent struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Timestamp      time.Time          `json:"timestamp" bson:"timestamp"`
+	Action         string             `json:"action" bson:"action"`
+	ActorID        string             `json:"actor_id,omitempty" bson:"actor_id,omitempty"`               // ว่างถ้าไม่ได้ล็อกอิน เช่นสมัครสมาชิก
+	ImpersonatorID string             `json:"impersonator_id,omitempty" bson:"impersonator_id,omitempty"` // admin ที่สวมสิทธิ์ ActorID อยู่
+	TargetUserID   string             `json:"target_user_id" bson:"target_user_id"`
+	Changes        []FieldChange      `json:"changes,omitempty" bson:"changes,omitempty"`
+	Source         string             `json:"source" bson:"source"`
+	RequestID      string             `json:"request_id,omitempty" bson:"request_id,omitempty"`
+	IP             string             `json:"ip,omitempty" bson:"ip,omitempty"`
+}
+
+// FieldChange คือค่าก่อนและหลังของ field ที่เปลี่ยน ค่าที่เป็นความลับจะถูกแทนด้วย [REDACTED]
+type FieldChange struct {
+	Field string      `json:"field" bson:"field"`
+	Old   interface{} `json:"old,omitempty" bson:"old,omitempty"`
+	New   interface{} `json:"new,omitempty" bson:"new,omitempty"`
+}
+
+// AuditFilter คือเงื่อนไขค้นหา audit log ค่าว่างหมายถึงไม่กรอง
+type AuditFilter struct {
+	UserID string
+	// ActorID ค้นหาทั้งผู้กระทำและ admin ที่สวมสิทธิ์
+	ActorID string
+	From    *time.Time
+	To      *time.Time
+	Page    int
+	Limit   int
+}
@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// สถานะของคำขอลบข้อมูลส่วนบุคคล
+const (
+	ErasurePending   = "pending"
+	ErasureRejected  = "rejected"
+	ErasureCompleted = "completed"
+)
+
+// ErasureStatuses คือสถานะทั้งหมดของคำขอลบข้อมูล
+var ErasureStatuses = []string{ErasurePending, ErasureRejected, ErasureCompleted}
+
+// ErasureRequest คือคำขอลบข้อมูลส่วนบุคคลของผู้ใช้อย่างถาวร ต้องมี admin ที่ไม่ใช่ผู้ยื่นคำขออนุมัติก่อนจึงจะลบจริง
+// คำขอถูกเก็บไว้หลังลบเสร็จเพื่อเป็นหลักฐาน จึงเก็บเฉพาะ ID ของผู้ใช้ซึ่งไม่ใช่ข้อมูลส่วนบุคคล
+type ErasureRequest struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserID      primitive.ObjectID `json:"user_id" bson:"user_id"`
+	RequestedBy primitive.ObjectID `json:"requested_by" bson:"requested_by"` // ผู้ใช้เอง, admin ที่ยื่นแทน หรือ NilObjectID ถ้าระบบลบเองหลังครบระยะเวลาเก็บ
+	Reason      string             `json:"reason,omitempty" bson:"reason,omitempty"`
+	Status      string             `json:"status" bson:"status"`
+	RequestedAt time.Time          `json:"requested_at" bson:"requested_at"`
+	// DecidedBy คือ admin ที่อนุมัติหรือปฏิเสธ และ Note คือเหตุผลที่ปฏิเสธ
+	DecidedBy *primitive.ObjectID `json:"decided_by,omitempty" bson:"decided_by,omitempty"`
+	DecidedAt *time.Time          `json:"decided_at,omitempty" bson:"decided_at,omitempty"`
+	Note      string              `json:"note,omitempty" bson:"note,omitempty"`
+	Receipt   *ErasureReceipt     `json:"receipt,omitempty" bson:"receipt,omitempty"`
+}
+
+// ErasureReceipt คือหลักฐานว่าข้อมูลของผู้ใช้ถูกลบแล้ว
+// Collections คือจำนวนเอกสารที่ถูกลบหรือแทนที่ ID ด้วยนามแฝงในแต่ละ collection
+// นามแฝงที่ใช้ไม่ถูกเก็บไว้ที่ใด จึงย้อนกลับไปหาผู้ใช้เดิมไม่ได้
+// Limitations คือข้อมูลที่การลบหาไม่เจอ เช่น request log ที่ user_id ถูกแฮชด้วย salt ช่วงก่อน
+type ErasureReceipt struct {
+	ErasedAt    time.Time        `json:"erased_at" bson:"erased_at"`
+	Collections map[string]int64 `json:"collections" bson:"collections"`
+	Limitations []string         `json:"limitations,omitempty" bson:"limitations,omitempty"`
+}
+
+// ErasureHashedLogsLimitation ถูกบันทึกใน receipt เมื่อ LOG_REDACT_USER_ID=hash
+const ErasureHashedLogsLimitation = "request_logs: user_id hashed with an earlier LOG_REDACT_SALT_ROTATION salt cannot be matched to the user and was left unchanged"
+
+// Pseudonym คือค่าที่ใช้แทน ID ของผู้ใช้ที่ถูกลบใน collection อื่น
+// field ที่เป็น ObjectID ใช้ ID ซึ่งสุ่มใหม่ ส่วน field ที่เป็น string ใช้ String() ที่ขึ้นต้นด้วย "erased:"
+type Pseudonym primitive.ObjectID
+
+// NewPseudonym สุ่มนามแฝงใหม่ที่ไม่ซ้ำกับผู้ใช้คนใด
+func NewPseudonym() Pseudonym {
+	return Pseudonym(primitive.NewObjectID())
+}
+
+// ID คืนนามแฝงสำหรับ field ที่เป็น ObjectID
+func (p Pseudonym) ID() primitive.ObjectID {
+	return primitive.ObjectID(p)
+}
+
+// String คืนนามแฝงสำหรับ field ที่เป็น string เช่น user_id ของ request log
+func (p Pseudonym) String() string {
+	return "erased:" + primitive.ObjectID(p).Hex()
+}
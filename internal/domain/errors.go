@@ -7,6 +7,7 @@ var (
 	ErrUserNotFound      = errors.New("ไม่พบผู้ใช้ในระบบ")
 	ErrUserAlreadyExists = errors.New("มีผู้ใช้นี้ในระบบแล้ว")
 	ErrUserInactive      = errors.New("บัญชีผู้ใช้ถูกระงับการใช้งาน")
+	ErrInvalidTransition = errors.New("เปลี่ยนสถานะผู้ใช้แบบนี้ไม่ได้")
 	ErrInvalidPassword   = errors.New("รหัสผ่านไม่ถูกต้อง")
 	ErrInvalidEmail      = errors.New("อีเมลไม่ถูกต้อง")
 	ErrInvalidName       = errors.New("ชื่อไม่ถูกต้อง")
@@ -15,10 +16,28 @@ var (
 	ErrUnauthorized     = errors.New("กรุณาเข้าสู่ระบบ")
 	ErrInvalidToken     = errors.New("โทเค็นไม่ถูกต้องหรือหมดอายุ")
 	ErrPermissionDenied = errors.New("ไม่มีสิทธิ์เข้าถึง")
+	ErrTokenNotFound    = errors.New("ไม่พบ token ในระบบ")
+	ErrSessionNotFound  = errors.New("ไม่พบ session ในระบบ")
+
+	// ข้อผิดพลาดเกี่ยวกับการยืนยันตัวตนแบบหลายขั้นตอน
+	ErrMFARequired       = errors.New("กรุณายืนยันรหัสจากแอพ authenticator")
+	ErrMFANotEnrolled    = errors.New("ยังไม่ได้ลงทะเบียนการยืนยันตัวตนแบบหลายขั้นตอน")
+	ErrMFAAlreadyEnabled = errors.New("เปิดใช้งานการยืนยันตัวตนแบบหลายขั้นตอนอยู่แล้ว")
+	ErrInvalidMFACode    = errors.New("รหัสยืนยันไม่ถูกต้อง")
+
+	// ข้อผิดพลาดเกี่ยวกับ OpenID Connect
+	ErrClientNotFound = errors.New("ไม่พบ client ในระบบ")
+	ErrIdentityLinked = errors.New("บัญชีภายนอกนี้ถูกผูกกับผู้ใช้อื่นแล้ว")
+
+	// ข้อผิดพลาดเกี่ยวกับการลบข้อมูลส่วนบุคคล
+	ErrErasureNotFound = errors.New("ไม่พบคำขอลบข้อมูลในระบบ")
+	ErrErasurePending  = errors.New("มีคำขอลบข้อมูลของผู้ใช้นี้รอการอนุมัติอยู่แล้ว")
+	ErrErasureDecided  = errors.New("คำขอลบข้อมูลนี้ถูกดำเนินการไปแล้ว")
 
 	// ข้อผิดพลาดเกี่ยวกับฐานข้อมูล
 	ErrDatabaseConnection = errors.New("ไม่สามารถเชื่อมต่อกับฐานข้อมูลได้")
 	ErrDatabaseOperation  = errors.New("เกิดข้อผิดพลาดในการทำงานกับฐานข้อมูล")
+	ErrLogNotFound        = errors.New("ไม่พบ request log ในระบบ")
 
 	// ข้อผิดพลาดทั่วไป
 	ErrInvalidInput       = errors.New("ข้อมูลไม่ถูกต้อง")
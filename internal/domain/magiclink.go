@@ -0,0 +1,20 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MagicLink แทนลิงก์เข้าสู่ระบบแบบใช้ครั้งเดียวที่ส่งทางอีเมล
+// ID ของเอกสารถูกใช้เป็น jti ของ token ที่ฝังในลิงก์
+type MagicLink struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	UserID      primitive.ObjectID `bson:"user_id"`
+	Fingerprint string             `bson:"fingerprint"` // hash ของ user agent + IP prefix ของผู้ขอ
+	IP          string             `bson:"ip"`
+	CreatedAt   time.Time          `bson:"created_at"`
+	ExpiresAt   time.Time          `bson:"expires_at"`
+	UsedAt      *time.Time         `bson:"used_at"`
+	RevokedAt   *time.Time         `bson:"revoked_at"`
+}
@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OAuthClient แทนแอพที่ลงทะเบียนใช้บริการนี้เป็น OpenID Connect provider
+type OAuthClient struct {
+	ID           primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	ClientID     string             `json:"client_id" bson:"client_id"`
+	SecretHash   string             `json:"-" bson:"secret_hash,omitempty"` // ว่างสำหรับ public client
+	Name         string             `json:"name" bson:"name"`
+	RedirectURIs []string           `json:"redirect_uris" bson:"redirect_uris"`
+	Scopes       []string           `json:"scopes" bson:"scopes"`
+	Public       bool               `json:"public" bson:"public"`
+	CreatedBy    primitive.ObjectID `json:"created_by" bson:"created_by"`
+	CreatedAt    time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// HasRedirectURI ตรวจสอบว่า redirect URI ตรงกับที่ลงทะเบียนไว้ทุกตัวอักษร
+func (c *OAuthClient) HasRedirectURI(uri string) bool {
+	for _, registered := range c.RedirectURIs {
+		if registered == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsScope ตรวจสอบว่าทุก scope ที่ขอ (คั่นด้วยช่องว่าง) ได้รับอนุญาตให้ client นี้
+func (c *OAuthClient) AllowsScope(scope string) bool {
+	allowed := make(map[string]bool, len(c.Scopes))
+	for _, s := range c.Scopes {
+		allowed[s] = true
+	}
+	for _, s := range strings.Fields(scope) {
+		if !allowed[s] {
+			return false
+		}
+	}
+	return true
+}
+
+// AuthorizationCode แทน authorization code ที่รอ client นำไปแลก token
+// เก็บเฉพาะ hash ของ code ไว้ในฐานข้อมูล
+type AuthorizationCode struct {
+	ID                  primitive.ObjectID `bson:"_id,omitempty"`
+	CodeHash            string             `bson:"code_hash"`
+	ClientID            string             `bson:"client_id"`
+	UserID              primitive.ObjectID `bson:"user_id"`
+	RedirectURI         string             `bson:"redirect_uri"`
+	Scope               string             `bson:"scope"`
+	Nonce               string             `bson:"nonce,omitempty"`
+	CodeChallenge       string             `bson:"code_challenge"`
+	CodeChallengeMethod string             `bson:"code_challenge_method"`
+	AuthTime            time.Time          `bson:"auth_time"`
+	CreatedAt           time.Time          `bson:"created_at"`
+	ExpiresAt           time.Time          `bson:"expires_at"`
+	UsedAt              *time.Time         `bson:"used_at"`
+}
@@ -0,0 +1,44 @@
+package domain
+
+const (
+	AuthMethodJWT           = "jwt"
+	AuthMethodPersonalToken = "personal_access_token"
+	AuthMethodServiceAPIKey = "api_key"
+	AuthMethodImpersonation = "impersonation"
+)
+
+// Principal คือผู้เรียก API ที่ยืนยันตัวตนแล้ว ไม่ว่าจะด้วย JWT หรือ token สำหรับเครื่อง
+type Principal struct {
+	UserID  string
+	Method  string
+	TokenID string
+	// SessionID คือ session ของ JWT จากการล็อกอิน ว่างสำหรับ token สำหรับเครื่อง
+	SessionID string
+	// ActorID คือ admin ที่สวมสิทธิ์ผู้ใช้นี้อยู่ ว่างถ้าผู้ใช้เรียกเอง
+	ActorID string
+	// Scopes เป็น nil สำหรับ JWT จากการล็อกอิน ซึ่งมีสิทธิ์เท่ากับตัวผู้ใช้
+	Scopes []string
+}
+
+// IsInteractive ตรวจสอบว่ายืนยันตัวตนด้วยการล็อกอินของผู้ใช้เอง ไม่ใช่ token สำหรับเครื่อง
+func (p *Principal) IsInteractive() bool {
+	return p.Method == AuthMethodJWT
+}
+
+// IsImpersonated ตรวจสอบว่า admin กำลังสวมสิทธิ์ผู้ใช้นี้อยู่
+func (p *Principal) IsImpersonated() bool {
+	return p.ActorID != ""
+}
+
+// HasScope ตรวจสอบสิทธิ์ของ token
+func (p *Principal) HasScope(scope string) bool {
+	if p.Scopes == nil {
+		return true
+	}
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
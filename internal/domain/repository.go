@@ -2,6 +2,7 @@ package domain
 
 import (
 	"context"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -9,16 +10,139 @@ import (
 // UserRepository defines the interface for user data operations
 type UserRepository interface {
 	Create(ctx context.Context, user User) (primitive.ObjectID, error)
+	// FindByEmail และ FindByName ไม่นับผู้ใช้ที่ถูก soft delete อีเมลและชื่อของผู้ใช้เหล่านั้นจึงนำไปสมัครใหม่ได้
 	FindByEmail(ctx context.Context, email string) (User, error)
 	FindByName(ctx context.Context, name string) (User, error)
 	FindByID(ctx context.Context, id primitive.ObjectID) (User, error)
 	FindAll(ctx context.Context) ([]User, error)
 	Update(ctx context.Context, id primitive.ObjectID, update map[string]interface{}) error
 	Delete(ctx context.Context, id primitive.ObjectID) error
+	// Restore นำผู้ใช้ที่ถูก soft delete กลับมา คืน ErrUserNotFound ถ้าไม่มีผู้ใช้ที่ถูกลบด้วย id นี้
+	// และคืน ErrUserAlreadyExists ถ้าอีเมลหรือชื่อถูกผู้ใช้คนอื่นใช้ไปแล้ว
+	Restore(ctx context.Context, id primitive.ObjectID) error
+	// ChangeStatus เปลี่ยนสถานะของผู้ใช้ที่ยังไม่ถูกลบและมีสถานะเป็น change.From อยู่
+	// คืน ErrUserNotFound ถ้าไม่พบผู้ใช้ และ ErrInvalidTransition ถ้าสถานะถูกเปลี่ยนไปก่อนแล้ว
+	ChangeStatus(ctx context.Context, id primitive.ObjectID, change StatusChange) error
+	// UseTOTPStep บันทึกว่าใช้รหัส TOTP ของ step นี้แล้ว เฉพาะเมื่อ step ใหม่กว่า step ที่ใช้ล่าสุด
+	// คืน ErrInvalidMFACode ถ้ารหัสของ step นี้หรือ step ที่ใหม่กว่าถูกใช้ไปก่อนแล้ว
+	UseTOTPStep(ctx context.Context, id primitive.ObjectID, step int64) error
+	// UseRecoveryCode ลบ recovery code (SHA-256 hash) ออกจากผู้ใช้ คืน ErrInvalidMFACode ถ้าไม่มีหรือถูกใช้ไปแล้ว
+	UseRecoveryCode(ctx context.Context, id primitive.ObjectID, hash string) error
+	// StartMFAChallenge ตั้ง challenge ของการล็อกอินขั้นที่สองแทน challenge เดิม และล้างจำนวนครั้งที่กรอกผิด
+	StartMFAChallenge(ctx context.Context, id primitive.ObjectID, challengeID string) error
+	// FailMFAChallenge นับการกรอกรหัสผิดของ challenge และยกเลิก challenge เมื่อผิดครบ limit ครั้ง
+	// คืน ErrInvalidToken ถ้า challenge ไม่ใช่ challenge ปัจจุบันของผู้ใช้
+	FailMFAChallenge(ctx context.Context, id primitive.ObjectID, challengeID string, limit int) error
+	// EndMFAChallenge ยกเลิก challenge หลังใช้สำเร็จ คืน ErrInvalidToken ถ้าถูกใช้หรือยกเลิกไปก่อนแล้ว
+	EndMFAChallenge(ctx context.Context, id primitive.ObjectID, challengeID string) error
+	// Search คืนผู้ใช้ตาม filter เรียงตามวันที่สร้าง
+	Search(ctx context.Context, filter UserFilter) ([]User, error)
+	// Each เรียก fn กับผู้ใช้ทีละคนตาม filter จาก cursor โดยไม่โหลดทั้งหมดไว้ในหน่วยความจำ
+	// ผู้ใช้ที่ส่งให้ fn ไม่มีรหัสผ่านและ secret ของ MFA ถ้า fn คืน error จะหยุดและคืน error นั้น
+	Each(ctx context.Context, filter UserFilter, fn func(User) error) error
+	// Count คืนจำนวนผู้ใช้ที่ยังไม่ถูกลบ
 	Count(ctx context.Context) (int64, error)
+	FindByLinkedIdentity(ctx context.Context, issuer, subject string) (User, error)
+	AddLinkedIdentity(ctx context.Context, id primitive.ObjectID, identity LinkedIdentity) error
+	RemoveLinkedIdentity(ctx context.Context, id primitive.ObjectID, issuer, subject string) error
 }
 
 // LogRepository defines the interface for request log operations
 type LogRepository interface {
 	Create(ctx context.Context, log RequestLog) error
+	CreateMany(ctx context.Context, logs []RequestLog) error
+	Find(ctx context.Context, filter LogFilter) ([]RequestLog, int64, error)
+	Stats(ctx context.Context, filter LogStatsFilter) ([]RouteStats, error)
+	// EachByUser calls fn for every log sent by or on behalf of the user (user_id
+	// or impersonator_id equal to one of userKeys, see redact.Redactor.UserIDKeys),
+	// oldest first, without loading them all into memory.
+	EachByUser(ctx context.Context, userKeys []string, fn func(RequestLog) error) error
+}
+
+// LogArchiveRepository defines the operations used to archive old request logs
+// to files and restore them. Ranges are [from, to).
+type LogArchiveRepository interface {
+	// Oldest returns the timestamp of the oldest log, or ErrLogNotFound if there are none.
+	Oldest(ctx context.Context) (time.Time, error)
+	CountRange(ctx context.Context, from, to time.Time) (int64, error)
+	EachInRange(ctx context.Context, from, to time.Time, fn func(RequestLog) error) error
+	DeleteRange(ctx context.Context, from, to time.Time) (int64, error)
+	// Restore inserts archived logs, skipping ones whose ID already exists, and
+	// returns how many were inserted.
+	Restore(ctx context.Context, logs []RequestLog) (int64, error)
+}
+
+// MagicLinkRepository defines the interface for magic-link login tokens
+type MagicLinkRepository interface {
+	Create(ctx context.Context, link MagicLink) (primitive.ObjectID, error)
+	// Consume marks an unused, unrevoked and unexpired link as used and returns it.
+	// The device fingerprint must match the one recorded when the link was requested.
+	Consume(ctx context.Context, id primitive.ObjectID, fingerprint string) (MagicLink, error)
+	RevokeAllForUser(ctx context.Context, userID primitive.ObjectID) (int64, error)
+}
+
+// OAuthClientRepository defines the interface for registered OIDC clients
+type OAuthClientRepository interface {
+	Create(ctx context.Context, client OAuthClient) (primitive.ObjectID, error)
+	FindByClientID(ctx context.Context, clientID string) (OAuthClient, error)
+	FindAll(ctx context.Context) ([]OAuthClient, error)
+	Delete(ctx context.Context, clientID string) error
+}
+
+// AuthorizationCodeRepository defines the interface for OIDC authorization codes
+type AuthorizationCodeRepository interface {
+	Create(ctx context.Context, code AuthorizationCode) error
+	// Consume marks an unused and unexpired code as used and returns it.
+	Consume(ctx context.Context, codeHash string) (AuthorizationCode, error)
+}
+
+// APITokenRepository defines the interface for personal access tokens and service API keys
+type APITokenRepository interface {
+	Create(ctx context.Context, token APIToken) (primitive.ObjectID, error)
+	FindByID(ctx context.Context, id primitive.ObjectID) (APIToken, error)
+	FindByPrefix(ctx context.Context, prefix string) (APIToken, error)
+	FindByUser(ctx context.Context, userID primitive.ObjectID, kind string) ([]APIToken, error)
+	FindByKind(ctx context.Context, kind string) ([]APIToken, error)
+	Revoke(ctx context.Context, id primitive.ObjectID) error
+	TouchLastUsed(ctx context.Context, id primitive.ObjectID, ip string) error
+}
+
+// SessionRepository defines the interface for login sessions
+type SessionRepository interface {
+	Create(ctx context.Context, session Session) (primitive.ObjectID, error)
+	FindByID(ctx context.Context, id primitive.ObjectID) (Session, error)
+	FindActiveByUser(ctx context.Context, userID primitive.ObjectID) ([]Session, error)
+	Touch(ctx context.Context, id primitive.ObjectID, ip string) error
+	Revoke(ctx context.Context, id primitive.ObjectID) error
+}
+
+// ErasureRepository defines the interface for personal data erasure requests
+type ErasureRepository interface {
+	// Create returns ErrErasurePending if the user already has a pending request.
+	Create(ctx context.Context, request ErasureRequest) (primitive.ObjectID, error)
+	FindByID(ctx context.Context, id primitive.ObjectID) (ErasureRequest, error)
+	// Find returns requests with the given status, or all requests if status is
+	// empty, newest first.
+	Find(ctx context.Context, status string) ([]ErasureRequest, error)
+	// Reject and Complete only change pending requests and return
+	// ErrErasureDecided otherwise.
+	Reject(ctx context.Context, id, adminID primitive.ObjectID, note string) error
+	Complete(ctx context.Context, id, adminID primitive.ObjectID, receipt ErasureReceipt) error
+}
+
+// PersonalDataEraser permanently removes a user and their personal data
+type PersonalDataEraser interface {
+	// Erase deletes the user document and data that only belongs to the user,
+	// and replaces the user's ID with pseudonym in records that must be kept.
+	// It returns the number of affected documents per collection and is safe to
+	// run again after a partial failure.
+	Erase(ctx context.Context, userID primitive.ObjectID, pseudonym Pseudonym) (map[string]int64, error)
+	// Limitations describes data Erase cannot find, to be kept in the receipt.
+	Limitations() []string
+}
+
+// AuditRepository defines the interface for the append-only audit log
+type AuditRepository interface {
+	Create(ctx context.Context, event AuditEvent) error
+	Find(ctx context.Context, filter AuditFilter) ([]AuditEvent, int64, error)
 }
@@ -1,15 +1,130 @@
 package domain
 
 import (
+	"math"
+	"sort"
 	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ช่องทางของ request ที่ถูกบันทึก
+const (
+	ProtocolHTTP = "http"
+	ProtocolGRPC = "grpc"
 )
 
 type RequestLog struct {
-	Method    string    `bson:"method"`
-	Path      string    `bson:"path"`
-	Status    int       `bson:"status"`
-	LatencyMS int64     `bson:"latency_ms"`
-	IP        string    `bson:"ip"`
-	UserAgent string    `bson:"user_agent"`
-	Timestamp time.Time `bson:"timestamp"`
+	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	RequestID      string             `json:"request_id,omitempty" bson:"request_id,omitempty"`
+	Protocol       string             `json:"protocol,omitempty" bson:"protocol,omitempty"`
+	Method         string             `json:"method" bson:"method"`
+	Path           string             `json:"path" bson:"path"`
+	Route          string             `json:"route,omitempty" bson:"route,omitempty"` // route template เช่น /users/:id ใช้จัดกลุ่มแทน path จริง
+	Query          string             `json:"query,omitempty" bson:"query,omitempty"`
+	Status         int                `json:"status" bson:"status"`
+	LatencyMS      int64              `json:"latency_ms" bson:"latency_ms"`
+	RequestSize    int64              `json:"request_size" bson:"request_size"`
+	ResponseSize   int64              `json:"response_size" bson:"response_size"`
+	Error          string             `json:"error,omitempty" bson:"error,omitempty"`
+	IP             string             `json:"ip" bson:"ip"`
+	UserAgent      string             `json:"user_agent" bson:"user_agent"`
+	Timestamp      time.Time          `json:"timestamp" bson:"timestamp"`
+	UserID         string             `json:"user_id,omitempty" bson:"user_id,omitempty"`
+	ImpersonatorID string             `json:"impersonator_id,omitempty" bson:"impersonator_id,omitempty"` // admin ที่ส่ง request นี้ในนามของ UserID
+	GRPCMethod     string             `json:"grpc_method,omitempty" bson:"grpc_method,omitempty"`
+	GRPCCode       string             `json:"grpc_code,omitempty" bson:"grpc_code,omitempty"`
+}
+
+// ช่วงเวลาที่ใช้รวมสถิติ
+const (
+	LogBucketMinute = "minute"
+	LogBucketHour   = "hour"
+	LogBucketDay    = "day"
+)
+
+// LogFilter คือเงื่อนไขค้นหา request log ค่าว่างหมายถึงไม่กรอง
+type LogFilter struct {
+	Path      string // prefix ของ path
+	StatusMin int
+	StatusMax int
+	IP        string
+	UserID    string
+	RequestID string
+	From      *time.Time
+	To        *time.Time
+	Page      int
+	Limit     int
+}
+
+// LogStatsFilter คือช่วงเวลาและขนาด bucket สำหรับสรุปสถิติ
+type LogStatsFilter struct {
+	From   time.Time
+	To     time.Time
+	Bucket string
+}
+
+// RouteStats คือสถิติของหนึ่ง route ในหนึ่ง bucket
+// latency percentile ใช้วิธี nearest-rank บน histogram ของ latency (ดู LatencyBinOf)
+type RouteStats struct {
+	Method       string    `bson:"method" json:"method"`
+	Route        string    `bson:"route" json:"route"`
+	BucketStart  time.Time `bson:"bucket_start" json:"bucket_start"`
+	Count        int64     `bson:"count" json:"count"`
+	ClientErrors int64     `bson:"client_errors" json:"client_errors"`
+	ServerErrors int64     `bson:"server_errors" json:"server_errors"`
+	ErrorRate    float64   `bson:"error_rate" json:"error_rate"` // สัดส่วน 5xx ต่อ request ทั้งหมด ไม่นับ 4xx
+	P50          int64     `bson:"p50_ms" json:"p50_ms"`
+	P95          int64     `bson:"p95_ms" json:"p95_ms"`
+	P99          int64     `bson:"p99_ms" json:"p99_ms"`
+}
+
+// latencyBinGrowth คืออัตราส่วนความกว้างของ bin ที่ติดกันใน histogram ของ latency
+// percentile ที่ได้จึงคลาดเคลื่อนจากค่าจริงไม่เกินราว 1%
+const latencyBinGrowth = 1.01
+
+// LatencyBin คือจำนวน request ที่ latency ตกอยู่ใน bin เดียวกัน
+// Max คือ latency สูงสุดที่พบจริงใน bin นั้น
+type LatencyBin struct {
+	Bin   int   `bson:"bin"`
+	Count int64 `bson:"count"`
+	Max   int64 `bson:"max"`
+}
+
+// LatencyBinOf คืนหมายเลข bin ของ latency (มิลลิวินาที) ใน histogram แบบ log scale
+// ค่าต่ำกว่าราว 100ms ได้ bin ละค่า จำนวน bin จึงจำกัดอยู่ที่ไม่กี่พันแม้ latency จะสูงมาก
+func LatencyBinOf(ms int64) int {
+	if ms < 0 {
+		ms = 0
+	}
+	return int(math.Floor(math.Log(float64(ms)+1) / math.Log(latencyBinGrowth)))
+}
+
+// LatencyBinDivisor คือตัวหารที่ pipeline ของฐานข้อมูลใช้คำนวณ bin ให้ตรงกับ LatencyBinOf
+func LatencyBinDivisor() float64 {
+	return math.Log(latencyBinGrowth)
+}
+
+// LatencyPercentile คืน latency ที่อันดับ floor(p * (n-1)) ของ histogram
+// โดยใช้ค่าสูงสุดของ bin ที่อันดับนั้นตกอยู่ คืน 0 ถ้า histogram ว่าง
+func LatencyPercentile(bins []LatencyBin, p float64) int64 {
+	sorted := append([]LatencyBin(nil), bins...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Bin < sorted[j].Bin })
+
+	var total int64
+	for _, b := range sorted {
+		total += b.Count
+	}
+	if total == 0 {
+		return 0
+	}
+	rank := int64(p * float64(total-1))
+	var seen int64
+	for _, b := range sorted {
+		seen += b.Count
+		if rank < seen {
+			return b.Max
+		}
+	}
+	return sorted[len(sorted)-1].Max
 }
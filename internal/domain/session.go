@@ -0,0 +1,29 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session แทนการล็อกอินหนึ่งครั้งจากอุปกรณ์หนึ่ง JWT ที่ออกให้จะผูกกับ session ผ่าน claim sid
+type Session struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserID     primitive.ObjectID `json:"user_id" bson:"user_id"`
+	Device     string             `json:"device" bson:"device"` // เช่น "Chrome on macOS"
+	UserAgent  string             `json:"user_agent" bson:"user_agent"`
+	IP         string             `json:"ip" bson:"ip"`
+	CreatedAt  time.Time          `json:"created_at" bson:"created_at"`
+	LastSeenAt time.Time          `json:"last_seen_at" bson:"last_seen_at"`
+	ExpiresAt  time.Time          `json:"expires_at" bson:"expires_at"`
+	RevokedAt  *time.Time         `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+	// ImpersonatorID คือ admin ที่สวมสิทธิ์ผู้ใช้ใน session นี้ และ Reason คือเหตุผลที่แจ้งไว้
+	ImpersonatorID *primitive.ObjectID `json:"impersonator_id,omitempty" bson:"impersonator_id,omitempty"`
+	Reason         string              `json:"reason,omitempty" bson:"reason,omitempty"`
+	Current        bool                `json:"current" bson:"-"` // session ของ request ปัจจุบัน
+}
+
+// IsActive ตรวจสอบว่า session ยังไม่ถูกยกเลิกและยังไม่หมดอายุ
+func (s *Session) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
@@ -0,0 +1,132 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// UserStatus คือสถานะในวงจรชีวิตของผู้ใช้ เปลี่ยนได้ตาม Transitions เท่านั้น
+type UserStatus string
+
+const (
+	StatusPending   UserStatus = "pending"
+	StatusActive    UserStatus = "active"
+	StatusSuspended UserStatus = "suspended"
+	StatusBanned    UserStatus = "banned"
+	// StatusDeleted ไม่ถูกเก็บใน field status แต่แทนผู้ใช้ที่มี deleted_at
+	StatusDeleted UserStatus = "deleted"
+)
+
+// ชื่อของการเปลี่ยนสถานะ ใช้เป็นชื่อ endpoint และต่อท้าย action ของ audit event (user.<ชื่อ>)
+const (
+	TransitionActivate   = "activate"
+	TransitionSuspend    = "suspend"
+	TransitionReactivate = "reactivate"
+	TransitionBan        = "ban"
+	TransitionDelete     = "delete"
+)
+
+// Transition คือการเปลี่ยนสถานะที่อนุญาตหนึ่งแบบ
+type Transition struct {
+	Action string
+	From   UserStatus
+	To     UserStatus
+}
+
+// Transitions คือตารางการเปลี่ยนสถานะทั้งหมด ผู้ใช้ทุกสถานะถูกลบ (soft delete) ได้
+// banned เปลี่ยนกลับไม่ได้นอกจากลบ
+var Transitions = []Transition{
+	{Action: TransitionActivate, From: StatusPending, To: StatusActive},
+	{Action: TransitionSuspend, From: StatusActive, To: StatusSuspended},
+	{Action: TransitionReactivate, From: StatusSuspended, To: StatusActive},
+	{Action: TransitionBan, From: StatusActive, To: StatusBanned},
+	{Action: TransitionDelete, From: StatusPending, To: StatusDeleted},
+	{Action: TransitionDelete, From: StatusActive, To: StatusDeleted},
+	{Action: TransitionDelete, From: StatusSuspended, To: StatusDeleted},
+	{Action: TransitionDelete, From: StatusBanned, To: StatusDeleted},
+}
+
+// InitialStatuses คือสถานะที่ผู้ใช้ใหม่เริ่มได้
+var InitialStatuses = []UserStatus{StatusPending, StatusActive}
+
+// IsInitial ตรวจสอบว่าผู้ใช้ใหม่เริ่มด้วยสถานะนี้ได้หรือไม่
+func (s UserStatus) IsInitial() bool {
+	for _, initial := range InitialStatuses {
+		if s == initial {
+			return true
+		}
+	}
+	return false
+}
+
+// FindTransition คืนการเปลี่ยนสถานะจาก from ด้วย action
+func FindTransition(from UserStatus, action string) (Transition, error) {
+	known := false
+	for _, t := range Transitions {
+		if t.Action != action {
+			continue
+		}
+		known = true
+		if t.From == from {
+			return t, nil
+		}
+	}
+	if !known {
+		return Transition{}, fmt.Errorf("%w: unknown status action %q", ErrInvalidInput, action)
+	}
+	return Transition{}, fmt.Errorf("%w: cannot %s a %s user", ErrInvalidTransition, action, from)
+}
+
+// TransitionTo คืนการเปลี่ยนสถานะจาก from ไป to
+func TransitionTo(from, to UserStatus) (Transition, error) {
+	for _, t := range Transitions {
+		if t.From == from && t.To == to {
+			return t, nil
+		}
+	}
+	return Transition{}, fmt.Errorf("%w: cannot change status from %s to %s", ErrInvalidTransition, from, to)
+}
+
+// StatusChange คือการเปลี่ยนสถานะหนึ่งครั้งพร้อมเหตุผล Until ใช้กับการระงับชั่วคราวเท่านั้น
+// ผู้ใช้จะกลับเป็น active เองเมื่อถึงเวลานั้น nil หมายถึงระงับจนกว่า admin จะเปิดใช้งานคืน
+type StatusChange struct {
+	Transition
+	Reason string
+	Until  *time.Time
+	At     time.Time
+}
+
+// Validate ตรวจเหตุผลและเวลาสิ้นสุดการระงับ
+func (c StatusChange) Validate() error {
+	if strings.TrimSpace(c.Reason) == "" {
+		return fmt.Errorf("%w: reason is required", ErrInvalidInput)
+	}
+	if c.Until != nil {
+		if c.To != StatusSuspended {
+			return fmt.Errorf("%w: until is only allowed when suspending", ErrInvalidInput)
+		}
+		if !c.Until.After(c.At) {
+			return fmt.Errorf("%w: until must be in the future", ErrInvalidInput)
+		}
+	}
+	return nil
+}
+
+// AppliesTo ตรวจว่าเปลี่ยนสถานะของ u ได้หรือไม่ เมื่อสถานะที่บันทึกไว้หรือสถานะ ณ เวลา At ตรงกับ From
+// ผู้ใช้ที่การระงับหมดเวลาแล้วจึงถูกระงับหรือแบนใหม่ได้ทันที และงานเปิดใช้งานคืนอัตโนมัติก็ยังเปลี่ยนจาก suspended ได้
+func (c StatusChange) AppliesTo(u User) bool {
+	return u.Status == c.From || u.EffectiveStatus(c.At) == c.From
+}
+
+// EffectiveStatus คืนสถานะ ณ เวลา now ผู้ใช้ที่ถูกลบคือ StatusDeleted
+// และการระงับที่หมดเวลาแล้วนับเป็น active แม้งานเปิดใช้งานคืนอัตโนมัติจะยังไม่ได้รัน
+func (u *User) EffectiveStatus(now time.Time) UserStatus {
+	switch {
+	case u.DeletedAt != nil:
+		return StatusDeleted
+	case u.Status == StatusSuspended && u.StatusUntil != nil && !now.Before(*u.StatusUntil):
+		return StatusActive
+	}
+	return u.Status
+}
@@ -6,18 +6,77 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Roles และ Statuses คือค่าทั้งหมดที่ตั้งให้ผู้ใช้ได้
+var (
+	Roles    = []string{RoleUser, RoleAdmin}
+	Statuses = []string{string(StatusPending), string(StatusActive), string(StatusSuspended), string(StatusBanned)}
+)
+
+// IsValidRole ตรวจสอบว่าเป็น role ที่ระบบรู้จัก
+func IsValidRole(role string) bool {
+	return contains(Roles, role)
+}
+
+// IsValidStatus ตรวจสอบว่าเป็น status ที่ระบบรู้จัก
+func IsValidStatus(status string) bool {
+	return contains(Statuses, status)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 // User แทนข้อมูลผู้ใช้ในระบบ
 type User struct {
-	ID        primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
-	Name      string             `json:"name" bson:"name" validate:"required"`
-	Email     string             `json:"email" bson:"email" validate:"required,email"`
-	Password  string             `json:"password" bson:"password" validate:"required"` // ไม่แสดงใน JSON
-	Role      string             `json:"role" bson:"role"`                             // เพิ่ม role
-	Status    string             `json:"status" bson:"status"`                         // เพิ่ม status
-	LastLogin *time.Time         `json:"last_login,omitempty" bson:"last_login,omitempty"`
-	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
-	UpdatedAt *time.Time         `json:"updated_at" bson:"updated_at"`
-	DeletedAt *time.Time         `json:"deleted_at" bson:"deleted_at"`
+	ID       primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Name     string             `json:"name" bson:"name" validate:"required"`
+	Email    string             `json:"email" bson:"email" validate:"required,email"`
+	Password string             `json:"password" bson:"password" validate:"required"` // ไม่แสดงใน JSON
+	Role     string             `json:"role" bson:"role"`                             // เพิ่ม role
+	Status   UserStatus         `json:"status" bson:"status"`
+	// StatusReason และ StatusUntil มาจากการเปลี่ยนสถานะครั้งล่าสุด StatusUntil คือเวลาสิ้นสุดการระงับชั่วคราว
+	StatusReason     string           `json:"status_reason,omitempty" bson:"status_reason,omitempty"`
+	StatusUntil      *time.Time       `json:"status_until,omitempty" bson:"status_until,omitempty"`
+	StatusChangedAt  *time.Time       `json:"status_changed_at,omitempty" bson:"status_changed_at,omitempty"`
+	MFA              MFASettings      `json:"mfa" bson:"mfa"`
+	LinkedIdentities []LinkedIdentity `json:"linked_identities,omitempty" bson:"linked_identities,omitempty"` // บัญชีจาก OIDC provider ภายนอก
+	LastLogin        *time.Time       `json:"last_login,omitempty" bson:"last_login,omitempty"`
+	CreatedAt        time.Time        `json:"created_at" bson:"created_at"`
+	UpdatedAt        *time.Time       `json:"updated_at" bson:"updated_at"`
+	DeletedAt        *time.Time       `json:"deleted_at" bson:"deleted_at"`
+}
+
+// MFASettings เก็บสถานะการลงทะเบียน TOTP ของผู้ใช้
+// secret และ recovery codes จะไม่ถูกส่งออกไปใน JSON
+type MFASettings struct {
+	Enabled       bool     `json:"enabled" bson:"enabled"`
+	Secret        string   `json:"-" bson:"secret,omitempty"`
+	PendingSecret string   `json:"-" bson:"pending_secret,omitempty"`
+	RecoveryCodes []string `json:"-" bson:"recovery_codes,omitempty"` // เก็บเป็น SHA-256 hash
+	LastUsedStep  int64    `json:"-" bson:"last_used_step,omitempty"`
+	// ChallengeID คือ challenge ของการล็อกอินขั้นที่สองที่ยังใช้ได้ ChallengeFailures คือจำนวนครั้งที่กรอกรหัสผิด
+	ChallengeID       string     `json:"-" bson:"challenge_id,omitempty"`
+	ChallengeFailures int        `json:"-" bson:"challenge_failures,omitempty"`
+	EnrolledAt        *time.Time `json:"enrolled_at,omitempty" bson:"enrolled_at,omitempty"`
+}
+
+// LinkedIdentity แทนบัญชีจาก OIDC provider ภายนอกที่ผูกกับผู้ใช้
+type LinkedIdentity struct {
+	Provider string    `json:"provider" bson:"provider"`
+	Issuer   string    `json:"issuer" bson:"issuer"`
+	Subject  string    `json:"subject" bson:"subject"`
+	Email    string    `json:"email,omitempty" bson:"email,omitempty"`
+	LinkedAt time.Time `json:"linked_at" bson:"linked_at"`
 }
 
 // NewUser สร้างผู้ใช้ใหม่
@@ -27,21 +86,21 @@ func NewUser(name, email, password string) *User {
 		Name:      name,
 		Email:     email,
 		Password:  password,
-		Role:      "user",   // ค่าเริ่มต้น
-		Status:    "active", // ค่าเริ่มต้น
+		Role:      RoleUser,     // ค่าเริ่มต้น
+		Status:    StatusActive, // ค่าเริ่มต้น
 		CreatedAt: now,
 		UpdatedAt: &now,
 	}
 }
 
-// IsActive ตรวจสอบว่าผู้ใช้ยังใช้งานอยู่หรือไม่
+// IsActive ตรวจสอบว่าผู้ใช้ยังใช้งานอยู่หรือไม่ ผู้ใช้ที่ไม่ active ล็อกอินและใช้ token ไม่ได้
 func (u *User) IsActive() bool {
-	return u.Status == "active" && u.DeletedAt == nil
+	return u.EffectiveStatus(time.Now()) == StatusActive
 }
 
 // IsAdmin ตรวจสอบว่าเป็นผู้ดูแลระบบหรือไม่
 func (u *User) IsAdmin() bool {
-	return u.Role == "admin"
+	return u.Role == RoleAdmin
 }
 
 // UpdateLastLogin อัพเดทเวลาล็อกอินล่าสุด
@@ -55,6 +114,32 @@ func (u *User) UpdateLastLogin() {
 func (u *User) SoftDelete() {
 	now := time.Now()
 	u.DeletedAt = &now
-	u.Status = "inactive"
 	u.UpdatedAt = &now
 }
+
+// RequiresMFA ตรวจสอบว่าผู้ใช้ต้องยืนยันตัวตนขั้นที่สองตอนล็อกอินหรือไม่
+func (u *User) RequiresMFA() bool {
+	return u.MFA.Enabled && u.MFA.Secret != ""
+}
+
+// UserFilter คือเงื่อนไขค้นหาผู้ใช้ ค่าว่างหมายถึงไม่กรอง
+type UserFilter struct {
+	// ID ใช้หาผู้ใช้คนเดียวรวมถึงคนที่ถูกลบแล้วเมื่อใช้คู่กับ IncludeDeleted
+	ID primitive.ObjectID
+	// Query ค้นหาบางส่วนของชื่อหรืออีเมลโดยไม่สนตัวพิมพ์เล็กใหญ่ ส่วน Email ต้องตรงทั้งหมด
+	Query  string
+	Email  string
+	Role   string
+	Status UserStatus
+	// StatusUntilBefore เลือกผู้ใช้ที่การระงับชั่วคราวสิ้นสุดก่อนเวลานี้
+	StatusUntilBefore *time.Time
+	// CreatedFrom และ CreatedTo กรองวันที่สร้างในช่วง [CreatedFrom, CreatedTo)
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	// IncludeDeleted รวมผู้ใช้ที่ถูก soft delete ด้วย
+	IncludeDeleted bool
+	// DeletedBefore เลือกเฉพาะผู้ใช้ที่ถูก soft delete ก่อนเวลานี้ ไม่ต้องตั้ง IncludeDeleted
+	DeletedBefore *time.Time
+	// Limit เป็น 0 หมายถึงไม่จำกัด
+	Limit int
+}
@@ -0,0 +1,143 @@
+package grpc
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/auth"
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/internal/requestlog"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/requestid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// callInfoKey carries a *callInfo that inner interceptors fill in, so the
+// logging interceptor (which runs outermost) can see who made the call
+type callInfoKey struct{}
+
+type callInfo struct {
+	principal *domain.Principal
+}
+
+// RequestIDInterceptor propagates the caller's x-request-id metadata or generates
+// a new ID, stores it in the context and echoes it back in the response header
+func RequestIDInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	id := requestid.Resolve(requestid.FromIncomingMetadata(ctx))
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestid.MetadataKey, id))
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("request.id", id))
+	return handler(requestid.WithID(ctx, id), req)
+}
+
+// LoggingInterceptor records every RPC in the same request log pipeline as HTTP requests
+// and writes one structured record per call to logger, like middleware.ConsoleLogger
+func LoggingInterceptor(writer *requestlog.Writer, logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		call := &callInfo{}
+		resp, err := handler(context.WithValue(ctx, callInfoKey{}, call), req)
+		duration := time.Since(start)
+
+		code := status.Code(err)
+		entry := domain.RequestLog{
+			RequestID:    requestid.FromContext(ctx),
+			Protocol:     domain.ProtocolGRPC,
+			Method:       http.MethodPost,
+			Path:         info.FullMethod,
+			Route:        info.FullMethod,
+			Status:       httpStatusFromCode(code),
+			LatencyMS:    duration.Milliseconds(),
+			RequestSize:  messageSize(req),
+			ResponseSize: messageSize(resp),
+			IP:           peerIP(ctx),
+			Timestamp:    time.Now(),
+			GRPCMethod:   info.FullMethod,
+			GRPCCode:     code.String(),
+		}
+		if err != nil {
+			entry.Error = status.Convert(err).Message()
+		}
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if ua := md.Get("user-agent"); len(ua) > 0 {
+				entry.UserAgent = ua[0]
+			}
+		}
+		if call.principal != nil {
+			entry.UserID = call.principal.UserID
+			entry.ImpersonatorID = call.principal.ActorID
+		}
+
+		writer.Write(entry)
+
+		logCtx := ctx
+		level := slog.LevelInfo
+		if call.principal != nil {
+			logCtx = auth.WithPrincipal(ctx, call.principal)
+		}
+		if entry.Status >= 500 {
+			level = slog.LevelError
+		} else if entry.Status >= 400 {
+			level = slog.LevelWarn
+		}
+		logger.LogAttrs(logCtx, level, "grpc request",
+			slog.String("method", info.FullMethod),
+			slog.String("code", entry.GRPCCode),
+			slog.Int64("latency_ms", entry.LatencyMS),
+			slog.String("ip", writer.Redactor().IP(entry.IP)),
+		)
+		return resp, err
+	}
+}
+
+// recordPrincipal tells the logging interceptor which principal made the call
+func recordPrincipal(ctx context.Context, principal *domain.Principal) {
+	if call, ok := ctx.Value(callInfoKey{}).(*callInfo); ok {
+		call.principal = principal
+	}
+}
+
+func messageSize(msg interface{}) int64 {
+	m, ok := msg.(proto.Message)
+	if !ok || m == nil {
+		return 0
+	}
+	return int64(proto.Size(m))
+}
+
+// httpStatusFromCode maps gRPC codes to the equivalent HTTP status so RPCs and
+// HTTP requests can be filtered and aggregated together
+func httpStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,19 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/pkg/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// MetricsInterceptor counts RPCs by method and status code and records their latency
+func MetricsInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	metrics.GRPCRequests.WithLabelValues(info.FullMethod, status.Code(err).String()).Inc()
+	metrics.GRPCDuration.WithLabelValues(info.FullMethod).Observe(time.Since(start).Seconds())
+	return resp, err
+}
@@ -3,15 +3,21 @@ package grpc
 import (
 	"context"
 	"errors"
-	"time"
+	"net"
+	"strings"
 
+	"github.com/Gsupakin/back_end_test_challeng/internal/audit"
+	"github.com/Gsupakin/back_end_test_challeng/internal/auth"
 	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/requestid"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/utils"
 	"github.com/Gsupakin/back_end_test_challeng/pkg/validator"
 	pb "github.com/Gsupakin/back_end_test_challeng/proto"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
@@ -47,17 +53,14 @@ func (s *UserServer) CreateUser(ctx context.Context, req *pb.CreateUserRequest)
 		return nil, status.Error(codes.AlreadyExists, "user with this name already exists")
 	}
 
-	// Create user
-	now := time.Now()
-	user := domain.User{
-		Name:      req.Name,
-		Email:     req.Email,
-		Password:  req.Password,
-		CreatedAt: now,
-		UpdatedAt: &now,
+	hashed, err := utils.HashPasswordContext(ctx, req.Password)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to hash password")
 	}
 
-	id, err := s.userRepo.Create(ctx, user)
+	// Create user ด้วย role และสถานะเริ่มต้นเดียวกับการสมัครผ่าน HTTP
+	user := domain.NewUser(req.Name, req.Email, hashed)
+	id, err := s.userRepo.Create(ctx, *user)
 	if err != nil {
 		return nil, status.Error(codes.Internal, "failed to create user")
 	}
@@ -95,30 +98,72 @@ func (s *UserServer) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.G
 	}, nil
 }
 
-// AuthInterceptor implements gRPC interceptor for authentication
-func AuthInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-	// Skip auth for CreateUser
-	if info.FullMethod == "/user.UserService/CreateUser" {
-		return handler(ctx, req)
-	}
+// methodScopes maps RPC methods to the scope an API token needs to call them
+var methodScopes = map[string]string{
+	"/user.UserService/GetUser": domain.ScopeUsersRead,
+}
 
-	// Get metadata
-	md, ok := metadata.FromIncomingContext(ctx)
-	if !ok {
-		return nil, status.Error(codes.Unauthenticated, "metadata is not provided")
-	}
+// AuthInterceptor returns a unary interceptor that authenticates JWTs, personal
+// access tokens and API keys from the "authorization" metadata
+func AuthInterceptor(authn *auth.Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		// Skip auth for CreateUser
+		if info.FullMethod == "/user.UserService/CreateUser" {
+			return handler(ctx, req)
+		}
+		// health check ของ orchestrator ไม่มี token
+		if strings.HasPrefix(info.FullMethod, "/grpc.health.v1.Health/") {
+			return handler(ctx, req)
+		}
+
+		// Get metadata
+		md, ok := metadata.FromIncomingContext(ctx)
+		if !ok {
+			return nil, status.Error(codes.Unauthenticated, "metadata is not provided")
+		}
+
+		// Get token
+		tokens := md.Get("authorization")
+		if len(tokens) == 0 {
+			return nil, status.Error(codes.Unauthenticated, "authorization token is not provided")
+		}
 
-	// Get token
-	tokens := md.Get("authorization")
-	if len(tokens) == 0 {
-		return nil, status.Error(codes.Unauthenticated, "authorization token is not provided")
+		principal, err := authn.Authenticate(ctx, strings.TrimPrefix(tokens[0], "Bearer "), peerIP(ctx))
+		if errors.Is(err, domain.ErrUserInactive) {
+			return nil, status.Error(codes.PermissionDenied, "account is not active")
+		}
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		}
+
+		if scope, ok := methodScopes[info.FullMethod]; ok && !principal.HasScope(scope) {
+			return nil, status.Error(codes.PermissionDenied, "token is missing required scope: "+scope)
+		}
+
+		recordPrincipal(ctx, principal)
+		return handler(auth.WithPrincipal(ctx, principal), req)
 	}
+}
 
-	// TODO: Validate token
-	// For now, just check if token exists
-	if tokens[0] == "" {
-		return nil, status.Error(codes.Unauthenticated, "invalid token")
+// AuditInterceptor tags the request context as coming from gRPC so audit events record their source
+func AuditInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	origin := audit.Origin{
+		Source:    domain.AuditSourceGRPC,
+		RequestID: requestid.FromContext(ctx),
+		IP:        peerIP(ctx),
 	}
+	return handler(audit.WithOrigin(ctx, origin), req)
+}
 
-	return handler(ctx, req)
+// peerIP returns the client IP of the gRPC connection
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
 }
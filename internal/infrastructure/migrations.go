@@ -0,0 +1,206 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// migrationCollection เก็บ ID ของ migration ที่รันแล้ว
+const migrationCollection = "schema_migrations"
+
+// Migration คือการเปลี่ยนแปลงโครงสร้างหรือข้อมูลในฐานข้อมูลหนึ่งครั้ง
+// Up ต้องรันซ้ำได้โดยไม่เสียหาย เพราะถ้าล้มเหลวกลางทางจะถูกรันใหม่ทั้งหมดในครั้งถัดไป
+type Migration struct {
+	ID          string
+	Description string
+	Up          func(ctx context.Context, db *mongo.Database) error
+}
+
+// MigrationStatus คือ migration หนึ่งรายการพร้อมเวลาที่รัน (nil ถ้ายังไม่ได้รัน)
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Migrations คือ migration ทั้งหมดเรียงตามลำดับที่ต้องรัน ห้ามแก้หรือเรียงรายการที่ปล่อยไปแล้วใหม่
+var Migrations = []Migration{
+	{
+		ID:          "0001_users_indexes",
+		Description: "unique index on users.email and users.name, index on users.deleted_at",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("users").Indexes().CreateMany(ctx, []mongo.IndexModel{
+				{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetUnique(true)},
+				{Keys: bson.D{{Key: "name", Value: 1}}, Options: options.Index().SetUnique(true)},
+				{Keys: bson.D{{Key: "deleted_at", Value: 1}}},
+			})
+			return err
+		},
+	},
+	{
+		ID:          "0002_users_default_role_status",
+		Description: "set role and status on users created without them (e.g. through gRPC)",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			users := db.Collection("users")
+			missing := func(field string) bson.M {
+				return bson.M{"$or": bson.A{bson.M{field: bson.M{"$exists": false}}, bson.M{field: ""}}}
+			}
+			if _, err := users.UpdateMany(ctx, missing("role"), bson.M{"$set": bson.M{"role": domain.RoleUser}}); err != nil {
+				return err
+			}
+			_, err := users.UpdateMany(ctx, missing("status"), bson.M{"$set": bson.M{"status": domain.StatusActive}})
+			return err
+		},
+	},
+	{
+		ID:          "0003_request_logs_indexes",
+		Description: "indexes used to search and aggregate request logs",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return NewMongoLogRepository(db.Collection("request_logs")).EnsureIndexes(ctx)
+		},
+	},
+	{
+		ID:          "0004_erasure_requests_indexes",
+		Description: "at most one pending erasure request per user, request_logs.impersonator_id index",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			if err := NewMongoLogRepository(db.Collection("request_logs")).EnsureIndexes(ctx); err != nil {
+				return err
+			}
+			return NewMongoErasureRepository(db.Collection("erasure_requests")).EnsureIndexes(ctx)
+		},
+	},
+	{
+		ID:          "0005_users_unique_active",
+		Description: "unique users.email and users.name only among users that are not deleted",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			users := db.Collection("users")
+			// partial index นับเฉพาะเอกสารที่มี deleted_at เป็น null จึงต้องเติม field ให้เอกสารเก่าที่ไม่มี
+			if _, err := users.UpdateMany(ctx, bson.M{"deleted_at": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"deleted_at": nil}}); err != nil {
+				return err
+			}
+			for _, name := range []string{"email_1", "name_1"} {
+				if _, err := users.Indexes().DropOne(ctx, name); err != nil && !isIndexNotFound(err) {
+					return err
+				}
+			}
+			active := bson.M{"deleted_at": bson.M{"$type": "null"}}
+			_, err := users.Indexes().CreateMany(ctx, []mongo.IndexModel{
+				{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetName("email_active").SetUnique(true).SetPartialFilterExpression(active)},
+				{Keys: bson.D{{Key: "name", Value: 1}}, Options: options.Index().SetName("name_active").SetUnique(true).SetPartialFilterExpression(active)},
+			})
+			return err
+		},
+	},
+	{
+		ID:          "0006_users_status_lifecycle",
+		Description: "replace the inactive status with suspended, index suspensions that end automatically",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			users := db.Collection("users")
+			now := time.Now()
+			_, err := users.UpdateMany(ctx, bson.M{"status": "inactive"}, bson.M{"$set": bson.M{
+				"status":            domain.StatusSuspended,
+				"status_reason":     "migrated from inactive",
+				"status_changed_at": now,
+			}})
+			if err != nil {
+				return err
+			}
+			_, err = users.Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "status_until", Value: 1}},
+				Options: options.Index().SetSparse(true),
+			})
+			return err
+		},
+	},
+	{
+		ID:          "0007_magic_links_ttl",
+		Description: "expire magic_links documents at expires_at, index magic_links.user_id",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return NewMongoMagicLinkRepository(db.Collection("magic_links")).EnsureIndexes(ctx)
+		},
+	},
+	{
+		ID:          "0008_users_linked_identities_unique",
+		Description: "unique users.linked_identities issuer and subject across users",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			// ผู้ใช้ที่ยังไม่ได้ผูกบัญชีไม่มี linked_identities.subject จึงไม่ถูกนับว่าซ้ำกัน
+			_, err := db.Collection("users").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys: bson.D{{Key: "linked_identities.issuer", Value: 1}, {Key: "linked_identities.subject", Value: 1}},
+				Options: options.Index().SetName("linked_identity_unique").SetUnique(true).
+					SetPartialFilterExpression(bson.M{"linked_identities.subject": bson.M{"$exists": true}}),
+			})
+			return err
+		},
+	},
+	{
+		ID:          "0009_oauth_codes_ttl",
+		Description: "expire oauth_codes documents at expires_at, index oauth_codes.code_hash",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return NewMongoAuthorizationCodeRepository(db.Collection("oauth_codes")).EnsureIndexes(ctx)
+		},
+	},
+}
+
+// isIndexNotFound คืน true ถ้า error มาจากการลบ index ที่ไม่มีอยู่ ซึ่งเกิดได้เมื่อรัน migration ซ้ำ
+func isIndexNotFound(err error) bool {
+	var cmdErr mongo.CommandError
+	return errors.As(err, &cmdErr) && cmdErr.Code == 27
+}
+
+// MigrationStatuses คืนทุก migration พร้อมสถานะว่ารันแล้วหรือยัง
+func MigrationStatuses(ctx context.Context, db *mongo.Database) ([]MigrationStatus, error) {
+	cursor, err := db.Collection(migrationCollection).Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	var applied []struct {
+		ID        string    `bson:"_id"`
+		AppliedAt time.Time `bson:"applied_at"`
+	}
+	if err := cursor.All(ctx, &applied); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(Migrations))
+	for i, m := range Migrations {
+		statuses[i].Migration = m
+		for _, a := range applied {
+			if a.ID == m.ID {
+				appliedAt := a.AppliedAt
+				statuses[i].AppliedAt = &appliedAt
+			}
+		}
+	}
+	return statuses, nil
+}
+
+// Migrate รัน migration ที่ยังไม่ได้รันตามลำดับ และคืน migration ที่รันในครั้งนี้
+// หยุดที่ migration แรกที่ล้มเหลวเพื่อไม่ให้รายการถัดไปรันบนข้อมูลที่ยังไม่พร้อม
+func Migrate(ctx context.Context, db *mongo.Database) ([]Migration, error) {
+	statuses, err := MigrationStatuses(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var ran []Migration
+	for _, s := range statuses {
+		if s.AppliedAt != nil {
+			continue
+		}
+		if err := s.Up(ctx, db); err != nil {
+			return ran, fmt.Errorf("migration %s: %w", s.ID, err)
+		}
+		_, err := db.Collection(migrationCollection).InsertOne(ctx, bson.M{"_id": s.ID, "applied_at": time.Now()})
+		if err != nil {
+			return ran, fmt.Errorf("migration %s: record: %w", s.ID, err)
+		}
+		ran = append(ran, s.Migration)
+	}
+	return ran, nil
+}
@@ -2,35 +2,32 @@ package infrastructure
 
 import (
 	"context"
-	"log"
-	"os"
-	"time"
 
+	"github.com/Gsupakin/back_end_test_challeng/internal/config"
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
-func ConnectMongo() *mongo.Client {
-	uri := os.Getenv("MONGODB_URI")
-	if uri == "" {
-		log.Fatal("MONGODB_URI not set in environment")
+// ConnectMongo เชื่อมต่อ MongoDB ตาม cfg และ ping ให้แน่ใจว่าใช้งานได้ภายใน cfg.ConnectTimeout
+// monitor เป็น nil ได้ถ้าไม่ต้องการ trace หรือ log คำสั่ง
+func ConnectMongo(ctx context.Context, cfg config.Mongo, monitor *event.CommandMonitor) (*mongo.Client, error) {
+	opts := options.Client().ApplyURI(cfg.URI)
+	if monitor != nil {
+		opts.SetMonitor(monitor)
 	}
-
-	clientOpts := options.Client().ApplyURI(uri)
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	client, err := mongo.Connect(ctx, clientOpts)
+	client, err := mongo.Connect(ctx, opts)
 	if err != nil {
-		log.Fatalf("Failed to connect to MongoDB: %v", err)
+		return nil, err
 	}
 
 	// ตรวจสอบ connection
-	if err := client.Ping(ctx, nil); err != nil {
-		log.Fatalf("MongoDB ping failed: %v", err)
+	pingCtx, cancel := context.WithTimeout(ctx, cfg.ConnectTimeout)
+	defer cancel()
+	if err := client.Ping(pingCtx, readpref.Primary()); err != nil {
+		client.Disconnect(ctx)
+		return nil, err
 	}
-
-	log.Println("✅ Connected to MongoDB")
-	return client
+	return client, nil
 }
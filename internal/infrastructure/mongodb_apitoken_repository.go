@@ -0,0 +1,116 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoAPITokenRepository implements domain.APITokenRepository
+type MongoAPITokenRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoAPITokenRepository creates a new instance of MongoAPITokenRepository
+func NewMongoAPITokenRepository(collection *mongo.Collection) *MongoAPITokenRepository {
+	return &MongoAPITokenRepository{
+		collection: collection,
+	}
+}
+
+// Create implements domain.APITokenRepository
+func (r *MongoAPITokenRepository) Create(ctx context.Context, token domain.APIToken) (primitive.ObjectID, error) {
+	ctx, done := observe(ctx, "api_tokens", "Create")
+	defer done()
+	result, err := r.collection.InsertOne(ctx, token)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return result.InsertedID.(primitive.ObjectID), nil
+}
+
+// FindByID implements domain.APITokenRepository
+func (r *MongoAPITokenRepository) FindByID(ctx context.Context, id primitive.ObjectID) (domain.APIToken, error) {
+	ctx, done := observe(ctx, "api_tokens", "FindByID")
+	defer done()
+	return r.findOne(ctx, bson.M{"_id": id})
+}
+
+// FindByPrefix implements domain.APITokenRepository
+func (r *MongoAPITokenRepository) FindByPrefix(ctx context.Context, prefix string) (domain.APIToken, error) {
+	ctx, done := observe(ctx, "api_tokens", "FindByPrefix")
+	defer done()
+	return r.findOne(ctx, bson.M{"prefix": prefix})
+}
+
+// FindByUser implements domain.APITokenRepository
+func (r *MongoAPITokenRepository) FindByUser(ctx context.Context, userID primitive.ObjectID, kind string) ([]domain.APIToken, error) {
+	ctx, done := observe(ctx, "api_tokens", "FindByUser")
+	defer done()
+	return r.find(ctx, bson.M{"user_id": userID, "kind": kind})
+}
+
+// FindByKind implements domain.APITokenRepository
+func (r *MongoAPITokenRepository) FindByKind(ctx context.Context, kind string) ([]domain.APIToken, error) {
+	ctx, done := observe(ctx, "api_tokens", "FindByKind")
+	defer done()
+	return r.find(ctx, bson.M{"kind": kind})
+}
+
+// Revoke implements domain.APITokenRepository
+func (r *MongoAPITokenRepository) Revoke(ctx context.Context, id primitive.ObjectID) error {
+	ctx, done := observe(ctx, "api_tokens", "Revoke")
+	defer done()
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrTokenNotFound
+	}
+	return nil
+}
+
+// TouchLastUsed implements domain.APITokenRepository
+func (r *MongoAPITokenRepository) TouchLastUsed(ctx context.Context, id primitive.ObjectID, ip string) error {
+	ctx, done := observe(ctx, "api_tokens", "TouchLastUsed")
+	defer done()
+	_, err := r.collection.UpdateByID(ctx, id, bson.M{"$set": bson.M{
+		"last_used_at": time.Now(),
+		"last_used_ip": ip,
+	}})
+	return err
+}
+
+func (r *MongoAPITokenRepository) findOne(ctx context.Context, filter bson.M) (domain.APIToken, error) {
+	var token domain.APIToken
+	err := r.collection.FindOne(ctx, filter).Decode(&token)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return token, domain.ErrTokenNotFound
+	}
+	return token, err
+}
+
+func (r *MongoAPITokenRepository) find(ctx context.Context, filter bson.M) ([]domain.APIToken, error) {
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.M{"created_at": -1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	tokens := []domain.APIToken{}
+	if err := cursor.All(ctx, &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
@@ -0,0 +1,77 @@
+package infrastructure
+
+import (
+	"context"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoAuditRepository implements domain.AuditRepository
+type MongoAuditRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoAuditRepository creates a new instance of MongoAuditRepository
+func NewMongoAuditRepository(collection *mongo.Collection) *MongoAuditRepository {
+	return &MongoAuditRepository{
+		collection: collection,
+	}
+}
+
+// Create implements domain.AuditRepository
+func (r *MongoAuditRepository) Create(ctx context.Context, event domain.AuditEvent) error {
+	ctx, done := observe(ctx, "audit_log", "Create")
+	defer done()
+	_, err := r.collection.InsertOne(ctx, event)
+	return err
+}
+
+// Find implements domain.AuditRepository
+func (r *MongoAuditRepository) Find(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEvent, int64, error) {
+	ctx, done := observe(ctx, "audit_log", "Find")
+	defer done()
+	query := bson.M{}
+	if filter.UserID != "" {
+		query["target_user_id"] = filter.UserID
+	}
+	if filter.ActorID != "" {
+		query["$or"] = bson.A{
+			bson.M{"actor_id": filter.ActorID},
+			bson.M{"impersonator_id": filter.ActorID},
+		}
+	}
+	if filter.From != nil || filter.To != nil {
+		timestamp := bson.M{}
+		if filter.From != nil {
+			timestamp["$gte"] = *filter.From
+		}
+		if filter.To != nil {
+			timestamp["$lt"] = *filter.To
+		}
+		query["timestamp"] = timestamp
+	}
+
+	total, err := r.collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64((filter.Page - 1) * filter.Limit)).
+		SetLimit(int64(filter.Limit))
+	cursor, err := r.collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	events := []domain.AuditEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, 0, err
+	}
+	return events, total, nil
+}
@@ -0,0 +1,241 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/redact"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoErasureRepository implements domain.ErasureRepository
+type MongoErasureRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoErasureRepository creates a new instance of MongoErasureRepository
+func NewMongoErasureRepository(collection *mongo.Collection) *MongoErasureRepository {
+	return &MongoErasureRepository{
+		collection: collection,
+	}
+}
+
+// EnsureIndexes สร้าง unique index ที่กันไม่ให้ผู้ใช้คนเดียวมีคำขอที่รออนุมัติมากกว่าหนึ่งรายการ
+func (r *MongoErasureRepository) EnsureIndexes(ctx context.Context) error {
+	ctx, done := observe(ctx, "erasure_requests", "EnsureIndexes")
+	defer done()
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}},
+			Options: options.Index().SetName("user_id_pending").SetUnique(true).
+				SetPartialFilterExpression(bson.M{"status": domain.ErasurePending}),
+		},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "requested_at", Value: -1}}},
+	})
+	return err
+}
+
+// Create implements domain.ErasureRepository
+func (r *MongoErasureRepository) Create(ctx context.Context, request domain.ErasureRequest) (primitive.ObjectID, error) {
+	ctx, done := observe(ctx, "erasure_requests", "Create")
+	defer done()
+	result, err := r.collection.InsertOne(ctx, request)
+	if mongo.IsDuplicateKeyError(err) {
+		return primitive.NilObjectID, domain.ErrErasurePending
+	}
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return result.InsertedID.(primitive.ObjectID), nil
+}
+
+// FindByID implements domain.ErasureRepository
+func (r *MongoErasureRepository) FindByID(ctx context.Context, id primitive.ObjectID) (domain.ErasureRequest, error) {
+	ctx, done := observe(ctx, "erasure_requests", "FindByID")
+	defer done()
+	var request domain.ErasureRequest
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&request)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return request, domain.ErrErasureNotFound
+	}
+	return request, err
+}
+
+// Find implements domain.ErasureRepository
+func (r *MongoErasureRepository) Find(ctx context.Context, status string) ([]domain.ErasureRequest, error) {
+	ctx, done := observe(ctx, "erasure_requests", "Find")
+	defer done()
+	query := bson.M{}
+	if status != "" {
+		query["status"] = status
+	}
+	cursor, err := r.collection.Find(ctx, query, options.Find().SetSort(bson.D{{Key: "requested_at", Value: -1}, {Key: "_id", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	requests := []domain.ErasureRequest{}
+	if err := cursor.All(ctx, &requests); err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// Reject implements domain.ErasureRepository
+func (r *MongoErasureRepository) Reject(ctx context.Context, id, adminID primitive.ObjectID, note string) error {
+	ctx, done := observe(ctx, "erasure_requests", "Reject")
+	defer done()
+	return r.decide(ctx, id, bson.M{
+		"status":     domain.ErasureRejected,
+		"decided_by": adminID,
+		"decided_at": time.Now(),
+		"note":       note,
+	})
+}
+
+// Complete implements domain.ErasureRepository
+func (r *MongoErasureRepository) Complete(ctx context.Context, id, adminID primitive.ObjectID, receipt domain.ErasureReceipt) error {
+	ctx, done := observe(ctx, "erasure_requests", "Complete")
+	defer done()
+	return r.decide(ctx, id, bson.M{
+		"status":     domain.ErasureCompleted,
+		"decided_by": adminID,
+		"decided_at": receipt.ErasedAt,
+		"receipt":    receipt,
+	})
+}
+
+// decide เปลี่ยนคำขอที่ยังรออนุมัติอยู่เท่านั้น
+func (r *MongoErasureRepository) decide(ctx context.Context, id primitive.ObjectID, set bson.M) error {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "status": domain.ErasurePending},
+		bson.M{"$set": set},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount > 0 {
+		return nil
+	}
+	count, err := r.collection.CountDocuments(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return domain.ErrErasureNotFound
+	}
+	return domain.ErrErasureDecided
+}
+
+// MongoPersonalDataEraser implements domain.PersonalDataEraser across every collection that refers to users
+type MongoPersonalDataEraser struct {
+	db       *mongo.Database
+	redactor *redact.Redactor
+}
+
+// NewMongoPersonalDataEraser creates a new instance of MongoPersonalDataEraser
+func NewMongoPersonalDataEraser(db *mongo.Database) *MongoPersonalDataEraser {
+	return &MongoPersonalDataEraser{db: db, redactor: redact.New(redact.DefaultPolicy(), nil, 24*time.Hour)}
+}
+
+// WithRedactor ใช้ redactor เดียวกับ request log เพื่อหา log ที่ user_id ถูกแฮชไว้
+func (e *MongoPersonalDataEraser) WithRedactor(r *redact.Redactor) *MongoPersonalDataEraser {
+	e.redactor = r
+	return e
+}
+
+// Limitations implements domain.PersonalDataEraser
+func (e *MongoPersonalDataEraser) Limitations() []string {
+	if e.redactor.Policy().UserID == redact.ModeHash {
+		return []string{domain.ErasureHashedLogsLimitation}
+	}
+	return nil
+}
+
+// Erase implements domain.PersonalDataEraser
+// ข้อมูลที่เป็นของผู้ใช้คนเดียว (session, token, magic link, authorization code) ถูกลบ
+// ส่วน request log และ audit log ยังเก็บไว้เพื่อสถิติและการตรวจสอบ แต่ ID ถูกแทนด้วยนามแฝงและ IP, user agent
+// และค่าก่อน/หลังของการแก้ไขข้อมูลผู้ใช้คนนี้ถูกลบออก เอกสารผู้ใช้ถูกลบเป็นขั้นสุดท้าย
+// ถ้าล้มเหลวกลางทางจึงยังหาผู้ใช้เจอและรันใหม่ได้
+func (e *MongoPersonalDataEraser) Erase(ctx context.Context, userID primitive.ObjectID, pseudonym domain.Pseudonym) (map[string]int64, error) {
+	ctx, done := observe(ctx, "users", "Erase")
+	defer done()
+	hex, alias := userID.Hex(), pseudonym.String()
+	logKeys := bson.M{"$in": e.redactor.UserIDKeys(hex)}
+	counts := map[string]int64{}
+
+	deleteMany := func(collection string, filter bson.M) error {
+		result, err := e.db.Collection(collection).DeleteMany(ctx, filter)
+		if err != nil {
+			return err
+		}
+		counts[collection] += result.DeletedCount
+		return nil
+	}
+	updateMany := func(collection string, filter bson.M, update interface{}) error {
+		result, err := e.db.Collection(collection).UpdateMany(ctx, filter, update)
+		if err != nil {
+			return err
+		}
+		counts[collection] += result.ModifiedCount
+		return nil
+	}
+
+	steps := []func() error{
+		func() error { return deleteMany("sessions", bson.M{"user_id": userID}) },
+		func() error {
+			return updateMany("sessions", bson.M{"impersonator_id": userID}, bson.M{"$set": bson.M{"impersonator_id": pseudonym.ID()}})
+		},
+		func() error { return deleteMany("api_tokens", bson.M{"user_id": userID}) },
+		func() error {
+			return updateMany("api_tokens", bson.M{"created_by": userID}, bson.M{"$set": bson.M{"created_by": pseudonym.ID()}})
+		},
+		func() error { return deleteMany("magic_links", bson.M{"user_id": userID}) },
+		func() error { return deleteMany("oauth_codes", bson.M{"user_id": userID}) },
+		func() error {
+			return updateMany("oauth_clients", bson.M{"created_by": userID}, bson.M{"$set": bson.M{"created_by": pseudonym.ID()}})
+		},
+
+		// path เช่น /users/<id> ก็อ้างถึงผู้ใช้เช่นกัน
+		func() error {
+			return updateMany("request_logs", bson.M{"path": bson.M{"$regex": hex}}, mongo.Pipeline{
+				{{Key: "$set", Value: bson.M{"path": bson.M{"$replaceAll": bson.M{"input": "$path", "find": hex, "replacement": alias}}}}},
+			})
+		},
+		func() error {
+			return updateMany("request_logs", bson.M{"user_id": logKeys}, bson.M{"$set": bson.M{"user_id": alias, "ip": "", "user_agent": ""}})
+		},
+		func() error {
+			return updateMany("request_logs", bson.M{"impersonator_id": logKeys}, bson.M{"$set": bson.M{"impersonator_id": alias}})
+		},
+
+		// ลบค่าก่อน/หลังก่อนเปลี่ยน target_user_id เพราะหลังจากนั้นจะหา event ของผู้ใช้นี้ไม่เจอแล้ว
+		func() error {
+			return updateMany("audit_log", bson.M{"target_user_id": hex, "changes.0": bson.M{"$exists": true}},
+				bson.M{"$unset": bson.M{"changes.$[].old": "", "changes.$[].new": ""}})
+		},
+		func() error {
+			return updateMany("audit_log", bson.M{"target_user_id": hex}, bson.M{"$set": bson.M{"target_user_id": alias}})
+		},
+		func() error {
+			return updateMany("audit_log", bson.M{"actor_id": hex}, bson.M{"$set": bson.M{"actor_id": alias}, "$unset": bson.M{"ip": ""}})
+		},
+		func() error {
+			return updateMany("audit_log", bson.M{"impersonator_id": hex}, bson.M{"$set": bson.M{"impersonator_id": alias}})
+		},
+
+		func() error { return deleteMany("users", bson.M{"_id": userID}) },
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return counts, err
+		}
+	}
+	return counts, nil
+}
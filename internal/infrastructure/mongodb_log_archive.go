@@ -0,0 +1,164 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	ttlIndexName      = "timestamp_ttl"
+	duplicateKeyError = 11000
+)
+
+// EnsureRetention สร้าง ปรับ หรือลบ TTL index บน timestamp ให้ตรงกับ retention
+// retention เป็น 0 หมายถึงเก็บ log ไว้ตลอด
+func (r *MongoLogRepository) EnsureRetention(ctx context.Context, retention time.Duration) error {
+	ctx, done := observe(ctx, "request_logs", "EnsureRetention")
+	defer done()
+	current, exists, err := r.ttlSeconds(ctx)
+	if err != nil {
+		return err
+	}
+	seconds := int32(retention / time.Second)
+
+	switch {
+	case retention <= 0 && exists:
+		_, err = r.collection.Indexes().DropOne(ctx, ttlIndexName)
+		return err
+	case retention <= 0:
+		return nil
+	case !exists:
+		_, err = r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "timestamp", Value: 1}},
+			Options: options.Index().SetName(ttlIndexName).SetExpireAfterSeconds(seconds),
+		})
+		return err
+	case current != seconds:
+		// collMod เปลี่ยนระยะเวลาได้โดยไม่ต้องสร้าง index ใหม่ทั้งหมด
+		return r.collection.Database().RunCommand(ctx, bson.D{
+			{Key: "collMod", Value: r.collection.Name()},
+			{Key: "index", Value: bson.D{
+				{Key: "name", Value: ttlIndexName},
+				{Key: "expireAfterSeconds", Value: seconds},
+			}},
+		}).Err()
+	}
+	return nil
+}
+
+func (r *MongoLogRepository) ttlSeconds(ctx context.Context) (int32, bool, error) {
+	cursor, err := r.collection.Indexes().List(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var index struct {
+			Name               string `bson:"name"`
+			ExpireAfterSeconds *int32 `bson:"expireAfterSeconds"`
+		}
+		if err := cursor.Decode(&index); err != nil {
+			return 0, false, err
+		}
+		if index.Name == ttlIndexName && index.ExpireAfterSeconds != nil {
+			return *index.ExpireAfterSeconds, true, nil
+		}
+	}
+	return 0, false, cursor.Err()
+}
+
+func timeRange(from, to time.Time) bson.M {
+	return bson.M{"timestamp": bson.M{"$gte": from, "$lt": to}}
+}
+
+// Oldest implements domain.LogArchiveRepository
+func (r *MongoLogRepository) Oldest(ctx context.Context) (time.Time, error) {
+	ctx, done := observe(ctx, "request_logs", "Oldest")
+	defer done()
+	var entry domain.RequestLog
+	err := r.collection.FindOne(ctx, bson.M{},
+		options.FindOne().SetSort(bson.D{{Key: "timestamp", Value: 1}}).SetProjection(bson.M{"timestamp": 1}),
+	).Decode(&entry)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return time.Time{}, domain.ErrLogNotFound
+	}
+	return entry.Timestamp, err
+}
+
+// CountRange implements domain.LogArchiveRepository
+func (r *MongoLogRepository) CountRange(ctx context.Context, from, to time.Time) (int64, error) {
+	ctx, done := observe(ctx, "request_logs", "CountRange")
+	defer done()
+	return r.collection.CountDocuments(ctx, timeRange(from, to))
+}
+
+// EachInRange implements domain.LogArchiveRepository
+func (r *MongoLogRepository) EachInRange(ctx context.Context, from, to time.Time, fn func(domain.RequestLog) error) error {
+	ctx, done := observe(ctx, "request_logs", "EachInRange")
+	defer done()
+	cursor, err := r.collection.Find(ctx, timeRange(from, to),
+		options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}, {Key: "_id", Value: 1}}),
+	)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var entry domain.RequestLog
+		if err := cursor.Decode(&entry); err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// DeleteRange implements domain.LogArchiveRepository
+func (r *MongoLogRepository) DeleteRange(ctx context.Context, from, to time.Time) (int64, error) {
+	ctx, done := observe(ctx, "request_logs", "DeleteRange")
+	defer done()
+	result, err := r.collection.DeleteMany(ctx, timeRange(from, to))
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// Restore implements domain.LogArchiveRepository
+func (r *MongoLogRepository) Restore(ctx context.Context, logs []domain.RequestLog) (int64, error) {
+	ctx, done := observe(ctx, "request_logs", "Restore")
+	defer done()
+	if len(logs) == 0 {
+		return 0, nil
+	}
+	docs := make([]interface{}, len(logs))
+	for i, l := range logs {
+		docs[i] = l
+	}
+	_, err := r.collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	if err == nil {
+		return int64(len(logs)), nil
+	}
+
+	// log ที่มีอยู่แล้ว (เช่น restore ไฟล์เดิมซ้ำ) ไม่ถือว่าผิดพลาด
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil {
+		return 0, err
+	}
+	for _, writeErr := range bulkErr.WriteErrors {
+		if writeErr.Code != duplicateKeyError {
+			return 0, err
+		}
+	}
+	return int64(len(logs) - len(bulkErr.WriteErrors)), nil
+}
@@ -0,0 +1,91 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoMagicLinkRepository implements domain.MagicLinkRepository
+type MongoMagicLinkRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoMagicLinkRepository creates a new instance of MongoMagicLinkRepository
+func NewMongoMagicLinkRepository(collection *mongo.Collection) *MongoMagicLinkRepository {
+	return &MongoMagicLinkRepository{
+		collection: collection,
+	}
+}
+
+// EnsureIndexes สร้าง TTL index ที่ลบลิงก์ทิ้งเมื่อหมดอายุ ไม่ว่าจะถูกใช้หรือยกเลิกแล้วหรือไม่
+// และ index ของ user_id ที่ใช้ยกเลิกลิงก์ทั้งหมดของผู้ใช้
+func (r *MongoMagicLinkRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetName("expires_at_ttl").SetExpireAfterSeconds(0)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+	})
+	return err
+}
+
+// Create implements domain.MagicLinkRepository
+func (r *MongoMagicLinkRepository) Create(ctx context.Context, link domain.MagicLink) (primitive.ObjectID, error) {
+	ctx, done := observe(ctx, "magic_links", "Create")
+	defer done()
+	result, err := r.collection.InsertOne(ctx, link)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return result.InsertedID.(primitive.ObjectID), nil
+}
+
+// Consume implements domain.MagicLinkRepository
+func (r *MongoMagicLinkRepository) Consume(ctx context.Context, id primitive.ObjectID, fingerprint string) (domain.MagicLink, error) {
+	ctx, done := observe(ctx, "magic_links", "Consume")
+	defer done()
+	now := time.Now()
+
+	// ใช้ FindOneAndUpdate เพื่อให้ลิงก์ถูกใช้ได้เพียงครั้งเดียวแม้มี request พร้อมกัน
+	var link domain.MagicLink
+	err := r.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{
+			"_id":         id,
+			"fingerprint": fingerprint,
+			"used_at":     nil,
+			"revoked_at":  nil,
+			"expires_at":  bson.M{"$gt": now},
+		},
+		bson.M{"$set": bson.M{"used_at": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&link)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return link, domain.ErrInvalidToken
+	}
+	return link, err
+}
+
+// RevokeAllForUser implements domain.MagicLinkRepository
+func (r *MongoMagicLinkRepository) RevokeAllForUser(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	ctx, done := observe(ctx, "magic_links", "RevokeAllForUser")
+	defer done()
+	result, err := r.collection.UpdateMany(
+		ctx,
+		bson.M{
+			"user_id":    userID,
+			"used_at":    nil,
+			"revoked_at": nil,
+		},
+		bson.M{"$set": bson.M{"revoked_at": time.Now()}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
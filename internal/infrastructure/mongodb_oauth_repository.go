@@ -0,0 +1,131 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoOAuthClientRepository implements domain.OAuthClientRepository
+type MongoOAuthClientRepository struct {
+	collection *mongo.Collection
+}
+
+// MongoAuthorizationCodeRepository implements domain.AuthorizationCodeRepository
+type MongoAuthorizationCodeRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoOAuthClientRepository creates a new instance of MongoOAuthClientRepository
+func NewMongoOAuthClientRepository(collection *mongo.Collection) *MongoOAuthClientRepository {
+	return &MongoOAuthClientRepository{
+		collection: collection,
+	}
+}
+
+// NewMongoAuthorizationCodeRepository creates a new instance of MongoAuthorizationCodeRepository
+func NewMongoAuthorizationCodeRepository(collection *mongo.Collection) *MongoAuthorizationCodeRepository {
+	return &MongoAuthorizationCodeRepository{
+		collection: collection,
+	}
+}
+
+// Create implements domain.OAuthClientRepository
+func (r *MongoOAuthClientRepository) Create(ctx context.Context, client domain.OAuthClient) (primitive.ObjectID, error) {
+	ctx, done := observe(ctx, "oauth_clients", "Create")
+	defer done()
+	result, err := r.collection.InsertOne(ctx, client)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return result.InsertedID.(primitive.ObjectID), nil
+}
+
+// FindByClientID implements domain.OAuthClientRepository
+func (r *MongoOAuthClientRepository) FindByClientID(ctx context.Context, clientID string) (domain.OAuthClient, error) {
+	ctx, done := observe(ctx, "oauth_clients", "FindByClientID")
+	defer done()
+	var client domain.OAuthClient
+	err := r.collection.FindOne(ctx, bson.M{"client_id": clientID}).Decode(&client)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return client, domain.ErrClientNotFound
+	}
+	return client, err
+}
+
+// FindAll implements domain.OAuthClientRepository
+func (r *MongoOAuthClientRepository) FindAll(ctx context.Context) ([]domain.OAuthClient, error) {
+	ctx, done := observe(ctx, "oauth_clients", "FindAll")
+	defer done()
+	cursor, err := r.collection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var clients []domain.OAuthClient
+	if err := cursor.All(ctx, &clients); err != nil {
+		return nil, err
+	}
+	return clients, nil
+}
+
+// Delete implements domain.OAuthClientRepository
+func (r *MongoOAuthClientRepository) Delete(ctx context.Context, clientID string) error {
+	ctx, done := observe(ctx, "oauth_clients", "Delete")
+	defer done()
+	result, err := r.collection.DeleteOne(ctx, bson.M{"client_id": clientID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return domain.ErrClientNotFound
+	}
+	return nil
+}
+
+// EnsureIndexes สร้าง TTL index ที่ลบ code ทิ้งเมื่อหมดอายุ ไม่ว่าจะถูกใช้แล้วหรือไม่
+// และ index ของ code_hash ที่ใช้แลก code เป็น token
+func (r *MongoAuthorizationCodeRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetName("expires_at_ttl").SetExpireAfterSeconds(0)},
+		{Keys: bson.D{{Key: "code_hash", Value: 1}}},
+	})
+	return err
+}
+
+// Create implements domain.AuthorizationCodeRepository
+func (r *MongoAuthorizationCodeRepository) Create(ctx context.Context, code domain.AuthorizationCode) error {
+	ctx, done := observe(ctx, "oauth_codes", "Create")
+	defer done()
+	_, err := r.collection.InsertOne(ctx, code)
+	return err
+}
+
+// Consume implements domain.AuthorizationCodeRepository
+func (r *MongoAuthorizationCodeRepository) Consume(ctx context.Context, codeHash string) (domain.AuthorizationCode, error) {
+	ctx, done := observe(ctx, "oauth_codes", "Consume")
+	defer done()
+	now := time.Now()
+
+	var code domain.AuthorizationCode
+	err := r.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{
+			"code_hash":  codeHash,
+			"used_at":    nil,
+			"expires_at": bson.M{"$gt": now},
+		},
+		bson.M{"$set": bson.M{"used_at": now}},
+	).Decode(&code)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return code, domain.ErrInvalidToken
+	}
+	return code, err
+}
@@ -2,12 +2,15 @@ package infrastructure
 
 import (
 	"context"
+	"errors"
+	"regexp"
 	"time"
 
 	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // MongoUserRepository implements domain.UserRepository
@@ -36,6 +39,8 @@ func NewMongoLogRepository(collection *mongo.Collection) *MongoLogRepository {
 
 // Create implements domain.UserRepository
 func (r *MongoUserRepository) Create(ctx context.Context, user domain.User) (primitive.ObjectID, error) {
+	ctx, done := observe(ctx, "users", "Create")
+	defer done()
 	result, err := r.collection.InsertOne(ctx, user)
 	if err != nil {
 		return primitive.NilObjectID, err
@@ -45,20 +50,26 @@ func (r *MongoUserRepository) Create(ctx context.Context, user domain.User) (pri
 
 // FindByEmail implements domain.UserRepository
 func (r *MongoUserRepository) FindByEmail(ctx context.Context, email string) (domain.User, error) {
+	ctx, done := observe(ctx, "users", "FindByEmail")
+	defer done()
 	var user domain.User
-	err := r.collection.FindOne(ctx, bson.M{"email": email}).Decode(&user)
+	err := r.collection.FindOne(ctx, bson.M{"email": email, "deleted_at": nil}).Decode(&user)
 	return user, err
 }
 
 // FindByName implements domain.UserRepository
 func (r *MongoUserRepository) FindByName(ctx context.Context, name string) (domain.User, error) {
+	ctx, done := observe(ctx, "users", "FindByName")
+	defer done()
 	var user domain.User
-	err := r.collection.FindOne(ctx, bson.M{"name": name}).Decode(&user)
+	err := r.collection.FindOne(ctx, bson.M{"name": name, "deleted_at": nil}).Decode(&user)
 	return user, err
 }
 
 // FindByID implements domain.UserRepository
 func (r *MongoUserRepository) FindByID(ctx context.Context, id primitive.ObjectID) (domain.User, error) {
+	ctx, done := observe(ctx, "users", "FindByID")
+	defer done()
 	var user domain.User
 	err := r.collection.FindOne(ctx, bson.M{
 		"_id":        id,
@@ -69,6 +80,8 @@ func (r *MongoUserRepository) FindByID(ctx context.Context, id primitive.ObjectI
 
 // FindAll implements domain.UserRepository
 func (r *MongoUserRepository) FindAll(ctx context.Context) ([]domain.User, error) {
+	ctx, done := observe(ctx, "users", "FindAll")
+	defer done()
 	cursor, err := r.collection.Find(ctx, bson.M{"deleted_at": nil})
 	if err != nil {
 		return nil, err
@@ -84,6 +97,8 @@ func (r *MongoUserRepository) FindAll(ctx context.Context) ([]domain.User, error
 
 // Update implements domain.UserRepository
 func (r *MongoUserRepository) Update(ctx context.Context, id primitive.ObjectID, update map[string]interface{}) error {
+	ctx, done := observe(ctx, "users", "Update")
+	defer done()
 	update["updated_at"] = time.Now()
 	_, err := r.collection.UpdateOne(
 		ctx,
@@ -98,6 +113,8 @@ func (r *MongoUserRepository) Update(ctx context.Context, id primitive.ObjectID,
 
 // Delete implements domain.UserRepository
 func (r *MongoUserRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	ctx, done := observe(ctx, "users", "Delete")
+	defer done()
 	_, err := r.collection.UpdateOne(
 		ctx,
 		bson.M{
@@ -109,13 +126,563 @@ func (r *MongoUserRepository) Delete(ctx context.Context, id primitive.ObjectID)
 	return err
 }
 
+// Restore implements domain.UserRepository
+func (r *MongoUserRepository) Restore(ctx context.Context, id primitive.ObjectID) error {
+	ctx, done := observe(ctx, "users", "Restore")
+	defer done()
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{
+			"_id":        id,
+			"deleted_at": bson.M{"$ne": nil},
+		},
+		bson.M{"$set": bson.M{"deleted_at": nil, "updated_at": time.Now()}},
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return domain.ErrUserAlreadyExists
+	}
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrUserNotFound
+	}
+	return nil
+}
+
+// ChangeStatus implements domain.UserRepository
+func (r *MongoUserRepository) ChangeStatus(ctx context.Context, id primitive.ObjectID, change domain.StatusChange) error {
+	ctx, done := observe(ctx, "users", "ChangeStatus")
+	defer done()
+	set := bson.M{
+		"status":            change.To,
+		"status_reason":     change.Reason,
+		"status_changed_at": change.At,
+		"updated_at":        change.At,
+	}
+	update := bson.M{"$set": set}
+	if change.Until != nil {
+		set["status_until"] = *change.Until
+	} else {
+		update["$unset"] = bson.M{"status_until": ""}
+	}
+	// ตรงกับ domain.StatusChange.AppliesTo การระงับที่หมดเวลาแล้วนับเป็น active
+	filter := bson.M{"_id": id, "deleted_at": nil, "status": change.From}
+	if change.From == domain.StatusActive {
+		delete(filter, "status")
+		filter["$or"] = bson.A{
+			bson.M{"status": domain.StatusActive},
+			bson.M{"status": domain.StatusSuspended, "status_until": bson.M{"$lte": change.At}},
+		}
+	}
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount > 0 {
+		return nil
+	}
+	count, err := r.collection.CountDocuments(ctx, bson.M{"_id": id, "deleted_at": nil})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return domain.ErrUserNotFound
+	}
+	return domain.ErrInvalidTransition
+}
+
+// UseTOTPStep implements domain.UserRepository
+// ใช้ filter เดียวกับการอัพเดทเพื่อให้ request ที่มาพร้อมกันใช้ step เดียวกันได้เพียงครั้งเดียว
+func (r *MongoUserRepository) UseTOTPStep(ctx context.Context, id primitive.ObjectID, step int64) error {
+	ctx, done := observe(ctx, "users", "UseTOTPStep")
+	defer done()
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{
+			"_id":        id,
+			"deleted_at": nil,
+			"$or": bson.A{
+				bson.M{"mfa.last_used_step": bson.M{"$lt": step}},
+				bson.M{"mfa.last_used_step": bson.M{"$exists": false}},
+			},
+		},
+		bson.M{"$set": bson.M{"mfa.last_used_step": step, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount != 1 {
+		return domain.ErrInvalidMFACode
+	}
+	return nil
+}
+
+// UseRecoveryCode implements domain.UserRepository
+func (r *MongoUserRepository) UseRecoveryCode(ctx context.Context, id primitive.ObjectID, hash string) error {
+	ctx, done := observe(ctx, "users", "UseRecoveryCode")
+	defer done()
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{
+			"_id":                id,
+			"deleted_at":         nil,
+			"mfa.recovery_codes": hash,
+		},
+		bson.M{
+			"$pull": bson.M{"mfa.recovery_codes": hash},
+			"$set":  bson.M{"updated_at": time.Now()},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount != 1 {
+		return domain.ErrInvalidMFACode
+	}
+	return nil
+}
+
+// StartMFAChallenge implements domain.UserRepository
+func (r *MongoUserRepository) StartMFAChallenge(ctx context.Context, id primitive.ObjectID, challengeID string) error {
+	ctx, done := observe(ctx, "users", "StartMFAChallenge")
+	defer done()
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{
+			"_id":        id,
+			"deleted_at": nil,
+		},
+		bson.M{"$set": bson.M{"mfa.challenge_id": challengeID, "mfa.challenge_failures": 0}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrUserNotFound
+	}
+	return nil
+}
+
+// FailMFAChallenge implements domain.UserRepository
+func (r *MongoUserRepository) FailMFAChallenge(ctx context.Context, id primitive.ObjectID, challengeID string, limit int) error {
+	ctx, done := observe(ctx, "users", "FailMFAChallenge")
+	defer done()
+	var user domain.User
+	err := r.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": id, "deleted_at": nil, "mfa.challenge_id": challengeID},
+		bson.M{"$inc": bson.M{"mfa.challenge_failures": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.M{"mfa.challenge_failures": 1}),
+	).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return domain.ErrInvalidToken
+	}
+	if err != nil {
+		return err
+	}
+	if user.MFA.ChallengeFailures < limit {
+		return nil
+	}
+	return r.EndMFAChallenge(ctx, id, challengeID)
+}
+
+// EndMFAChallenge implements domain.UserRepository
+func (r *MongoUserRepository) EndMFAChallenge(ctx context.Context, id primitive.ObjectID, challengeID string) error {
+	ctx, done := observe(ctx, "users", "EndMFAChallenge")
+	defer done()
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "deleted_at": nil, "mfa.challenge_id": challengeID},
+		bson.M{"$unset": bson.M{"mfa.challenge_id": "", "mfa.challenge_failures": ""}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount != 1 {
+		return domain.ErrInvalidToken
+	}
+	return nil
+}
+
+// Search implements domain.UserRepository
+func (r *MongoUserRepository) Search(ctx context.Context, filter domain.UserFilter) ([]domain.User, error) {
+	ctx, done := observe(ctx, "users", "Search")
+	defer done()
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+	if filter.Limit > 0 {
+		opts.SetLimit(int64(filter.Limit))
+	}
+	cursor, err := r.collection.Find(ctx, userQuery(filter), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	users := []domain.User{}
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// Each implements domain.UserRepository
+func (r *MongoUserRepository) Each(ctx context.Context, filter domain.UserFilter, fn func(domain.User) error) error {
+	ctx, done := observe(ctx, "users", "Each")
+	defer done()
+	// รหัสผ่านและ secret ของ MFA ไม่ถูกส่งออกจากฐานข้อมูลเลย
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetBatchSize(500).
+		SetProjection(bson.M{
+			"password":           0,
+			"mfa.secret":         0,
+			"mfa.pending_secret": 0,
+			"mfa.recovery_codes": 0,
+		})
+	if filter.Limit > 0 {
+		opts.SetLimit(int64(filter.Limit))
+	}
+	cursor, err := r.collection.Find(ctx, userQuery(filter), opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var user domain.User
+		if err := cursor.Decode(&user); err != nil {
+			return err
+		}
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// userQuery แปลง domain.UserFilter เป็น query ของ MongoDB
+func userQuery(filter domain.UserFilter) bson.M {
+	query := bson.M{}
+	if !filter.IncludeDeleted {
+		query["deleted_at"] = nil
+	}
+	if filter.DeletedBefore != nil {
+		query["deleted_at"] = bson.M{"$ne": nil, "$lt": *filter.DeletedBefore}
+	}
+	if !filter.ID.IsZero() {
+		query["_id"] = filter.ID
+	}
+	if filter.Email != "" {
+		query["email"] = filter.Email
+	}
+	if filter.Query != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(filter.Query), Options: "i"}
+		query["$or"] = bson.A{bson.M{"name": pattern}, bson.M{"email": pattern}}
+	}
+	if filter.Role != "" {
+		query["role"] = filter.Role
+	}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	if filter.StatusUntilBefore != nil {
+		query["status_until"] = bson.M{"$lt": *filter.StatusUntilBefore}
+	}
+	if filter.CreatedFrom != nil || filter.CreatedTo != nil {
+		created := bson.M{}
+		if filter.CreatedFrom != nil {
+			created["$gte"] = *filter.CreatedFrom
+		}
+		if filter.CreatedTo != nil {
+			created["$lt"] = *filter.CreatedTo
+		}
+		query["created_at"] = created
+	}
+	return query
+}
+
 // Count implements domain.UserRepository
 func (r *MongoUserRepository) Count(ctx context.Context) (int64, error) {
-	return r.collection.CountDocuments(ctx, bson.M{})
+	ctx, done := observe(ctx, "users", "Count")
+	defer done()
+	return r.collection.CountDocuments(ctx, bson.M{"deleted_at": nil})
+}
+
+// FindByLinkedIdentity implements domain.UserRepository
+func (r *MongoUserRepository) FindByLinkedIdentity(ctx context.Context, issuer, subject string) (domain.User, error) {
+	ctx, done := observe(ctx, "users", "FindByLinkedIdentity")
+	defer done()
+	var user domain.User
+	err := r.collection.FindOne(ctx, bson.M{
+		"linked_identities": bson.M{"$elemMatch": bson.M{"issuer": issuer, "subject": subject}},
+		"deleted_at":        nil,
+	}).Decode(&user)
+	return user, err
+}
+
+// AddLinkedIdentity implements domain.UserRepository
+func (r *MongoUserRepository) AddLinkedIdentity(ctx context.Context, id primitive.ObjectID, identity domain.LinkedIdentity) error {
+	ctx, done := observe(ctx, "users", "AddLinkedIdentity")
+	defer done()
+	// ห้ามผูกบัญชีภายนอกเดียวกันกับผู้ใช้มากกว่าหนึ่งคน unique index (migration 0008)
+	// กันกรณีที่สอง request ผ่านการตรวจนี้พร้อมกัน
+	count, err := r.collection.CountDocuments(ctx, bson.M{
+		"linked_identities": bson.M{"$elemMatch": bson.M{"issuer": identity.Issuer, "subject": identity.Subject}},
+	})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return domain.ErrIdentityLinked
+	}
+
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{
+			"_id":        id,
+			"deleted_at": nil,
+		},
+		bson.M{
+			"$push": bson.M{"linked_identities": identity},
+			"$set":  bson.M{"updated_at": time.Now()},
+		},
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return domain.ErrIdentityLinked
+	}
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrUserNotFound
+	}
+	return nil
+}
+
+// RemoveLinkedIdentity implements domain.UserRepository
+func (r *MongoUserRepository) RemoveLinkedIdentity(ctx context.Context, id primitive.ObjectID, issuer, subject string) error {
+	ctx, done := observe(ctx, "users", "RemoveLinkedIdentity")
+	defer done()
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{
+			"_id":        id,
+			"deleted_at": nil,
+		},
+		bson.M{
+			"$pull": bson.M{"linked_identities": bson.M{"issuer": issuer, "subject": subject}},
+			"$set":  bson.M{"updated_at": time.Now()},
+		},
+	)
+	return err
 }
 
 // Create implements domain.LogRepository
 func (r *MongoLogRepository) Create(ctx context.Context, log domain.RequestLog) error {
+	ctx, done := observe(ctx, "request_logs", "Create")
+	defer done()
 	_, err := r.collection.InsertOne(ctx, log)
 	return err
 }
+
+// CreateMany implements domain.LogRepository
+func (r *MongoLogRepository) CreateMany(ctx context.Context, logs []domain.RequestLog) error {
+	ctx, done := observe(ctx, "request_logs", "CreateMany")
+	defer done()
+	if len(logs) == 0 {
+		return nil
+	}
+	docs := make([]interface{}, len(logs))
+	for i, l := range logs {
+		docs[i] = l
+	}
+	// unordered: เอกสารที่เสียหนึ่งรายการไม่ทำให้รายการที่เหลือในชุดหายไปด้วย
+	_, err := r.collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	return err
+}
+
+// EnsureIndexes สร้าง index ที่ใช้ค้นหาและสรุปสถิติ request log
+func (r *MongoLogRepository) EnsureIndexes(ctx context.Context) error {
+	ctx, done := observe(ctx, "request_logs", "EnsureIndexes")
+	defer done()
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "timestamp", Value: -1}, {Key: "status", Value: 1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "timestamp", Value: -1}}},
+		{Keys: bson.D{{Key: "request_id", Value: 1}}},
+		// ใช้หา log ที่ admin ส่งในนามผู้ใช้อื่นตอนส่งออกหรือลบข้อมูลของ admin คนนั้น
+		{Keys: bson.D{{Key: "impersonator_id", Value: 1}}, Options: options.Index().SetSparse(true)},
+	})
+	return err
+}
+
+// Find implements domain.LogRepository
+func (r *MongoLogRepository) Find(ctx context.Context, filter domain.LogFilter) ([]domain.RequestLog, int64, error) {
+	ctx, done := observe(ctx, "request_logs", "Find")
+	defer done()
+	query := bson.M{}
+	if filter.Path != "" {
+		query["path"] = bson.M{"$regex": "^" + regexp.QuoteMeta(filter.Path)}
+	}
+	if filter.StatusMin > 0 || filter.StatusMax > 0 {
+		status := bson.M{}
+		if filter.StatusMin > 0 {
+			status["$gte"] = filter.StatusMin
+		}
+		if filter.StatusMax > 0 {
+			status["$lte"] = filter.StatusMax
+		}
+		query["status"] = status
+	}
+	if filter.IP != "" {
+		query["ip"] = filter.IP
+	}
+	if filter.UserID != "" {
+		query["user_id"] = filter.UserID
+	}
+	if filter.RequestID != "" {
+		query["request_id"] = filter.RequestID
+	}
+	if filter.From != nil || filter.To != nil {
+		timestamp := bson.M{}
+		if filter.From != nil {
+			timestamp["$gte"] = *filter.From
+		}
+		if filter.To != nil {
+			timestamp["$lt"] = *filter.To
+		}
+		query["timestamp"] = timestamp
+	}
+
+	total, err := r.collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64((filter.Page - 1) * filter.Limit)).
+		SetLimit(int64(filter.Limit))
+	cursor, err := r.collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	logs := []domain.RequestLog{}
+	if err := cursor.All(ctx, &logs); err != nil {
+		return nil, 0, err
+	}
+	return logs, total, nil
+}
+
+// Stats implements domain.LogRepository
+// ต้องใช้ MongoDB 5.0 ขึ้นไปสำหรับ $dateTrunc
+func (r *MongoLogRepository) Stats(ctx context.Context, filter domain.LogStatsFilter) ([]domain.RouteStats, error) {
+	ctx, done := observe(ctx, "request_logs", "Stats")
+	defer done()
+	// log ที่บันทึกก่อนมี route template ใช้ path แทน
+	route := bson.M{"$cond": bson.A{
+		bson.M{"$gt": bson.A{bson.M{"$ifNull": bson.A{"$route", ""}}, ""}},
+		"$route",
+		"$path",
+	}}
+	statusBetween := func(lo, hi int) bson.M {
+		return bson.M{"$cond": bson.A{
+			bson.M{"$and": bson.A{
+				bson.M{"$gte": bson.A{"$status", lo}},
+				bson.M{"$lt": bson.A{"$status", hi}},
+			}},
+			1, 0,
+		}}
+	}
+	// histogram แบบ log scale ตาม domain.LatencyBinOf ทำให้แต่ละกลุ่มเก็บไม่เกินไม่กี่พัน bin
+	// แทนที่จะเก็บ latency ทุกค่า จึงไม่ชนขีดจำกัดหน่วยความจำของ $group และขนาดเอกสาร 16MB
+	bin := bson.M{"$toInt": bson.M{"$floor": bson.M{"$divide": bson.A{
+		bson.M{"$ln": bson.M{"$add": bson.A{bson.M{"$max": bson.A{"$latency_ms", 0}}, 1}}},
+		domain.LatencyBinDivisor(),
+	}}}}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"timestamp": bson.M{"$gte": filter.From, "$lt": filter.To}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{
+				"method": "$method",
+				"route":  route,
+				"bucket": bson.M{"$dateTrunc": bson.M{"date": "$timestamp", "unit": filter.Bucket}},
+				"bin":    bin,
+			},
+			"count":         bson.M{"$sum": 1},
+			"client_errors": bson.M{"$sum": statusBetween(400, 500)},
+			"server_errors": bson.M{"$sum": statusBetween(500, 600)},
+			"max":           bson.M{"$max": "$latency_ms"},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":           bson.M{"method": "$_id.method", "route": "$_id.route", "bucket": "$_id.bucket"},
+			"count":         bson.M{"$sum": "$count"},
+			"client_errors": bson.M{"$sum": "$client_errors"},
+			"server_errors": bson.M{"$sum": "$server_errors"},
+			"bins":          bson.M{"$push": bson.M{"bin": "$_id.bin", "count": "$count", "max": "$max"}},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":           0,
+			"method":        "$_id.method",
+			"route":         "$_id.route",
+			"bucket_start":  "$_id.bucket",
+			"count":         1,
+			"client_errors": 1,
+			"server_errors": 1,
+			"error_rate":    bson.M{"$divide": bson.A{"$server_errors", "$count"}},
+			"bins":          1,
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "bucket_start", Value: 1}, {Key: "count", Value: -1}, {Key: "route", Value: 1}}}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var docs []struct {
+		domain.RouteStats `bson:",inline"`
+		Bins              []domain.LatencyBin `bson:"bins"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	stats := make([]domain.RouteStats, 0, len(docs))
+	for _, d := range docs {
+		d.P50 = domain.LatencyPercentile(d.Bins, 0.50)
+		d.P95 = domain.LatencyPercentile(d.Bins, 0.95)
+		d.P99 = domain.LatencyPercentile(d.Bins, 0.99)
+		stats = append(stats, d.RouteStats)
+	}
+	return stats, nil
+}
+
+// EachByUser implements domain.LogRepository
+func (r *MongoLogRepository) EachByUser(ctx context.Context, userKeys []string, fn func(domain.RequestLog) error) error {
+	ctx, done := observe(ctx, "request_logs", "EachByUser")
+	defer done()
+	keys := bson.M{"$in": userKeys}
+	query := bson.M{"$or": bson.A{bson.M{"user_id": keys}, bson.M{"impersonator_id": keys}}}
+	cursor, err := r.collection.Find(ctx, query,
+		options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}, {Key: "_id", Value: 1}}),
+	)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var entry domain.RequestLog
+		if err := cursor.Decode(&entry); err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
@@ -0,0 +1,102 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoSessionRepository implements domain.SessionRepository
+type MongoSessionRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoSessionRepository creates a new instance of MongoSessionRepository
+func NewMongoSessionRepository(collection *mongo.Collection) *MongoSessionRepository {
+	return &MongoSessionRepository{
+		collection: collection,
+	}
+}
+
+// Create implements domain.SessionRepository
+func (r *MongoSessionRepository) Create(ctx context.Context, session domain.Session) (primitive.ObjectID, error) {
+	ctx, done := observe(ctx, "sessions", "Create")
+	defer done()
+	result, err := r.collection.InsertOne(ctx, session)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return result.InsertedID.(primitive.ObjectID), nil
+}
+
+// FindByID implements domain.SessionRepository
+func (r *MongoSessionRepository) FindByID(ctx context.Context, id primitive.ObjectID) (domain.Session, error) {
+	ctx, done := observe(ctx, "sessions", "FindByID")
+	defer done()
+	var session domain.Session
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&session)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return session, domain.ErrSessionNotFound
+	}
+	return session, err
+}
+
+// FindActiveByUser implements domain.SessionRepository
+func (r *MongoSessionRepository) FindActiveByUser(ctx context.Context, userID primitive.ObjectID) ([]domain.Session, error) {
+	ctx, done := observe(ctx, "sessions", "FindActiveByUser")
+	defer done()
+	cursor, err := r.collection.Find(
+		ctx,
+		bson.M{
+			"user_id":    userID,
+			"revoked_at": nil,
+			"expires_at": bson.M{"$gt": time.Now()},
+		},
+		options.Find().SetSort(bson.M{"last_seen_at": -1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	sessions := []domain.Session{}
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// Touch implements domain.SessionRepository
+func (r *MongoSessionRepository) Touch(ctx context.Context, id primitive.ObjectID, ip string) error {
+	ctx, done := observe(ctx, "sessions", "Touch")
+	defer done()
+	_, err := r.collection.UpdateByID(ctx, id, bson.M{"$set": bson.M{
+		"last_seen_at": time.Now(),
+		"ip":           ip,
+	}})
+	return err
+}
+
+// Revoke implements domain.SessionRepository
+func (r *MongoSessionRepository) Revoke(ctx context.Context, id primitive.ObjectID) error {
+	ctx, done := observe(ctx, "sessions", "Revoke")
+	defer done()
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrSessionNotFound
+	}
+	return nil
+}
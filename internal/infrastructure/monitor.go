@@ -0,0 +1,63 @@
+package infrastructure
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// CommandMonitor log คำสั่ง MongoDB ของทุก repository ผ่าน logger พร้อม request_id จาก context
+// คำสั่งที่ล้มเหลวหรือใช้เวลาเกิน slow เป็นระดับ warn ส่วนคำสั่งปกติเป็นระดับ debug
+// log ไม่มีเนื้อหาของคำสั่งเพราะอาจมีข้อมูลผู้ใช้
+func CommandMonitor(logger *slog.Logger, slow time.Duration) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Succeeded: func(ctx context.Context, evt *event.CommandSucceededEvent) {
+			level := slog.LevelDebug
+			if slow > 0 && evt.Duration >= slow {
+				level = slog.LevelWarn
+			}
+			logger.LogAttrs(ctx, level, "mongo command",
+				slog.String("command", evt.CommandName),
+				slog.String("database", evt.DatabaseName),
+				slog.Int64("duration_ms", evt.Duration.Milliseconds()),
+			)
+		},
+		Failed: func(ctx context.Context, evt *event.CommandFailedEvent) {
+			logger.LogAttrs(ctx, slog.LevelWarn, "mongo command failed",
+				slog.String("command", evt.CommandName),
+				slog.String("database", evt.DatabaseName),
+				slog.Int64("duration_ms", evt.Duration.Milliseconds()),
+				slog.String("error", evt.Failure),
+			)
+		},
+	}
+}
+
+// Monitors รวมหลาย CommandMonitor เข้าด้วยกัน เพราะ mongo client รับได้เพียงตัวเดียว
+func Monitors(monitors ...*event.CommandMonitor) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, evt *event.CommandStartedEvent) {
+			for _, m := range monitors {
+				if m.Started != nil {
+					m.Started(ctx, evt)
+				}
+			}
+		},
+		Succeeded: func(ctx context.Context, evt *event.CommandSucceededEvent) {
+			for _, m := range monitors {
+				if m.Succeeded != nil {
+					m.Succeeded(ctx, evt)
+				}
+			}
+		},
+		Failed: func(ctx context.Context, evt *event.CommandFailedEvent) {
+			for _, m := range monitors {
+				if m.Failed != nil {
+					m.Failed(ctx, evt)
+				}
+			}
+		},
+	}
+}
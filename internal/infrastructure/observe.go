@@ -0,0 +1,28 @@
+package infrastructure
+
+import (
+	"context"
+
+	"github.com/Gsupakin/back_end_test_challeng/pkg/metrics"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// observe เริ่ม span ของ repository method และจับเวลาลง metrics ใช้แบบ
+//
+//	ctx, done := observe(ctx, "users", "FindByID")
+//	defer done()
+//
+// คำสั่ง MongoDB ที่ใช้ ctx ที่คืนกลับไปจะเป็น span ลูกของ method นี้
+func observe(ctx context.Context, repository, operation string) (context.Context, func()) {
+	ctx, span := tracing.Start(ctx, repository+"."+operation,
+		attribute.String("db.system", "mongodb"),
+		attribute.String("repository", repository),
+	)
+	stop := metrics.ObserveMongo(repository, operation)
+	return ctx, func() {
+		stop()
+		span.End()
+	}
+}
@@ -0,0 +1,99 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ExpiredReason คือเหตุผลที่ Reactivator บันทึกเมื่อเปิดใช้งานผู้ใช้คืน
+const ExpiredReason = "suspension expired"
+
+// reactivateBatchSize คือจำนวนผู้ใช้ที่อ่านจากฐานข้อมูลต่อรอบ
+const reactivateBatchSize = 100
+
+// Reactivator เปิดใช้งานผู้ใช้ที่ถูกระงับชั่วคราวและเลยเวลา status_until แล้ว
+// ผ่าน UserRepository.ChangeStatus จึงได้ audit event user.reactivate เหมือนที่ admin ทำเอง
+type Reactivator struct {
+	users  domain.UserRepository
+	logger *slog.Logger
+}
+
+func NewReactivator(users domain.UserRepository) *Reactivator {
+	return &Reactivator{users: users, logger: slog.Default()}
+}
+
+// WithLogger เปลี่ยน logger ที่ RunEvery ใช้รายงานผล
+func (r *Reactivator) WithLogger(l *slog.Logger) *Reactivator {
+	r.logger = l
+	return r
+}
+
+// Run เปิดใช้งานผู้ใช้ทุกคนที่การระงับหมดเวลาก่อน now และคืน ID ของผู้ใช้เหล่านั้น
+// ผู้ใช้ที่สถานะถูกเปลี่ยนไประหว่างนั้นจะถูกข้าม หยุดที่ error อื่นแล้วลองใหม่ในรอบถัดไป
+func (r *Reactivator) Run(ctx context.Context, now time.Time) ([]primitive.ObjectID, error) {
+	transition, err := domain.FindTransition(domain.StatusSuspended, domain.TransitionReactivate)
+	if err != nil {
+		return nil, err
+	}
+
+	var reactivated []primitive.ObjectID
+	skipped := map[primitive.ObjectID]bool{}
+	for {
+		users, err := r.users.Search(ctx, domain.UserFilter{
+			Status:            domain.StatusSuspended,
+			StatusUntilBefore: &now,
+			Limit:             reactivateBatchSize,
+		})
+		if err != nil {
+			return reactivated, err
+		}
+		progressed := false
+		for _, user := range users {
+			if skipped[user.ID] {
+				continue
+			}
+			change := domain.StatusChange{Transition: transition, Reason: ExpiredReason, At: now}
+			err := r.users.ChangeStatus(ctx, user.ID, change)
+			if errors.Is(err, domain.ErrInvalidTransition) || errors.Is(err, domain.ErrUserNotFound) {
+				skipped[user.ID] = true
+				continue
+			}
+			if err != nil {
+				return reactivated, fmt.Errorf("reactivate user %s: %w", user.ID.Hex(), err)
+			}
+			reactivated = append(reactivated, user.ID)
+			progressed = true
+		}
+		if len(users) < reactivateBatchSize || !progressed {
+			return reactivated, nil
+		}
+	}
+}
+
+// RunEvery เรียก Run ทันทีและทุก interval จนกว่า ctx จะถูกยกเลิก
+func (r *Reactivator) RunEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ids, err := r.Run(ctx, time.Now())
+		for _, id := range ids {
+			r.logger.Info("reactivated user after suspension expired", "user_id", id.Hex())
+		}
+		if err != nil && !errors.Is(err, context.Canceled) {
+			r.logger.Error("user reactivation failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
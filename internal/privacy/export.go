@@ -0,0 +1,101 @@
+// Package privacy สร้างไฟล์ ZIP ข้อมูลส่วนบุคคลที่เจ้าของข้อมูลขอดาวน์โหลดได้
+// และลบผู้ใช้ที่ถูก soft delete เกินระยะเวลาที่กำหนดอย่างถาวร
+package privacy
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+)
+
+// ชื่อไฟล์ใน ZIP
+const (
+	ProfileFile     = "profile.json"
+	RequestLogsFile = "request_logs.json"
+)
+
+// Profile คือข้อมูลผู้ใช้ที่ส่งออก ไม่มีรหัสผ่านและ secret ของ MFA
+type Profile struct {
+	ID               string                  `json:"id"`
+	Name             string                  `json:"name"`
+	Email            string                  `json:"email"`
+	Role             string                  `json:"role"`
+	Status           domain.UserStatus       `json:"status"`
+	MFAEnabled       bool                    `json:"mfa_enabled"`
+	MFAEnrolledAt    *time.Time              `json:"mfa_enrolled_at,omitempty"`
+	LinkedIdentities []domain.LinkedIdentity `json:"linked_identities"`
+	LastLogin        *time.Time              `json:"last_login,omitempty"`
+	CreatedAt        time.Time               `json:"created_at"`
+	UpdatedAt        *time.Time              `json:"updated_at,omitempty"`
+}
+
+// NewProfile คัดเฉพาะ field ที่ส่งออกได้จาก user
+func NewProfile(user domain.User) Profile {
+	identities := user.LinkedIdentities
+	if identities == nil {
+		identities = []domain.LinkedIdentity{}
+	}
+	return Profile{
+		ID:               user.ID.Hex(),
+		Name:             user.Name,
+		Email:            user.Email,
+		Role:             user.Role,
+		Status:           user.Status,
+		MFAEnabled:       user.MFA.Enabled,
+		MFAEnrolledAt:    user.MFA.EnrolledAt,
+		LinkedIdentities: identities,
+		LastLogin:        user.LastLogin,
+		CreatedAt:        user.CreatedAt,
+		UpdatedAt:        user.UpdatedAt,
+	}
+}
+
+// WriteExport เขียน ZIP ที่มี profile.json และ request_logs.json (JSON array ของทุก request log
+// ที่ผู้ใช้ส่งเองหรือส่งในนามผู้อื่นตอนสวมสิทธิ์) ลง w
+// userKeys คือค่าที่ user_id ของผู้ใช้อาจถูกบันทึกไว้ใน log ดู redact.Redactor.UserIDKeys
+// log ถูกอ่านจาก cursor และเขียนทีละรายการจึงใช้หน่วยความจำคงที่ ZIP จะไม่สมบูรณ์ถ้าคืน error
+func WriteExport(ctx context.Context, w io.Writer, user domain.User, logs domain.LogRepository, userKeys []string, now time.Time) error {
+	archive := zip.NewWriter(w)
+
+	file, err := archive.CreateHeader(&zip.FileHeader{Name: ProfileFile, Method: zip.Deflate, Modified: now})
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(file)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(NewProfile(user)); err != nil {
+		return err
+	}
+
+	file, err = archive.CreateHeader(&zip.FileHeader{Name: RequestLogsFile, Method: zip.Deflate, Modified: now})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(file, "["); err != nil {
+		return err
+	}
+	separator := "\n"
+	err = logs.EachByUser(ctx, userKeys, func(entry domain.RequestLog) error {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(file, separator); err != nil {
+			return err
+		}
+		separator = ",\n"
+		_, err = file.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(file, "\n]\n"); err != nil {
+		return err
+	}
+	return archive.Close()
+}
@@ -0,0 +1,112 @@
+package privacy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PurgeReason คือเหตุผลในคำขอลบข้อมูลที่ Purger บันทึกไว้เป็นหลักฐาน
+const PurgeReason = "retention"
+
+// purgeBatchSize คือจำนวนผู้ใช้ที่อ่านจากฐานข้อมูลต่อรอบ
+const purgeBatchSize = 100
+
+// PurgeResult คือผลการลบผู้ใช้หนึ่งคน
+type PurgeResult struct {
+	UserID    primitive.ObjectID
+	ErasureID primitive.ObjectID
+	Receipt   domain.ErasureReceipt
+}
+
+// Purger ลบผู้ใช้ที่ถูก soft delete นานกว่า after อย่างถาวรด้วย PersonalDataEraser เดียวกับคำขอลบข้อมูล
+// และบันทึกคำขอที่เสร็จแล้วพร้อมใบยืนยันการลบ โดย RequestedBy เป็น NilObjectID เพราะระบบเป็นผู้ลบเอง
+type Purger struct {
+	users    domain.UserRepository
+	erasures domain.ErasureRepository
+	eraser   domain.PersonalDataEraser
+	after    time.Duration
+	logger   *slog.Logger
+}
+
+func NewPurger(users domain.UserRepository, erasures domain.ErasureRepository, eraser domain.PersonalDataEraser, after time.Duration) *Purger {
+	return &Purger{users: users, erasures: erasures, eraser: eraser, after: after, logger: slog.Default()}
+}
+
+// WithLogger เปลี่ยน logger ที่ RunEvery ใช้รายงานผล
+func (p *Purger) WithLogger(l *slog.Logger) *Purger {
+	p.logger = l
+	return p
+}
+
+// Run ลบผู้ใช้ทุกคนที่ถูก soft delete ก่อน now - after เริ่มจากคนที่สร้างก่อน
+// หยุดที่ผู้ใช้คนแรกที่ลบไม่สำเร็จ ผู้ใช้คนนั้นยังอยู่และจะถูกลองใหม่ในรอบถัดไป
+func (p *Purger) Run(ctx context.Context, now time.Time) ([]PurgeResult, error) {
+	cutoff := now.Add(-p.after)
+
+	var results []PurgeResult
+	for {
+		users, err := p.users.Search(ctx, domain.UserFilter{DeletedBefore: &cutoff, Limit: purgeBatchSize})
+		if err != nil {
+			return results, err
+		}
+		for _, user := range users {
+			result, err := p.purge(ctx, user.ID)
+			if err != nil {
+				return results, fmt.Errorf("purge user %s: %w", user.ID.Hex(), err)
+			}
+			results = append(results, result)
+		}
+		if len(users) < purgeBatchSize {
+			return results, nil
+		}
+	}
+}
+
+func (p *Purger) purge(ctx context.Context, userID primitive.ObjectID) (PurgeResult, error) {
+	counts, err := p.eraser.Erase(ctx, userID, domain.NewPseudonym())
+	if err != nil {
+		return PurgeResult{}, err
+	}
+	receipt := domain.ErasureReceipt{ErasedAt: time.Now(), Collections: counts, Limitations: p.eraser.Limitations()}
+	id, err := p.erasures.Create(ctx, domain.ErasureRequest{
+		UserID:      userID,
+		RequestedBy: primitive.NilObjectID,
+		Reason:      PurgeReason,
+		Status:      domain.ErasureCompleted,
+		RequestedAt: receipt.ErasedAt,
+		DecidedAt:   &receipt.ErasedAt,
+		Receipt:     &receipt,
+	})
+	if err != nil {
+		return PurgeResult{}, fmt.Errorf("user was erased but the receipt could not be saved: %w", err)
+	}
+	return PurgeResult{UserID: userID, ErasureID: id, Receipt: receipt}, nil
+}
+
+// RunEvery เรียก Run ทันทีและทุก interval จนกว่า ctx จะถูกยกเลิก
+func (p *Purger) RunEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		results, err := p.Run(ctx, time.Now())
+		for _, r := range results {
+			p.logger.Info("purged deleted user", "erasure_id", r.ErasureID.Hex(), "collections", r.Receipt.Collections)
+		}
+		if err != nil && !errors.Is(err, context.Canceled) {
+			p.logger.Error("user purge failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package requestlog
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+)
+
+const (
+	day            = 24 * time.Hour
+	restoreBatch   = 500
+	maxArchiveLine = 1 << 20
+)
+
+// ArchiveConfig คือการตั้งค่าการเก็บรักษาและ archive request log
+type ArchiveConfig struct {
+	Retention    time.Duration // TTL ของ log ใน MongoDB, 0 คือเก็บตลอด
+	ArchiveAfter time.Duration // archive log ที่เก่ากว่านี้ลงไฟล์แล้วลบออก, 0 คือไม่ archive
+	Dir          string
+	Interval     time.Duration
+}
+
+// ArchiveResult คือผลการ archive log ของหนึ่งวัน
+type ArchiveResult struct {
+	Day   time.Time
+	File  string
+	Count int64
+}
+
+// Archiver ย้าย log ที่เก่ากว่า after ไปเป็นไฟล์ NDJSON บีบอัดด้วย gzip วันละหนึ่งไฟล์ (UTC)
+type Archiver struct {
+	store  domain.LogArchiveRepository
+	dir    string
+	after  time.Duration
+	logger *slog.Logger
+}
+
+func NewArchiver(store domain.LogArchiveRepository, dir string, after time.Duration) *Archiver {
+	return &Archiver{store: store, dir: dir, after: after, logger: slog.Default()}
+}
+
+// WithLogger เปลี่ยน logger ที่ RunEvery ใช้รายงานผล
+func (a *Archiver) WithLogger(l *slog.Logger) *Archiver {
+	a.logger = l
+	return a
+}
+
+// ArchiveFileName คืนชื่อไฟล์ archive ของวันนั้น
+func ArchiveFileName(d time.Time) string {
+	return "request_logs-" + d.UTC().Format("2006-01-02") + ".ndjson.gz"
+}
+
+// Run archive log ทุกวันที่จบก่อน now - after ทีละวันเริ่มจากวันที่เก่าที่สุด
+// log ของวันหนึ่งจะถูกลบก็ต่อเมื่อไฟล์ถูกเขียนและตรวจจำนวนแล้วเท่านั้น
+func (a *Archiver) Run(ctx context.Context, now time.Time) ([]ArchiveResult, error) {
+	if err := os.MkdirAll(a.dir, 0o750); err != nil {
+		return nil, err
+	}
+	cutoff := startOfDay(now.Add(-a.after))
+
+	var results []ArchiveResult
+	for {
+		oldest, err := a.store.Oldest(ctx)
+		if errors.Is(err, domain.ErrLogNotFound) {
+			return results, nil
+		}
+		if err != nil {
+			return results, err
+		}
+		if !oldest.Before(cutoff) {
+			return results, nil
+		}
+
+		from := startOfDay(oldest)
+		to := from.Add(day)
+		if to.After(cutoff) {
+			to = cutoff
+		}
+		result, err := a.archiveRange(ctx, from, to)
+		if err != nil {
+			return results, fmt.Errorf("archive %s: %w", from.Format("2006-01-02"), err)
+		}
+		results = append(results, result)
+	}
+}
+
+// RunEvery เรียก Run ทันทีและทุก interval จนกว่า ctx จะถูกยกเลิก
+func (a *Archiver) RunEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		results, err := a.Run(ctx, time.Now())
+		for _, r := range results {
+			a.logger.Info("archived request logs", "day", r.Day.Format("2006-01-02"), "count", r.Count, "file", r.File)
+		}
+		if err != nil && !errors.Is(err, context.Canceled) {
+			a.logger.Error("request log archival failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *Archiver) archiveRange(ctx context.Context, from, to time.Time) (ArchiveResult, error) {
+	result := ArchiveResult{Day: from}
+
+	expected, err := a.store.CountRange(ctx, from, to)
+	if err != nil {
+		return result, err
+	}
+
+	result.File = a.nextFileName(from)
+	tmp := result.File + ".tmp"
+	written, err := a.writeFile(ctx, tmp, from, to)
+	if err != nil {
+		os.Remove(tmp)
+		return result, err
+	}
+	if written != expected {
+		os.Remove(tmp)
+		return result, fmt.Errorf("wrote %d logs but expected %d", written, expected)
+	}
+
+	// อ่านไฟล์กลับมานับอีกครั้งเพื่อยืนยันว่าไฟล์สมบูรณ์ก่อนลบข้อมูลจริง
+	read, err := countLines(tmp)
+	if err != nil || read != expected {
+		os.Remove(tmp)
+		return result, fmt.Errorf("verify archive: read %d of %d logs: %v", read, expected, err)
+	}
+	if err := os.Rename(tmp, result.File); err != nil {
+		os.Remove(tmp)
+		return result, err
+	}
+
+	// ถ้ามี log ถูกเพิ่มเข้ามาในช่วงนี้ระหว่าง archive จะไม่ลบ เพื่อไม่ให้ log ที่ไม่อยู่ในไฟล์หายไป
+	current, err := a.store.CountRange(ctx, from, to)
+	if err != nil {
+		return result, err
+	}
+	if current != expected {
+		return result, fmt.Errorf("logs changed during archival (%d -> %d), kept them in the database", expected, current)
+	}
+	deleted, err := a.store.DeleteRange(ctx, from, to)
+	if err != nil {
+		return result, err
+	}
+	result.Count = deleted
+	return result, nil
+}
+
+// nextFileName คืนชื่อไฟล์ที่ยังไม่มีอยู่ ถ้าวันนั้นเคย archive แล้วจะต่อท้ายด้วยลำดับ
+func (a *Archiver) nextFileName(d time.Time) string {
+	path := filepath.Join(a.dir, ArchiveFileName(d))
+	for i := 1; fileExists(path); i++ {
+		path = filepath.Join(a.dir, fmt.Sprintf("request_logs-%s.%d.ndjson.gz", d.UTC().Format("2006-01-02"), i))
+	}
+	return path
+}
+
+func (a *Archiver) writeFile(ctx context.Context, path string, from, to time.Time) (int64, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	buf := bufio.NewWriter(gz)
+	enc := json.NewEncoder(buf)
+
+	var written int64
+	err = a.store.EachInRange(ctx, from, to, func(entry domain.RequestLog) error {
+		written++
+		return enc.Encode(entry)
+	})
+	if err != nil {
+		return written, err
+	}
+	if err := buf.Flush(); err != nil {
+		return written, err
+	}
+	if err := gz.Close(); err != nil {
+		return written, err
+	}
+	if err := f.Sync(); err != nil {
+		return written, err
+	}
+	return written, f.Close()
+}
+
+// RestoreFile นำ log จากไฟล์ archive กลับเข้า collection log ที่มีอยู่แล้วจะถูกข้าม
+// จึงเรียกซ้ำกับไฟล์เดิมได้อย่างปลอดภัย คืนจำนวนที่อ่านได้และจำนวนที่เพิ่มจริง
+func RestoreFile(ctx context.Context, store domain.LogArchiveRepository, path string) (read, inserted int64, err error) {
+	batch := make([]domain.RequestLog, 0, restoreBatch)
+	flush := func() error {
+		n, err := store.Restore(ctx, batch)
+		inserted += n
+		batch = batch[:0]
+		return err
+	}
+
+	err = eachLine(path, func(line []byte) error {
+		var entry domain.RequestLog
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return fmt.Errorf("line %d: %w", read+1, err)
+		}
+		read++
+		batch = append(batch, entry)
+		if len(batch) == restoreBatch {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return read, inserted, err
+	}
+	return read, inserted, flush()
+}
+
+func countLines(path string) (int64, error) {
+	var n int64
+	err := eachLine(path, func([]byte) error {
+		n++
+		return nil
+	})
+	return n, err
+}
+
+func eachLine(path string, fn func([]byte) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 64*1024), maxArchiveLine)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if err := fn(scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func startOfDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,18 @@
+package requestlog
+
+import (
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/redact"
+)
+
+// Redact ใช้ policy ของ redactor กับทุก field ที่อาจเป็นข้อมูลส่วนบุคคลใน request log
+func Redact(r *redact.Redactor, entry domain.RequestLog) domain.RequestLog {
+	entry.IP = r.IP(entry.IP)
+	entry.UserAgent = r.UserAgent(entry.UserAgent)
+	entry.Path = r.Path(entry.Path, entry.Route)
+	entry.Query = r.Query(entry.Query)
+	entry.Error = r.Error(entry.Error)
+	entry.UserID = r.UserID(entry.UserID)
+	entry.ImpersonatorID = r.UserID(entry.ImpersonatorID)
+	return entry
+}
@@ -0,0 +1,239 @@
+// Package requestlog เขียน request log ลงฐานข้อมูลแบบ asynchronous ผ่านคิวขนาดจำกัด
+// โดยรวบเป็นชุดแล้วบันทึกทีเดียวเมื่อครบจำนวนหรือครบรอบเวลา
+package requestlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/redact"
+)
+
+// พฤติกรรมเมื่อคิวเต็ม
+const (
+	PolicyDrop  = "drop"  // ทิ้ง log ใหม่ทันที request ไม่ต้องรอ
+	PolicyBlock = "block" // รอจนคิวว่างหรือครบ BlockTimeout แล้วจึงทิ้ง
+)
+
+type Config struct {
+	QueueSize     int
+	BatchSize     int
+	FlushInterval time.Duration
+	Policy        string
+	BlockTimeout  time.Duration
+	WriteTimeout  time.Duration
+	// Redactor ลดข้อมูลส่วนบุคคลก่อนเข้าคิว ถ้าไม่กำหนดจะใช้ redact.DefaultPolicy
+	Redactor *redact.Redactor
+	// Logger ใช้รายงานชุดที่บันทึกไม่สำเร็จ ถ้าไม่กำหนดจะใช้ slog.Default
+	Logger *slog.Logger
+}
+
+// DefaultConfig คืนค่าเริ่มต้นที่ใช้ได้กับ traffic ทั่วไป
+func DefaultConfig() Config {
+	return Config{
+		QueueSize:     10000,
+		BatchSize:     500,
+		FlushInterval: time.Second,
+		Policy:        PolicyDrop,
+		BlockTimeout:  100 * time.Millisecond,
+		WriteTimeout:  5 * time.Second,
+	}
+}
+
+// Stats คือตัวนับของ pipeline ตั้งแต่เริ่มทำงาน
+type Stats struct {
+	Enqueued int64 `json:"enqueued"`
+	Dropped  int64 `json:"dropped"`
+	Written  int64 `json:"written"`
+	Failed   int64 `json:"failed"`
+	Batches  int64 `json:"batches"`
+	Queued   int   `json:"queued"`
+}
+
+// Writer รับ log จากหลาย goroutine แล้วให้ goroutine เดียวบันทึกเป็นชุด
+type Writer struct {
+	repo  domain.LogRepository
+	cfg   Config
+	queue chan domain.RequestLog
+
+	// mu ป้องกันการส่งเข้า queue หลังจากถูกปิดแล้ว
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+
+	enqueued atomic.Int64
+	dropped  atomic.Int64
+	written  atomic.Int64
+	failed   atomic.Int64
+	batches  atomic.Int64
+}
+
+// NewWriter สร้าง Writer และเริ่ม goroutine สำหรับ flush ทันที ต้องเรียก Close ตอนปิดโปรแกรม
+func NewWriter(repo domain.LogRepository, cfg Config) *Writer {
+	def := DefaultConfig()
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = def.QueueSize
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = def.BatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = def.FlushInterval
+	}
+	if cfg.Policy != PolicyBlock {
+		cfg.Policy = PolicyDrop
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = def.WriteTimeout
+	}
+	if cfg.Redactor == nil {
+		cfg.Redactor = redact.New(redact.DefaultPolicy(), nil, 24*time.Hour)
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+
+	w := &Writer{
+		repo:  repo,
+		cfg:   cfg,
+		queue: make(chan domain.RequestLog, cfg.QueueSize),
+		done:  make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Write ใส่ log เข้าคิว คืนค่า false ถ้า log ถูกทิ้งเพราะคิวเต็มหรือ Writer ปิดแล้ว
+func (w *Writer) Write(entry domain.RequestLog) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		w.dropped.Add(1)
+		return false
+	}
+	entry = Redact(w.cfg.Redactor, entry)
+
+	select {
+	case w.queue <- entry:
+		w.enqueued.Add(1)
+		return true
+	default:
+	}
+
+	if w.cfg.Policy == PolicyBlock {
+		var timeout <-chan time.Time
+		if w.cfg.BlockTimeout > 0 {
+			timer := time.NewTimer(w.cfg.BlockTimeout)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		select {
+		case w.queue <- entry:
+			w.enqueued.Add(1)
+			return true
+		case <-timeout:
+		}
+	}
+
+	w.dropped.Add(1)
+	return false
+}
+
+// Redactor คืน redactor ที่ Writer ใช้ เพื่อให้ console log ใช้ policy เดียวกัน
+func (w *Writer) Redactor() *redact.Redactor {
+	return w.cfg.Redactor
+}
+
+func (w *Writer) Stats() Stats {
+	return Stats{
+		Enqueued: w.enqueued.Load(),
+		Dropped:  w.dropped.Load(),
+		Written:  w.written.Load(),
+		Failed:   w.failed.Load(),
+		Batches:  w.batches.Load(),
+		Queued:   len(w.queue),
+	}
+}
+
+// Healthy คืน error เมื่อ Writer ปิดแล้วหรือคิวค้างถึงสัดส่วน threshold (0-1) ของขนาดคิว
+// ซึ่งหมายความว่าฐานข้อมูลบันทึก log ไม่ทันและ log ใหม่กำลังจะถูกทิ้ง
+func (w *Writer) Healthy(threshold float64) error {
+	w.mu.RLock()
+	closed := w.closed
+	w.mu.RUnlock()
+	if closed {
+		return errors.New("request log writer is closed")
+	}
+	if queued := len(w.queue); float64(queued) >= threshold*float64(cap(w.queue)) {
+		return fmt.Errorf("request log backlog %d of %d", queued, cap(w.queue))
+	}
+	return nil
+}
+
+// Close หยุดรับ log ใหม่ แล้วรอให้ log ที่ค้างในคิวถูกบันทึกจนหมดหรือจน ctx หมดเวลา
+func (w *Writer) Close(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *Writer) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]domain.RequestLog, 0, w.cfg.BatchSize)
+	for {
+		select {
+		case entry, ok := <-w.queue:
+			if !ok {
+				w.flush(batch)
+				return
+			}
+			batch = append(batch, entry)
+			if len(batch) >= w.cfg.BatchSize {
+				w.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				w.flush(batch)
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+func (w *Writer) flush(batch []domain.RequestLog) {
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), w.cfg.WriteTimeout)
+	defer cancel()
+
+	w.batches.Add(1)
+	if err := w.repo.CreateMany(ctx, batch); err != nil {
+		w.failed.Add(int64(len(batch)))
+		w.cfg.Logger.Error("failed to write request logs", "count", len(batch), "error", err)
+		return
+	}
+	w.written.Add(int64(len(batch)))
+}
@@ -0,0 +1,270 @@
+// Package userexport เขียนข้อมูลผู้ใช้ออกเป็น CSV, NDJSON หรือ Parquet ทีละคน
+// ใช้หน่วยความจำคงที่ไม่ว่าจะมีผู้ใช้กี่คน และไม่มี field รหัสผ่านให้เลือกเลย
+package userexport
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/parquet-go/parquet-go"
+)
+
+const (
+	FormatCSV     = "csv"
+	FormatNDJSON  = "ndjson"
+	FormatParquet = "parquet"
+
+	// parquetRowGroup คือจำนวนผู้ใช้ต่อ row group ของ Parquet ซึ่งต้องพักไว้ในหน่วยความจำก่อนเขียน
+	parquetRowGroup = 10000
+)
+
+// ContentTypes คือ Content-Type ของแต่ละรูปแบบ
+var ContentTypes = map[string]string{
+	FormatCSV:     "text/csv; charset=utf-8",
+	FormatNDJSON:  "application/x-ndjson",
+	FormatParquet: "application/vnd.apache.parquet",
+}
+
+// field คือคอลัมน์หนึ่งที่ส่งออกได้ value คืน string, bool, time.Time หรือ nil
+type field struct {
+	name  string
+	node  parquet.Node
+	value func(u domain.User) interface{}
+}
+
+func optionalTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return *t
+}
+
+var timestamp = parquet.Timestamp(parquet.Millisecond)
+
+// fields คือคอลัมน์ทั้งหมดตามลำดับเริ่มต้น
+var fields = []field{
+	{"id", parquet.String(), func(u domain.User) interface{} { return u.ID.Hex() }},
+	{"name", parquet.String(), func(u domain.User) interface{} { return u.Name }},
+	{"email", parquet.String(), func(u domain.User) interface{} { return u.Email }},
+	{"role", parquet.String(), func(u domain.User) interface{} { return u.Role }},
+	{"status", parquet.String(), func(u domain.User) interface{} { return string(u.Status) }},
+	{"mfa_enabled", parquet.Leaf(parquet.BooleanType), func(u domain.User) interface{} { return u.MFA.Enabled }},
+	{"linked_providers", parquet.String(), func(u domain.User) interface{} {
+		providers := make([]string, len(u.LinkedIdentities))
+		for i, identity := range u.LinkedIdentities {
+			providers[i] = identity.Provider
+		}
+		return strings.Join(providers, ",")
+	}},
+	{"last_login", parquet.Optional(timestamp), func(u domain.User) interface{} { return optionalTime(u.LastLogin) }},
+	{"created_at", timestamp, func(u domain.User) interface{} { return u.CreatedAt }},
+	{"updated_at", parquet.Optional(timestamp), func(u domain.User) interface{} { return optionalTime(u.UpdatedAt) }},
+	{"deleted_at", parquet.Optional(timestamp), func(u domain.User) interface{} { return optionalTime(u.DeletedAt) }},
+}
+
+// Fields คืนชื่อคอลัมน์ทั้งหมดที่เลือกได้ตามลำดับเริ่มต้น
+func Fields() []string {
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.name
+	}
+	return names
+}
+
+// Writer เขียนผู้ใช้ทีละคน ต้องเรียก Close เพื่อเขียนส่วนท้ายของไฟล์ (เช่น footer ของ Parquet)
+type Writer interface {
+	Write(user domain.User) error
+	Close() error
+}
+
+// NewWriter สร้าง Writer ตาม format names คือคอลัมน์ที่ต้องการตามลำดับ ว่างหมายถึงทุกคอลัมน์
+// NewWriter ไม่เขียนอะไรลง w จึงตั้ง header ของ response หลังตรวจ format และคอลัมน์ผ่านแล้วได้
+// Close ของ Writer ไม่ปิด w
+func NewWriter(w io.Writer, format string, names []string) (Writer, error) {
+	selected, err := selectFields(names)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case FormatCSV:
+		return &csvWriter{w: csv.NewWriter(w), fields: selected, record: make([]string, len(selected))}, nil
+	case FormatNDJSON:
+		return &ndjsonWriter{w: bufio.NewWriter(w), fields: selected}, nil
+	case FormatParquet:
+		return newParquetWriter(w, selected), nil
+	}
+	return nil, fmt.Errorf("unsupported format %q (use %s, %s or %s)", format, FormatCSV, FormatNDJSON, FormatParquet)
+}
+
+func selectFields(names []string) ([]field, error) {
+	if len(names) == 0 {
+		return fields, nil
+	}
+	var selected []field
+	seen := map[string]bool{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate field %q", name)
+		}
+		seen[name] = true
+		found := false
+		for _, f := range fields {
+			if f.name == name {
+				selected = append(selected, f)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown field %q (available: %s)", name, strings.Join(Fields(), ", "))
+		}
+	}
+	return selected, nil
+}
+
+type csvWriter struct {
+	w       *csv.Writer
+	fields  []field
+	record  []string
+	started bool
+}
+
+// header เขียนแถวชื่อคอลัมน์ครั้งแรกที่มีการเขียน ไฟล์ที่ไม่มีผู้ใช้เลยก็ยังมีแถวนี้
+func (c *csvWriter) header() error {
+	if c.started {
+		return nil
+	}
+	c.started = true
+	for i, f := range c.fields {
+		c.record[i] = f.name
+	}
+	return c.w.Write(c.record)
+}
+
+func (c *csvWriter) Write(user domain.User) error {
+	if err := c.header(); err != nil {
+		return err
+	}
+	for i, f := range c.fields {
+		switch v := f.value(user).(type) {
+		case nil:
+			c.record[i] = ""
+		case string:
+			c.record[i] = v
+		case bool:
+			c.record[i] = strconv.FormatBool(v)
+		case time.Time:
+			c.record[i] = v.UTC().Format(time.RFC3339)
+		}
+	}
+	return c.w.Write(c.record)
+}
+
+func (c *csvWriter) Close() error {
+	if err := c.header(); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type ndjsonWriter struct {
+	w      *bufio.Writer
+	fields []field
+}
+
+// Write เขียน object ทีละ field เพื่อคงลำดับคอลัมน์ตามที่เลือก
+func (n *ndjsonWriter) Write(user domain.User) error {
+	n.w.WriteByte('{')
+	for i, f := range n.fields {
+		if i > 0 {
+			n.w.WriteByte(',')
+		}
+		key, _ := json.Marshal(f.name)
+		value, err := json.Marshal(f.value(user))
+		if err != nil {
+			return err
+		}
+		n.w.Write(key)
+		n.w.WriteByte(':')
+		n.w.Write(value)
+	}
+	n.w.WriteString("}\n")
+	// ส่งออกทีละบรรทัดเมื่อ buffer เต็ม ผู้รับจึงเริ่มอ่านได้ก่อนจบ
+	if n.w.Buffered() > 32*1024 {
+		return n.w.Flush()
+	}
+	return nil
+}
+
+func (n *ndjsonWriter) Close() error {
+	return n.w.Flush()
+}
+
+type parquetWriter struct {
+	w      *parquet.Writer
+	fields []field
+	// columns คือตำแหน่งคอลัมน์ใน schema ของแต่ละ field (parquet เรียงคอลัมน์ตามชื่อ)
+	columns []int
+	row     parquet.Row
+}
+
+func newParquetWriter(w io.Writer, selected []field) *parquetWriter {
+	group := parquet.Group{}
+	for _, f := range selected {
+		group[f.name] = f.node
+	}
+	schema := parquet.NewSchema("user", group)
+
+	pw := &parquetWriter{
+		w:       parquet.NewWriter(w, schema, parquet.MaxRowsPerRowGroup(parquetRowGroup)),
+		fields:  selected,
+		columns: make([]int, len(selected)),
+		row:     make(parquet.Row, len(selected)),
+	}
+	for i, f := range selected {
+		for index, path := range schema.Columns() {
+			if path[0] == f.name {
+				pw.columns[i] = index
+			}
+		}
+	}
+	return pw
+}
+
+func (p *parquetWriter) Write(user domain.User) error {
+	for i, f := range p.fields {
+		column := p.columns[i]
+		optional := f.node.Optional()
+		var value parquet.Value
+		switch v := f.value(user).(type) {
+		case nil:
+			p.row[column] = parquet.NullValue().Level(0, 0, column)
+			continue
+		case string:
+			value = parquet.ByteArrayValue([]byte(v))
+		case bool:
+			value = parquet.BooleanValue(v)
+		case time.Time:
+			value = parquet.Int64Value(v.UnixMilli())
+		}
+		definition := 0
+		if optional {
+			definition = 1
+		}
+		p.row[column] = value.Level(0, definition, column)
+	}
+	_, err := p.w.WriteRows([]parquet.Row{p.row})
+	return err
+}
+
+func (p *parquetWriter) Close() error {
+	return p.w.Close()
+}
@@ -0,0 +1,321 @@
+package userimport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/utils"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/validator"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// ModeSkip ข้ามแถวที่มีผู้ใช้อีเมลนี้อยู่แล้ว
+	ModeSkip = "skip"
+	// ModeUpsert อัพเดทผู้ใช้ที่มีอีเมลนี้อยู่แล้วด้วยค่าที่ระบุในแถว
+	ModeUpsert = "upsert"
+)
+
+// statusReason คือเหตุผลที่บันทึกเมื่อการนำเข้าเปลี่ยนสถานะของผู้ใช้ที่มีอยู่แล้ว
+const statusReason = "bulk import"
+
+const (
+	ActionCreated = "created"
+	ActionUpdated = "updated"
+	ActionSkipped = "skipped"
+	ActionFailed  = "failed"
+)
+
+// Options กำหนดวิธีนำเข้า
+type Options struct {
+	Mode string
+	// DryRun ตรวจสอบทุกแถวและรายงานผลที่จะเกิดขึ้นโดยไม่เขียนฐานข้อมูลและไม่ hash รหัสผ่าน
+	DryRun bool
+	// Workers คือจำนวนแถวที่ hash รหัสผ่านและเขียนฐานข้อมูลพร้อมกัน 0 หมายถึงจำนวน CPU
+	Workers int
+}
+
+// Result คือผลของหนึ่งแถว Line คือเลขบรรทัดในไฟล์ (เริ่มที่ 1)
+type Result struct {
+	Line   int    `json:"line"`
+	Email  string `json:"email,omitempty"`
+	Action string `json:"action"`
+	UserID string `json:"user_id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Report คือผลการนำเข้าทั้งไฟล์ Results เรียงตามเลขบรรทัด
+type Report struct {
+	Mode    string   `json:"mode"`
+	DryRun  bool     `json:"dry_run"`
+	Total   int      `json:"total"`
+	Created int      `json:"created"`
+	Updated int      `json:"updated"`
+	Skipped int      `json:"skipped"`
+	Failed  int      `json:"failed"`
+	Results []Result `json:"results"`
+}
+
+func (r *Report) add(result Result) {
+	r.Total++
+	switch result.Action {
+	case ActionCreated:
+		r.Created++
+	case ActionUpdated:
+		r.Updated++
+	case ActionSkipped:
+		r.Skipped++
+	case ActionFailed:
+		r.Failed++
+	}
+	r.Results = append(r.Results, result)
+}
+
+// Importer นำเข้าผู้ใช้ผ่าน domain.UserRepository
+// ใช้ repository ที่ครอบด้วย audit เพื่อให้ผู้ใช้ที่นำเข้าถูกบันทึกใน audit log เหมือนช่องทางอื่น
+type Importer struct {
+	userRepo domain.UserRepository
+}
+
+// NewImporter สร้าง Importer
+func NewImporter(userRepo domain.UserRepository) *Importer {
+	return &Importer{userRepo: userRepo}
+}
+
+// Run อ่านทุกแถวจาก rows แล้วนำเข้าตาม opts
+// แถวที่ผิดไม่ทำให้แถวอื่นล้มเหลว Run คืน error เฉพาะเมื่ออ่านไฟล์ต่อไม่ได้หรือ ctx ถูกยกเลิก
+// ซึ่งในกรณีนั้น Report มีผลของแถวที่ทำไปแล้ว
+func (im *Importer) Run(ctx context.Context, rows Reader, opts Options) (*Report, error) {
+	if opts.Mode == "" {
+		opts.Mode = ModeSkip
+	}
+	if opts.Mode != ModeSkip && opts.Mode != ModeUpsert {
+		return nil, fmt.Errorf("unsupported mode %q (use %s or %s)", opts.Mode, ModeSkip, ModeUpsert)
+	}
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	jobs := make(chan Row)
+	results := make(chan Result)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for row := range jobs {
+				results <- im.apply(ctx, row, opts)
+			}
+		}()
+	}
+
+	report := &Report{Mode: opts.Mode, DryRun: opts.DryRun, Results: []Result{}}
+	collected := make(chan struct{})
+	go func() {
+		for result := range results {
+			report.add(result)
+		}
+		close(collected)
+	}()
+
+	// ตรวจซ้ำภายในไฟล์ตามลำดับบรรทัด แถวแรกที่ถูกต้องเป็นของจริง
+	firstEmail := map[string]int{}
+	firstName := map[string]int{}
+	var runErr error
+read:
+	for {
+		row, err := rows.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			runErr = err
+			break
+		}
+
+		if err := validate(row); err != nil {
+			results <- failed(row, err)
+			continue
+		}
+		email, name := strings.ToLower(row.Email), strings.ToLower(row.Name)
+		if line, ok := firstEmail[email]; ok {
+			results <- failed(row, fmt.Errorf("duplicate email in file, first seen on line %d", line))
+			continue
+		}
+		if line, ok := firstName[name]; ok {
+			results <- failed(row, fmt.Errorf("duplicate name in file, first seen on line %d", line))
+			continue
+		}
+		firstEmail[email], firstName[name] = row.Line, row.Line
+
+		select {
+		case jobs <- row:
+		case <-ctx.Done():
+			runErr = ctx.Err()
+			break read
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	close(results)
+	<-collected
+
+	sort.Slice(report.Results, func(i, j int) bool { return report.Results[i].Line < report.Results[j].Line })
+	return report, runErr
+}
+
+// validate ตรวจแถวด้วยกฎเดียวกับ /register โดยไม่ดูฐานข้อมูล
+func validate(row Row) error {
+	if row.Err != nil {
+		return row.Err
+	}
+	if err := validator.ValidateName(row.Name); err != nil {
+		return fmt.Errorf("name: %w", err)
+	}
+	if err := validator.ValidateEmail(row.Email); err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+	if row.Password != "" && row.PasswordHash != "" {
+		return errors.New("password and password_hash must not both be set")
+	}
+	if row.Password != "" {
+		if err := validator.ValidatePassword(row.Password); err != nil {
+			return fmt.Errorf("password: %w", err)
+		}
+	}
+	if row.PasswordHash != "" {
+		if _, err := bcrypt.Cost([]byte(row.PasswordHash)); err != nil {
+			return errors.New("password_hash is not a bcrypt hash")
+		}
+	}
+	if row.Role != "" && !domain.IsValidRole(row.Role) {
+		return fmt.Errorf("role must be one of %s", strings.Join(domain.Roles, ", "))
+	}
+	if row.Status != "" && !domain.IsValidStatus(row.Status) {
+		return fmt.Errorf("status must be one of %s", strings.Join(domain.Statuses, ", "))
+	}
+	return nil
+}
+
+// apply ตรวจแถวกับข้อมูลในฐานข้อมูลแล้วสร้างหรืออัพเดทผู้ใช้
+func (im *Importer) apply(ctx context.Context, row Row, opts Options) Result {
+	existing, err := im.userRepo.FindByEmail(ctx, row.Email)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return failed(row, err)
+	}
+	exists := err == nil
+	if exists && opts.Mode == ModeSkip {
+		return Result{Line: row.Line, Email: row.Email, Action: ActionSkipped, UserID: existing.ID.Hex()}
+	}
+	byName, err := im.userRepo.FindByName(ctx, row.Name)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return failed(row, err)
+	}
+	if err == nil && (!exists || byName.ID != existing.ID) {
+		return failed(row, errors.New("name already exists"))
+	}
+
+	if exists {
+		return im.update(ctx, row, existing, opts)
+	}
+	return im.create(ctx, row, opts)
+}
+
+func (im *Importer) create(ctx context.Context, row Row, opts Options) Result {
+	if row.Password == "" && row.PasswordHash == "" {
+		return failed(row, errors.New("password or password_hash is required for a new user"))
+	}
+	if row.Status != "" && !domain.UserStatus(row.Status).IsInitial() {
+		return failed(row, errors.New("a new user can only be pending or active"))
+	}
+	if opts.DryRun {
+		return Result{Line: row.Line, Email: row.Email, Action: ActionCreated}
+	}
+
+	hashed, err := im.password(ctx, row)
+	if err != nil {
+		return failed(row, err)
+	}
+	user := *domain.NewUser(row.Name, row.Email, hashed)
+	if row.Role != "" {
+		user.Role = row.Role
+	}
+	if row.Status != "" {
+		user.Status = domain.UserStatus(row.Status)
+	}
+	id, err := im.userRepo.Create(ctx, user)
+	if err != nil {
+		return failed(row, err)
+	}
+	return Result{Line: row.Line, Email: row.Email, Action: ActionCreated, UserID: id.Hex()}
+}
+
+func (im *Importer) update(ctx context.Context, row Row, existing domain.User, opts Options) Result {
+	result := Result{Line: row.Line, Email: row.Email, Action: ActionUpdated, UserID: existing.ID.Hex()}
+
+	update := map[string]interface{}{}
+	if row.Name != existing.Name {
+		update["name"] = row.Name
+	}
+	if row.Role != "" && row.Role != existing.Role {
+		update["role"] = row.Role
+	}
+	// สถานะเปลี่ยนได้ตามตารางการเปลี่ยนสถานะเท่านั้น และถูกบันทึกเป็น event ของการเปลี่ยนสถานะแยกจากการแก้ข้อมูลอื่น
+	var change *domain.StatusChange
+	if status := domain.UserStatus(row.Status); status != "" && status != existing.Status {
+		transition, err := domain.TransitionTo(existing.Status, status)
+		if err != nil {
+			return failed(row, err)
+		}
+		change = &domain.StatusChange{Transition: transition, Reason: statusReason, At: time.Now()}
+	}
+	hasPassword := row.Password != "" || row.PasswordHash != ""
+	if len(update) == 0 && !hasPassword && change == nil {
+		result.Action = ActionSkipped
+		return result
+	}
+	if opts.DryRun {
+		return result
+	}
+
+	if hasPassword {
+		hashed, err := im.password(ctx, row)
+		if err != nil {
+			return failed(row, err)
+		}
+		update["password"] = hashed
+	}
+	if len(update) > 0 {
+		if err := im.userRepo.Update(ctx, existing.ID, update); err != nil {
+			return failed(row, err)
+		}
+	}
+	if change != nil {
+		if err := im.userRepo.ChangeStatus(ctx, existing.ID, *change); err != nil {
+			return failed(row, err)
+		}
+	}
+	return result
+}
+
+// password คืน bcrypt hash ของแถว ใช้ hash ที่ให้มาโดยตรงถ้ามี
+func (im *Importer) password(ctx context.Context, row Row) (string, error) {
+	if row.PasswordHash != "" {
+		return row.PasswordHash, nil
+	}
+	return utils.HashPasswordContext(ctx, row.Password)
+}
+
+func failed(row Row, err error) Result {
+	return Result{Line: row.Line, Email: row.Email, Action: ActionFailed, Error: err.Error()}
+}
@@ -0,0 +1,182 @@
+// Package userimport นำเข้าผู้ใช้จำนวนมากจากไฟล์ CSV หรือ NDJSON ทีละแถวโดยไม่โหลดทั้งไฟล์
+// ทุกแถวถูกตรวจสอบด้วยกฎเดียวกับ /register และได้ผลลัพธ์รายแถวกลับมา
+package userimport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+
+	// maxLineSize คือความยาวสูงสุดของหนึ่งบรรทัดใน NDJSON
+	maxLineSize = 1 << 20
+)
+
+// Row คือผู้ใช้หนึ่งแถวจากไฟล์ ค่าว่างหมายถึงไม่ได้ระบุ
+// Password คือรหัสผ่านจริง ส่วน PasswordHash คือ bcrypt hash ที่ hash มาแล้ว ระบุได้อย่างใดอย่างหนึ่ง
+type Row struct {
+	Line         int    `json:"-"`
+	Name         string `json:"name"`
+	Email        string `json:"email"`
+	Password     string `json:"password"`
+	PasswordHash string `json:"password_hash"`
+	Role         string `json:"role"`
+	Status       string `json:"status"`
+	// Err คือความผิดพลาดของแถวนี้ตอนอ่าน เช่น JSON เสีย แถวถัดไปยังอ่านต่อได้
+	Err error `json:"-"`
+}
+
+// Reader อ่านทีละแถวและคืน io.EOF เมื่อหมดไฟล์
+// error อื่นหมายถึงอ่านต่อไม่ได้ ส่วนความผิดพลาดเฉพาะแถวอยู่ใน Row.Err
+type Reader interface {
+	Next() (Row, error)
+}
+
+// FormatFromFilename เดารูปแบบจากนามสกุลไฟล์ คืนค่าว่างถ้าไม่รู้จัก
+func FormatFromFilename(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return FormatCSV
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON
+	}
+	return ""
+}
+
+// NewReader สร้าง Reader ตาม format (FormatCSV หรือ FormatNDJSON)
+func NewReader(r io.Reader, format string) (Reader, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVReader(r)
+	case FormatNDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+		return &ndjsonReader{scanner: scanner}, nil
+	}
+	return nil, fmt.Errorf("unsupported format %q (use %s or %s)", format, FormatCSV, FormatNDJSON)
+}
+
+// csvColumns คือคอลัมน์ที่รู้จัก แถวแรกของไฟล์ต้องเป็นชื่อคอลัมน์
+var csvColumns = []string{"name", "email", "password", "password_hash", "role", "status"}
+
+type csvReader struct {
+	r       *csv.Reader
+	columns []string
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("csv: file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("csv header: %w", err)
+	}
+
+	seen := map[string]bool{}
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if i == 0 {
+			column = strings.TrimPrefix(column, "\ufeff") // BOM จาก Excel
+		}
+		if !contains(csvColumns, column) {
+			return nil, fmt.Errorf("csv header: unknown column %q (known: %s)", column, strings.Join(csvColumns, ", "))
+		}
+		if seen[column] {
+			return nil, fmt.Errorf("csv header: duplicate column %q", column)
+		}
+		seen[column] = true
+		header[i] = column
+	}
+	for _, required := range []string{"name", "email"} {
+		if !seen[required] {
+			return nil, fmt.Errorf("csv header: missing column %q", required)
+		}
+	}
+	return &csvReader{r: cr, columns: header}, nil
+}
+
+func (c *csvReader) Next() (Row, error) {
+	record, err := c.r.Read()
+	if errors.Is(err, io.EOF) {
+		return Row{}, io.EOF
+	}
+	line, _ := c.r.FieldPos(0)
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) && errors.Is(parseErr.Err, csv.ErrFieldCount) {
+		return Row{Line: parseErr.StartLine, Err: fmt.Errorf("expected %d fields, got %d", len(c.columns), len(record))}, nil
+	}
+	if err != nil {
+		return Row{}, err
+	}
+
+	row := Row{Line: line}
+	for i, value := range record {
+		value = strings.TrimSpace(value)
+		switch c.columns[i] {
+		case "name":
+			row.Name = value
+		case "email":
+			row.Email = value
+		case "password":
+			row.Password = value
+		case "password_hash":
+			row.PasswordHash = value
+		case "role":
+			row.Role = value
+		case "status":
+			row.Status = value
+		}
+	}
+	return row, nil
+}
+
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (n *ndjsonReader) Next() (Row, error) {
+	for n.scanner.Scan() {
+		n.line++
+		data := bytes.TrimSpace(n.scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		row := Row{Line: n.line}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		// field ที่สะกดผิดต้องเป็น error ไม่ใช่ถูกเงียบหายไป
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&row); err != nil {
+			return Row{Line: n.line, Err: fmt.Errorf("invalid JSON: %w", err)}, nil
+		}
+		row.Line = n.line
+		return row, nil
+	}
+	if err := n.scanner.Err(); err != nil {
+		return Row{}, fmt.Errorf("line %d: %w", n.line+1, err)
+	}
+	return Row{}, io.EOF
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"github.com/Gsupakin/back_end_test_challeng/internal/audit"
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/requestid"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditOrigin แนบที่มาของ request (HTTP, request ID, IP) ไว้ใน context ให้ audit log ใช้
+func AuditOrigin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(audit.WithOrigin(c.Request.Context(), audit.Origin{
+			Source:    domain.AuditSourceHTTP,
+			RequestID: requestid.FromContext(c.Request.Context()),
+			IP:        c.ClientIP(),
+		}))
+		c.Next()
+	}
+}
@@ -1,32 +1,141 @@
 package middleware
 
 import (
+	"crypto/subtle"
+	"errors"
 	"net/http"
 	"strings"
 
-	"github.com/Gsupakin/back_end_test_challeng/pkg/jwt"
+	"github.com/Gsupakin/back_end_test_challeng/internal/auth"
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
 
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func JWTAuth() gin.HandlerFunc {
+// JWTAuth ยืนยันตัวตนจาก header Authorization: Bearer <credential>
+// รับได้ทั้ง JWT จากการล็อกอิน personal access token และ service API key
+// ถ้าเปิด cookie mode จะรับ JWT จาก cookie ได้ด้วย โดย request ที่เปลี่ยนแปลงข้อมูลต้องแนบ CSRF token
+func JWTAuth(authn *auth.Authenticator, cookies auth.CookieConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
+		tokenStr, fromCookie, ok := credential(c, cookies)
+		if !ok {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header missing"})
 			return
 		}
 
-		tokenStr := strings.Split(authHeader, "Bearer ")[1]
-
-		claims, err := jwt.ValidateToken(tokenStr)
+		principal, err := authn.Authenticate(c.Request.Context(), tokenStr, c.ClientIP())
+		if errors.Is(err, domain.ErrUserInactive) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Account is not active"})
+			return
+		}
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			return
 		}
 
+		// browser แนบ cookie ให้อัตโนมัติ จึงต้องตรวจ CSRF ส่วน header Authorization ไม่ต้อง
+		if fromCookie && !isSafeMethod(c.Request.Method) && !validCSRF(c, cookies, principal.SessionID) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Invalid CSRF token"})
+			return
+		}
+
 		// เก็บ user_id ไว้ใช้ใน handler
-		c.Set("user_id", claims.UserID)
+		c.Set("user_id", principal.UserID)
+		c.Set("principal", principal)
+		c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), principal))
+		c.Next()
+	}
+}
+
+// credential อ่าน token จาก header Authorization หรือจาก cookie ถ้าเปิด cookie mode
+func credential(c *gin.Context, cookies auth.CookieConfig) (token string, fromCookie, ok bool) {
+	if authHeader := c.GetHeader("Authorization"); authHeader != "" {
+		// header ที่ไม่ใช่ Bearer ส่งค่าว่างไปให้ Authenticate ปฏิเสธ
+		token, _ = strings.CutPrefix(authHeader, "Bearer ")
+		if token == authHeader {
+			token = ""
+		}
+		return token, false, true
+	}
+
+	if cookies.Enabled() {
+		if token, err := c.Cookie(cookies.Name); err == nil && token != "" {
+			return token, true, true
+		}
+	}
+	return "", false, false
+}
+
+// validCSRF ตรวจสอบ double-submit CSRF token: ค่าใน header ต้องตรงกับ cookie และออกให้ session นี้
+func validCSRF(c *gin.Context, cookies auth.CookieConfig, sessionID string) bool {
+	header := c.GetHeader(auth.CSRFHeader)
+	cookie, err := c.Cookie(cookies.CSRFName)
+	if err != nil || header == "" || subtle.ConstantTimeCompare([]byte(header), []byte(cookie)) != 1 {
+		return false
+	}
+	return auth.ValidCSRFToken(header, sessionID)
+}
+
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// RequireScope อนุญาตเฉพาะ credential ที่มี scope ที่กำหนด JWT จากการล็อกอินผ่านเสมอ ต้องใช้หลัง JWTAuth
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := auth.PrincipalFromContext(c.Request.Context())
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+		if !principal.HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Token is missing required scope: " + scope})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireInteractive ปฏิเสธ token สำหรับเครื่องและ token ของ admin ที่สวมสิทธิ์ผู้ใช้
+// ใช้กับ endpoint ที่จัดการ credential ของผู้ใช้เอง
+func RequireInteractive() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := auth.PrincipalFromContext(c.Request.Context())
+		if !ok || !principal.IsInteractive() {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "This endpoint requires an interactive login"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// ForbidImpersonation ปฏิเสธ request ที่ admin ส่งในนามผู้ใช้ ใช้กับการกระทำที่ละเอียดอ่อน เช่น การลบบัญชี
+func ForbidImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if principal, ok := auth.PrincipalFromContext(c.Request.Context()); ok && principal.IsImpersonated() {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "This action is not allowed while impersonating a user"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireAdmin อนุญาตเฉพาะผู้ใช้ที่มี role เป็น admin ต้องใช้หลัง JWTAuth
+func RequireAdmin(userRepo domain.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		objID, err := primitive.ObjectIDFromHex(c.GetString("user_id"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		user, err := userRepo.FindByID(c.Request.Context(), objID)
+		if err != nil || !user.IsAdmin() {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			return
+		}
+
 		c.Next()
 	}
 }
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/pkg/redact"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ConsoleLogger ใช้แทน logger ของ gin.Default ซึ่งพิมพ์ข้อความที่ log pipeline อ่านไม่ได้
+// รวมถึง path จริงและ query string ที่อาจมี user ID หรือ token
+// แต่ละ request เป็นหนึ่ง record ที่มี route template, IP ตาม policy ของ redactor, request_id และ user_id
+func ConsoleLogger(logger *slog.Logger, redactor *redact.Redactor) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "(no route)"
+		}
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+		logger.LogAttrs(c.Request.Context(), level, "http request",
+			slog.String("method", c.Request.Method),
+			slog.String("route", route),
+			slog.Int("status", status),
+			slog.Int64("latency_ms", time.Since(start).Milliseconds()),
+			slog.String("ip", redactor.IP(c.ClientIP())),
+		)
+	}
+}
+
+// Recovery ใช้แทน gin.Recovery เพื่อให้ panic ถูกบันทึกผ่าน logger เดียวกันพร้อม request_id
+func Recovery(logger *slog.Logger) gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, err any) {
+		logger.ErrorContext(c.Request.Context(), "panic recovered",
+			"route", c.FullPath(), "panic", err, "stack", string(debug.Stack()))
+		c.AbortWithStatus(http.StatusInternalServerError)
+	})
+}
@@ -1,40 +1,91 @@
 package middleware
 
 import (
-	"log"
+	"bytes"
+	"encoding/json"
 	"time"
 
+	"github.com/Gsupakin/back_end_test_challeng/internal/auth"
 	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
-
-	"context"
+	"github.com/Gsupakin/back_end_test_challeng/internal/requestlog"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/requestid"
 
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func RequestLoggerToMongo(logCollection *mongo.Collection) gin.HandlerFunc {
+// เก็บ body ของ response ที่ error ไว้แค่พอให้อ่านข้อความ error ได้
+const maxErrorBody = 1024
+
+// RequestLogger ส่ง log ของทุก request เข้า writer ซึ่งจะลดข้อมูลส่วนบุคคลและบันทึกเป็นชุดในเบื้องหลัง
+func RequestLogger(writer *requestlog.Writer) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
+		capture := &errorCapture{ResponseWriter: c.Writer}
+		c.Writer = capture
 		c.Next()
 		duration := time.Since(start)
 
 		logEntry := domain.RequestLog{
-			Method:    c.Request.Method,
-			Path:      c.Request.URL.Path,
-			Status:    c.Writer.Status(),
-			LatencyMS: duration.Milliseconds(),
-			IP:        c.ClientIP(),
-			UserAgent: c.Request.UserAgent(),
-			Timestamp: time.Now(),
+			RequestID:    requestid.FromContext(c.Request.Context()),
+			Protocol:     domain.ProtocolHTTP,
+			Method:       c.Request.Method,
+			Path:         c.Request.URL.Path,
+			Route:        c.FullPath(),
+			Query:        c.Request.URL.RawQuery,
+			Status:       c.Writer.Status(),
+			LatencyMS:    duration.Milliseconds(),
+			RequestSize:  max(c.Request.ContentLength, 0),
+			ResponseSize: int64(max(c.Writer.Size(), 0)),
+			Error:        errorMessage(c, capture.body.Bytes()),
+			IP:           c.ClientIP(),
+			UserAgent:    c.Request.UserAgent(),
+			Timestamp:    time.Now(),
+			UserID:       c.GetString("user_id"),
+		}
+		if principal, ok := auth.PrincipalFromContext(c.Request.Context()); ok {
+			logEntry.ImpersonatorID = principal.ActorID
 		}
 
-		go func(entry domain.RequestLog) {
-			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-			defer cancel()
-			_, err := logCollection.InsertOne(ctx, entry)
-			if err != nil {
-				log.Printf("Failed to log request: %v", err)
-			}
-		}(logEntry)
+		writer.Write(logEntry)
+	}
+}
+
+// errorCapture เก็บส่วนต้นของ response body เมื่อ status เป็น error
+type errorCapture struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *errorCapture) Write(b []byte) (int, error) {
+	w.capture(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *errorCapture) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *errorCapture) capture(b []byte) {
+	if w.Status() < 400 || w.body.Len() >= maxErrorBody {
+		return
+	}
+	w.body.Write(b[:min(len(b), maxErrorBody-w.body.Len())])
+}
+
+// errorMessage ใช้ error ที่ handler แนบไว้กับ gin ก่อน ถ้าไม่มีจึงอ่านจาก {"error": "..."} ใน response
+func errorMessage(c *gin.Context, body []byte) string {
+	if err := c.Errors.Last(); err != nil {
+		return err.Error()
+	}
+	if c.Writer.Status() < 400 || len(body) == 0 {
+		return ""
+	}
+	var payload struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &payload) == nil {
+		return payload.Error
 	}
+	return ""
 }
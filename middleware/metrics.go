@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/pkg/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics นับ request และจับเวลาตาม route template เพื่อไม่ให้ ID ใน path ทำให้ label มีค่าไม่จำกัด
+// request ที่ไม่ตรงกับ route ใดรวมอยู่ใน "(no route)"
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "(no route)"
+		}
+		metrics.HTTPRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		metrics.HTTPDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"github.com/Gsupakin/back_end_test_challeng/pkg/requestid"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestID ใช้ X-Request-ID ที่ client ส่งมาหรือสร้างใหม่ แล้วแนบไว้ใน context และ response header
+// ต้องลงทะเบียนก่อน middleware ตัวอื่น (ยกเว้น Tracing) เพื่อให้ log, audit และ trace ใช้ ID เดียวกัน
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := requestid.Resolve(c.GetHeader(requestid.Header))
+		c.Set("request_id", id)
+		c.Header(requestid.Header, id)
+		trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.String("request.id", id))
+		c.Request = c.Request.WithContext(requestid.WithID(c.Request.Context(), id))
+		c.Next()
+	}
+}
@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+// Tracing เริ่ม span ของแต่ละ request โดยต่อจาก traceparent ที่ client ส่งมา (ถ้ามี)
+// ชื่อ span เป็น route template ต้องลงทะเบียนเป็นตัวแรกเพื่อให้ middleware อื่นอยู่ใน span เดียวกัน
+// การ scrape /metrics ไม่ถูก trace
+func Tracing(service string) gin.HandlerFunc {
+	return otelgin.Middleware(service, otelgin.WithFilter(func(r *http.Request) bool {
+		return r.URL.Path != "/metrics"
+	}))
+}
@@ -0,0 +1,190 @@
+// Package health รวม readiness check ของแต่ละ subsystem ไว้ใน Registry เดียว
+// ผลของการตรวจถูกใช้ทั้ง /readyz และ gRPC health service (grpc.health.v1) จึงเห็นสถานะตรงกันเสมอ
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// สถานะของ check และของทั้ง service
+const (
+	StatusUp       = "up"
+	StatusDown     = "down"
+	StatusDraining = "draining"
+)
+
+// Check คืน error เมื่อ subsystem ยังไม่พร้อมรับ request ต้องเคารพ ctx ที่มี timeout
+type Check func(ctx context.Context) error
+
+// CheckResult คือผลของ check หนึ่งตัว
+type CheckResult struct {
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// Report คือผลการตรวจ readiness ทั้งหมด
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// Ready บอกว่า service พร้อมรับ traffic หรือไม่
+func (r Report) Ready() bool {
+	return r.Status == StatusUp
+}
+
+// Registry เก็บ check ที่ subsystem ลงทะเบียนไว้ และ gRPC health server ที่สะท้อนผลเดียวกัน
+type Registry struct {
+	timeout time.Duration
+
+	mu     sync.RWMutex
+	checks map[string]Check
+
+	// statusMu ป้องกันไม่ให้ผลของ Check ที่ค้างอยู่ย้อนสถานะกลับเป็น SERVING หลัง Drain
+	statusMu sync.Mutex
+	draining atomic.Bool
+	grpc     *health.Server
+	services []string
+}
+
+// NewRegistry สร้าง Registry ที่จำกัดเวลาของแต่ละ check ไว้ที่ timeout
+// services คือชื่อ gRPC service ที่จะรายงานสถานะ นอกเหนือจาก "" ที่หมายถึงทั้ง server
+// สถานะเริ่มต้นเป็น NOT_SERVING จนกว่าจะตรวจผ่านครั้งแรก
+func NewRegistry(timeout time.Duration, services ...string) *Registry {
+	r := &Registry{
+		timeout:  timeout,
+		checks:   make(map[string]Check),
+		grpc:     health.NewServer(),
+		services: append([]string{""}, services...),
+	}
+	r.setServing(healthpb.HealthCheckResponse_NOT_SERVING)
+	return r
+}
+
+// Register เพิ่ม check ชื่อ name ถ้ามีชื่อนี้อยู่แล้วจะถูกแทนที่
+func (r *Registry) Register(name string, check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[name] = check
+}
+
+// GRPCServer คืน gRPC health server สำหรับลงทะเบียนด้วย grpc_health_v1.RegisterHealthServer
+func (r *Registry) GRPCServer() *health.Server {
+	return r.grpc
+}
+
+// Check รันทุก check พร้อมกัน แล้วอัพเดทสถานะของ gRPC health service ตามผล
+// ระหว่าง graceful shutdown จะคืนสถานะ draining โดยไม่ต้องรัน check
+func (r *Registry) Check(ctx context.Context) Report {
+	if r.draining.Load() {
+		return Report{Status: StatusDraining, Checks: map[string]CheckResult{}}
+	}
+
+	r.mu.RLock()
+	names := make([]string, 0, len(r.checks))
+	for name := range r.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	checks := make([]Check, len(names))
+	for i, name := range names {
+		checks[i] = r.checks[name]
+	}
+	r.mu.RUnlock()
+
+	results := make([]CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = r.run(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusUp, Checks: make(map[string]CheckResult, len(names))}
+	for i, name := range names {
+		report.Checks[name] = results[i]
+		if results[i].Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+	if r.draining.Load() {
+		report.Status = StatusDraining
+		return report
+	}
+	if report.Ready() {
+		r.setServing(healthpb.HealthCheckResponse_SERVING)
+	} else {
+		r.setServing(healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+	return report
+}
+
+func (r *Registry) run(ctx context.Context, check Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	if err == nil {
+		err = ctx.Err()
+	}
+	result := CheckResult{
+		Status:     StatusUp,
+		DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Watch รัน Check ทุก interval จน ctx ถูกยกเลิก เพื่อให้ gRPC health service
+// เปลี่ยนสถานะได้เองแม้ไม่มีใครเรียก /readyz
+func (r *Registry) Watch(ctx context.Context, interval time.Duration) {
+	r.Check(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Check(ctx)
+		}
+	}
+}
+
+// Drain ทำให้ readiness เป็น false ถาวร ใช้ตอนเริ่ม graceful shutdown
+// เพื่อให้ load balancer หยุดส่ง traffic ใหม่ก่อนปิด server
+func (r *Registry) Drain() {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+	r.draining.Store(true)
+	r.setServing(healthpb.HealthCheckResponse_NOT_SERVING)
+}
+
+// Draining บอกว่าเริ่ม graceful shutdown แล้วหรือไม่
+func (r *Registry) Draining() bool {
+	return r.draining.Load()
+}
+
+func (r *Registry) setServing(status healthpb.HealthCheckResponse_ServingStatus) {
+	for _, service := range r.services {
+		r.grpc.SetServingStatus(service, status)
+	}
+}
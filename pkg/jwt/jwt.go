@@ -2,28 +2,113 @@ package jwt
 
 import (
 	"errors"
-	"os"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+const (
+	// PurposeMFA คือ token ชั่วคราวที่ออกให้หลังตรวจรหัสผ่านผ่านแล้ว รอยืนยันรหัส TOTP
+	PurposeMFA = "mfa"
+	// PurposeMagicLink คือ token ที่ฝังอยู่ในลิงก์เข้าสู่ระบบที่ส่งทางอีเมล
+	PurposeMagicLink = "magic_link"
+	// PurposeOIDCAccess คือ access token ที่ออกให้ client ภายนอกผ่าน OpenID Connect
+	// ใช้เรียกได้เฉพาะ /userinfo ไม่ใช่ API ทั่วไป
+	PurposeOIDCAccess = "oidc_access"
+	// PurposeOIDCLogin คือ token ใน cookie ที่ผูก state ระหว่างการล็อกอินผ่าน IdP ภายนอก
+	PurposeOIDCLogin = "oidc_login"
+)
+
+// SessionTTL คืออายุของ token ที่ออกให้หลังล็อกอิน และของ session ที่ token ผูกอยู่
+// ตั้งจาก config (AUTH_SESSION_TTL) ตอนเริ่มโปรแกรม
+var SessionTTL = 24 * time.Hour
+
+// secret คือ key ที่ตั้งด้วย SetSecret จาก config (JWT_SECRET_KEY หรือ JWT_SECRET_KEY_FILE) ตอนเริ่มโปรแกรม
+var secret atomic.Value
+
+// SetSecret ตั้ง key สำหรับเซ็นและตรวจสอบ token แบบ HS256 ต้องเรียกก่อนออกหรือตรวจ token ใด ๆ
+func SetSecret(key string) {
+	secret.Store(key)
+}
+
+// Secret คืน key สำหรับเซ็น token แบบ HS256 คืน error ถ้ายังไม่ได้เรียก SetSecret
+func Secret() (string, error) {
+	if key, _ := secret.Load().(string); key != "" {
+		return key, nil
+	}
+	return "", errors.New("JWT secret not set, call jwt.SetSecret with the configured JWT_SECRET_KEY")
+}
+
 type Claims struct {
 	UserID string `json:"user_id"`
+	// SessionID ผูก token กับ session ฝั่งเซิร์ฟเวอร์ เมื่อ session ถูกยกเลิก token จะใช้ไม่ได้ทันที
+	SessionID string `json:"sid,omitempty"`
+	// Purpose ระบุว่า token นี้ใช้สำหรับอะไร ค่าว่างหมายถึง access token ปกติ
+	Purpose string `json:"purpose,omitempty"`
+	Scope   string `json:"scope,omitempty"`
+	// Actor คือผู้ที่ใช้ token แทนเจ้าของจริง (RFC 8693) มีค่าเฉพาะตอน admin สวมสิทธิ์ผู้ใช้
+	Actor *Actor `json:"act,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateJWT(userID string) (string, error) {
-	// อ่าน secret key จาก environment variable
-	secretKey := os.Getenv("JWT_SECRET_KEY")
-	if secretKey == "" {
-		return "", errors.New("JWT_SECRET_KEY not set in environment")
+// Actor ระบุ admin ที่กำลังสวมสิทธิ์ผู้ใช้
+type Actor struct {
+	Subject string `json:"sub"`
+}
+
+func GenerateJWT(userID, sessionID string) (string, error) {
+	return signClaims(&Claims{UserID: userID, SessionID: sessionID}, SessionTTL)
+}
+
+func ValidateToken(tokenString string) (*Claims, error) {
+	claims, err := parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	// token ที่มี purpose ใช้เรียก API ทั่วไปไม่ได้
+	if claims.Purpose != "" {
+		return nil, errors.New("invalid token")
+	}
+
+	return claims, nil
+}
+
+// GenerateImpersonationJWT สร้าง token อายุสั้นที่ sub เป็นผู้ใช้เป้าหมายและ act เป็น admin ที่สวมสิทธิ์
+func GenerateImpersonationJWT(userID, sessionID, actorID string, ttl time.Duration) (string, error) {
+	return signClaims(&Claims{
+		UserID:           userID,
+		SessionID:        sessionID,
+		Actor:            &Actor{Subject: actorID},
+		RegisteredClaims: jwt.RegisteredClaims{Subject: userID},
+	}, ttl)
+}
+
+// GeneratePurposeToken สร้าง token อายุสั้นที่ใช้ได้เฉพาะงานที่ระบุใน purpose
+// tokenID (jti) ใช้อ้างอิงกับข้อมูลที่เก็บฝั่งเซิร์ฟเวอร์ เช่น token แบบใช้ครั้งเดียว
+func GeneratePurposeToken(userID, purpose, tokenID string, ttl time.Duration) (string, error) {
+	if purpose == "" {
+		return "", errors.New("purpose is required")
+	}
+	return sign(userID, purpose, tokenID, ttl)
+}
+
+// GenerateAccessToken สร้าง OIDC access token ที่ผูกกับ client และ scope ที่ได้รับอนุญาต
+func GenerateAccessToken(userID, clientID, scope string, ttl time.Duration) (string, error) {
+	secretKey, err := Secret()
+	if err != nil {
+		return "", err
 	}
 
 	claims := &Claims{
-		UserID: userID,
+		UserID:  userID,
+		Purpose: PurposeOIDCAccess,
+		Scope:   scope,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			Subject:   userID,
+			Audience:  jwt.ClaimStrings{clientID},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
@@ -32,16 +117,51 @@ func GenerateJWT(userID string) (string, error) {
 	return token.SignedString([]byte(secretKey))
 }
 
-func ValidateToken(tokenString string) (*Claims, error) {
-	// อ่าน secret key จาก environment variable
-	secretKey := os.Getenv("JWT_SECRET_KEY")
-	if secretKey == "" {
-		return nil, errors.New("JWT_SECRET_KEY not set in environment")
+// ValidatePurposeToken ตรวจสอบ token และยืนยันว่า purpose ตรงกับที่คาดไว้
+func ValidatePurposeToken(tokenString, purpose string) (*Claims, error) {
+	claims, err := parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Purpose != purpose {
+		return nil, errors.New("invalid token")
+	}
+
+	return claims, nil
+}
+
+func sign(userID, purpose, tokenID string, ttl time.Duration) (string, error) {
+	return signClaims(&Claims{
+		UserID:           userID,
+		Purpose:          purpose,
+		RegisteredClaims: jwt.RegisteredClaims{ID: tokenID},
+	}, ttl)
+}
+
+func signClaims(claims *Claims, ttl time.Duration) (string, error) {
+	secretKey, err := Secret()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(ttl))
+	claims.IssuedAt = jwt.NewNumericDate(now)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(secretKey))
+}
+
+func parse(tokenString string) (*Claims, error) {
+	secretKey, err := Secret()
+	if err != nil {
+		return nil, err
 	}
 
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		return []byte(secretKey), nil
-	})
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 
 	if err != nil {
 		return nil, err
@@ -0,0 +1,128 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// KeySet เก็บ RSA key สำหรับเซ็น ID token (RS256) และเผยแพร่ public key ผ่าน JWKS
+type KeySet struct {
+	key *rsa.PrivateKey
+	kid string
+}
+
+// JWK แทน public key หนึ่งตัวในรูปแบบ JSON Web Key
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// NewKeySet สร้าง KeySet จาก private key ที่มีอยู่แล้ว
+func NewKeySet(key *rsa.PrivateKey) *KeySet {
+	sum := sha256.Sum256(key.PublicKey.N.Bytes())
+	return &KeySet{
+		key: key,
+		kid: base64.RawURLEncoding.EncodeToString(sum[:8]),
+	}
+}
+
+// GenerateKeySet สร้าง RSA key ใหม่ขนาด 2048 bit
+func GenerateKeySet() (*KeySet, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeySet(key), nil
+}
+
+// LoadKeySet อ่าน RSA private key จากไฟล์ PEM (PKCS#1 หรือ PKCS#8)
+func LoadKeySet(path string) (*KeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found in signing key file")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return NewKeySet(key), nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("signing key is not an RSA key")
+	}
+	return NewKeySet(key), nil
+}
+
+// KeyID คืนค่า kid ของ key ปัจจุบัน
+func (k *KeySet) KeyID() string {
+	return k.kid
+}
+
+// Sign เซ็น claims ด้วย RS256 และใส่ kid ใน header
+func (k *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = k.kid
+	return token.SignedString(k.key)
+}
+
+// SignIDToken สร้าง OpenID Connect ID token โดย extra คือ claims ของผู้ใช้ตาม scope ที่ได้รับ
+func (k *KeySet) SignIDToken(issuer, subject, audience, nonce string, authTime time.Time, ttl time.Duration, extra map[string]interface{}) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{}
+	for key, value := range extra {
+		claims[key] = value
+	}
+	claims["iss"] = issuer
+	claims["sub"] = subject
+	claims["aud"] = audience
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(ttl).Unix()
+	claims["auth_time"] = authTime.Unix()
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+	return k.Sign(claims)
+}
+
+// PublicKey คืนค่า public key สำหรับตรวจสอบลายเซ็น
+func (k *KeySet) PublicKey() *rsa.PublicKey {
+	return &k.key.PublicKey
+}
+
+// JWKS คืนค่า public keys ในรูปแบบที่ใช้ตอบ /.well-known/jwks.json
+func (k *KeySet) JWKS() map[string][]JWK {
+	pub := k.key.PublicKey
+	return map[string][]JWK{
+		"keys": {{
+			Kty: "RSA",
+			Use: "sig",
+			Alg: jwt.SigningMethodRS256.Alg(),
+			Kid: k.kid,
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	}
+}
@@ -0,0 +1,97 @@
+// Package logger สร้าง *slog.Logger สำหรับทั้ง service โดยเลือก JSON หรือ text และระดับ log ได้
+// ทุก record ที่ log ผ่าน *Context จะถูกเติม request_id, user_id และ trace_id จาก context ให้อัตโนมัติ
+package logger
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/Gsupakin/back_end_test_challeng/pkg/redact"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/requestid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// รูปแบบ output
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+type Config struct {
+	Format string
+	Level  slog.Level
+	// Redactor ใช้ policy ของ LOG_REDACT_USER_ID กับ user_id ที่เติมจาก context ถ้าไม่กำหนดจะเก็บค่าจริง
+	Redactor *redact.Redactor
+}
+
+// DefaultConfig คืน JSON ระดับ info ซึ่ง log pipeline อ่านได้โดยตรง
+func DefaultConfig() Config {
+	return Config{Format: FormatJSON, Level: slog.LevelInfo}
+}
+
+// New สร้าง logger ที่เขียนลง w
+func New(w io.Writer, cfg Config) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: cfg.Level}
+	var h slog.Handler
+	if cfg.Format == FormatText {
+		h = slog.NewTextHandler(w, opts)
+	} else {
+		h = slog.NewJSONHandler(w, opts)
+	}
+	return slog.New(&contextHandler{Handler: h, redactor: cfg.Redactor})
+}
+
+type userIDKey struct{}
+
+// WithUserID แนบ user ID ของผู้เรียกไว้ใน context เพื่อให้ทุก log ของ request นั้นมี user_id
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDKey{}, userID)
+}
+
+// UserIDFromContext อ่าน user ID ที่แนบไว้ด้วย WithUserID
+func UserIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(userIDKey{}).(string)
+	return id
+}
+
+// contextHandler เติม request_id, user_id, trace_id และ span_id จาก context ก่อนส่งต่อให้ handler จริง
+type contextHandler struct {
+	slog.Handler
+	redactor *redact.Redactor
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		if id := requestid.FromContext(ctx); id != "" {
+			r.AddAttrs(slog.String("request_id", id))
+		}
+		if id := UserIDFromContext(ctx); id != "" {
+			if h.redactor != nil {
+				id = h.redactor.UserID(id)
+			}
+			if id != "" {
+				r.AddAttrs(slog.String("user_id", id))
+			}
+		}
+		if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+			r.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs), redactor: h.redactor}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name), redactor: h.redactor}
+}
+
+// Fatal log ข้อความระดับ error แล้วจบโปรแกรม ใช้แทน log.Fatal ตอนเริ่มระบบ
+func Fatal(l *slog.Logger, msg string, args ...any) {
+	l.Error(msg, args...)
+	os.Exit(1)
+}
@@ -0,0 +1,91 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+// Mailer ส่งอีเมลแบบข้อความธรรมดา
+type Mailer interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+// SMTPMailer ส่งอีเมลผ่าน SMTP server
+type SMTPMailer struct {
+	addr     string
+	from     string
+	auth     smtp.Auth
+	hostname string
+}
+
+// NewSMTPMailer creates a new SMTPMailer; username ว่างหมายถึงไม่ต้อง authenticate
+func NewSMTPMailer(host, port, username, password, from string) *SMTPMailer {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &SMTPMailer{
+		addr:     net.JoinHostPort(host, port),
+		from:     from,
+		auth:     auth,
+		hostname: host,
+	}
+}
+
+// Send implements Mailer
+func (m *SMTPMailer) Send(ctx context.Context, to, subject, body string) error {
+	if strings.ContainsAny(to, "\r\n") || strings.ContainsAny(subject, "\r\n") {
+		return fmt.Errorf("invalid mail header")
+	}
+
+	msg := strings.Join([]string{
+		"From: " + m.from,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- smtp.SendMail(m.addr, m.auth, m.from, []string{to}, []byte(msg))
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// LogMailer พิมพ์อีเมลลง log แทนการส่งจริง ใช้สำหรับ development เท่านั้น
+// ถ้า Logger เป็น nil จะใช้ slog.Default
+type LogMailer struct {
+	Logger *slog.Logger
+}
+
+// Send implements Mailer
+func (m LogMailer) Send(ctx context.Context, to, subject, body string) error {
+	l := m.Logger
+	if l == nil {
+		l = slog.Default()
+	}
+	l.InfoContext(ctx, "email not sent, SMTP is not configured", "to", to, "subject", subject, "body", body)
+	return nil
+}
+
+// New คืน SMTPMailer หรือ LogMailer ที่เขียนอีเมลลง logger ถ้า host ว่าง
+func New(logger *slog.Logger, host, port, username, password, from string) Mailer {
+	if host == "" {
+		logger.Warn("SMTP_HOST not set, emails will be written to the log")
+		return LogMailer{Logger: logger}
+	}
+	return NewSMTPMailer(host, port, username, password, from)
+}
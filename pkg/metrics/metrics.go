@@ -0,0 +1,117 @@
+// Package metrics เก็บ Prometheus metrics ของทั้ง service ไว้ใน Registry เดียว
+// และเปิดให้ scrape ผ่าน Handler ที่ /metrics
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "backend"
+
+// ช่องทางการล็อกอินและผลลัพธ์ที่ใช้เป็น label ของ Logins
+const (
+	LoginPassword  = "password"
+	LoginMFA       = "mfa"
+	LoginMagicLink = "magic_link"
+	LoginIdentity  = "identity"
+
+	LoginSuccess     = "success"
+	LoginFailure     = "failure"
+	LoginMFARequired = "mfa_required"
+)
+
+// Registry รวม metrics ทั้งหมดของ service รวมถึง Go runtime และ process
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and route template.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	GRPCRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "grpc_requests_total",
+		Help:      "gRPC calls by full method name and status code.",
+	}, []string{"method", "code"})
+
+	GRPCDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "grpc_request_duration_seconds",
+		Help:      "gRPC call latency by full method name.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	MongoDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "mongo_operation_duration_seconds",
+		Help:      "MongoDB repository method latency.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"repository", "operation"})
+
+	// bcrypt cost 14 ใช้เวลาราววินาทีต่อครั้ง จึงใช้ bucket ที่กว้างกว่าปกติ
+	PasswordHashDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "password_hash_duration_seconds",
+		Help:      "bcrypt duration by operation (hash, compare).",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2, 4, 8},
+	}, []string{"operation"})
+
+	Logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Login attempts by method and result (success, failure, mfa_required).",
+	}, []string{"method", "result"})
+
+	RegisteredUsers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "registered_users",
+		Help:      "Number of user accounts that are not deleted, refreshed periodically.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests, HTTPDuration,
+		GRPCRequests, GRPCDuration,
+		MongoDuration,
+		PasswordHashDuration,
+		Logins,
+		RegisteredUsers,
+	)
+}
+
+// Handler คืน http.Handler สำหรับ endpoint /metrics
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// ObserveMongo จับเวลาการทำงานของ repository method ใช้แบบ
+//
+//	defer metrics.ObserveMongo("users", "FindByID")()
+func ObserveMongo(repository, operation string) func() {
+	start := time.Now()
+	return func() {
+		MongoDuration.WithLabelValues(repository, operation).Observe(time.Since(start).Seconds())
+	}
+}
+
+// RecordLogin นับความพยายามล็อกอินหนึ่งครั้ง
+func RecordLogin(method, result string) {
+	Logins.WithLabelValues(method, result).Inc()
+}
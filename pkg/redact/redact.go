@@ -0,0 +1,285 @@
+// Package redact ลดข้อมูลส่วนบุคคลก่อนเขียนลง log ตาม policy ราย field
+// เช่นตัด IP, แฮช IP ด้วย salt ที่หมุนตามช่วงเวลา, ย่อ user agent และปิดอีเมลหรือ token ในข้อความ
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/pkg/utils"
+)
+
+// Marker แทนค่าที่ถูกซ่อน
+const Marker = "[REDACTED]"
+
+// วิธีจัดการแต่ละ field ไม่ใช่ทุก mode ใช้ได้กับทุก field ดู Policy
+const (
+	ModeKeep      = "keep"
+	ModeDrop      = "drop"
+	ModeHash      = "hash"
+	ModeTruncate  = "truncate"
+	ModeNormalize = "normalize"
+	ModeMask      = "mask"
+	ModeRoute     = "route"
+)
+
+// Policy กำหนดวิธีจัดการข้อมูลแต่ละ field
+type Policy struct {
+	IP        string // keep, truncate (IPv4 /24, IPv6 /48), hash, drop
+	UserAgent string // keep, normalize (เช่น "Chrome on macOS"), drop
+	Query     string // mask (ซ่อนค่าความลับ อีเมลและ token), drop
+	Error     string // mask, drop
+	UserID    string // keep, hash, drop
+	Path      string // keep, route (ใช้ route template แทน path จริง)
+}
+
+// DefaultPolicy คือค่าที่ผ่านการทบทวนด้าน GDPR แล้ว
+func DefaultPolicy() Policy {
+	return Policy{
+		IP:        ModeTruncate,
+		UserAgent: ModeNormalize,
+		Query:     ModeMask,
+		Error:     ModeMask,
+		UserID:    ModeKeep,
+		Path:      ModeKeep,
+	}
+}
+
+var allowedModes = map[string][]string{
+	"LOG_REDACT_IP":         {ModeKeep, ModeTruncate, ModeHash, ModeDrop},
+	"LOG_REDACT_USER_AGENT": {ModeKeep, ModeNormalize, ModeDrop},
+	"LOG_REDACT_QUERY":      {ModeMask, ModeDrop},
+	"LOG_REDACT_ERROR":      {ModeMask, ModeDrop},
+	"LOG_REDACT_USER_ID":    {ModeKeep, ModeHash, ModeDrop},
+	"LOG_REDACT_PATH":       {ModeKeep, ModeRoute},
+}
+
+// AllowedModes คืน mode ที่ field ซึ่งตั้งผ่าน env (เช่น LOG_REDACT_IP) รองรับ
+func AllowedModes(env string) []string {
+	return allowedModes[env]
+}
+
+// Redactor ใช้ Policy กับค่าแต่ละ field ปลอดภัยต่อการใช้จากหลาย goroutine
+type Redactor struct {
+	policy Policy
+	salts  *rotatingSalt
+}
+
+// New สร้าง Redactor ถ้า secret ว่างจะสุ่มขึ้นมาใหม่ ทำให้แฮชเทียบข้าม process หรือหลัง restart ไม่ได้
+func New(policy Policy, secret []byte, rotation time.Duration) *Redactor {
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic("redact: crypto/rand failed: " + err.Error())
+		}
+	}
+	return &Redactor{
+		policy: policy,
+		salts:  &rotatingSalt{secret: secret, rotation: rotation, now: time.Now},
+	}
+}
+
+func (r *Redactor) Policy() Policy {
+	return r.policy
+}
+
+// SetClock เปลี่ยนนาฬิกาที่ใช้หมุน salt สำหรับการทดสอบ
+func (r *Redactor) SetClock(now func() time.Time) {
+	r.salts.mu.Lock()
+	defer r.salts.mu.Unlock()
+	r.salts.now = now
+	r.salts.period = 0
+}
+
+// IP ตัดหรือแฮช IP ตาม policy
+func (r *Redactor) IP(ip string) string {
+	if ip == "" {
+		return ""
+	}
+	switch r.policy.IP {
+	case ModeKeep:
+		return ip
+	case ModeDrop:
+		return ""
+	case ModeHash:
+		return r.hash("ip", ip)
+	default:
+		return TruncateIP(ip)
+	}
+}
+
+// UserAgent ย่อ user agent ให้เหลือแค่ชนิด browser และระบบปฏิบัติการ
+func (r *Redactor) UserAgent(ua string) string {
+	if ua == "" {
+		return ""
+	}
+	switch r.policy.UserAgent {
+	case ModeKeep:
+		return ua
+	case ModeDrop:
+		return ""
+	default:
+		return utils.DeviceLabel(ua)
+	}
+}
+
+// UserID แฮชหรือซ่อน user ID ตาม policy
+func (r *Redactor) UserID(id string) string {
+	if id == "" {
+		return ""
+	}
+	switch r.policy.UserID {
+	case ModeDrop:
+		return ""
+	case ModeHash:
+		return r.hash("user", id)
+	default:
+		return id
+	}
+}
+
+// UserIDKeys คืนทุกค่าที่ user_id ของผู้ใช้ id อาจถูกบันทึกไว้และยังหาได้
+// คือ ID จริง และแฮชของช่วง salt ปัจจุบันเมื่อ policy เป็น hash
+// ไม่รวมแฮชของช่วงก่อนหน้า เพราะการหมุน salt ตั้งใจให้เชื่อมโยงข้ามช่วงเวลาไม่ได้
+func (r *Redactor) UserIDKeys(id string) []string {
+	if r.policy.UserID == ModeHash {
+		return []string{id, r.hash("user", id)}
+	}
+	return []string{id}
+}
+
+// Path คืน route template แทน path จริงเมื่อ policy เป็น route และรู้ route
+func (r *Redactor) Path(path, route string) string {
+	if r.policy.Path == ModeRoute && route != "" {
+		return route
+	}
+	return path
+}
+
+// Query ซ่อนค่าของ parameter ที่เป็นความลับ และปิดอีเมลหรือ token ในค่าอื่น
+// ส่วนที่ไม่ถูกแก้จะคงรูปเดิมตามที่ client ส่งมา
+func (r *Redactor) Query(raw string) string {
+	if raw == "" || r.policy.Query == ModeDrop {
+		return ""
+	}
+	parts := strings.Split(raw, "&")
+	for i, part := range parts {
+		key, value, hasValue := strings.Cut(part, "=")
+		name, err := url.QueryUnescape(key)
+		if err != nil {
+			name = key
+		}
+		if SensitiveKey(name) {
+			parts[i] = key + "=" + Marker
+			continue
+		}
+		if !hasValue {
+			continue
+		}
+		decoded, err := url.QueryUnescape(value)
+		if err != nil {
+			decoded = value
+		}
+		if masked := Text(decoded); masked != decoded {
+			parts[i] = key + "=" + masked
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// Error ปิดอีเมลและ token ในข้อความ error
+func (r *Redactor) Error(msg string) string {
+	if r.policy.Error == ModeDrop {
+		return ""
+	}
+	return Text(msg)
+}
+
+// hash คืน HMAC-SHA256 แบบย่อของค่า โดยใช้ salt ของช่วงเวลาปัจจุบัน
+// ค่าเดียวกันในช่วงเวลาเดียวกันได้แฮชเดียวกัน แต่เชื่อมโยงข้ามช่วงเวลาไม่ได้
+func (r *Redactor) hash(kind, value string) string {
+	mac := hmac.New(sha256.New, r.salts.current())
+	mac.Write([]byte(kind + ":" + value))
+	return hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// rotatingSalt คำนวณ salt จาก secret และลำดับช่วงเวลา จึงได้ค่าเดียวกันทุก instance ที่ใช้ secret เดียวกัน
+type rotatingSalt struct {
+	mu       sync.Mutex
+	secret   []byte
+	rotation time.Duration
+	now      func() time.Time
+	period   int64
+	salt     []byte
+}
+
+func (s *rotatingSalt) current() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	period := s.now().UnixNano()/int64(s.rotation) + 1
+	if period != s.period {
+		mac := hmac.New(sha256.New, s.secret)
+		fmt.Fprintf(mac, "salt:%d", period)
+		s.salt = mac.Sum(nil)
+		s.period = period
+	}
+	return s.salt
+}
+
+// TruncateIP ตัด IPv4 เหลือ /24 และ IPv6 เหลือ /48
+func TruncateIP(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return parsed.Mask(net.CIDRMask(48, 128)).String()
+}
+
+// sensitiveKeys คือส่วนของชื่อ field หรือ parameter ที่ห้ามบันทึกค่าจริง
+var sensitiveKeys = []string{"password", "secret", "token", "hash", "recovery_codes", "code", "state", "api_key", "signature"}
+
+// SensitiveKey บอกว่าชื่อ field หรือ parameter นี้เก็บค่าความลับหรือไม่
+func SensitiveKey(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	// JWT, token ของระบบ (bet_pat_/bet_key_), Bearer credential และสตริงสุ่มยาวเช่น magic link token
+	tokenPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]*`),
+		regexp.MustCompile(`bet_(pat|key)_[A-Za-z0-9_\-]+`),
+		regexp.MustCompile(`(?i)bearer\s+[A-Za-z0-9._~+/\-]+=*`),
+		regexp.MustCompile(`\b[A-Za-z0-9_\-]{32,}\b`),
+	}
+)
+
+// Text ปิดอีเมลและ token ในข้อความอิสระ
+func Text(s string) string {
+	if s == "" {
+		return s
+	}
+	s = emailPattern.ReplaceAllString(s, "[EMAIL]")
+	for _, p := range tokenPatterns {
+		s = p.ReplaceAllString(s, Marker)
+	}
+	return s
+}
@@ -0,0 +1,81 @@
+// Package requestid สร้างและส่งต่อ request ID ระหว่าง HTTP, gRPC และ log
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	Header      = "X-Request-ID"
+	MetadataKey = "x-request-id" // gRPC metadata key ต้องเป็นตัวพิมพ์เล็ก
+
+	maxLength = 128
+)
+
+type contextKey struct{}
+
+// New สร้าง request ID แบบสุ่มขนาด 128 bit
+func New() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		panic("requestid: crypto/rand failed: " + err.Error())
+	}
+	return hex.EncodeToString(buf)
+}
+
+// Valid ตรวจว่า ID ที่ client ส่งมาปลอดภัยพอจะใส่ใน log และ response header
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for _, r := range id {
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// Resolve คืน ID ที่รับมาถ้าถูกต้อง ไม่เช่นนั้นสร้างใหม่
+func Resolve(incoming string) string {
+	if Valid(incoming) {
+		return incoming
+	}
+	return New()
+}
+
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// FromIncomingMetadata อ่าน request ID จาก metadata ของ gRPC call ที่เข้ามา
+func FromIncomingMetadata(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if ids := md.Get(MetadataKey); len(ids) > 0 {
+		return ids[0]
+	}
+	return ""
+}
+
+// UnaryClientInterceptor ส่ง request ID ใน context ต่อไปยัง gRPC server ปลายทาง
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if id := FromContext(ctx); id != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, MetadataKey, id)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
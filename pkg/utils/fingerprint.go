@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"net"
+	"strings"
+)
+
+// IPPrefix คืนค่า network prefix ของ IP (/24 สำหรับ IPv4, /48 สำหรับ IPv6)
+// เพื่อให้ยังจับคู่อุปกรณ์เดิมได้แม้ IP จะเปลี่ยนภายในเครือข่ายเดียวกัน
+func IPPrefix(ip string) string {
+	parsed := net.ParseIP(strings.TrimSpace(ip))
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String() + "/24"
+	}
+	return parsed.Mask(net.CIDRMask(48, 128)).String() + "/48"
+}
+
+// DeviceFingerprint สร้าง fingerprint ของอุปกรณ์จาก user agent และ IP prefix
+func DeviceFingerprint(userAgent, ip string) string {
+	return HashToken(strings.TrimSpace(userAgent) + "|" + IPPrefix(ip))
+}
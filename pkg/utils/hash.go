@@ -1,13 +1,45 @@
 package utils
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"context"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/pkg/metrics"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/tracing"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BcryptCost คือ cost ของ bcrypt ที่ใช้ hash รหัสผ่านใหม่ ตั้งจาก config (BCRYPT_COST) ตอนเริ่มโปรแกรม
+// hash เดิมยังตรวจสอบได้เพราะ cost ถูกเก็บไว้ใน hash
+var BcryptCost = 14
 
 func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
+	return HashPasswordContext(context.Background(), password)
+}
+
+// HashPasswordContext เหมือน HashPassword แต่บันทึก span เป็นลูกของ span ใน ctx
+func HashPasswordContext(ctx context.Context, password string) (string, error) {
+	defer observeHash(ctx, "hash")()
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), BcryptCost)
 	return string(bytes), err
 }
 
 func CheckPasswordHash(password, hash string) bool {
+	return CheckPasswordHashContext(context.Background(), password, hash)
+}
+
+// CheckPasswordHashContext เหมือน CheckPasswordHash แต่บันทึก span เป็นลูกของ span ใน ctx
+func CheckPasswordHashContext(ctx context.Context, password, hash string) bool {
+	defer observeHash(ctx, "compare")()
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+func observeHash(ctx context.Context, operation string) func() {
+	start := time.Now()
+	_, span := tracing.Start(ctx, "bcrypt."+operation)
+	return func() {
+		span.End()
+		metrics.PasswordHashDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"strings"
+)
+
+// RandomToken สร้างสตริงสุ่มที่ปลอดภัยสำหรับใช้เป็น token
+func RandomToken(nBytes int) (string, error) {
+	buf := make([]byte, nBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf)), nil
+}
+
+// HashToken แฮช token ที่มี entropy สูงด้วย SHA-256 สำหรับเก็บในฐานข้อมูล
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateRecoveryCodes สร้าง recovery codes แบบใช้ครั้งเดียวในรูปแบบ xxxxx-xxxxx
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		raw, err := RandomToken(8)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, raw[:5]+"-"+raw[5:10])
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode ตัดช่องว่างและขีดออกก่อนนำไปแฮชเปรียบเทียบ
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}
@@ -0,0 +1,55 @@
+package utils
+
+import "strings"
+
+// ลำดับมีผล: Edge และ Opera มีคำว่า Chrome อยู่ใน user agent ด้วย และ Chrome มีคำว่า Safari
+var browsers = []struct{ token, name string }{
+	{"Edg/", "Edge"},
+	{"OPR/", "Opera"},
+	{"Firefox/", "Firefox"},
+	{"Chrome/", "Chrome"},
+	{"CriOS/", "Chrome"},
+	{"Safari/", "Safari"},
+	{"curl/", "curl"},
+	{"PostmanRuntime/", "Postman"},
+	{"Go-http-client/", "Go HTTP client"},
+	{"grpc-go/", "gRPC client"},
+}
+
+var platforms = []struct{ token, name string }{
+	{"iPhone", "iOS"},
+	{"iPad", "iPadOS"},
+	{"Android", "Android"},
+	{"Windows", "Windows"},
+	{"Mac OS X", "macOS"},
+	{"CrOS", "ChromeOS"},
+	{"Linux", "Linux"},
+}
+
+// DeviceLabel แปลง user agent เป็นชื่ออุปกรณ์ที่อ่านง่าย เช่น "Chrome on macOS"
+func DeviceLabel(userAgent string) string {
+	var browser, platform string
+	for _, b := range browsers {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	for _, p := range platforms {
+		if strings.Contains(userAgent, p.token) {
+			platform = p.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && platform != "":
+		return browser + " on " + platform
+	case browser != "":
+		return browser
+	case platform != "":
+		return "Unknown browser on " + platform
+	default:
+		return "Unknown device"
+	}
+}
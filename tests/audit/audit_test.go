@@ -0,0 +1,159 @@
+package audit_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/application"
+	"github.com/Gsupakin/back_end_test_challeng/internal/audit"
+	"github.com/Gsupakin/back_end_test_challeng/internal/auth"
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/middleware"
+	appjwt "github.com/Gsupakin/back_end_test_challeng/pkg/jwt"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/utils"
+	"github.com/Gsupakin/back_end_test_challeng/tests/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const password = "Password123"
+
+func TestAuditTrail(t *testing.T) {
+	appjwt.SetSecret("audit-test-secret")
+	gin.SetMode(gin.TestMode)
+
+	auditRepo := mocks.NewAuditRepository()
+	recorder := audit.NewRecorder(auditRepo)
+	userRepo := audit.NewUserRepository(mocks.NewUserRepository(), recorder)
+	sessionRepo := audit.NewSessionRepository(mocks.NewSessionRepository(), recorder)
+
+	hashed, err := utils.HashPassword(password)
+	require.NoError(t, err)
+	admin := *domain.NewUser("Auditor", "auditor@example.com", hashed)
+	admin.Role = "admin"
+	admin.ID, err = userRepo.Create(context.Background(), admin)
+	require.NoError(t, err)
+	user := *domain.NewUser("Audited", "audited@example.com", hashed)
+	user.ID, err = userRepo.Create(context.Background(), user)
+	require.NoError(t, err)
+
+	sessions := application.NewSessionHandler(userRepo, sessionRepo, auth.CookieConfig{})
+	userHandler := application.NewUserHandler(userRepo, sessions)
+
+	router := gin.New()
+	router.Use(middleware.RequestID())
+	router.Use(middleware.AuditOrigin())
+	router.POST("/login", userHandler.Login)
+	authed := router.Group("/", middleware.JWTAuth(auth.NewAuthenticator(mocks.NewAPITokenRepository(), sessionRepo), auth.CookieConfig{}))
+	authed.PUT("/users/:id", userHandler.UpdateUser)
+	authed.GET("/admin/audit", middleware.RequireAdmin(userRepo), application.NewAuditHandler(auditRepo).List)
+
+	do := func(method, path, token string, body interface{}) (int, []byte) {
+		var buf bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&buf).Encode(body)
+		}
+		req, _ := http.NewRequest(method, path, &buf)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Request-ID", "req-123")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code, w.Body.Bytes()
+	}
+
+	status, raw := do(http.MethodPost, "/login", "", gin.H{"email": admin.Email, "password": password})
+	require.Equal(t, http.StatusOK, status, string(raw))
+	var login map[string]string
+	json.Unmarshal(raw, &login)
+	adminToken := login["token"]
+
+	status, raw = do(http.MethodPut, "/users/"+user.ID.Hex(), adminToken, gin.H{"email": "changed@example.com"})
+	require.Equal(t, http.StatusOK, status, string(raw))
+
+	events := auditRepo.Events()
+	actions := []string{}
+	for _, e := range events {
+		actions = append(actions, e.Action)
+	}
+	// การล็อกอินอัพเดท last_login แต่ไม่ถือเป็นการแก้ไขข้อมูลผู้ใช้
+	assert.Equal(t, []string{domain.AuditUserCreate, domain.AuditUserCreate, domain.AuditUserLogin, domain.AuditUserUpdate}, actions)
+
+	loginEvent := events[2]
+	assert.Equal(t, admin.ID.Hex(), loginEvent.ActorID)
+	assert.Equal(t, domain.AuditSourceHTTP, loginEvent.Source)
+
+	update := events[3]
+	assert.Equal(t, admin.ID.Hex(), update.ActorID)
+	assert.Equal(t, user.ID.Hex(), update.TargetUserID)
+	assert.Equal(t, "req-123", update.RequestID)
+	assert.Equal(t, domain.AuditSourceHTTP, update.Source)
+	require.Len(t, update.Changes, 1)
+	assert.Equal(t, domain.FieldChange{Field: "email", Old: "audited@example.com", New: "changed@example.com"}, update.Changes[0])
+
+	t.Run("Query By Target User", func(t *testing.T) {
+		status, raw := do(http.MethodGet, "/admin/audit?user="+user.ID.Hex(), adminToken, nil)
+		require.Equal(t, http.StatusOK, status, string(raw))
+		var page struct {
+			Events []domain.AuditEvent `json:"events"`
+			Total  int64               `json:"total"`
+		}
+		require.NoError(t, json.Unmarshal(raw, &page))
+		assert.EqualValues(t, 2, page.Total)
+		assert.Equal(t, domain.AuditUserUpdate, page.Events[0].Action)
+	})
+
+	t.Run("Query By Actor With Pagination", func(t *testing.T) {
+		status, raw := do(http.MethodGet, "/admin/audit?actor="+admin.ID.Hex()+"&limit=1&page=2", adminToken, nil)
+		require.Equal(t, http.StatusOK, status, string(raw))
+		var page struct {
+			Events []domain.AuditEvent `json:"events"`
+			Total  int64               `json:"total"`
+		}
+		require.NoError(t, json.Unmarshal(raw, &page))
+		assert.EqualValues(t, 2, page.Total)
+		require.Len(t, page.Events, 1)
+		assert.Equal(t, domain.AuditUserLogin, page.Events[0].Action)
+	})
+
+	t.Run("Invalid Time Range", func(t *testing.T) {
+		status, _ := do(http.MethodGet, "/admin/audit?from=yesterday", adminToken, nil)
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+}
+
+func TestChangesRedactSecrets(t *testing.T) {
+	user := *domain.NewUser("Secretive", "secret@example.com", "old-hash")
+	user.MFA = domain.MFASettings{PendingSecret: "OLDSECRET"}
+
+	changes := audit.Changes(user, map[string]interface{}{
+		"password":   "new-hash",
+		"name":       "Secretive",
+		"mfa":        domain.MFASettings{Enabled: true, Secret: "NEWSECRET", RecoveryCodes: []string{"a", "b"}},
+		"last_login": user.CreatedAt,
+		"status":     "inactive",
+	})
+
+	byField := map[string]domain.FieldChange{}
+	for _, c := range changes {
+		byField[c.Field] = c
+	}
+	assert.NotContains(t, byField, "name", "unchanged fields are not recorded")
+	assert.NotContains(t, byField, "last_login")
+	assert.Equal(t, audit.Redacted, byField["password"].Old)
+	assert.Equal(t, audit.Redacted, byField["password"].New)
+	assert.Equal(t, "active", byField["status"].Old)
+
+	encoded, err := json.Marshal(byField["mfa"])
+	require.NoError(t, err)
+	assert.NotContains(t, string(encoded), "NEWSECRET")
+	assert.NotContains(t, string(encoded), "OLDSECRET")
+	assert.Contains(t, string(encoded), `"enabled":true`)
+}
@@ -0,0 +1,187 @@
+package auth_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/application"
+	"github.com/Gsupakin/back_end_test_challeng/internal/auth"
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	grpcserver "github.com/Gsupakin/back_end_test_challeng/internal/grpc"
+	"github.com/Gsupakin/back_end_test_challeng/middleware"
+	appjwt "github.com/Gsupakin/back_end_test_challeng/pkg/jwt"
+	"github.com/Gsupakin/back_end_test_challeng/tests/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type env struct {
+	router    *gin.Engine
+	authn     *auth.Authenticator
+	tokenRepo *mocks.APITokenRepository
+	user      domain.User
+	jwt       string
+}
+
+func setup(t *testing.T) *env {
+	t.Helper()
+	appjwt.SetSecret("api-token-test-secret")
+	gin.SetMode(gin.TestMode)
+
+	userRepo := mocks.NewUserRepository()
+	tokenRepo := mocks.NewAPITokenRepository()
+	sessionRepo := mocks.NewSessionRepository()
+	user := *domain.NewUser("Token User", "token.user@example.com", "not-a-bcrypt-hash")
+	id, err := userRepo.Create(context.Background(), user)
+	require.NoError(t, err)
+	user.ID = id
+
+	jwtToken, err := appjwt.GenerateJWT(user.ID.Hex(), sessionRepo.StartSession(user.ID))
+	require.NoError(t, err)
+
+	authn := auth.NewAuthenticator(tokenRepo, sessionRepo)
+	userHandler := application.NewUserHandler(userRepo, application.NewSessionHandler(userRepo, sessionRepo, auth.CookieConfig{}))
+	tokenHandler := application.NewTokenHandler(userRepo, tokenRepo)
+
+	router := gin.New()
+	authed := router.Group("/", middleware.JWTAuth(authn, auth.CookieConfig{}))
+	authed.GET("/users/:id", middleware.RequireScope(domain.ScopeUsersRead), userHandler.GetUserByID)
+	me := authed.Group("/me", middleware.RequireInteractive())
+	me.POST("/tokens", tokenHandler.CreatePersonal)
+	me.GET("/tokens", tokenHandler.ListPersonal)
+	me.DELETE("/tokens/:id", tokenHandler.RevokePersonal)
+
+	return &env{router: router, authn: authn, tokenRepo: tokenRepo, user: user, jwt: jwtToken}
+}
+
+func (e *env) do(method, path, token string, body interface{}) (int, map[string]interface{}) {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req, _ := http.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	e.router.ServeHTTP(w, req)
+
+	var resp map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return w.Code, resp
+}
+
+func (e *env) createToken(t *testing.T, scopes ...string) (string, string) {
+	status, body := e.do(http.MethodPost, "/me/tokens", e.jwt, gin.H{"name": "ci", "scopes": scopes})
+	require.Equal(t, http.StatusCreated, status, body)
+	details := body["details"].(map[string]interface{})
+	return body["token"].(string), details["id"].(string)
+}
+
+func TestPersonalAccessToken(t *testing.T) {
+	e := setup(t)
+	token, tokenID := e.createToken(t, domain.ScopeUsersRead)
+	assert.Regexp(t, `^bet_pat_[a-z2-7]{8}_[a-z2-7]+$`, token)
+
+	stored, err := e.tokenRepo.FindByPrefix(context.Background(), token[:16])
+	require.NoError(t, err)
+	assert.NotContains(t, stored.Hash, token)
+	assert.Nil(t, stored.LastUsedAt)
+
+	status, body := e.do(http.MethodGet, "/users/"+e.user.ID.Hex(), token, nil)
+	require.Equal(t, http.StatusOK, status, body)
+	assert.Equal(t, e.user.Email, body["email"])
+
+	stored, _ = e.tokenRepo.FindByPrefix(context.Background(), token[:16])
+	assert.NotNil(t, stored.LastUsedAt)
+
+	t.Run("Cannot Manage Credentials", func(t *testing.T) {
+		status, _ := e.do(http.MethodGet, "/me/tokens", token, nil)
+		assert.Equal(t, http.StatusForbidden, status)
+	})
+
+	t.Run("Tampered Secret", func(t *testing.T) {
+		status, _ := e.do(http.MethodGet, "/users/"+e.user.ID.Hex(), token[:len(token)-1]+"x", nil)
+		assert.Equal(t, http.StatusUnauthorized, status)
+	})
+
+	t.Run("Revoked Token Is Rejected", func(t *testing.T) {
+		status, _ := e.do(http.MethodDelete, "/me/tokens/"+tokenID, e.jwt, nil)
+		require.Equal(t, http.StatusOK, status)
+
+		status, _ = e.do(http.MethodGet, "/users/"+e.user.ID.Hex(), token, nil)
+		assert.Equal(t, http.StatusUnauthorized, status)
+	})
+}
+
+func TestPersonalAccessTokenScopes(t *testing.T) {
+	e := setup(t)
+	token, _ := e.createToken(t, domain.ScopeUsersWrite)
+
+	status, _ := e.do(http.MethodGet, "/users/"+e.user.ID.Hex(), token, nil)
+	assert.Equal(t, http.StatusForbidden, status)
+
+	t.Run("Admin Scope Requires Admin", func(t *testing.T) {
+		status, _ := e.do(http.MethodPost, "/me/tokens", e.jwt, gin.H{"name": "x", "scopes": []string{domain.ScopeAdmin}})
+		assert.Equal(t, http.StatusForbidden, status)
+	})
+
+	t.Run("Unknown Scope", func(t *testing.T) {
+		status, _ := e.do(http.MethodPost, "/me/tokens", e.jwt, gin.H{"name": "x", "scopes": []string{"everything"}})
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+
+	t.Run("Malformed Authorization Header", func(t *testing.T) {
+		status, _ := e.do(http.MethodGet, "/users/"+e.user.ID.Hex(), "", nil)
+		assert.Equal(t, http.StatusUnauthorized, status)
+
+		req, _ := http.NewRequest(http.MethodGet, "/users/"+e.user.ID.Hex(), nil)
+		req.Header.Set("Authorization", token)
+		w := httptest.NewRecorder()
+		e.router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+func TestGRPCAuthInterceptor(t *testing.T) {
+	e := setup(t)
+	readToken, _ := e.createToken(t, domain.ScopeUsersRead)
+	writeToken, _ := e.createToken(t, domain.ScopeUsersWrite)
+
+	interceptor := grpcserver.AuthInterceptor(e.authn)
+	info := &grpc.UnaryServerInfo{FullMethod: "/user.UserService/GetUser"}
+	call := func(credential string) (*domain.Principal, error) {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+credential))
+		var principal *domain.Principal
+		_, err := interceptor(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			principal, _ = auth.PrincipalFromContext(ctx)
+			return nil, nil
+		})
+		return principal, err
+	}
+
+	principal, err := call(e.jwt)
+	require.NoError(t, err)
+	assert.Equal(t, domain.AuthMethodJWT, principal.Method)
+
+	principal, err = call(readToken)
+	require.NoError(t, err)
+	assert.Equal(t, domain.AuthMethodPersonalToken, principal.Method)
+	assert.Equal(t, e.user.ID.Hex(), principal.UserID)
+
+	_, err = call(writeToken)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = call("not-a-token")
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
@@ -0,0 +1,129 @@
+package auth_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/application"
+	"github.com/Gsupakin/back_end_test_challeng/internal/auth"
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/middleware"
+	appjwt "github.com/Gsupakin/back_end_test_challeng/pkg/jwt"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/utils"
+	"github.com/Gsupakin/back_end_test_challeng/tests/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCookieMode(t *testing.T) {
+	appjwt.SetSecret("cookie-test-secret")
+	gin.SetMode(gin.TestMode)
+
+	cookies := auth.CookieConfig{
+		Mode:     auth.CookieModeOn,
+		Name:     "access_token",
+		CSRFName: "csrf_token",
+		Path:     "/",
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	}
+
+	userRepo := mocks.NewUserRepository()
+	sessionRepo := mocks.NewSessionRepository()
+	hashed, err := utils.HashPassword(sessionPassword)
+	require.NoError(t, err)
+	user := *domain.NewUser("Cookie User", sessionEmail, hashed)
+	user.ID, err = userRepo.Create(context.Background(), user)
+	require.NoError(t, err)
+
+	sessions := application.NewSessionHandler(userRepo, sessionRepo, cookies)
+	userHandler := application.NewUserHandler(userRepo, sessions)
+
+	router := gin.New()
+	router.POST("/login", userHandler.Login)
+	authed := router.Group("/", middleware.JWTAuth(auth.NewAuthenticator(mocks.NewAPITokenRepository(), sessionRepo), cookies))
+	authed.GET("/me/sessions", sessions.List)
+	authed.DELETE("/me/sessions/:id", sessions.Revoke)
+	authed.POST("/logout", sessions.Logout)
+
+	body, _ := json.Marshal(gin.H{"email": sessionEmail, "password": sessionPassword})
+	req, _ := http.NewRequest(http.MethodPost, "/login", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var loginResp map[string]string
+	json.Unmarshal(w.Body.Bytes(), &loginResp)
+	assert.Empty(t, loginResp["token"], "token must not be exposed to JavaScript in cookie mode")
+	require.NotEmpty(t, loginResp["csrf_token"])
+
+	var sessionCookie, csrfCookie *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		switch c.Name {
+		case cookies.Name:
+			sessionCookie = c
+		case cookies.CSRFName:
+			csrfCookie = c
+		}
+	}
+	require.NotNil(t, sessionCookie)
+	require.NotNil(t, csrfCookie)
+	assert.True(t, sessionCookie.HttpOnly)
+	assert.True(t, sessionCookie.Secure)
+	assert.Equal(t, http.SameSiteStrictMode, sessionCookie.SameSite)
+	assert.False(t, csrfCookie.HttpOnly)
+	assert.Equal(t, loginResp["csrf_token"], csrfCookie.Value)
+
+	do := func(method, path, csrfHeader string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, nil)
+		req.AddCookie(sessionCookie)
+		req.AddCookie(csrfCookie)
+		if csrfHeader != "" {
+			req.Header.Set(auth.CSRFHeader, csrfHeader)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w = do(http.MethodGet, "/me/sessions", "")
+	require.Equal(t, http.StatusOK, w.Code, "safe methods do not need a CSRF token")
+	var list []domain.Session
+	json.Unmarshal(w.Body.Bytes(), &list)
+	require.Len(t, list, 1)
+
+	t.Run("Missing CSRF Header", func(t *testing.T) {
+		w := do(http.MethodPost, "/logout", "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("CSRF Token From Another Session", func(t *testing.T) {
+		other, err := auth.NewCSRFToken(sessionRepo.StartSession(user.ID))
+		require.NoError(t, err)
+
+		req, _ := http.NewRequest(http.MethodPost, "/logout", nil)
+		req.AddCookie(sessionCookie)
+		req.AddCookie(&http.Cookie{Name: cookies.CSRFName, Value: other})
+		req.Header.Set(auth.CSRFHeader, other)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Logout With CSRF Token", func(t *testing.T) {
+		w := do(http.MethodPost, "/logout", csrfCookie.Value)
+		require.Equal(t, http.StatusOK, w.Code)
+		for _, c := range w.Result().Cookies() {
+			assert.True(t, c.MaxAge < 0, "cookie %s should be cleared", c.Name)
+		}
+
+		w = do(http.MethodGet, "/me/sessions", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
@@ -0,0 +1,134 @@
+package auth_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/application"
+	"github.com/Gsupakin/back_end_test_challeng/internal/auth"
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/middleware"
+	appjwt "github.com/Gsupakin/back_end_test_challeng/pkg/jwt"
+	"github.com/Gsupakin/back_end_test_challeng/tests/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImpersonation(t *testing.T) {
+	appjwt.SetSecret("impersonation-test-secret")
+	gin.SetMode(gin.TestMode)
+
+	userRepo := mocks.NewUserRepository()
+	sessionRepo := mocks.NewSessionRepository()
+	tokenRepo := mocks.NewAPITokenRepository()
+
+	admin := *domain.NewUser("Support Admin", "admin@example.com", "x")
+	admin.Role = "admin"
+	admin.ID, _ = userRepo.Create(context.Background(), admin)
+	otherAdmin := *domain.NewUser("Other Admin", "admin2@example.com", "x")
+	otherAdmin.Role = "admin"
+	otherAdmin.ID, _ = userRepo.Create(context.Background(), otherAdmin)
+	user := *domain.NewUser("Customer", "customer@example.com", "x")
+	user.ID, _ = userRepo.Create(context.Background(), user)
+
+	adminToken, err := appjwt.GenerateJWT(admin.ID.Hex(), sessionRepo.StartSession(admin.ID))
+	require.NoError(t, err)
+
+	sessions := application.NewSessionHandler(userRepo, sessionRepo, auth.CookieConfig{})
+	userHandler := application.NewUserHandler(userRepo, sessions)
+	tokenHandler := application.NewTokenHandler(userRepo, tokenRepo)
+
+	router := gin.New()
+	authed := router.Group("/", middleware.JWTAuth(auth.NewAuthenticator(tokenRepo, sessionRepo), auth.CookieConfig{}))
+	authed.GET("/users/:id", userHandler.GetUserByID)
+	authed.PUT("/users/:id", userHandler.UpdateUser)
+	authed.DELETE("/users/:id", middleware.ForbidImpersonation(), userHandler.DeleteUser)
+	authed.POST("/logout", sessions.Logout)
+	authed.POST("/me/tokens", middleware.RequireInteractive(), tokenHandler.CreatePersonal)
+	adminGroup := authed.Group("/admin", middleware.RequireAdmin(userRepo))
+	adminGroup.POST("/users/:id/impersonate", middleware.RequireInteractive(), sessions.Impersonate)
+	adminGroup.DELETE("/impersonations/:id", sessions.EndImpersonation)
+
+	do := func(method, path, token string, body interface{}) (int, map[string]interface{}) {
+		var buf bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&buf).Encode(body)
+		}
+		req, _ := http.NewRequest(method, path, &buf)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var resp map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+	impersonate := func(target string) (int, map[string]interface{}) {
+		return do(http.MethodPost, "/admin/users/"+target+"/impersonate", adminToken, gin.H{"reason": "ticket #42"})
+	}
+
+	status, body := impersonate(user.ID.Hex())
+	require.Equal(t, http.StatusOK, status, body)
+	token := body["token"].(string)
+	sessionID := body["session_id"].(string)
+
+	claims, err := appjwt.ValidateToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID.Hex(), claims.Subject)
+	require.NotNil(t, claims.Actor)
+	assert.Equal(t, admin.ID.Hex(), claims.Actor.Subject)
+
+	status, body = do(http.MethodGet, "/users/"+user.ID.Hex(), token, nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, user.Email, body["email"])
+
+	t.Run("Sensitive Actions Are Forbidden", func(t *testing.T) {
+		status, _ := do(http.MethodDelete, "/users/"+user.ID.Hex(), token, nil)
+		assert.Equal(t, http.StatusForbidden, status)
+
+		status, _ = do(http.MethodPut, "/users/"+user.ID.Hex(), token, gin.H{"email": "attacker@example.com"})
+		assert.Equal(t, http.StatusForbidden, status)
+
+		status, _ = do(http.MethodPost, "/me/tokens", token, gin.H{"name": "x", "scopes": []string{domain.ScopeUsersRead}})
+		assert.Equal(t, http.StatusForbidden, status)
+
+		status, _ = impersonate(otherAdmin.ID.Hex())
+		assert.Equal(t, http.StatusForbidden, status)
+	})
+
+	t.Run("Forged Actor Claim Is Rejected", func(t *testing.T) {
+		forged, err := appjwt.GenerateImpersonationJWT(user.ID.Hex(), sessionID, otherAdmin.ID.Hex(), time.Minute)
+		require.NoError(t, err)
+		status, _ := do(http.MethodGet, "/users/"+user.ID.Hex(), forged, nil)
+		assert.Equal(t, http.StatusUnauthorized, status)
+	})
+
+	t.Run("Admin Ends Impersonation", func(t *testing.T) {
+		status, _ := do(http.MethodDelete, "/admin/impersonations/"+sessionID, adminToken, nil)
+		require.Equal(t, http.StatusOK, status)
+
+		status, _ = do(http.MethodGet, "/users/"+user.ID.Hex(), token, nil)
+		assert.Equal(t, http.StatusUnauthorized, status)
+	})
+
+	t.Run("Impersonated User Can Log Out", func(t *testing.T) {
+		_, body := impersonate(user.ID.Hex())
+		token := body["token"].(string)
+		status, _ := do(http.MethodPost, "/logout", token, nil)
+		require.Equal(t, http.StatusOK, status)
+
+		status, _ = do(http.MethodGet, "/users/"+user.ID.Hex(), token, nil)
+		assert.Equal(t, http.StatusUnauthorized, status)
+	})
+
+	t.Run("Cannot Impersonate Without Reason", func(t *testing.T) {
+		status, _ := do(http.MethodPost, "/admin/users/"+user.ID.Hex()+"/impersonate", adminToken, gin.H{})
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+}
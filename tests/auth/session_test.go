@@ -0,0 +1,148 @@
+package auth_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/application"
+	"github.com/Gsupakin/back_end_test_challeng/internal/auth"
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	grpcserver "github.com/Gsupakin/back_end_test_challeng/internal/grpc"
+	"github.com/Gsupakin/back_end_test_challeng/middleware"
+	appjwt "github.com/Gsupakin/back_end_test_challeng/pkg/jwt"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/utils"
+	"github.com/Gsupakin/back_end_test_challeng/tests/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	sessionEmail    = "session.user@example.com"
+	sessionPassword = "Password123"
+	chromeOnMac     = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	firefoxOnLinux  = "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0"
+)
+
+func TestSessions(t *testing.T) {
+	appjwt.SetSecret("session-test-secret")
+	gin.SetMode(gin.TestMode)
+
+	userRepo := mocks.NewUserRepository()
+	sessionRepo := mocks.NewSessionRepository()
+	hashed, err := utils.HashPassword(sessionPassword)
+	require.NoError(t, err)
+	user := *domain.NewUser("Session User", sessionEmail, hashed)
+	user.ID, err = userRepo.Create(context.Background(), user)
+	require.NoError(t, err)
+
+	authn := auth.NewAuthenticator(mocks.NewAPITokenRepository(), sessionRepo)
+	sessions := application.NewSessionHandler(userRepo, sessionRepo, auth.CookieConfig{})
+	userHandler := application.NewUserHandler(userRepo, sessions)
+
+	router := gin.New()
+	router.POST("/login", userHandler.Login)
+	authed := router.Group("/", middleware.JWTAuth(authn, auth.CookieConfig{}))
+	authed.GET("/me/sessions", sessions.List)
+	authed.DELETE("/me/sessions/:id", sessions.Revoke)
+
+	do := func(method, path, token, userAgent string, body interface{}) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&buf).Encode(body)
+		}
+		req, _ := http.NewRequest(method, path, &buf)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", userAgent)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	login := func(userAgent string) string {
+		w := do(http.MethodPost, "/login", "", userAgent, gin.H{"email": sessionEmail, "password": sessionPassword})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var body map[string]string
+		json.Unmarshal(w.Body.Bytes(), &body)
+		return body["token"]
+	}
+
+	laptop := login(chromeOnMac)
+	server := login(firefoxOnLinux)
+
+	updated, _ := userRepo.FindByID(context.Background(), user.ID)
+	assert.NotNil(t, updated.LastLogin)
+
+	w := do(http.MethodGet, "/me/sessions", laptop, chromeOnMac, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var list []domain.Session
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list, 2)
+
+	var serverSession domain.Session
+	for _, s := range list {
+		if s.Current {
+			assert.Equal(t, "Chrome on macOS", s.Device)
+		} else {
+			serverSession = s
+		}
+	}
+	require.Equal(t, "Firefox on Linux", serverSession.Device)
+
+	w = do(http.MethodDelete, "/me/sessions/"+serverSession.ID.Hex(), laptop, chromeOnMac, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	t.Run("Revoked Session Token Is Rejected Over HTTP", func(t *testing.T) {
+		w := do(http.MethodGet, "/me/sessions", server, firefoxOnLinux, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = do(http.MethodGet, "/me/sessions", laptop, chromeOnMac, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Revoked Session Token Is Rejected Over gRPC", func(t *testing.T) {
+		interceptor := grpcserver.AuthInterceptor(authn)
+		info := &grpc.UnaryServerInfo{FullMethod: "/user.UserService/GetUser"}
+		call := func(token string) error {
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+			_, err := interceptor(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, nil
+			})
+			return err
+		}
+
+		assert.NoError(t, call(laptop))
+		assert.Equal(t, codes.Unauthenticated, status.Code(call(server)))
+	})
+
+	t.Run("Cannot Revoke Another User's Session", func(t *testing.T) {
+		otherID := sessionRepo.StartSession(domain.NewUser("x", "x@example.com", "x").ID)
+		w := do(http.MethodDelete, "/me/sessions/"+otherID, laptop, chromeOnMac, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestTokensNeedConfiguredSecret(t *testing.T) {
+	// JWT_SECRET_KEY ใน environment ไม่ถูกอ่านตรง ๆ ต้องมาจาก config ผ่าน SetSecret เท่านั้น
+	t.Setenv("JWT_SECRET_KEY", "from-environment")
+	appjwt.SetSecret("")
+	_, err := appjwt.GenerateJWT("user", "session")
+	assert.Error(t, err)
+
+	appjwt.SetSecret("session-test-secret")
+	token, err := appjwt.GenerateJWT("user", "session")
+	require.NoError(t, err)
+	claims, err := appjwt.ValidateToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, "user", claims.UserID)
+}
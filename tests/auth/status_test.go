@@ -0,0 +1,165 @@
+package auth_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/application"
+	"github.com/Gsupakin/back_end_test_challeng/internal/auth"
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	grpcserver "github.com/Gsupakin/back_end_test_challeng/internal/grpc"
+	"github.com/Gsupakin/back_end_test_challeng/middleware"
+	appjwt "github.com/Gsupakin/back_end_test_challeng/pkg/jwt"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/utils"
+	pb "github.com/Gsupakin/back_end_test_challeng/proto"
+	"github.com/Gsupakin/back_end_test_challeng/tests/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestUserStatusIsEnforced(t *testing.T) {
+	appjwt.SetSecret("status-test-secret")
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+
+	userRepo := mocks.NewUserRepository()
+	sessionRepo := mocks.NewSessionRepository()
+	hashed, err := utils.HashPassword(sessionPassword)
+	require.NoError(t, err)
+	user := *domain.NewUser("Status User", sessionEmail, hashed)
+	user.ID, err = userRepo.Create(ctx, user)
+	require.NoError(t, err)
+
+	authn := auth.NewAuthenticator(mocks.NewAPITokenRepository(), sessionRepo).WithUsers(userRepo)
+	sessions := application.NewSessionHandler(userRepo, sessionRepo, auth.CookieConfig{})
+	userHandler := application.NewUserHandler(userRepo, sessions)
+
+	router := gin.New()
+	router.POST("/login", userHandler.Login)
+	authed := router.Group("/", middleware.JWTAuth(authn, auth.CookieConfig{}))
+	authed.GET("/me/sessions", sessions.List)
+
+	do := func(method, path, token string, body interface{}) (int, map[string]interface{}) {
+		var buf bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&buf).Encode(body)
+		}
+		req, _ := http.NewRequest(method, path, &buf)
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var resp map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+	login := func() (int, map[string]interface{}) {
+		return do(http.MethodPost, "/login", "", gin.H{"email": sessionEmail, "password": sessionPassword})
+	}
+	interceptor := grpcserver.AuthInterceptor(authn)
+	info := &grpc.UnaryServerInfo{FullMethod: "/user.UserService/GetUser"}
+	callGRPC := func(token string) error {
+		md := metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+token))
+		_, err := interceptor(md, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+		return err
+	}
+	change := func(action string, until *time.Time, at time.Time) {
+		current, err := userRepo.FindByID(ctx, user.ID)
+		require.NoError(t, err)
+		transition, err := domain.FindTransition(current.Status, action)
+		require.NoError(t, err)
+		require.NoError(t, userRepo.ChangeStatus(ctx, user.ID, domain.StatusChange{Transition: transition, Reason: "test", Until: until, At: at}))
+	}
+
+	code, body := login()
+	require.Equal(t, http.StatusOK, code, body)
+	token := body["token"].(string)
+
+	until := time.Now().Add(time.Hour)
+	change(domain.TransitionSuspend, &until, time.Now())
+
+	code, body = login()
+	assert.Equal(t, http.StatusForbidden, code)
+	assert.Equal(t, string(domain.StatusSuspended), body["status"])
+	assert.Contains(t, body["error"], "suspended until")
+
+	// token ที่ออกไว้ก่อนถูกระงับใช้ต่อไม่ได้ทั้ง HTTP และ gRPC
+	code, _ = do(http.MethodGet, "/me/sessions", token, nil)
+	assert.Equal(t, http.StatusForbidden, code)
+	assert.Equal(t, codes.PermissionDenied, status.Code(callGRPC(token)))
+
+	t.Run("Expired Suspension", func(t *testing.T) {
+		past := time.Now().Add(-time.Minute)
+		change(domain.TransitionReactivate, nil, time.Now())
+		change(domain.TransitionSuspend, &past, past.Add(-time.Hour))
+
+		code, body := login()
+		require.Equal(t, http.StatusOK, code, body)
+		code, _ = do(http.MethodGet, "/me/sessions", token, nil)
+		assert.Equal(t, http.StatusOK, code)
+		assert.NoError(t, callGRPC(token))
+	})
+
+	t.Run("Banned", func(t *testing.T) {
+		change(domain.TransitionReactivate, nil, time.Now())
+		change(domain.TransitionBan, nil, time.Now())
+
+		code, body := login()
+		assert.Equal(t, http.StatusForbidden, code)
+		assert.Equal(t, "Account is banned", body["error"])
+		code, _ = do(http.MethodGet, "/me/sessions", token, nil)
+		assert.Equal(t, http.StatusForbidden, code)
+	})
+}
+
+func TestGRPCCreatedUserCanAuthenticate(t *testing.T) {
+	appjwt.SetSecret("status-test-secret")
+	gin.SetMode(gin.TestMode)
+	utils.BcryptCost = 4
+	ctx := context.Background()
+
+	userRepo := mocks.NewUserRepository()
+	sessionRepo := mocks.NewSessionRepository()
+	authn := auth.NewAuthenticator(mocks.NewAPITokenRepository(), sessionRepo).WithUsers(userRepo)
+	userHandler := application.NewUserHandler(userRepo, application.NewSessionHandler(userRepo, sessionRepo, auth.CookieConfig{}))
+	router := gin.New()
+	router.POST("/login", userHandler.Login)
+
+	resp, err := grpcserver.NewUserServer(userRepo).CreateUser(ctx, &pb.CreateUserRequest{
+		Name: "Grpc User", Email: "grpc.user@example.com", Password: sessionPassword,
+	})
+	require.NoError(t, err)
+	created, err := userRepo.FindByEmail(ctx, "grpc.user@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, resp.Id, created.ID.Hex())
+	assert.Equal(t, domain.StatusActive, created.Status)
+	assert.Equal(t, domain.RoleUser, created.Role)
+	assert.NotEqual(t, sessionPassword, created.Password, "password is stored hashed")
+
+	body, _ := json.Marshal(gin.H{"email": "grpc.user@example.com", "password": sessionPassword})
+	req, _ := http.NewRequest(http.MethodPost, "/login", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var login map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &login))
+
+	principal, err := authn.Authenticate(ctx, login["token"].(string), "")
+	require.NoError(t, err)
+	assert.Equal(t, resp.Id, principal.UserID)
+}
@@ -0,0 +1,306 @@
+package config_test
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/auth"
+	"github.com/Gsupakin/back_end_test_challeng/internal/config"
+	"github.com/Gsupakin/back_end_test_challeng/internal/requestlog"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/logger"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/mailer"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/redact"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestDefaults(t *testing.T) {
+	cfg, err := config.Load(nil)
+	require.NoError(t, err)
+	assert.Equal(t, ":8080", cfg.Server.HTTPAddr)
+	assert.Equal(t, ":50051", cfg.Server.GRPCAddr)
+	assert.Equal(t, "Test", cfg.Mongo.Database)
+	assert.Equal(t, 14, cfg.Auth.BcryptCost)
+	assert.Equal(t, 24*time.Hour, cfg.Auth.SessionTTL)
+	assert.Equal(t, 10*time.Second, cfg.Server.UserCountInterval)
+	assert.Equal(t, 5*time.Second, cfg.Server.DrainDelay)
+}
+
+func TestPrecedence(t *testing.T) {
+	file := writeFile(t, "config.yaml", `
+server:
+  http_addr: ":9000"
+  grpc_addr: ":9001"
+mongo:
+  database: from_file
+  slow_query: 2s
+log:
+  level: warn
+  queue_size: 50
+`)
+	t.Setenv("CONFIG_FILE", file)
+	t.Setenv("GRPC_ADDR", ":9101")
+	t.Setenv("MONGODB_DATABASE", "from_env")
+	t.Setenv("LOG_LEVEL", "error")
+
+	cfg, err := config.Load([]string{"-mongo.database", "from_flag"})
+	require.NoError(t, err)
+
+	assert.Equal(t, ":9000", cfg.Server.HTTPAddr, "file overrides default")
+	assert.Equal(t, ":9101", cfg.Server.GRPCAddr, "env overrides file")
+	assert.Equal(t, "from_flag", cfg.Mongo.Database, "flag overrides env")
+	assert.Equal(t, 2*time.Second, cfg.Mongo.SlowQuery)
+	assert.Equal(t, "error", cfg.Log.Level)
+	assert.Equal(t, 50, cfg.Log.QueueSize)
+
+	// subsystem ได้ค่าที่รวมแล้วในรูปที่ parse แล้ว
+	assert.Equal(t, slog.LevelError, cfg.Log.LoggerConfig().Level)
+	assert.Equal(t, 50, cfg.Log.WriterConfig().QueueSize)
+}
+
+func TestConfigFlagOverridesEnvFile(t *testing.T) {
+	t.Setenv("CONFIG_FILE", writeFile(t, "a.yaml", "mongo:\n  database: a\n"))
+	other := writeFile(t, "b.yaml", "mongo:\n  database: b\n")
+
+	cfg, err := config.Load([]string{"-config", other})
+	require.NoError(t, err)
+	assert.Equal(t, "b", cfg.Mongo.Database)
+}
+
+func TestSecretFromFile(t *testing.T) {
+	t.Setenv("JWT_SECRET_KEY", "")
+	t.Setenv("JWT_SECRET_KEY_FILE", writeFile(t, "jwt", "s3cret\n"))
+
+	cfg, err := config.Load(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", cfg.Auth.JWTSecret)
+
+	t.Setenv("JWT_SECRET_KEY", "inline")
+	_, err = config.Load(nil)
+	assert.EqualError(t, err, "JWT_SECRET_KEY and JWT_SECRET_KEY_FILE must not both be set")
+}
+
+func TestSecretsHaveNoFlag(t *testing.T) {
+	_, err := config.Load([]string{"-auth.jwt_secret", "leaked"})
+	assert.Error(t, err)
+}
+
+func TestInvalidValues(t *testing.T) {
+	t.Setenv("LOG_QUEUE_SIZE", "lots")
+	_, err := config.Load(nil)
+	assert.EqualError(t, err, `LOG_QUEUE_SIZE: "lots" is not an integer`)
+	t.Setenv("LOG_QUEUE_SIZE", "")
+
+	_, err = config.Load([]string{"-server.shutdown_timeout", "10"})
+	assert.EqualError(t, err, `-server.shutdown_timeout: "10" is not a duration such as 30s or 5m`)
+
+	t.Setenv("CONFIG_FILE", writeFile(t, "typo.yaml", "mongo:\n  databse: x\n"))
+	_, err = config.Load(nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "databse")
+}
+
+func TestValidate(t *testing.T) {
+	cfg := config.Default()
+	cfg.Auth.BcryptCost = 2
+	cfg.Server.ShutdownTimeout = 0
+	cfg.Server.MetricsAddr = cfg.Server.HTTPAddr
+	cfg.Server.DrainDelay = -time.Second
+
+	err := cfg.Validate()
+	require.Error(t, err)
+	for _, msg := range []string{
+		"MONGODB_URI is required",
+		"JWT_SECRET_KEY is required",
+		"BCRYPT_COST must be between 4 and 31",
+		"SHUTDOWN_TIMEOUT must be a positive duration",
+		"METRICS_ADDR must differ from HTTP_ADDR",
+		"SHUTDOWN_DRAIN_DELAY must not be negative",
+	} {
+		assert.Contains(t, err.Error(), msg)
+	}
+
+	cfg = config.Default()
+	cfg.Mongo.URI = "mongodb://localhost:27017"
+	cfg.Auth.JWTSecret = "secret"
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestValidateSubsystems(t *testing.T) {
+	cfg := config.Default()
+	cfg.Mongo.URI = "mongodb://localhost:27017"
+	cfg.Auth.JWTSecret = "secret"
+	cfg.Cookie.Mode = "sometimes"
+	cfg.Cookie.SameSite = "None"
+	cfg.Cookie.Secure = false
+	cfg.SMTP.Host = "smtp.example.com"
+	cfg.SMTP.Port = "smtp"
+	cfg.Log.Level = "loud"
+	cfg.Log.QueuePolicy = "wait"
+	cfg.Log.RetentionDays = 7
+	cfg.Log.ArchiveAfterDays = 30
+	cfg.Redact.IP = "mask"
+	cfg.Redact.SaltRotation = 0
+	cfg.Tracing.Exporter = "jaeger"
+
+	err := cfg.Validate()
+	require.Error(t, err)
+	for _, msg := range []string{
+		"AUTH_COOKIE_MODE must be one of off, on, both",
+		"AUTH_COOKIE_SAMESITE=none requires AUTH_COOKIE_SECURE=true",
+		"SMTP_PORT must be a port number",
+		"LOG_LEVEL must be debug, info, warn or error",
+		"LOG_QUEUE_POLICY must be one of drop, block",
+		"LOG_RETENTION_DAYS must be greater than LOG_ARCHIVE_AFTER_DAYS",
+		"LOG_REDACT_IP must be one of keep, truncate, hash, drop",
+		"LOG_REDACT_SALT_ROTATION must be a positive duration",
+		"OTEL_TRACES_EXPORTER must be one of none, otlp, console, file",
+	} {
+		assert.Contains(t, err.Error(), msg)
+	}
+	assert.NotContains(t, err.Error(), "AUTH_COOKIE_SAMESITE must be", "same_site is case-insensitive")
+}
+
+func TestSubsystemConfigs(t *testing.T) {
+	cfg := config.Default()
+	cfg.Cookie.Mode = "Both"
+	cfg.Cookie.SameSite = "strict"
+	cfg.Log.RetentionDays = 90
+	cfg.Log.ArchiveAfterDays = 30
+	cfg.Redact.UserID = "HASH"
+	cfg.Tracing.Exporter = "file"
+	require.NoError(t, cfg.Cookie.Validate())
+
+	cookie := cfg.Cookie.CookieConfig()
+	assert.Equal(t, auth.CookieModeBoth, cookie.Mode)
+	assert.True(t, cookie.Enabled())
+	assert.Equal(t, http.SameSiteStrictMode, cookie.SameSite)
+	assert.Equal(t, "/", cookie.Path)
+
+	archive := cfg.Log.ArchiveConfig()
+	assert.Equal(t, 90*24*time.Hour, archive.Retention)
+	assert.Equal(t, 30*24*time.Hour, archive.ArchiveAfter)
+	assert.Equal(t, "./archive/request_logs", archive.Dir)
+
+	writer := cfg.Log.WriterConfig()
+	assert.Equal(t, requestlog.PolicyDrop, writer.Policy)
+	assert.Equal(t, 500, writer.BatchSize)
+
+	assert.Equal(t, redact.ModeHash, cfg.Redact.Policy().UserID)
+	assert.Equal(t, redact.DefaultPolicy().IP, cfg.Redact.Policy().IP)
+	assert.Equal(t, tracing.ExporterFile, cfg.Tracing.TracingConfig().Exporter)
+
+	_, logged := cfg.SMTP.Mailer(slog.Default()).(mailer.LogMailer)
+	assert.True(t, logged, "without SMTP_HOST emails are written to the log")
+}
+
+// TestSubsystemsFromEnv ตรวจว่าค่าจาก environment ไปถึงแต่ละ subsystem ในรูปที่ parse แล้ว
+// และค่าที่ไม่ถูกต้องถูกปฏิเสธโดย Validate ของส่วนนั้น
+func TestSubsystemsFromEnv(t *testing.T) {
+	t.Run("Logger", func(t *testing.T) {
+		cfg, err := config.Load(nil)
+		require.NoError(t, err)
+		assert.Equal(t, logger.FormatJSON, cfg.Log.LoggerConfig().Format)
+		assert.Equal(t, slog.LevelInfo, cfg.Log.LoggerConfig().Level)
+
+		t.Setenv("LOG_FORMAT", "TEXT")
+		t.Setenv("LOG_LEVEL", "debug")
+		cfg, err = config.Load(nil)
+		require.NoError(t, err)
+		require.NoError(t, cfg.Log.Validate())
+		assert.Equal(t, logger.FormatText, cfg.Log.LoggerConfig().Format)
+		assert.Equal(t, slog.LevelDebug, cfg.Log.LoggerConfig().Level)
+
+		t.Setenv("LOG_LEVEL", "verbose")
+		cfg, err = config.Load(nil)
+		require.NoError(t, err)
+		assert.Error(t, cfg.Log.Validate())
+
+		t.Setenv("LOG_LEVEL", "")
+		t.Setenv("LOG_FORMAT", "xml")
+		cfg, err = config.Load(nil)
+		require.NoError(t, err)
+		assert.Error(t, cfg.Log.Validate())
+	})
+
+	t.Run("Archive", func(t *testing.T) {
+		t.Setenv("LOG_RETENTION_DAYS", "90")
+		t.Setenv("LOG_ARCHIVE_AFTER_DAYS", "30")
+		t.Setenv("LOG_ARCHIVE_DIR", "/var/archive")
+		cfg, err := config.Load(nil)
+		require.NoError(t, err)
+		require.NoError(t, cfg.Log.Validate())
+		archive := cfg.Log.ArchiveConfig()
+		assert.Equal(t, 90*24*time.Hour, archive.Retention)
+		assert.Equal(t, 30*24*time.Hour, archive.ArchiveAfter)
+		assert.Equal(t, "/var/archive", archive.Dir)
+
+		// TTL ต้องนานกว่าอายุที่ archive ไม่เช่นนั้น log จะหายก่อนถูก archive
+		t.Setenv("LOG_RETENTION_DAYS", "7")
+		cfg, err = config.Load(nil)
+		require.NoError(t, err)
+		assert.Error(t, cfg.Log.Validate())
+
+		t.Setenv("LOG_RETENTION_DAYS", "-1")
+		cfg, err = config.Load(nil)
+		require.NoError(t, err)
+		assert.Error(t, cfg.Log.Validate())
+	})
+
+	t.Run("Redact", func(t *testing.T) {
+		t.Setenv("LOG_REDACT_IP", "drop")
+		t.Setenv("LOG_REDACT_USER_AGENT", "keep")
+		t.Setenv("LOG_REDACT_PATH", "route")
+		cfg, err := config.Load(nil)
+		require.NoError(t, err)
+		require.NoError(t, cfg.Redact.Validate())
+		r := cfg.Redact.Redactor()
+		assert.Equal(t, "", r.IP("203.0.113.42"))
+		assert.Equal(t, "curl/8.4.0", r.UserAgent("curl/8.4.0"))
+		assert.Equal(t, "/users/:id", r.Path("/users/abc", "/users/:id"))
+
+		t.Setenv("LOG_REDACT_QUERY", "keep")
+		cfg, err = config.Load(nil)
+		require.NoError(t, err)
+		assert.Error(t, cfg.Redact.Validate(), "raw query strings may contain secrets and cannot be kept")
+	})
+
+	t.Run("Tracing", func(t *testing.T) {
+		cfg, err := config.Load(nil)
+		require.NoError(t, err)
+		assert.Equal(t, tracing.ExporterNone, cfg.Tracing.TracingConfig().Exporter)
+
+		t.Setenv("OTEL_TRACES_EXPORTER", "otlp")
+		t.Setenv("OTEL_SERVICE_NAME", "users")
+		cfg, err = config.Load(nil)
+		require.NoError(t, err)
+		require.NoError(t, cfg.Tracing.Validate())
+		assert.Equal(t, tracing.ExporterOTLP, cfg.Tracing.TracingConfig().Exporter)
+		assert.Equal(t, "users", cfg.Tracing.TracingConfig().ServiceName)
+
+		t.Setenv("OTEL_TRACES_EXPORTER", "jaeger")
+		cfg, err = config.Load(nil)
+		require.NoError(t, err)
+		assert.Error(t, cfg.Tracing.Validate())
+	})
+}
+
+func TestExampleFileMatchesDefaults(t *testing.T) {
+	cfg, err := config.Load([]string{"-config", "../../config.example.yaml"})
+	require.NoError(t, err)
+	expected := config.Default()
+	expected.Mongo.URI = cfg.Mongo.URI
+	expected.Auth.JWTSecret = cfg.Auth.JWTSecret
+	assert.Equal(t, expected, *cfg)
+}
@@ -11,9 +11,13 @@ import (
 	"testing"
 
 	"github.com/Gsupakin/back_end_test_challeng/internal/application"
+	"github.com/Gsupakin/back_end_test_challeng/internal/auth"
+	"github.com/Gsupakin/back_end_test_challeng/internal/config"
 	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
 	"github.com/Gsupakin/back_end_test_challeng/internal/infrastructure"
+	"github.com/Gsupakin/back_end_test_challeng/internal/requestlog"
 	"github.com/Gsupakin/back_end_test_challeng/middleware"
+	appjwt "github.com/Gsupakin/back_end_test_challeng/pkg/jwt"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	"github.com/stretchr/testify/assert"
@@ -34,30 +38,42 @@ func init() {
 
 func setupTest() (*gin.Engine, *application.UserHandler, *mongo.Client) {
 	gin.SetMode(gin.TestMode)
-	client := infrastructure.ConnectMongo()
-	db := client.Database("Test")
+	cfg, err := config.Load(nil)
+	if err != nil {
+		panic("Error loading config: " + err.Error())
+	}
+	appjwt.SetSecret(cfg.Auth.JWTSecret)
+	client, err := infrastructure.ConnectMongo(context.Background(), cfg.Mongo, nil)
+	if err != nil {
+		panic("Error connecting to MongoDB: " + err.Error())
+	}
+	db := client.Database(cfg.Mongo.Database)
 	userCollection := db.Collection("users")
 	logCollection := db.Collection("request_logs")
+	apiTokenCollection := db.Collection("api_tokens")
+	sessionCollection := db.Collection("sessions")
 
 	// Initialize repositories
 	userRepo := infrastructure.NewMongoUserRepository(userCollection)
 	logRepo := infrastructure.NewMongoLogRepository(logCollection)
+	apiTokenRepo := infrastructure.NewMongoAPITokenRepository(apiTokenCollection)
+	sessionRepo := infrastructure.NewMongoSessionRepository(sessionCollection)
 
 	// Initialize handler
-	userHandler := application.NewUserHandler(userRepo, logRepo)
+	userHandler := application.NewUserHandler(userRepo, application.NewSessionHandler(userRepo, sessionRepo, auth.CookieConfig{}))
 
 	router := gin.Default()
-	router.Use(middleware.RequestLoggerToMongo(logCollection))
+	router.Use(middleware.RequestLogger(requestlog.NewWriter(logRepo, requestlog.DefaultConfig())))
 
 	router.POST("/register", userHandler.Register)
 	router.POST("/login", userHandler.Login)
 
-	auth := router.Group("/", middleware.JWTAuth())
+	authed := router.Group("/", middleware.JWTAuth(auth.NewAuthenticator(apiTokenRepo, sessionRepo), auth.CookieConfig{}))
 	{
-		auth.GET("/users", userHandler.ListUsers)
-		auth.GET("/users/:id", userHandler.GetUserByID)
-		auth.PUT("/users/:id", userHandler.UpdateUser)
-		auth.DELETE("/users/:id", userHandler.DeleteUser)
+		authed.GET("/users", userHandler.ListUsers)
+		authed.GET("/users/:id", userHandler.GetUserByID)
+		authed.PUT("/users/:id", userHandler.UpdateUser)
+		authed.DELETE("/users/:id", userHandler.DeleteUser)
 	}
 
 	return router, userHandler, client
@@ -0,0 +1,177 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/application"
+	"github.com/Gsupakin/back_end_test_challeng/internal/auth"
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	grpcserver "github.com/Gsupakin/back_end_test_challeng/internal/grpc"
+	"github.com/Gsupakin/back_end_test_challeng/internal/requestlog"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/health"
+	pb "github.com/Gsupakin/back_end_test_challeng/proto"
+	"github.com/Gsupakin/back_end_test_challeng/tests/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newRouter(registry *health.Registry) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	h := application.NewHealthHandler(registry)
+	router := gin.New()
+	router.GET("/healthz", h.Liveness)
+	router.GET("/readyz", h.Readiness)
+	return router
+}
+
+func get(router *gin.Engine, path string) (int, health.Report) {
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	var report health.Report
+	json.Unmarshal(rec.Body.Bytes(), &report)
+	return rec.Code, report
+}
+
+func TestReadinessReportsEachCheck(t *testing.T) {
+	registry := health.NewRegistry(time.Second)
+	mongoErr := errors.New("server selection timeout")
+	var mongoDown bool
+	registry.Register("mongodb", func(context.Context) error {
+		if mongoDown {
+			return mongoErr
+		}
+		return nil
+	})
+	registry.Register("keys", func(context.Context) error { return nil })
+	router := newRouter(registry)
+
+	code, report := get(router, "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, health.StatusUp, report.Status)
+	assert.Equal(t, health.StatusUp, report.Checks["mongodb"].Status)
+	assert.Equal(t, health.StatusUp, report.Checks["keys"].Status)
+
+	mongoDown = true
+	code, report = get(router, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, health.StatusDown, report.Status)
+	assert.Equal(t, health.StatusDown, report.Checks["mongodb"].Status)
+	assert.Equal(t, mongoErr.Error(), report.Checks["mongodb"].Error)
+	assert.Equal(t, health.StatusUp, report.Checks["keys"].Status)
+
+	// liveness ไม่ขึ้นกับ dependency
+	code, report = get(router, "/healthz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, health.StatusUp, report.Status)
+}
+
+func TestCheckTimeout(t *testing.T) {
+	registry := health.NewRegistry(20 * time.Millisecond)
+	registry.Register("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	start := time.Now()
+	report := registry.Check(context.Background())
+	assert.Less(t, time.Since(start), time.Second)
+	assert.False(t, report.Ready())
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["slow"].Error)
+}
+
+func TestDrainFlipsReadiness(t *testing.T) {
+	registry := health.NewRegistry(time.Second)
+	registry.Register("mongodb", func(context.Context) error { return nil })
+	router := newRouter(registry)
+
+	code, _ := get(router, "/readyz")
+	require.Equal(t, http.StatusOK, code)
+
+	registry.Drain()
+	code, report := get(router, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, health.StatusDraining, report.Status)
+
+	code, _ = get(router, "/healthz")
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestRequestLogBacklogCheck(t *testing.T) {
+	repo := mocks.NewLogRepository()
+	repo.Block = make(chan struct{})
+	w := requestlog.NewWriter(repo, requestlog.Config{QueueSize: 4, BatchSize: 1, FlushInterval: time.Hour})
+
+	require.NoError(t, w.Healthy(0.5))
+	// รายการแรกค้างอยู่ที่ฐานข้อมูล รายการถัดไปค้างในคิว
+	for i := 0; i < 3; i++ {
+		w.Write(domain.RequestLog{Method: http.MethodGet, Path: "/users", Timestamp: time.Now()})
+	}
+	assert.Eventually(t, func() bool { return w.Healthy(0.5) != nil }, time.Second, 5*time.Millisecond)
+
+	close(repo.Block)
+	require.NoError(t, w.Close(context.Background()))
+	assert.EqualError(t, w.Healthy(0.5), "request log writer is closed")
+}
+
+func TestGRPCHealthServiceFollowsRegistry(t *testing.T) {
+	registry := health.NewRegistry(time.Second, pb.UserService_ServiceDesc.ServiceName)
+	var down bool
+	registry.Register("mongodb", func(context.Context) error {
+		if down {
+			return errors.New("unreachable")
+		}
+		return nil
+	})
+
+	// health service ต้องเรียกได้โดยไม่มี token แม้มี AuthInterceptor
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		grpcserver.AuthInterceptor(auth.NewAuthenticator(mocks.NewAPITokenRepository(), mocks.NewSessionRepository())),
+	))
+	healthpb.RegisterHealthServer(server, registry.GRPCServer())
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	status := func(service string) healthpb.HealthCheckResponse_ServingStatus {
+		resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		require.NoError(t, err)
+		return resp.Status
+	}
+
+	// ยังไม่เคยตรวจจึงยังไม่พร้อม
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status(""))
+
+	registry.Check(context.Background())
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, status(""))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, status("user.UserService"))
+
+	down = true
+	registry.Check(context.Background())
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status("user.UserService"))
+
+	down = false
+	registry.Check(context.Background())
+	registry.Drain()
+	registry.Check(context.Background())
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status(""))
+}
@@ -0,0 +1,172 @@
+package lifecycle_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/application"
+	"github.com/Gsupakin/back_end_test_challeng/internal/audit"
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/internal/lifecycle"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/utils"
+	"github.com/Gsupakin/back_end_test_challeng/tests/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestTransitionTable(t *testing.T) {
+	cases := []struct {
+		from   domain.UserStatus
+		action string
+		to     domain.UserStatus
+		err    error
+	}{
+		{domain.StatusPending, domain.TransitionActivate, domain.StatusActive, nil},
+		{domain.StatusActive, domain.TransitionSuspend, domain.StatusSuspended, nil},
+		{domain.StatusSuspended, domain.TransitionReactivate, domain.StatusActive, nil},
+		{domain.StatusActive, domain.TransitionBan, domain.StatusBanned, nil},
+		{domain.StatusBanned, domain.TransitionDelete, domain.StatusDeleted, nil},
+		{domain.StatusPending, domain.TransitionDelete, domain.StatusDeleted, nil},
+		{domain.StatusPending, domain.TransitionSuspend, "", domain.ErrInvalidTransition},
+		{domain.StatusSuspended, domain.TransitionBan, "", domain.ErrInvalidTransition},
+		{domain.StatusBanned, domain.TransitionReactivate, "", domain.ErrInvalidTransition},
+		{domain.StatusActive, domain.TransitionActivate, "", domain.ErrInvalidTransition},
+		{domain.StatusActive, "lock", "", domain.ErrInvalidInput},
+	}
+	for _, c := range cases {
+		transition, err := domain.FindTransition(c.from, c.action)
+		if c.err != nil {
+			assert.ErrorIs(t, err, c.err, "%s %s", c.action, c.from)
+			continue
+		}
+		require.NoError(t, err, "%s %s", c.action, c.from)
+		assert.Equal(t, c.to, transition.To)
+	}
+
+	_, err := domain.TransitionTo(domain.StatusBanned, domain.StatusActive)
+	assert.ErrorIs(t, err, domain.ErrInvalidTransition)
+	assert.True(t, domain.StatusPending.IsInitial())
+	assert.False(t, domain.StatusBanned.IsInitial())
+}
+
+func TestStatusChangeValidation(t *testing.T) {
+	now := time.Now()
+	suspend, err := domain.FindTransition(domain.StatusActive, domain.TransitionSuspend)
+	require.NoError(t, err)
+	ban, err := domain.FindTransition(domain.StatusActive, domain.TransitionBan)
+	require.NoError(t, err)
+	later, earlier := now.Add(time.Hour), now.Add(-time.Hour)
+
+	assert.ErrorIs(t, domain.StatusChange{Transition: suspend, Reason: " ", At: now}.Validate(), domain.ErrInvalidInput)
+	assert.ErrorIs(t, domain.StatusChange{Transition: suspend, Reason: "spam", Until: &earlier, At: now}.Validate(), domain.ErrInvalidInput)
+	assert.ErrorIs(t, domain.StatusChange{Transition: ban, Reason: "spam", Until: &later, At: now}.Validate(), domain.ErrInvalidInput)
+	assert.NoError(t, domain.StatusChange{Transition: suspend, Reason: "spam", Until: &later, At: now}.Validate())
+
+	user := domain.User{Status: domain.StatusSuspended, StatusUntil: &later}
+	assert.Equal(t, domain.StatusSuspended, user.EffectiveStatus(now))
+	assert.Equal(t, domain.StatusActive, user.EffectiveStatus(later))
+	user.DeletedAt = &now
+	assert.Equal(t, domain.StatusDeleted, user.EffectiveStatus(now))
+}
+
+func TestReactivateExpiredSuspensions(t *testing.T) {
+	utils.BcryptCost = 4
+	ctx := context.Background()
+	auditRepo := mocks.NewAuditRepository()
+	users := audit.NewUserRepository(mocks.NewUserRepository(), audit.NewRecorder(auditRepo))
+	admin := application.NewUserAdmin(users, mocks.NewAPITokenRepository())
+
+	until := time.Now().Add(time.Hour)
+	for _, name := range []string{"Short", "Open"} {
+		_, err := admin.Create(ctx, application.NewUser{Name: name, Email: name + "@example.com", Password: "Secret123"})
+		require.NoError(t, err)
+	}
+	short, err := admin.Transition(ctx, "Short@example.com", domain.TransitionSuspend, "cool down", &until)
+	require.NoError(t, err)
+	_, err = admin.Transition(ctx, "Open@example.com", domain.TransitionSuspend, "investigation", nil)
+	require.NoError(t, err)
+
+	reactivator := lifecycle.NewReactivator(users)
+	ids, err := reactivator.Run(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, ids, "suspension has not expired yet")
+
+	ids, err = reactivator.Run(ctx, until.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, []primitive.ObjectID{short.ID}, ids)
+
+	user, err := users.FindByID(ctx, short.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.StatusActive, user.Status)
+	assert.Equal(t, lifecycle.ExpiredReason, user.StatusReason)
+	assert.Nil(t, user.StatusUntil)
+	open, err := admin.Find(ctx, "Open@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, domain.StatusSuspended, open.Status, "suspensions without until stay until an admin reactivates")
+
+	var actions []string
+	for _, e := range auditRepo.Events() {
+		if e.TargetUserID == short.ID.Hex() {
+			actions = append(actions, e.Action)
+		}
+	}
+	assert.Equal(t, []string{domain.AuditUserCreate, "user.suspend", "user.reactivate"}, actions)
+}
+
+func TestExpiredSuspensionCanBeChangedAgain(t *testing.T) {
+	utils.BcryptCost = 4
+	ctx := context.Background()
+	users := mocks.NewUserRepository()
+	admin := application.NewUserAdmin(users, mocks.NewAPITokenRepository())
+	for _, u := range []application.NewUser{
+		{Name: "Root", Email: "root@example.com", Password: "Secret123", Role: domain.RoleAdmin},
+		{Name: "Other", Email: "other@example.com", Password: "Secret123", Role: domain.RoleAdmin},
+	} {
+		_, err := admin.Create(ctx, u)
+		require.NoError(t, err)
+	}
+	suspend, err := domain.FindTransition(domain.StatusActive, domain.TransitionSuspend)
+	require.NoError(t, err)
+	// การระงับที่หมดเวลาแล้วแต่ Reactivator ยังไม่ได้รัน
+	expire := func(ref string) domain.User {
+		user, err := admin.Find(ctx, ref)
+		require.NoError(t, err)
+		until := time.Now().Add(-time.Minute)
+		change := domain.StatusChange{Transition: suspend, Reason: "cool down", Until: &until, At: until.Add(-time.Hour)}
+		require.NoError(t, users.ChangeStatus(ctx, user.ID, change))
+		user, err = admin.Find(ctx, ref)
+		require.NoError(t, err)
+		require.Equal(t, domain.StatusSuspended, user.Status)
+		require.True(t, user.IsActive())
+		return user
+	}
+
+	expire("root@example.com")
+	user, err := admin.Transition(ctx, "root@example.com", domain.TransitionSuspend, "again", nil)
+	require.NoError(t, err)
+	assert.Equal(t, domain.StatusSuspended, user.Status)
+	assert.Nil(t, user.StatusUntil)
+	assert.False(t, user.IsActive())
+	_, err = admin.Transition(ctx, "root@example.com", domain.TransitionReactivate, "done", nil)
+	require.NoError(t, err)
+
+	expire("root@example.com")
+	_, err = admin.Transition(ctx, "root@example.com", domain.TransitionReactivate, "done", nil)
+	assert.ErrorIs(t, err, domain.ErrInvalidTransition, "an expired suspension is already active")
+	user, err = admin.SetStatus(ctx, "root@example.com", domain.StatusBanned, "abuse", nil)
+	require.NoError(t, err)
+	assert.Equal(t, domain.StatusBanned, user.Status)
+	assert.Nil(t, user.StatusUntil)
+
+	// Reactivator ไม่เปิดใช้งานผู้ใช้ที่ถูกแบนไปแล้ว
+	ids, err := lifecycle.NewReactivator(users).Run(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+
+	// admin ที่การระงับหมดเวลาแล้วคือ admin คนสุดท้ายที่ยัง active
+	expire("other@example.com")
+	_, err = admin.Transition(ctx, "other@example.com", domain.TransitionBan, "abuse", nil)
+	assert.ErrorIs(t, err, application.ErrLastAdmin)
+}
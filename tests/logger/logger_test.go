@@ -0,0 +1,116 @@
+package logger_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/auth"
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/middleware"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/logger"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/redact"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/requestid"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decode(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var records []map[string]any
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var r map[string]any
+		require.NoError(t, dec.Decode(&r))
+		records = append(records, r)
+	}
+	return records
+}
+
+func TestContextEnrichment(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.New(&buf, logger.Config{Format: logger.FormatJSON, Level: slog.LevelInfo})
+
+	ctx := requestid.WithID(context.Background(), "req-1")
+	ctx = logger.WithUserID(ctx, "user-1")
+	l.With("component", "test").InfoContext(ctx, "hello", "n", 1)
+	l.Info("no context")
+	l.DebugContext(ctx, "filtered out")
+
+	records := decode(t, &buf)
+	require.Len(t, records, 2)
+	assert.Equal(t, "hello", records[0]["msg"])
+	assert.Equal(t, "req-1", records[0]["request_id"])
+	assert.Equal(t, "user-1", records[0]["user_id"])
+	assert.Equal(t, "test", records[0]["component"])
+	assert.NotContains(t, records[1], "request_id")
+	assert.NotContains(t, records[1], "user_id")
+}
+
+func TestUserIDFollowsRedactionPolicy(t *testing.T) {
+	policy := redact.DefaultPolicy()
+	policy.UserID = redact.ModeHash
+	var buf bytes.Buffer
+	l := logger.New(&buf, logger.Config{Format: logger.FormatJSON, Redactor: redact.New(policy, nil, time.Hour)})
+
+	l.InfoContext(logger.WithUserID(context.Background(), "65a000000000000000000001"), "hello")
+	records := decode(t, &buf)
+	require.Len(t, records, 1)
+	assert.Len(t, records[0]["user_id"], 16)
+	assert.NotEqual(t, "65a000000000000000000001", records[0]["user_id"])
+}
+
+func TestTextFormat(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.New(&buf, logger.Config{Format: logger.FormatText, Level: slog.LevelInfo})
+	l.InfoContext(requestid.WithID(context.Background(), "req-2"), "hello")
+	assert.Contains(t, buf.String(), "msg=hello request_id=req-2")
+}
+
+func TestConsoleLoggerAndRecovery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var buf bytes.Buffer
+	l := logger.New(&buf, logger.DefaultConfig())
+
+	router := gin.New()
+	router.Use(middleware.RequestID())
+	router.Use(middleware.ConsoleLogger(l, redact.New(redact.DefaultPolicy(), nil, time.Hour)), middleware.Recovery(l))
+	router.GET("/users/:id", func(c *gin.Context) {
+		// จำลอง JWTAuth ที่แนบผู้เรียกไว้ใน context
+		c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), &domain.Principal{UserID: "user-9"}))
+		c.Status(http.StatusOK)
+	})
+	router.GET("/panic", func(c *gin.Context) { panic("boom") })
+
+	req := httptest.NewRequest(http.MethodGet, "/users/abc?token=secret", nil)
+	req.Header.Set(requestid.Header, "req-3")
+	req.RemoteAddr = "203.0.113.42:1234"
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	assert.NotContains(t, buf.String(), "secret")
+	assert.NotContains(t, buf.String(), "/users/abc")
+
+	records := decode(t, &buf)
+	require.Len(t, records, 3)
+	assert.Equal(t, "http request", records[0]["msg"])
+	assert.Equal(t, "/users/:id", records[0]["route"])
+	assert.Equal(t, "203.0.113.0", records[0]["ip"])
+	assert.Equal(t, "req-3", records[0]["request_id"])
+	assert.Equal(t, "user-9", records[0]["user_id"])
+
+	assert.Equal(t, "panic recovered", records[1]["msg"])
+	assert.Equal(t, "boom", records[1]["panic"])
+	assert.True(t, strings.Contains(records[1]["stack"].(string), "goroutine"))
+	assert.Equal(t, "ERROR", records[2]["level"])
+	assert.Equal(t, float64(http.StatusInternalServerError), records[2]["status"])
+}
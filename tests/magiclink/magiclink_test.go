@@ -0,0 +1,205 @@
+package magiclink_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/application"
+	"github.com/Gsupakin/back_end_test_challeng/internal/auth"
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/middleware"
+	appjwt "github.com/Gsupakin/back_end_test_challeng/pkg/jwt"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/utils"
+	"github.com/Gsupakin/back_end_test_challeng/tests/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	email     = "magic.user@example.com"
+	userAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	clientIP  = "198.51.100.7"
+)
+
+// mailbox เก็บอีเมลที่ handler ส่งจาก goroutine
+type mailbox chan string
+
+func (m mailbox) Send(ctx context.Context, to, subject, body string) error {
+	m <- body
+	return nil
+}
+
+type env struct {
+	router *gin.Engine
+	links  *mocks.MagicLinkRepository
+	mail   mailbox
+	user   domain.User
+}
+
+func setup(t *testing.T) *env {
+	t.Helper()
+	appjwt.SetSecret("magic-link-test-secret")
+	gin.SetMode(gin.TestMode)
+
+	users := mocks.NewUserRepository()
+	sessionRepo := mocks.NewSessionRepository()
+	links := mocks.NewMagicLinkRepository()
+	user := *domain.NewUser("Magic User", email, "not-a-bcrypt-hash")
+	var err error
+	user.ID, err = users.Create(context.Background(), user)
+	require.NoError(t, err)
+
+	mail := make(mailbox, 10)
+	sessions := application.NewSessionHandler(users, sessionRepo, auth.CookieConfig{})
+	h := application.NewMagicLinkHandler(users, links, sessions, mail, "https://app.example.com/magic")
+
+	router := gin.New()
+	router.POST("/login/magic-link", h.Request)
+	router.POST("/login/magic-link/verify", h.Verify)
+	authed := router.Group("/", middleware.JWTAuth(auth.NewAuthenticator(mocks.NewAPITokenRepository(), sessionRepo), auth.CookieConfig{}))
+	authed.DELETE("/me/magic-links", h.RevokeAll)
+
+	return &env{router: router, links: links, mail: mail, user: user}
+}
+
+func (e *env) do(method, path, ua, bearer string, body interface{}) (int, string) {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req, _ := http.NewRequest(method, path, &buf)
+	req.RemoteAddr = clientIP + ":40000"
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", ua)
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	w := httptest.NewRecorder()
+	e.router.ServeHTTP(w, req)
+	return w.Code, w.Body.String()
+}
+
+var linkPattern = regexp.MustCompile(`https://app\.example\.com/magic\?token=(\S+)`)
+
+// requestLink ขอลิงก์และคืน token จากอีเมลที่ถูกส่ง
+func (e *env) requestLink(t *testing.T) string {
+	t.Helper()
+	status, _ := e.do(http.MethodPost, "/login/magic-link", userAgent, "", gin.H{"email": email})
+	require.Equal(t, http.StatusAccepted, status)
+	select {
+	case body := <-e.mail:
+		match := linkPattern.FindStringSubmatch(body)
+		require.NotNil(t, match, body)
+		token, err := url.QueryUnescape(match[1])
+		require.NoError(t, err)
+		return token
+	case <-time.After(2 * time.Second):
+		t.Fatal("login link email was not sent")
+		return ""
+	}
+}
+
+func (e *env) verify(token, ua string) (int, map[string]interface{}) {
+	status, body := e.do(http.MethodPost, "/login/magic-link/verify", ua, "", gin.H{"token": token})
+	var resp map[string]interface{}
+	json.Unmarshal([]byte(body), &resp)
+	return status, resp
+}
+
+func TestRequestAndVerify(t *testing.T) {
+	e := setup(t)
+	token := e.requestLink(t)
+
+	status, body := e.verify(token, userAgent)
+	require.Equal(t, http.StatusOK, status, body)
+	claims, err := appjwt.ValidateToken(body["token"].(string))
+	require.NoError(t, err)
+	assert.Equal(t, e.user.ID.Hex(), claims.UserID)
+
+	links := e.links.Links()
+	require.Len(t, links, 1)
+	assert.NotNil(t, links[0].UsedAt)
+	assert.Equal(t, clientIP, links[0].IP)
+
+	t.Run("Second Use Is Rejected", func(t *testing.T) {
+		status, _ := e.verify(token, userAgent)
+		assert.Equal(t, http.StatusUnauthorized, status)
+	})
+}
+
+func TestFingerprintMismatch(t *testing.T) {
+	e := setup(t)
+	token := e.requestLink(t)
+
+	status, body := e.verify(token, "curl/8.4.0")
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, "Invalid or expired login link", body["error"])
+
+	// การลองจากอุปกรณ์อื่นไม่ทำให้ลิงก์ถูกใช้ไป
+	status, _ = e.verify(token, userAgent)
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestRevokedAndExpiredLinks(t *testing.T) {
+	e := setup(t)
+	first := e.requestLink(t)
+	second := e.requestLink(t)
+
+	// ใช้ลิงก์แรกล็อกอินแล้วยกเลิกลิงก์ที่เหลือทั้งหมด
+	status, body := e.verify(first, userAgent)
+	require.Equal(t, http.StatusOK, status, body)
+	code, resp := e.do(http.MethodDelete, "/me/magic-links", userAgent, body["token"].(string), nil)
+	require.Equal(t, http.StatusOK, code, resp)
+	assert.JSONEq(t, `{"revoked": 1}`, resp)
+
+	status, _ = e.verify(second, userAgent)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	// ลิงก์ที่หมดอายุในฐานข้อมูลใช้ไม่ได้แม้ token ยังไม่หมดอายุ
+	past := time.Now().Add(-time.Minute)
+	linkID, err := e.links.Create(context.Background(), domain.MagicLink{
+		UserID:      e.user.ID,
+		Fingerprint: utils.DeviceFingerprint(userAgent, clientIP),
+		CreatedAt:   past.Add(-15 * time.Minute),
+		ExpiresAt:   past,
+	})
+	require.NoError(t, err)
+	expired, err := appjwt.GeneratePurposeToken(e.user.ID.Hex(), appjwt.PurposeMagicLink, linkID.Hex(), time.Minute)
+	require.NoError(t, err)
+	status, _ = e.verify(expired, userAgent)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	// token ของ purpose อื่นใช้แทนลิงก์ไม่ได้
+	mfa, err := appjwt.GeneratePurposeToken(e.user.ID.Hex(), appjwt.PurposeMFA, linkID.Hex(), time.Minute)
+	require.NoError(t, err)
+	status, _ = e.verify(mfa, userAgent)
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
+
+func TestRequestDoesNotRevealAccounts(t *testing.T) {
+	e := setup(t)
+
+	knownStatus, known := e.do(http.MethodPost, "/login/magic-link", userAgent, "", gin.H{"email": email})
+	unknownStatus, unknown := e.do(http.MethodPost, "/login/magic-link", userAgent, "", gin.H{"email": "nobody@example.com"})
+	assert.Equal(t, http.StatusAccepted, knownStatus)
+	assert.Equal(t, knownStatus, unknownStatus)
+	assert.Equal(t, known, unknown)
+
+	select {
+	case <-e.mail:
+	case <-time.After(2 * time.Second):
+		t.Fatal("login link email was not sent")
+	}
+	assert.Len(t, e.links.Links(), 1, "no link is created for an unknown email")
+
+	status, _ := e.do(http.MethodPost, "/login/magic-link", userAgent, "", gin.H{"email": "not-an-email"})
+	assert.Equal(t, http.StatusBadRequest, status)
+}
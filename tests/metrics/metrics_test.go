@@ -0,0 +1,105 @@
+package metrics_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/application"
+	"github.com/Gsupakin/back_end_test_challeng/internal/auth"
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	grpcserver "github.com/Gsupakin/back_end_test_challeng/internal/grpc"
+	"github.com/Gsupakin/back_end_test_challeng/middleware"
+	appjwt "github.com/Gsupakin/back_end_test_challeng/pkg/jwt"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/metrics"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/utils"
+	"github.com/Gsupakin/back_end_test_challeng/tests/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestHTTPMetricsUseRouteTemplate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.Metrics())
+	router.GET("/users/:id", func(c *gin.Context) { c.Status(http.StatusNotFound) })
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	counter := metrics.HTTPRequests.WithLabelValues(http.MethodGet, "/users/:id", "404")
+	before := testutil.ToFloat64(counter)
+	for _, id := range []string{"a", "b", "c"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/"+id, nil))
+	}
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nope", nil))
+	assert.Equal(t, before+3, testutil.ToFloat64(counter))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues(http.MethodGet, "(no route)", "404")))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	assert.Contains(t, body, `backend_http_request_duration_seconds_count{method="GET",route="/users/:id"} 3`)
+	assert.NotContains(t, body, "/users/a")
+	assert.Contains(t, body, "go_goroutines")
+	assert.Contains(t, body, "backend_registered_users")
+}
+
+func TestGRPCMetrics(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/user.UserService/GetUser"}
+	_, err := grpcserver.MetricsInterceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "user not found")
+	})
+	require.Error(t, err)
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.GRPCRequests.WithLabelValues(info.FullMethod, "NotFound")))
+}
+
+func TestMongoTimer(t *testing.T) {
+	done := metrics.ObserveMongo("users", "FindByID")
+	done()
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.MongoDuration))
+}
+
+func TestLoginCounters(t *testing.T) {
+	appjwt.SetSecret("metrics-test-secret")
+	gin.SetMode(gin.TestMode)
+
+	userRepo := mocks.NewUserRepository()
+	hashed, err := utils.HashPassword("Password123!")
+	require.NoError(t, err)
+	user := *domain.NewUser("Metrics User", "metrics@example.com", hashed)
+	_, err = userRepo.Create(context.Background(), user)
+	require.NoError(t, err)
+
+	sessions := application.NewSessionHandler(userRepo, mocks.NewSessionRepository(), auth.CookieConfig{})
+	router := gin.New()
+	router.POST("/login", application.NewUserHandler(userRepo, sessions).Login)
+
+	login := func(email, password string) int {
+		body, _ := json.Marshal(gin.H{"email": email, "password": password})
+		req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, login("nobody@example.com", "Password123!"))
+	assert.Equal(t, http.StatusUnauthorized, login("metrics@example.com", "wrong"))
+	assert.Equal(t, http.StatusOK, login("metrics@example.com", "Password123!"))
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.Logins.WithLabelValues(metrics.LoginPassword, metrics.LoginFailure)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.Logins.WithLabelValues(metrics.LoginPassword, metrics.LoginSuccess)))
+
+	// bcrypt ถูกจับเวลาทั้งตอน hash และตอนเทียบรหัสผ่าน
+	count, err := testutil.GatherAndCount(metrics.Registry, "backend_password_hash_duration_seconds")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count, "one series per operation")
+}
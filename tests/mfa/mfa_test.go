@@ -0,0 +1,251 @@
+package mfa_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/application"
+	"github.com/Gsupakin/back_end_test_challeng/internal/auth"
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/middleware"
+	appjwt "github.com/Gsupakin/back_end_test_challeng/pkg/jwt"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/totp"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/utils"
+	"github.com/Gsupakin/back_end_test_challeng/tests/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	email    = "mfa.user@example.com"
+	password = "Password123"
+)
+
+type env struct {
+	router *gin.Engine
+	users  *mocks.UserRepository
+	user   domain.User
+}
+
+func setup(t *testing.T) *env {
+	t.Helper()
+	appjwt.SetSecret("mfa-test-secret")
+	gin.SetMode(gin.TestMode)
+	utils.BcryptCost = 4
+
+	users := mocks.NewUserRepository()
+	sessionRepo := mocks.NewSessionRepository()
+	hashed, err := utils.HashPassword(password)
+	require.NoError(t, err)
+	user := *domain.NewUser("MFA User", email, hashed)
+	user.ID, err = users.Create(context.Background(), user)
+	require.NoError(t, err)
+
+	authn := auth.NewAuthenticator(mocks.NewAPITokenRepository(), sessionRepo)
+	sessions := application.NewSessionHandler(users, sessionRepo, auth.CookieConfig{})
+	userHandler := application.NewUserHandler(users, sessions)
+	mfaHandler := application.NewMFAHandler(users, sessions, "Test")
+
+	router := gin.New()
+	router.POST("/login", userHandler.Login)
+	router.POST("/login/mfa", mfaHandler.VerifyLogin)
+	me := router.Group("/me", middleware.JWTAuth(authn, auth.CookieConfig{}))
+	me.POST("/mfa/totp", mfaHandler.Enroll)
+	me.POST("/mfa/totp/confirm", mfaHandler.Confirm)
+	me.POST("/mfa/recovery-codes", mfaHandler.RegenerateRecoveryCodes)
+
+	return &env{router: router, users: users, user: user}
+}
+
+func (e *env) do(path, token string, body interface{}) (int, map[string]interface{}) {
+	var buf bytes.Buffer
+	json.NewEncoder(&buf).Encode(body)
+	req, _ := http.NewRequest(http.MethodPost, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	e.router.ServeHTTP(w, req)
+	var resp map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return w.Code, resp
+}
+
+// login ล็อกอินด้วยรหัสผ่านและคืน JWT หรือ MFA challenge token ถ้าเปิด MFA อยู่
+func (e *env) login(t *testing.T) (token string, mfaToken string) {
+	t.Helper()
+	status, body := e.do("/login", "", gin.H{"email": email, "password": password})
+	require.Equal(t, http.StatusOK, status, body)
+	if body["mfa_required"] == true {
+		return "", body["mfa_token"].(string)
+	}
+	return body["token"].(string), ""
+}
+
+// enroll เปิด MFA ให้ผู้ใช้และคืน secret กับ recovery codes
+func (e *env) enroll(t *testing.T) (string, []string) {
+	t.Helper()
+	token, _ := e.login(t)
+	status, body := e.do("/me/mfa/totp", token, nil)
+	require.Equal(t, http.StatusOK, status, body)
+	secret := body["secret"].(string)
+	assert.Contains(t, body["otpauth_uri"], "otpauth://totp/Test:")
+	assert.NotEmpty(t, body["qr_png"])
+
+	status, _ = e.do("/me/mfa/totp/confirm", token, gin.H{"code": "000000"})
+	require.Equal(t, http.StatusUnauthorized, status)
+	status, body = e.do("/me/mfa/totp/confirm", token, gin.H{"code": code(t, secret, 0)})
+	require.Equal(t, http.StatusOK, status, body)
+	var codes []string
+	for _, c := range body["recovery_codes"].([]interface{}) {
+		codes = append(codes, c.(string))
+	}
+	require.Len(t, codes, 10)
+
+	status, _ = e.do("/me/mfa/totp", token, nil)
+	assert.Equal(t, http.StatusConflict, status, "already enabled")
+	return secret, codes
+}
+
+// code คืนรหัส TOTP ของ step ปัจจุบันบวก offset
+func code(t *testing.T, secret string, offset int64) string {
+	t.Helper()
+	c, err := totp.Code(secret, totp.Step(time.Now())+offset)
+	require.NoError(t, err)
+	return c
+}
+
+func TestEnrollAndLogin(t *testing.T) {
+	e := setup(t)
+	secret, _ := e.enroll(t)
+
+	stored, err := e.users.FindByID(context.Background(), e.user.ID)
+	require.NoError(t, err)
+	assert.True(t, stored.RequiresMFA())
+	assert.Empty(t, stored.MFA.PendingSecret)
+
+	_, mfaToken := e.login(t)
+	require.NotEmpty(t, mfaToken)
+
+	// รหัสที่ใช้ยืนยันตอนลงทะเบียนใช้ซ้ำไม่ได้
+	status, _ := e.do("/login/mfa", "", gin.H{"mfa_token": mfaToken, "code": code(t, secret, 0)})
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status, body := e.do("/login/mfa", "", gin.H{"mfa_token": mfaToken, "code": code(t, secret, 1)})
+	require.Equal(t, http.StatusOK, status, body)
+	claims, err := appjwt.ValidateToken(body["token"].(string))
+	require.NoError(t, err)
+	assert.Equal(t, e.user.ID.Hex(), claims.UserID)
+
+	t.Run("Challenge Is Single Use", func(t *testing.T) {
+		status, _ := e.do("/login/mfa", "", gin.H{"mfa_token": mfaToken, "code": code(t, secret, 1)})
+		assert.Equal(t, http.StatusUnauthorized, status)
+	})
+
+	t.Run("New Challenge Replaces The Old One", func(t *testing.T) {
+		_, first := e.login(t)
+		_, second := e.login(t)
+		status, body := e.do("/login/mfa", "", gin.H{"mfa_token": first, "code": "123456"})
+		assert.Equal(t, http.StatusUnauthorized, status)
+		assert.Equal(t, "Invalid or expired MFA token", body["error"])
+		status, body = e.do("/login/mfa", "", gin.H{"mfa_token": second, "code": "123456"})
+		assert.Equal(t, http.StatusUnauthorized, status)
+		assert.Equal(t, "Invalid MFA code", body["error"])
+	})
+}
+
+func TestRecoveryCodeIsSingleUse(t *testing.T) {
+	e := setup(t)
+	_, codes := e.enroll(t)
+
+	_, mfaToken := e.login(t)
+	status, body := e.do("/login/mfa", "", gin.H{"mfa_token": mfaToken, "code": codes[0]})
+	require.Equal(t, http.StatusOK, status, body)
+
+	_, mfaToken = e.login(t)
+	status, _ = e.do("/login/mfa", "", gin.H{"mfa_token": mfaToken, "code": codes[0]})
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	stored, err := e.users.FindByID(context.Background(), e.user.ID)
+	require.NoError(t, err)
+	assert.Len(t, stored.MFA.RecoveryCodes, 9)
+}
+
+func TestConcurrentVerifyAcceptsCodeOnce(t *testing.T) {
+	e := setup(t)
+	_, codes := e.enroll(t)
+	_, mfaToken := e.login(t)
+
+	// น้อยกว่าจำนวนครั้งที่ทำให้ challenge ถูกยกเลิก เพื่อให้มี request ที่สำเร็จหนึ่งครั้งเสมอ
+	const attempts = 4
+	var wg sync.WaitGroup
+	results := make(chan int, attempts)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status, _ := e.do("/login/mfa", "", gin.H{"mfa_token": mfaToken, "code": codes[1]})
+			results <- status
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	succeeded := 0
+	for status := range results {
+		if status == http.StatusOK {
+			succeeded++
+		}
+	}
+	assert.Equal(t, 1, succeeded)
+}
+
+func TestChallengeIsRevokedAfterFailures(t *testing.T) {
+	e := setup(t)
+	secret, codes := e.enroll(t)
+	_, mfaToken := e.login(t)
+
+	for i := 0; i < 5; i++ {
+		status, body := e.do("/login/mfa", "", gin.H{"mfa_token": mfaToken, "code": "000000"})
+		require.Equal(t, http.StatusUnauthorized, status)
+		assert.Equal(t, "Invalid MFA code", body["error"])
+	}
+
+	// รหัสที่ถูกต้องก็ใช้กับ challenge ที่ถูกยกเลิกแล้วไม่ได้ และ recovery code ยังไม่ถูกใช้
+	status, body := e.do("/login/mfa", "", gin.H{"mfa_token": mfaToken, "code": code(t, secret, 1)})
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, "Invalid or expired MFA token", body["error"])
+	status, _ = e.do("/login/mfa", "", gin.H{"mfa_token": mfaToken, "code": codes[0]})
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	_, mfaToken = e.login(t)
+	status, body = e.do("/login/mfa", "", gin.H{"mfa_token": mfaToken, "code": codes[0]})
+	assert.Equal(t, http.StatusOK, status, body)
+}
+
+func TestRegenerateRecoveryCodes(t *testing.T) {
+	e := setup(t)
+	secret, old := e.enroll(t)
+	_, mfaToken := e.login(t)
+	status, body := e.do("/login/mfa", "", gin.H{"mfa_token": mfaToken, "code": old[0]})
+	require.Equal(t, http.StatusOK, status, body)
+	token := body["token"].(string)
+
+	status, _ = e.do("/me/mfa/recovery-codes", token, gin.H{"code": "000000"})
+	assert.Equal(t, http.StatusUnauthorized, status)
+	status, body = e.do("/me/mfa/recovery-codes", token, gin.H{"code": code(t, secret, 1)})
+	require.Equal(t, http.StatusOK, status, body)
+	assert.Len(t, body["recovery_codes"], 10)
+
+	_, mfaToken = e.login(t)
+	status, _ = e.do("/login/mfa", "", gin.H{"mfa_token": mfaToken, "code": old[1]})
+	assert.Equal(t, http.StatusUnauthorized, status, "old recovery codes are replaced")
+}
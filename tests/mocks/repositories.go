@@ -0,0 +1,1215 @@
+// Package mocks มี repository แบบเก็บในหน่วยความจำ สำหรับทดสอบโดยไม่ต้องต่อ MongoDB
+package mocks
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/redact"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// UserRepository implements domain.UserRepository in memory
+type UserRepository struct {
+	mu    sync.Mutex
+	users map[primitive.ObjectID]domain.User
+}
+
+// NewUserRepository creates an empty in-memory UserRepository
+func NewUserRepository() *UserRepository {
+	return &UserRepository{users: map[primitive.ObjectID]domain.User{}}
+}
+
+// Create implements domain.UserRepository
+func (r *UserRepository) Create(ctx context.Context, user domain.User) (primitive.ObjectID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	r.users[user.ID] = user
+	return user.ID, nil
+}
+
+// FindByEmail implements domain.UserRepository
+func (r *UserRepository) FindByEmail(ctx context.Context, email string) (domain.User, error) {
+	return r.findOne(func(u domain.User) bool { return u.Email == email && u.DeletedAt == nil })
+}
+
+// FindByName implements domain.UserRepository
+func (r *UserRepository) FindByName(ctx context.Context, name string) (domain.User, error) {
+	return r.findOne(func(u domain.User) bool { return u.Name == name && u.DeletedAt == nil })
+}
+
+// FindByID implements domain.UserRepository
+func (r *UserRepository) FindByID(ctx context.Context, id primitive.ObjectID) (domain.User, error) {
+	return r.findOne(func(u domain.User) bool { return u.ID == id && u.DeletedAt == nil })
+}
+
+// FindAll implements domain.UserRepository
+func (r *UserRepository) FindAll(ctx context.Context) ([]domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var users []domain.User
+	for _, u := range r.users {
+		if u.DeletedAt == nil {
+			users = append(users, u)
+		}
+	}
+	return users, nil
+}
+
+// Update implements domain.UserRepository; รองรับเฉพาะ field ที่ handler ใช้งาน
+func (r *UserRepository) Update(ctx context.Context, id primitive.ObjectID, update map[string]interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok || u.DeletedAt != nil {
+		return nil
+	}
+	for key, value := range update {
+		switch key {
+		case "name":
+			u.Name = value.(string)
+		case "email":
+			u.Email = value.(string)
+		case "password":
+			u.Password = value.(string)
+		case "role":
+			u.Role = value.(string)
+		case "last_login":
+			u.LastLogin = value.(*time.Time)
+		case "mfa":
+			u.MFA = value.(domain.MFASettings)
+		case "mfa.pending_secret":
+			u.MFA.PendingSecret = value.(string)
+		case "mfa.recovery_codes":
+			u.MFA.RecoveryCodes = value.([]string)
+		}
+	}
+	now := time.Now()
+	u.UpdatedAt = &now
+	r.users[id] = u
+	return nil
+}
+
+// Delete implements domain.UserRepository
+func (r *UserRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if u, ok := r.users[id]; ok && u.DeletedAt == nil {
+		now := time.Now()
+		u.DeletedAt = &now
+		r.users[id] = u
+	}
+	return nil
+}
+
+// Restore implements domain.UserRepository
+func (r *UserRepository) Restore(ctx context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok || u.DeletedAt == nil {
+		return domain.ErrUserNotFound
+	}
+	for _, other := range r.users {
+		if other.DeletedAt == nil && (other.Email == u.Email || other.Name == u.Name) {
+			return domain.ErrUserAlreadyExists
+		}
+	}
+	u.DeletedAt = nil
+	r.users[id] = u
+	return nil
+}
+
+// ChangeStatus implements domain.UserRepository
+func (r *UserRepository) ChangeStatus(ctx context.Context, id primitive.ObjectID, change domain.StatusChange) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok || u.DeletedAt != nil {
+		return domain.ErrUserNotFound
+	}
+	if !change.AppliesTo(u) {
+		return domain.ErrInvalidTransition
+	}
+	at := change.At
+	u.Status, u.StatusReason, u.StatusUntil = change.To, change.Reason, change.Until
+	u.StatusChangedAt, u.UpdatedAt = &at, &at
+	r.users[id] = u
+	return nil
+}
+
+// UseTOTPStep implements domain.UserRepository
+func (r *UserRepository) UseTOTPStep(ctx context.Context, id primitive.ObjectID, step int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok || u.DeletedAt != nil || u.MFA.LastUsedStep >= step {
+		return domain.ErrInvalidMFACode
+	}
+	u.MFA.LastUsedStep = step
+	r.users[id] = u
+	return nil
+}
+
+// UseRecoveryCode implements domain.UserRepository
+func (r *UserRepository) UseRecoveryCode(ctx context.Context, id primitive.ObjectID, hash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok || u.DeletedAt != nil {
+		return domain.ErrInvalidMFACode
+	}
+	for i, stored := range u.MFA.RecoveryCodes {
+		if stored == hash {
+			u.MFA.RecoveryCodes = append(append([]string{}, u.MFA.RecoveryCodes[:i]...), u.MFA.RecoveryCodes[i+1:]...)
+			r.users[id] = u
+			return nil
+		}
+	}
+	return domain.ErrInvalidMFACode
+}
+
+// StartMFAChallenge implements domain.UserRepository
+func (r *UserRepository) StartMFAChallenge(ctx context.Context, id primitive.ObjectID, challengeID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok || u.DeletedAt != nil {
+		return domain.ErrUserNotFound
+	}
+	u.MFA.ChallengeID, u.MFA.ChallengeFailures = challengeID, 0
+	r.users[id] = u
+	return nil
+}
+
+// FailMFAChallenge implements domain.UserRepository
+func (r *UserRepository) FailMFAChallenge(ctx context.Context, id primitive.ObjectID, challengeID string, limit int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok || u.DeletedAt != nil || challengeID == "" || u.MFA.ChallengeID != challengeID {
+		return domain.ErrInvalidToken
+	}
+	u.MFA.ChallengeFailures++
+	if u.MFA.ChallengeFailures >= limit {
+		u.MFA.ChallengeID, u.MFA.ChallengeFailures = "", 0
+	}
+	r.users[id] = u
+	return nil
+}
+
+// EndMFAChallenge implements domain.UserRepository
+func (r *UserRepository) EndMFAChallenge(ctx context.Context, id primitive.ObjectID, challengeID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok || u.DeletedAt != nil || challengeID == "" || u.MFA.ChallengeID != challengeID {
+		return domain.ErrInvalidToken
+	}
+	u.MFA.ChallengeID, u.MFA.ChallengeFailures = "", 0
+	r.users[id] = u
+	return nil
+}
+
+// Search implements domain.UserRepository
+func (r *UserRepository) Search(ctx context.Context, filter domain.UserFilter) ([]domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	users := []domain.User{}
+	for _, u := range r.users {
+		if matchesFilter(u, filter) {
+			users = append(users, u)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if !users[i].CreatedAt.Equal(users[j].CreatedAt) {
+			return users[i].CreatedAt.Before(users[j].CreatedAt)
+		}
+		return users[i].ID.Hex() < users[j].ID.Hex()
+	})
+	if filter.Limit > 0 && len(users) > filter.Limit {
+		users = users[:filter.Limit]
+	}
+	return users, nil
+}
+
+// Each implements domain.UserRepository; ซ่อนรหัสผ่านและ secret ของ MFA เหมือน MongoDB
+func (r *UserRepository) Each(ctx context.Context, filter domain.UserFilter, fn func(domain.User) error) error {
+	users, _ := r.Search(ctx, filter)
+	for _, u := range users {
+		u.Password = ""
+		u.MFA.Secret, u.MFA.PendingSecret, u.MFA.RecoveryCodes = "", "", nil
+		if err := fn(u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func matchesFilter(u domain.User, filter domain.UserFilter) bool {
+	query := strings.ToLower(filter.Query)
+	switch {
+	case !filter.IncludeDeleted && filter.DeletedBefore == nil && u.DeletedAt != nil:
+	case filter.DeletedBefore != nil && (u.DeletedAt == nil || !u.DeletedAt.Before(*filter.DeletedBefore)):
+	case !filter.ID.IsZero() && u.ID != filter.ID:
+	case filter.Email != "" && u.Email != filter.Email:
+	case query != "" && !strings.Contains(strings.ToLower(u.Name), query) && !strings.Contains(strings.ToLower(u.Email), query):
+	case filter.Role != "" && u.Role != filter.Role:
+	case filter.Status != "" && u.Status != filter.Status:
+	case filter.StatusUntilBefore != nil && (u.StatusUntil == nil || !u.StatusUntil.Before(*filter.StatusUntilBefore)):
+	case filter.CreatedFrom != nil && u.CreatedAt.Before(*filter.CreatedFrom):
+	case filter.CreatedTo != nil && !u.CreatedAt.Before(*filter.CreatedTo):
+	default:
+		return true
+	}
+	return false
+}
+
+// Count implements domain.UserRepository
+func (r *UserRepository) Count(ctx context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var n int64
+	for _, u := range r.users {
+		if u.DeletedAt == nil {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// FindByLinkedIdentity implements domain.UserRepository
+func (r *UserRepository) FindByLinkedIdentity(ctx context.Context, issuer, subject string) (domain.User, error) {
+	return r.findOne(func(u domain.User) bool {
+		return u.DeletedAt == nil && hasIdentity(u, issuer, subject)
+	})
+}
+
+// AddLinkedIdentity implements domain.UserRepository
+func (r *UserRepository) AddLinkedIdentity(ctx context.Context, id primitive.ObjectID, identity domain.LinkedIdentity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range r.users {
+		if hasIdentity(u, identity.Issuer, identity.Subject) {
+			return domain.ErrIdentityLinked
+		}
+	}
+	u, ok := r.users[id]
+	if !ok || u.DeletedAt != nil {
+		return domain.ErrUserNotFound
+	}
+	u.LinkedIdentities = append(u.LinkedIdentities, identity)
+	r.users[id] = u
+	return nil
+}
+
+// RemoveLinkedIdentity implements domain.UserRepository
+func (r *UserRepository) RemoveLinkedIdentity(ctx context.Context, id primitive.ObjectID, issuer, subject string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok {
+		return nil
+	}
+	var kept []domain.LinkedIdentity
+	for _, identity := range u.LinkedIdentities {
+		if identity.Issuer != issuer || identity.Subject != subject {
+			kept = append(kept, identity)
+		}
+	}
+	u.LinkedIdentities = kept
+	r.users[id] = u
+	return nil
+}
+
+func hasIdentity(u domain.User, issuer, subject string) bool {
+	for _, identity := range u.LinkedIdentities {
+		if identity.Issuer == issuer && identity.Subject == subject {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *UserRepository) findOne(match func(domain.User) bool) (domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range r.users {
+		if match(u) {
+			return u, nil
+		}
+	}
+	return domain.User{}, mongo.ErrNoDocuments
+}
+
+// OAuthClientRepository implements domain.OAuthClientRepository in memory
+type OAuthClientRepository struct {
+	mu      sync.Mutex
+	clients map[string]domain.OAuthClient
+}
+
+// NewOAuthClientRepository creates an empty in-memory OAuthClientRepository
+func NewOAuthClientRepository() *OAuthClientRepository {
+	return &OAuthClientRepository{clients: map[string]domain.OAuthClient{}}
+}
+
+// Create implements domain.OAuthClientRepository
+func (r *OAuthClientRepository) Create(ctx context.Context, client domain.OAuthClient) (primitive.ObjectID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	client.ID = primitive.NewObjectID()
+	r.clients[client.ClientID] = client
+	return client.ID, nil
+}
+
+// FindByClientID implements domain.OAuthClientRepository
+func (r *OAuthClientRepository) FindByClientID(ctx context.Context, clientID string) (domain.OAuthClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	client, ok := r.clients[clientID]
+	if !ok {
+		return client, domain.ErrClientNotFound
+	}
+	return client, nil
+}
+
+// FindAll implements domain.OAuthClientRepository
+func (r *OAuthClientRepository) FindAll(ctx context.Context) ([]domain.OAuthClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var clients []domain.OAuthClient
+	for _, c := range r.clients {
+		clients = append(clients, c)
+	}
+	return clients, nil
+}
+
+// Delete implements domain.OAuthClientRepository
+func (r *OAuthClientRepository) Delete(ctx context.Context, clientID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.clients[clientID]; !ok {
+		return domain.ErrClientNotFound
+	}
+	delete(r.clients, clientID)
+	return nil
+}
+
+// MagicLinkRepository implements domain.MagicLinkRepository in memory
+type MagicLinkRepository struct {
+	mu    sync.Mutex
+	links map[primitive.ObjectID]domain.MagicLink
+}
+
+// NewMagicLinkRepository creates an empty in-memory MagicLinkRepository
+func NewMagicLinkRepository() *MagicLinkRepository {
+	return &MagicLinkRepository{links: map[primitive.ObjectID]domain.MagicLink{}}
+}
+
+// Create implements domain.MagicLinkRepository
+func (r *MagicLinkRepository) Create(ctx context.Context, link domain.MagicLink) (primitive.ObjectID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	link.ID = primitive.NewObjectID()
+	r.links[link.ID] = link
+	return link.ID, nil
+}
+
+// Consume implements domain.MagicLinkRepository
+func (r *MagicLinkRepository) Consume(ctx context.Context, id primitive.ObjectID, fingerprint string) (domain.MagicLink, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	link, ok := r.links[id]
+	now := time.Now()
+	if !ok || link.Fingerprint != fingerprint || link.UsedAt != nil || link.RevokedAt != nil || !now.Before(link.ExpiresAt) {
+		return domain.MagicLink{}, domain.ErrInvalidToken
+	}
+	link.UsedAt = &now
+	r.links[id] = link
+	return link, nil
+}
+
+// RevokeAllForUser implements domain.MagicLinkRepository
+func (r *MagicLinkRepository) RevokeAllForUser(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	var revoked int64
+	for id, link := range r.links {
+		if link.UserID == userID && link.UsedAt == nil && link.RevokedAt == nil {
+			link.RevokedAt = &now
+			r.links[id] = link
+			revoked++
+		}
+	}
+	return revoked, nil
+}
+
+// Links คืนลิงก์ทั้งหมดที่ถูกสร้าง
+func (r *MagicLinkRepository) Links() []domain.MagicLink {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	links := make([]domain.MagicLink, 0, len(r.links))
+	for _, link := range r.links {
+		links = append(links, link)
+	}
+	return links
+}
+
+// AuthorizationCodeRepository implements domain.AuthorizationCodeRepository in memory
+type AuthorizationCodeRepository struct {
+	mu    sync.Mutex
+	codes map[string]domain.AuthorizationCode
+}
+
+// NewAuthorizationCodeRepository creates an empty in-memory AuthorizationCodeRepository
+func NewAuthorizationCodeRepository() *AuthorizationCodeRepository {
+	return &AuthorizationCodeRepository{codes: map[string]domain.AuthorizationCode{}}
+}
+
+// Create implements domain.AuthorizationCodeRepository
+func (r *AuthorizationCodeRepository) Create(ctx context.Context, code domain.AuthorizationCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.codes[code.CodeHash] = code
+	return nil
+}
+
+// Consume implements domain.AuthorizationCodeRepository
+func (r *AuthorizationCodeRepository) Consume(ctx context.Context, codeHash string) (domain.AuthorizationCode, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	code, ok := r.codes[codeHash]
+	if !ok || code.UsedAt != nil || time.Now().After(code.ExpiresAt) {
+		return domain.AuthorizationCode{}, domain.ErrInvalidToken
+	}
+	now := time.Now()
+	code.UsedAt = &now
+	r.codes[codeHash] = code
+	return code, nil
+}
+
+// APITokenRepository implements domain.APITokenRepository in memory
+type APITokenRepository struct {
+	mu     sync.Mutex
+	tokens map[primitive.ObjectID]domain.APIToken
+}
+
+// NewAPITokenRepository creates an empty in-memory APITokenRepository
+func NewAPITokenRepository() *APITokenRepository {
+	return &APITokenRepository{tokens: map[primitive.ObjectID]domain.APIToken{}}
+}
+
+// Create implements domain.APITokenRepository
+func (r *APITokenRepository) Create(ctx context.Context, token domain.APIToken) (primitive.ObjectID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token.ID = primitive.NewObjectID()
+	r.tokens[token.ID] = token
+	return token.ID, nil
+}
+
+// FindByID implements domain.APITokenRepository
+func (r *APITokenRepository) FindByID(ctx context.Context, id primitive.ObjectID) (domain.APIToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.tokens[id]
+	if !ok {
+		return token, domain.ErrTokenNotFound
+	}
+	return token, nil
+}
+
+// FindByPrefix implements domain.APITokenRepository
+func (r *APITokenRepository) FindByPrefix(ctx context.Context, prefix string) (domain.APIToken, error) {
+	tokens := r.filter(func(t domain.APIToken) bool { return t.Prefix == prefix })
+	if len(tokens) == 0 {
+		return domain.APIToken{}, domain.ErrTokenNotFound
+	}
+	return tokens[0], nil
+}
+
+// FindByUser implements domain.APITokenRepository
+func (r *APITokenRepository) FindByUser(ctx context.Context, userID primitive.ObjectID, kind string) ([]domain.APIToken, error) {
+	return r.filter(func(t domain.APIToken) bool { return t.UserID == userID && t.Kind == kind }), nil
+}
+
+// FindByKind implements domain.APITokenRepository
+func (r *APITokenRepository) FindByKind(ctx context.Context, kind string) ([]domain.APIToken, error) {
+	return r.filter(func(t domain.APIToken) bool { return t.Kind == kind }), nil
+}
+
+// Revoke implements domain.APITokenRepository
+func (r *APITokenRepository) Revoke(ctx context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.tokens[id]
+	if !ok || token.RevokedAt != nil {
+		return domain.ErrTokenNotFound
+	}
+	now := time.Now()
+	token.RevokedAt = &now
+	r.tokens[id] = token
+	return nil
+}
+
+// TouchLastUsed implements domain.APITokenRepository
+func (r *APITokenRepository) TouchLastUsed(ctx context.Context, id primitive.ObjectID, ip string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.tokens[id]
+	if !ok {
+		return domain.ErrTokenNotFound
+	}
+	now := time.Now()
+	token.LastUsedAt, token.LastUsedIP = &now, ip
+	r.tokens[id] = token
+	return nil
+}
+
+func (r *APITokenRepository) filter(match func(domain.APIToken) bool) []domain.APIToken {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tokens := []domain.APIToken{}
+	for _, t := range r.tokens {
+		if match(t) {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens
+}
+
+// SessionRepository implements domain.SessionRepository in memory
+type SessionRepository struct {
+	mu       sync.Mutex
+	sessions map[primitive.ObjectID]domain.Session
+}
+
+// NewSessionRepository creates an empty in-memory SessionRepository
+func NewSessionRepository() *SessionRepository {
+	return &SessionRepository{sessions: map[primitive.ObjectID]domain.Session{}}
+}
+
+// Create implements domain.SessionRepository
+func (r *SessionRepository) Create(ctx context.Context, session domain.Session) (primitive.ObjectID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session.ID = primitive.NewObjectID()
+	r.sessions[session.ID] = session
+	return session.ID, nil
+}
+
+// FindByID implements domain.SessionRepository
+func (r *SessionRepository) FindByID(ctx context.Context, id primitive.ObjectID) (domain.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.sessions[id]
+	if !ok {
+		return session, domain.ErrSessionNotFound
+	}
+	return session, nil
+}
+
+// FindActiveByUser implements domain.SessionRepository
+func (r *SessionRepository) FindActiveByUser(ctx context.Context, userID primitive.ObjectID) ([]domain.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sessions := []domain.Session{}
+	for _, s := range r.sessions {
+		if s.UserID == userID && s.IsActive(time.Now()) {
+			sessions = append(sessions, s)
+		}
+	}
+	return sessions, nil
+}
+
+// Touch implements domain.SessionRepository
+func (r *SessionRepository) Touch(ctx context.Context, id primitive.ObjectID, ip string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.sessions[id]
+	if !ok {
+		return domain.ErrSessionNotFound
+	}
+	session.LastSeenAt, session.IP = time.Now(), ip
+	r.sessions[id] = session
+	return nil
+}
+
+// Revoke implements domain.SessionRepository
+func (r *SessionRepository) Revoke(ctx context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.sessions[id]
+	if !ok || session.RevokedAt != nil {
+		return domain.ErrSessionNotFound
+	}
+	now := time.Now()
+	session.RevokedAt = &now
+	r.sessions[id] = session
+	return nil
+}
+
+// StartSession สร้าง session ที่ใช้งานได้ให้ผู้ใช้ แล้วคืนค่า ID สำหรับออก JWT ในการทดสอบ
+func (r *SessionRepository) StartSession(userID primitive.ObjectID) string {
+	now := time.Now()
+	id, _ := r.Create(context.Background(), domain.Session{
+		UserID:     userID,
+		Device:     "Test device",
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(time.Hour),
+	})
+	return id.Hex()
+}
+
+// AuditRepository implements domain.AuditRepository in memory
+type AuditRepository struct {
+	mu     sync.Mutex
+	events []domain.AuditEvent
+}
+
+// NewAuditRepository creates an empty in-memory AuditRepository
+func NewAuditRepository() *AuditRepository {
+	return &AuditRepository{}
+}
+
+// Create implements domain.AuditRepository
+func (r *AuditRepository) Create(ctx context.Context, event domain.AuditEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	event.ID = primitive.NewObjectID()
+	r.events = append(r.events, event)
+	return nil
+}
+
+// Find implements domain.AuditRepository
+func (r *AuditRepository) Find(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEvent, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	matched := []domain.AuditEvent{}
+	for _, e := range r.events {
+		if filter.UserID != "" && e.TargetUserID != filter.UserID {
+			continue
+		}
+		if filter.ActorID != "" && e.ActorID != filter.ActorID && e.ImpersonatorID != filter.ActorID {
+			continue
+		}
+		if filter.From != nil && e.Timestamp.Before(*filter.From) || filter.To != nil && !e.Timestamp.Before(*filter.To) {
+			continue
+		}
+		matched = append(matched, e)
+	}
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].Timestamp.After(matched[j].Timestamp) })
+
+	total := int64(len(matched))
+	start := (filter.Page - 1) * filter.Limit
+	if start > len(matched) {
+		start = len(matched)
+	}
+	end := start + filter.Limit
+	if end > len(matched) {
+		end = len(matched)
+	}
+	return matched[start:end], total, nil
+}
+
+// Events คืน event ทั้งหมดตามลำดับที่บันทึก
+func (r *AuditRepository) Events() []domain.AuditEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]domain.AuditEvent(nil), r.events...)
+}
+
+// LogRepository implements domain.LogRepository in memory
+// Block ใช้จำลองฐานข้อมูลที่ช้า การเขียนแต่ละชุดจะรอจนกว่าจะได้รับค่าจาก channel นี้
+type LogRepository struct {
+	mu      sync.Mutex
+	logs    []domain.RequestLog
+	batches []int
+	Block   chan struct{}
+	Err     error
+}
+
+// NewLogRepository creates an empty in-memory LogRepository
+func NewLogRepository() *LogRepository {
+	return &LogRepository{}
+}
+
+// Create implements domain.LogRepository
+func (r *LogRepository) Create(ctx context.Context, log domain.RequestLog) error {
+	return r.CreateMany(ctx, []domain.RequestLog{log})
+}
+
+// CreateMany implements domain.LogRepository
+func (r *LogRepository) CreateMany(ctx context.Context, logs []domain.RequestLog) error {
+	if r.Block != nil {
+		select {
+		case <-r.Block:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Err != nil {
+		return r.Err
+	}
+	for _, l := range logs {
+		if l.ID.IsZero() {
+			l.ID = primitive.NewObjectID()
+		}
+		r.logs = append(r.logs, l)
+	}
+	r.batches = append(r.batches, len(logs))
+	return nil
+}
+
+// Logs returns every stored log
+func (r *LogRepository) Logs() []domain.RequestLog {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]domain.RequestLog(nil), r.logs...)
+}
+
+// Batches returns the size of each write in order
+func (r *LogRepository) Batches() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]int(nil), r.batches...)
+}
+
+// Find implements domain.LogRepository
+func (r *LogRepository) Find(ctx context.Context, filter domain.LogFilter) ([]domain.RequestLog, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	matched := []domain.RequestLog{}
+	for _, l := range r.logs {
+		if filter.Path != "" && !strings.HasPrefix(l.Path, filter.Path) ||
+			filter.StatusMin > 0 && l.Status < filter.StatusMin ||
+			filter.StatusMax > 0 && l.Status > filter.StatusMax ||
+			filter.IP != "" && l.IP != filter.IP ||
+			filter.UserID != "" && l.UserID != filter.UserID ||
+			filter.RequestID != "" && l.RequestID != filter.RequestID ||
+			filter.From != nil && l.Timestamp.Before(*filter.From) ||
+			filter.To != nil && !l.Timestamp.Before(*filter.To) {
+			continue
+		}
+		matched = append(matched, l)
+	}
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].Timestamp.After(matched[j].Timestamp) })
+
+	total := int64(len(matched))
+	start := (filter.Page - 1) * filter.Limit
+	if start > len(matched) {
+		start = len(matched)
+	}
+	end := start + filter.Limit
+	if end > len(matched) {
+		end = len(matched)
+	}
+	return matched[start:end], total, nil
+}
+
+// Stats implements domain.LogRepository with the same grouping and latency
+// histogram as the Mongo aggregation pipeline
+func (r *LogRepository) Stats(ctx context.Context, filter domain.LogStatsFilter) ([]domain.RouteStats, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	type key struct {
+		method, route string
+		bucket        time.Time
+	}
+	groups := map[key]*domain.RouteStats{}
+	histograms := map[key]map[int]*domain.LatencyBin{}
+	for _, l := range r.logs {
+		if l.Timestamp.Before(filter.From) || !l.Timestamp.Before(filter.To) {
+			continue
+		}
+		route := l.Route
+		if route == "" {
+			route = l.Path
+		}
+		k := key{l.Method, route, truncate(l.Timestamp, filter.Bucket)}
+		g, ok := groups[k]
+		if !ok {
+			g = &domain.RouteStats{Method: k.method, Route: k.route, BucketStart: k.bucket}
+			groups[k] = g
+		}
+		g.Count++
+		switch {
+		case l.Status >= 500:
+			g.ServerErrors++
+		case l.Status >= 400:
+			g.ClientErrors++
+		}
+		if histograms[k] == nil {
+			histograms[k] = map[int]*domain.LatencyBin{}
+		}
+		n := domain.LatencyBinOf(l.LatencyMS)
+		b, ok := histograms[k][n]
+		if !ok {
+			b = &domain.LatencyBin{Bin: n, Max: l.LatencyMS}
+			histograms[k][n] = b
+		}
+		b.Count++
+		if l.LatencyMS > b.Max {
+			b.Max = l.LatencyMS
+		}
+	}
+
+	stats := []domain.RouteStats{}
+	for k, g := range groups {
+		var bins []domain.LatencyBin
+		for _, b := range histograms[k] {
+			bins = append(bins, *b)
+		}
+		g.P50 = domain.LatencyPercentile(bins, 0.50)
+		g.P95 = domain.LatencyPercentile(bins, 0.95)
+		g.P99 = domain.LatencyPercentile(bins, 0.99)
+		g.ErrorRate = float64(g.ServerErrors) / float64(g.Count)
+		stats = append(stats, *g)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if !stats[i].BucketStart.Equal(stats[j].BucketStart) {
+			return stats[i].BucketStart.Before(stats[j].BucketStart)
+		}
+		if stats[i].Count != stats[j].Count {
+			return stats[i].Count > stats[j].Count
+		}
+		return stats[i].Route < stats[j].Route
+	})
+	return stats, nil
+}
+
+func truncate(t time.Time, bucket string) time.Time {
+	t = t.UTC()
+	switch bucket {
+	case domain.LogBucketMinute:
+		return t.Truncate(time.Minute)
+	case domain.LogBucketDay:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	default:
+		return t.Truncate(time.Hour)
+	}
+}
+
+// Oldest implements domain.LogArchiveRepository
+func (r *LogRepository) Oldest(ctx context.Context) (time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.logs) == 0 {
+		return time.Time{}, domain.ErrLogNotFound
+	}
+	oldest := r.logs[0].Timestamp
+	for _, l := range r.logs[1:] {
+		if l.Timestamp.Before(oldest) {
+			oldest = l.Timestamp
+		}
+	}
+	return oldest, nil
+}
+
+func inRange(l domain.RequestLog, from, to time.Time) bool {
+	return !l.Timestamp.Before(from) && l.Timestamp.Before(to)
+}
+
+// CountRange implements domain.LogArchiveRepository
+func (r *LogRepository) CountRange(ctx context.Context, from, to time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var n int64
+	for _, l := range r.logs {
+		if inRange(l, from, to) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// EachInRange implements domain.LogArchiveRepository
+func (r *LogRepository) EachInRange(ctx context.Context, from, to time.Time, fn func(domain.RequestLog) error) error {
+	r.mu.Lock()
+	matched := []domain.RequestLog{}
+	for _, l := range r.logs {
+		if inRange(l, from, to) {
+			matched = append(matched, l)
+		}
+	}
+	r.mu.Unlock()
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].Timestamp.Before(matched[j].Timestamp) })
+	for _, l := range matched {
+		if err := fn(l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteRange implements domain.LogArchiveRepository
+func (r *LogRepository) DeleteRange(ctx context.Context, from, to time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kept := r.logs[:0]
+	var deleted int64
+	for _, l := range r.logs {
+		if inRange(l, from, to) {
+			deleted++
+			continue
+		}
+		kept = append(kept, l)
+	}
+	r.logs = kept
+	return deleted, nil
+}
+
+// Restore implements domain.LogArchiveRepository
+func (r *LogRepository) Restore(ctx context.Context, logs []domain.RequestLog) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	existing := map[primitive.ObjectID]bool{}
+	for _, l := range r.logs {
+		existing[l.ID] = true
+	}
+	var inserted int64
+	for _, l := range logs {
+		if existing[l.ID] {
+			continue
+		}
+		existing[l.ID] = true
+		r.logs = append(r.logs, l)
+		inserted++
+	}
+	return inserted, nil
+}
+
+// EachByUser implements domain.LogRepository
+func (r *LogRepository) EachByUser(ctx context.Context, userKeys []string, fn func(domain.RequestLog) error) error {
+	r.mu.Lock()
+	matched := []domain.RequestLog{}
+	for _, l := range r.logs {
+		if slices.Contains(userKeys, l.UserID) || slices.Contains(userKeys, l.ImpersonatorID) {
+			matched = append(matched, l)
+		}
+	}
+	r.mu.Unlock()
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].Timestamp.Before(matched[j].Timestamp) })
+	for _, l := range matched {
+		if err := fn(l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ErasureRepository implements domain.ErasureRepository in memory
+type ErasureRepository struct {
+	mu       sync.Mutex
+	requests map[primitive.ObjectID]domain.ErasureRequest
+}
+
+// NewErasureRepository creates an empty in-memory ErasureRepository
+func NewErasureRepository() *ErasureRepository {
+	return &ErasureRepository{requests: map[primitive.ObjectID]domain.ErasureRequest{}}
+}
+
+// Create implements domain.ErasureRepository
+func (r *ErasureRepository) Create(ctx context.Context, request domain.ErasureRequest) (primitive.ObjectID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.requests {
+		if existing.UserID == request.UserID && existing.Status == domain.ErasurePending {
+			return primitive.NilObjectID, domain.ErrErasurePending
+		}
+	}
+	request.ID = primitive.NewObjectID()
+	r.requests[request.ID] = request
+	return request.ID, nil
+}
+
+// FindByID implements domain.ErasureRepository
+func (r *ErasureRepository) FindByID(ctx context.Context, id primitive.ObjectID) (domain.ErasureRequest, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	request, ok := r.requests[id]
+	if !ok {
+		return request, domain.ErrErasureNotFound
+	}
+	return request, nil
+}
+
+// Find implements domain.ErasureRepository
+func (r *ErasureRepository) Find(ctx context.Context, status string) ([]domain.ErasureRequest, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	requests := []domain.ErasureRequest{}
+	for _, request := range r.requests {
+		if status == "" || request.Status == status {
+			requests = append(requests, request)
+		}
+	}
+	sort.Slice(requests, func(i, j int) bool { return requests[i].RequestedAt.After(requests[j].RequestedAt) })
+	return requests, nil
+}
+
+// Reject implements domain.ErasureRepository
+func (r *ErasureRepository) Reject(ctx context.Context, id, adminID primitive.ObjectID, note string) error {
+	return r.decide(id, func(request *domain.ErasureRequest) {
+		now := time.Now()
+		request.Status, request.DecidedBy, request.DecidedAt, request.Note = domain.ErasureRejected, &adminID, &now, note
+	})
+}
+
+// Complete implements domain.ErasureRepository
+func (r *ErasureRepository) Complete(ctx context.Context, id, adminID primitive.ObjectID, receipt domain.ErasureReceipt) error {
+	return r.decide(id, func(request *domain.ErasureRequest) {
+		request.Status, request.DecidedBy, request.DecidedAt, request.Receipt = domain.ErasureCompleted, &adminID, &receipt.ErasedAt, &receipt
+	})
+}
+
+func (r *ErasureRepository) decide(id primitive.ObjectID, apply func(*domain.ErasureRequest)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	request, ok := r.requests[id]
+	if !ok {
+		return domain.ErrErasureNotFound
+	}
+	if request.Status != domain.ErasurePending {
+		return domain.ErrErasureDecided
+	}
+	apply(&request)
+	r.requests[id] = request
+	return nil
+}
+
+// PersonalDataEraser implements domain.PersonalDataEraser over the in-memory
+// repositories with the same rules as MongoDB. Repositories left nil are skipped
+// and Err simulates a database failure before anything is erased.
+type PersonalDataEraser struct {
+	Users    *UserRepository
+	Logs     *LogRepository
+	Sessions *SessionRepository
+	Tokens   *APITokenRepository
+	Audit    *AuditRepository
+	Redactor *redact.Redactor
+	Err      error
+}
+
+func (e *PersonalDataEraser) logKeys(hex string) []string {
+	if e.Redactor == nil {
+		return []string{hex}
+	}
+	return e.Redactor.UserIDKeys(hex)
+}
+
+// Limitations implements domain.PersonalDataEraser
+func (e *PersonalDataEraser) Limitations() []string {
+	if e.Redactor != nil && e.Redactor.Policy().UserID == redact.ModeHash {
+		return []string{domain.ErasureHashedLogsLimitation}
+	}
+	return nil
+}
+
+// Erase implements domain.PersonalDataEraser
+func (e *PersonalDataEraser) Erase(ctx context.Context, userID primitive.ObjectID, pseudonym domain.Pseudonym) (map[string]int64, error) {
+	if e.Err != nil {
+		return map[string]int64{}, e.Err
+	}
+	hex, alias := userID.Hex(), pseudonym.String()
+	counts := map[string]int64{}
+
+	if e.Sessions != nil {
+		e.Sessions.mu.Lock()
+		for id, s := range e.Sessions.sessions {
+			switch {
+			case s.UserID == userID:
+				delete(e.Sessions.sessions, id)
+			case s.ImpersonatorID != nil && *s.ImpersonatorID == userID:
+				pid := pseudonym.ID()
+				s.ImpersonatorID = &pid
+				e.Sessions.sessions[id] = s
+			default:
+				continue
+			}
+			counts["sessions"]++
+		}
+		e.Sessions.mu.Unlock()
+	}
+	if e.Tokens != nil {
+		e.Tokens.mu.Lock()
+		for id, t := range e.Tokens.tokens {
+			switch {
+			case t.UserID == userID:
+				delete(e.Tokens.tokens, id)
+			case t.CreatedBy == userID:
+				t.CreatedBy = pseudonym.ID()
+				e.Tokens.tokens[id] = t
+			default:
+				continue
+			}
+			counts["api_tokens"]++
+		}
+		e.Tokens.mu.Unlock()
+	}
+	if e.Logs != nil {
+		keys := e.logKeys(hex)
+		e.Logs.mu.Lock()
+		for i, l := range e.Logs.logs {
+			changed := l
+			changed.Path = strings.ReplaceAll(l.Path, hex, alias)
+			if slices.Contains(keys, l.UserID) {
+				changed.UserID, changed.IP, changed.UserAgent = alias, "", ""
+			}
+			if slices.Contains(keys, l.ImpersonatorID) {
+				changed.ImpersonatorID = alias
+			}
+			if changed != l {
+				e.Logs.logs[i] = changed
+				counts["request_logs"]++
+			}
+		}
+		e.Logs.mu.Unlock()
+	}
+	if e.Audit != nil {
+		e.Audit.mu.Lock()
+		for i, ev := range e.Audit.events {
+			changed := false
+			if ev.TargetUserID == hex {
+				for j := range ev.Changes {
+					ev.Changes[j].Old, ev.Changes[j].New = nil, nil
+				}
+				ev.TargetUserID, changed = alias, true
+			}
+			if ev.ActorID == hex {
+				ev.ActorID, ev.IP, changed = alias, "", true
+			}
+			if ev.ImpersonatorID == hex {
+				ev.ImpersonatorID, changed = alias, true
+			}
+			if changed {
+				e.Audit.events[i] = ev
+				counts["audit_log"]++
+			}
+		}
+		e.Audit.mu.Unlock()
+	}
+	if e.Users != nil {
+		e.Users.mu.Lock()
+		if _, ok := e.Users.users[userID]; ok {
+			delete(e.Users.users, userID)
+			counts["users"]++
+		}
+		e.Users.mu.Unlock()
+	}
+	return counts, nil
+}
@@ -0,0 +1,456 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/application"
+	"github.com/Gsupakin/back_end_test_challeng/internal/auth"
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	appjwt "github.com/Gsupakin/back_end_test_challeng/pkg/jwt"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/totp"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/utils"
+	"github.com/Gsupakin/back_end_test_challeng/tests/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	testClientID     = "test-client"
+	testClientSecret = "test-client-secret"
+	testRedirectURI  = "http://client.local/callback"
+	testEmail        = "oidc@example.com"
+	testPassword     = "Password123"
+)
+
+type provider struct {
+	server   *httptest.Server
+	user     domain.User
+	users    *mocks.UserRepository
+	sessions *mocks.SessionRepository
+}
+
+func setupProvider(t *testing.T) *provider {
+	t.Helper()
+	appjwt.SetSecret("oidc-test-secret")
+	gin.SetMode(gin.TestMode)
+
+	userRepo := mocks.NewUserRepository()
+	clientRepo := mocks.NewOAuthClientRepository()
+	codeRepo := mocks.NewAuthorizationCodeRepository()
+	sessionRepo := mocks.NewSessionRepository()
+
+	hashed, err := utils.HashPassword(testPassword)
+	require.NoError(t, err)
+	user := *domain.NewUser("OIDC User", testEmail, hashed)
+	user.ID, err = userRepo.Create(context.Background(), user)
+	require.NoError(t, err)
+
+	_, err = clientRepo.Create(context.Background(), domain.OAuthClient{
+		ClientID:     testClientID,
+		SecretHash:   utils.HashToken(testClientSecret),
+		Name:         "Test Client",
+		RedirectURIs: []string{testRedirectURI},
+		Scopes:       []string{"openid", "profile", "email"},
+	})
+	require.NoError(t, err)
+
+	keys, err := appjwt.GenerateKeySet()
+	require.NoError(t, err)
+
+	router := gin.New()
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	authn := auth.NewAuthenticator(mocks.NewAPITokenRepository(), sessionRepo).WithUsers(userRepo)
+	h := application.NewOIDCHandler(userRepo, clientRepo, codeRepo, authn, keys, server.URL)
+	router.GET("/.well-known/openid-configuration", h.Discovery)
+	router.GET("/.well-known/jwks.json", h.JWKS)
+	router.GET("/authorize", h.Authorize)
+	router.POST("/authorize", h.Approve)
+	router.POST("/token", h.Token)
+	router.GET("/userinfo", h.UserInfo)
+
+	return &provider{server: server, user: user, users: userRepo, sessions: sessionRepo}
+}
+
+// client จำลอง relying party ที่ทำ authorization code flow พร้อม PKCE
+type client struct {
+	t        *testing.T
+	http     *http.Client
+	config   map[string]interface{}
+	verifier string
+	state    string
+	nonce    string
+}
+
+func newClient(t *testing.T, issuer string) *client {
+	c := &client{
+		t: t,
+		http: &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}},
+		verifier: strings.Repeat("v", 20) + "-verifier-0123456789abcdefghij",
+		state:    "state-123",
+		nonce:    "nonce-456",
+	}
+
+	resp, err := c.http.Get(issuer + "/.well-known/openid-configuration")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&c.config))
+	return c
+}
+
+func (c *client) endpoint(name string) string {
+	return c.config[name].(string)
+}
+
+func (c *client) authorizeValues() url.Values {
+	sum := sha256.Sum256([]byte(c.verifier))
+	return url.Values{
+		"client_id":             {testClientID},
+		"redirect_uri":          {testRedirectURI},
+		"response_type":         {"code"},
+		"scope":                 {"openid profile email"},
+		"state":                 {c.state},
+		"nonce":                 {c.nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(sum[:])},
+		"code_challenge_method": {"S256"},
+	}
+}
+
+// login เปิดหน้า consent แล้วส่งฟอร์มอนุญาต คืนค่า authorization code จาก redirect
+func (c *client) login(email, password string) string {
+	params := c.authorizeValues()
+
+	resp, err := c.http.Get(c.endpoint("authorization_endpoint") + "?" + params.Encode())
+	require.NoError(c.t, err)
+	resp.Body.Close()
+	require.Equal(c.t, http.StatusOK, resp.StatusCode)
+	require.Contains(c.t, resp.Header.Get("Content-Type"), "text/html")
+
+	form := c.authorizeValues()
+	form.Set("email", email)
+	form.Set("password", password)
+	form.Set("decision", "approve")
+	resp, err = c.http.PostForm(c.endpoint("authorization_endpoint"), form)
+	require.NoError(c.t, err)
+	resp.Body.Close()
+	require.Equal(c.t, http.StatusFound, resp.StatusCode)
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(c.t, err)
+	require.Equal(c.t, c.state, location.Query().Get("state"))
+	return location.Query().Get("code")
+}
+
+func (c *client) exchange(code, verifier string) (int, map[string]interface{}) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {testRedirectURI},
+		"code_verifier": {verifier},
+	}
+	req, _ := http.NewRequest(http.MethodPost, c.endpoint("token_endpoint"), strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(testClientID, testClientSecret)
+
+	resp, err := c.http.Do(req)
+	require.NoError(c.t, err)
+	defer resp.Body.Close()
+
+	var body map[string]interface{}
+	require.NoError(c.t, json.NewDecoder(resp.Body).Decode(&body))
+	return resp.StatusCode, body
+}
+
+// verifyIDToken ตรวจลายเซ็น ID token ด้วย public key จาก JWKS ของ provider
+func (c *client) verifyIDToken(raw string) jwt.MapClaims {
+	resp, err := c.http.Get(c.endpoint("jwks_uri"))
+	require.NoError(c.t, err)
+	defer resp.Body.Close()
+
+	var jwks struct {
+		Keys []appjwt.JWK `json:"keys"`
+	}
+	require.NoError(c.t, json.NewDecoder(resp.Body).Decode(&jwks))
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		for _, key := range jwks.Keys {
+			if key.Kid == token.Header["kid"] {
+				n, _ := base64.RawURLEncoding.DecodeString(key.N)
+				e, _ := base64.RawURLEncoding.DecodeString(key.E)
+				return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+			}
+		}
+		return nil, jwt.ErrTokenUnverifiable
+	}, jwt.WithValidMethods([]string{"RS256"}), jwt.WithIssuer(c.config["issuer"].(string)), jwt.WithAudience(testClientID))
+	require.NoError(c.t, err)
+	return claims
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	p := setupProvider(t)
+	c := newClient(t, p.server.URL)
+
+	code := c.login(testEmail, testPassword)
+	require.NotEmpty(t, code)
+
+	status, tokens := c.exchange(code, c.verifier)
+	require.Equal(t, http.StatusOK, status, tokens)
+	assert.Equal(t, "Bearer", tokens["token_type"])
+
+	claims := c.verifyIDToken(tokens["id_token"].(string))
+	assert.Equal(t, p.user.ID.Hex(), claims["sub"])
+	assert.Equal(t, c.nonce, claims["nonce"])
+	assert.Equal(t, testEmail, claims["email"])
+	assert.Equal(t, "OIDC User", claims["name"])
+
+	req, _ := http.NewRequest(http.MethodGet, c.endpoint("userinfo_endpoint"), nil)
+	req.Header.Set("Authorization", "Bearer "+tokens["access_token"].(string))
+	resp, err := c.http.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var info map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&info))
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, p.user.ID.Hex(), info["sub"])
+	assert.Equal(t, testEmail, info["email"])
+
+	t.Run("Code Cannot Be Reused", func(t *testing.T) {
+		status, body := c.exchange(code, c.verifier)
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Equal(t, "invalid_grant", body["error"])
+	})
+
+	t.Run("Access Token Rejected By API Validation", func(t *testing.T) {
+		_, err := appjwt.ValidateToken(tokens["access_token"].(string))
+		assert.Error(t, err)
+	})
+}
+
+func TestAuthorizationCodeFlowErrors(t *testing.T) {
+	p := setupProvider(t)
+	c := newClient(t, p.server.URL)
+
+	t.Run("Wrong PKCE Verifier", func(t *testing.T) {
+		code := c.login(testEmail, testPassword)
+		status, body := c.exchange(code, strings.Repeat("x", 50))
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Equal(t, "invalid_grant", body["error"])
+	})
+
+	t.Run("Wrong Password Re-renders Consent", func(t *testing.T) {
+		form := c.authorizeValues()
+		form.Set("email", testEmail)
+		form.Set("password", "wrong-password")
+		form.Set("decision", "approve")
+		resp, err := c.http.PostForm(c.endpoint("authorization_endpoint"), form)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Deny Redirects With access_denied", func(t *testing.T) {
+		form := c.authorizeValues()
+		form.Set("decision", "deny")
+		resp, err := c.http.PostForm(c.endpoint("authorization_endpoint"), form)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusFound, resp.StatusCode)
+		location, _ := url.Parse(resp.Header.Get("Location"))
+		assert.Equal(t, "access_denied", location.Query().Get("error"))
+	})
+
+	t.Run("Missing PKCE", func(t *testing.T) {
+		params := c.authorizeValues()
+		params.Del("code_challenge")
+		resp, err := c.http.Get(c.endpoint("authorization_endpoint") + "?" + params.Encode())
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusFound, resp.StatusCode)
+		location, _ := url.Parse(resp.Header.Get("Location"))
+		assert.Equal(t, "invalid_request", location.Query().Get("error"))
+	})
+
+	t.Run("Unregistered Redirect URI", func(t *testing.T) {
+		params := c.authorizeValues()
+		params.Set("redirect_uri", "http://evil.local/callback")
+		resp, err := c.http.Get(c.endpoint("authorization_endpoint") + "?" + params.Encode())
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Unknown Code", func(t *testing.T) {
+		status, body := c.exchange("does-not-exist", c.verifier)
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Equal(t, "invalid_grant", body["error"])
+	})
+}
+
+func TestApproveWithBearerToken(t *testing.T) {
+	p := setupProvider(t)
+	c := newClient(t, p.server.URL)
+	ctx := context.Background()
+
+	approve := func(token string) *http.Response {
+		form := c.authorizeValues()
+		form.Set("decision", "approve")
+		req, _ := http.NewRequest(http.MethodPost, c.endpoint("authorization_endpoint"), strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := c.http.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+	login := func() string {
+		token, err := appjwt.GenerateJWT(p.user.ID.Hex(), p.sessions.StartSession(p.user.ID))
+		require.NoError(t, err)
+		return token
+	}
+
+	resp := approve(login())
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	location, _ := url.Parse(resp.Header.Get("Location"))
+	assert.NotEmpty(t, location.Query().Get("code"))
+
+	t.Run("Revoked Session", func(t *testing.T) {
+		sid := p.sessions.StartSession(p.user.ID)
+		token, err := appjwt.GenerateJWT(p.user.ID.Hex(), sid)
+		require.NoError(t, err)
+		id, _ := primitive.ObjectIDFromHex(sid)
+		require.NoError(t, p.sessions.Revoke(ctx, id))
+		assert.Equal(t, http.StatusUnauthorized, approve(token).StatusCode)
+	})
+
+	t.Run("Impersonation", func(t *testing.T) {
+		admin := primitive.NewObjectID()
+		now := time.Now()
+		sid, err := p.sessions.Create(ctx, domain.Session{UserID: p.user.ID, ImpersonatorID: &admin, CreatedAt: now, LastSeenAt: now, ExpiresAt: now.Add(time.Hour)})
+		require.NoError(t, err)
+		token, err := appjwt.GenerateJWT(p.user.ID.Hex(), sid.Hex())
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, approve(token).StatusCode)
+	})
+
+	t.Run("Banned User", func(t *testing.T) {
+		token := login()
+		transition, err := domain.FindTransition(domain.StatusActive, domain.TransitionBan)
+		require.NoError(t, err)
+		require.NoError(t, p.users.ChangeStatus(ctx, p.user.ID, domain.StatusChange{Transition: transition, Reason: "abuse", At: time.Now()}))
+		assert.Equal(t, http.StatusUnauthorized, approve(token).StatusCode)
+	})
+}
+
+var mfaTokenField = regexp.MustCompile(`name="mfa_token" value="([^"]+)"`)
+
+func TestApproveWithMFA(t *testing.T) {
+	p := setupProvider(t)
+	c := newClient(t, p.server.URL)
+	ctx := context.Background()
+	utils.BcryptCost = 4
+
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	hashed, err := utils.HashPassword(testPassword)
+	require.NoError(t, err)
+	user := *domain.NewUser("MFA User", "mfa.oidc@example.com", hashed)
+	user.MFA = domain.MFASettings{Enabled: true, Secret: secret}
+	user.ID, err = p.users.Create(ctx, user)
+	require.NoError(t, err)
+
+	// post ส่งฟอร์มหน้า consent และคืน status, mfa_token ในฟอร์มที่ตอบกลับ และ redirect
+	post := func(values map[string]string) (int, string, string) {
+		form := c.authorizeValues()
+		form.Set("decision", "approve")
+		for k, v := range values {
+			form.Set(k, v)
+		}
+		resp, err := c.http.PostForm(c.endpoint("authorization_endpoint"), form)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var body strings.Builder
+		_, err = io.Copy(&body, resp.Body)
+		require.NoError(t, err)
+		token := ""
+		if m := mfaTokenField.FindStringSubmatch(body.String()); m != nil {
+			token = m[1]
+		}
+		return resp.StatusCode, token, resp.Header.Get("Location")
+	}
+	password := func() string {
+		status, token, _ := post(map[string]string{"email": user.Email, "password": testPassword})
+		require.Equal(t, http.StatusOK, status)
+		require.NotEmpty(t, token, "password alone asks for the MFA code")
+		return token
+	}
+	valid := func() string {
+		code, err := totp.Code(secret, totp.Step(time.Now()))
+		require.NoError(t, err)
+		return code
+	}
+	wrong := func() string {
+		for _, code := range []string{"000000", "111111", "222222"} {
+			if _, ok := totp.Validate(secret, code, time.Now(), 0); !ok {
+				return code
+			}
+		}
+		t.Fatal("no invalid code")
+		return ""
+	}
+
+	t.Run("Password And OTP In One Post", func(t *testing.T) {
+		status, token, location := post(map[string]string{"email": user.Email, "password": testPassword, "otp": valid()})
+		assert.Equal(t, http.StatusOK, status)
+		assert.NotEmpty(t, token)
+		assert.Empty(t, location, "the otp is only checked against a challenge")
+	})
+
+	t.Run("Failures Revoke The Challenge", func(t *testing.T) {
+		token := password()
+		for i := 0; i < 5; i++ {
+			status, retry, _ := post(map[string]string{"mfa_token": token, "otp": wrong()})
+			require.Equal(t, http.StatusUnauthorized, status)
+			if i < 4 {
+				assert.Equal(t, token, retry, "the form keeps the challenge for another try")
+			}
+		}
+		status, retry, location := post(map[string]string{"mfa_token": token, "otp": valid()})
+		assert.Equal(t, http.StatusUnauthorized, status, "the 6th attempt is rejected even with a valid code")
+		assert.Empty(t, retry)
+		assert.Empty(t, location)
+	})
+
+	t.Run("Valid Code", func(t *testing.T) {
+		code := valid()
+		token := password()
+		status, _, location := post(map[string]string{"mfa_token": token, "otp": code})
+		require.Equal(t, http.StatusFound, status)
+		redirect, err := url.Parse(location)
+		require.NoError(t, err)
+		assert.NotEmpty(t, redirect.Query().Get("code"))
+
+		status, _, _ = post(map[string]string{"mfa_token": token, "otp": code})
+		assert.Equal(t, http.StatusUnauthorized, status, "the challenge is single use")
+	})
+}
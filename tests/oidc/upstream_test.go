@@ -0,0 +1,301 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/application"
+	"github.com/Gsupakin/back_end_test_challeng/internal/auth"
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/middleware"
+	appjwt "github.com/Gsupakin/back_end_test_challeng/pkg/jwt"
+	"github.com/Gsupakin/back_end_test_challeng/tests/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	idpClientID     = "service-client"
+	idpClientSecret = "service-secret"
+)
+
+type pendingAuth struct {
+	nonce       string
+	challenge   string
+	redirectURI string
+}
+
+// mockIdP คือ OIDC provider จำลองที่อนุมัติทุก request ทันทีด้วยผู้ใช้ที่กำหนดไว้
+type mockIdP struct {
+	server *httptest.Server
+	keys   *appjwt.KeySet
+
+	mu            sync.Mutex
+	subject       string
+	email         string
+	emailVerified bool
+	codes         map[string]pendingAuth
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	keys, err := appjwt.GenerateKeySet()
+	require.NoError(t, err)
+
+	idp := &mockIdP{keys: keys, codes: map[string]pendingAuth{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.discovery)
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(idp.keys.JWKS())
+	})
+	mux.HandleFunc("/authorize", idp.authorize)
+	mux.HandleFunc("/token", idp.token)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (idp *mockIdP) setUser(subject, email string, verified bool) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.subject, idp.email, idp.emailVerified = subject, email, verified
+}
+
+func (idp *mockIdP) discovery(w http.ResponseWriter, r *http.Request) {
+	issuer := idp.server.URL
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"issuer":                                issuer,
+		"authorization_endpoint":                issuer + "/authorize",
+		"token_endpoint":                        issuer + "/token",
+		"jwks_uri":                              issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (idp *mockIdP) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != idpClientID || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	code := "code-" + q.Get("state")[:12]
+	idp.mu.Lock()
+	idp.codes[code] = pendingAuth{nonce: q.Get("nonce"), challenge: q.Get("code_challenge"), redirectURI: q.Get("redirect_uri")}
+	idp.mu.Unlock()
+
+	redirect, _ := url.Parse(q.Get("redirect_uri"))
+	values := redirect.Query()
+	values.Set("code", code)
+	values.Set("state", q.Get("state"))
+	redirect.RawQuery = values.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (idp *mockIdP) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != idpClientID || clientSecret != idpClientSecret {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+
+	idp.mu.Lock()
+	pending, ok := idp.codes[r.PostForm.Get("code")]
+	delete(idp.codes, r.PostForm.Get("code"))
+	subject, email, verified := idp.subject, idp.email, idp.emailVerified
+	idp.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != pending.challenge || pending.redirectURI != r.PostForm.Get("redirect_uri") {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant"}`))
+		return
+	}
+
+	idToken, _ := idp.keys.SignIDToken(idp.server.URL, subject, idpClientID, pending.nonce, time.Now(), time.Hour, map[string]interface{}{
+		"email":          email,
+		"email_verified": verified,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "upstream-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+type service struct {
+	server      *httptest.Server
+	userRepo    *mocks.UserRepository
+	sessionRepo *mocks.SessionRepository
+	user        domain.User
+	http        *http.Client
+}
+
+func setupService(t *testing.T, idp *mockIdP) *service {
+	t.Helper()
+	appjwt.SetSecret("upstream-test-secret")
+	gin.SetMode(gin.TestMode)
+
+	userRepo := mocks.NewUserRepository()
+	sessionRepo := mocks.NewSessionRepository()
+	user := *domain.NewUser("Corp User", "corp.user@example.com", "not-a-bcrypt-hash")
+	id, err := userRepo.Create(context.Background(), user)
+	require.NoError(t, err)
+	user.ID = id
+
+	router := gin.New()
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	h := application.NewIdentityHandler(userRepo, application.NewSessionHandler(userRepo, sessionRepo, auth.CookieConfig{}), []application.UpstreamProvider{{
+		Name:         "corp",
+		Issuer:       idp.server.URL,
+		ClientID:     idpClientID,
+		ClientSecret: idpClientSecret,
+		RedirectURL:  server.URL + "/auth/corp/callback",
+	}})
+	router.GET("/auth/:provider/login", h.Login)
+	router.GET("/auth/:provider/callback", h.Callback)
+	authed := router.Group("/", middleware.JWTAuth(auth.NewAuthenticator(mocks.NewAPITokenRepository(), sessionRepo), auth.CookieConfig{}))
+	authed.GET("/me/identities", h.ListIdentities)
+	authed.POST("/me/identities/:provider", h.StartLink)
+	authed.DELETE("/me/identities/:provider/:subject", h.Unlink)
+
+	jar, _ := cookiejar.New(nil)
+	return &service{server: server, userRepo: userRepo, sessionRepo: sessionRepo, user: user, http: &http.Client{Jar: jar}}
+}
+
+func (s *service) do(t *testing.T, method, path, token string) (int, map[string]interface{}) {
+	req, _ := http.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := s.http.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var body map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&body)
+	return resp.StatusCode, body
+}
+
+func (s *service) login(t *testing.T) (int, map[string]interface{}) {
+	return s.do(t, http.MethodGet, s.server.URL+"/auth/corp/login", "")
+}
+
+func TestUpstreamLoginLinksByVerifiedEmail(t *testing.T) {
+	idp := newMockIdP(t)
+	svc := setupService(t, idp)
+
+	idp.setUser("corp-subject-1", svc.user.Email, true)
+	status, body := svc.login(t)
+	require.Equal(t, http.StatusOK, status, body)
+	token := body["token"].(string)
+
+	claims, err := appjwt.ValidateToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, svc.user.ID.Hex(), claims.UserID)
+
+	linked, err := svc.userRepo.FindByLinkedIdentity(context.Background(), idp.server.URL, "corp-subject-1")
+	require.NoError(t, err)
+	assert.Equal(t, svc.user.ID, linked.ID)
+
+	t.Run("Linked Identity Is Used Even Without Verified Email", func(t *testing.T) {
+		idp.setUser("corp-subject-1", "renamed@example.com", false)
+		status, body := svc.login(t)
+		require.Equal(t, http.StatusOK, status, body)
+		assert.NotEmpty(t, body["token"])
+	})
+
+	t.Run("List And Unlink", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, svc.server.URL+"/me/identities", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := svc.http.Do(req)
+		require.NoError(t, err)
+		var identities []domain.LinkedIdentity
+		json.NewDecoder(resp.Body).Decode(&identities)
+		resp.Body.Close()
+		require.Len(t, identities, 1)
+		assert.Equal(t, "corp", identities[0].Provider)
+
+		status, _ := svc.do(t, http.MethodDelete, svc.server.URL+"/me/identities/corp/corp-subject-1", token)
+		assert.Equal(t, http.StatusOK, status)
+
+		_, err = svc.userRepo.FindByLinkedIdentity(context.Background(), idp.server.URL, "corp-subject-1")
+		assert.Error(t, err)
+	})
+}
+
+func TestUpstreamLoginRejectsUnverifiedEmail(t *testing.T) {
+	idp := newMockIdP(t)
+	svc := setupService(t, idp)
+
+	idp.setUser("corp-subject-2", svc.user.Email, false)
+	status, body := svc.login(t)
+	assert.Equal(t, http.StatusForbidden, status, body)
+}
+
+func TestUpstreamExplicitLink(t *testing.T) {
+	idp := newMockIdP(t)
+	svc := setupService(t, idp)
+
+	token, err := appjwt.GenerateJWT(svc.user.ID.Hex(), svc.sessionRepo.StartSession(svc.user.ID))
+	require.NoError(t, err)
+
+	status, body := svc.do(t, http.MethodPost, svc.server.URL+"/me/identities/corp", token)
+	require.Equal(t, http.StatusOK, status, body)
+
+	// ผู้ใช้ล็อกอินที่ IdP ด้วยอีเมลอื่นที่ยังไม่ยืนยัน แต่ผูกได้เพราะเริ่มจากบัญชีที่ล็อกอินอยู่
+	idp.setUser("corp-subject-3", "other@example.com", false)
+	status, body = svc.do(t, http.MethodGet, body["authorization_url"].(string), "")
+	require.Equal(t, http.StatusOK, status, body)
+	assert.Equal(t, "Identity linked successfully", body["message"])
+
+	status, body = svc.login(t)
+	require.Equal(t, http.StatusOK, status, body)
+	claims, err := appjwt.ValidateToken(body["token"].(string))
+	require.NoError(t, err)
+	assert.Equal(t, svc.user.ID.Hex(), claims.UserID)
+
+	t.Run("Identity Cannot Move To Another Or Missing User", func(t *testing.T) {
+		user, err := svc.userRepo.FindByID(context.Background(), svc.user.ID)
+		require.NoError(t, err)
+		linked := user.LinkedIdentities
+		require.NotEmpty(t, linked)
+
+		other := domain.NewUser("Other", "other.user@example.com", "$2a$04$hash")
+		otherID, err := svc.userRepo.Create(context.Background(), *other)
+		require.NoError(t, err)
+		assert.ErrorIs(t, svc.userRepo.AddLinkedIdentity(context.Background(), otherID, linked[0]), domain.ErrIdentityLinked)
+
+		fresh := domain.LinkedIdentity{Provider: "corp", Issuer: linked[0].Issuer, Subject: "corp-subject-9"}
+		assert.ErrorIs(t, svc.userRepo.AddLinkedIdentity(context.Background(), primitive.NewObjectID(), fresh), domain.ErrUserNotFound)
+	})
+
+	t.Run("Callback Without Login Cookie", func(t *testing.T) {
+		fresh := &http.Client{}
+		resp, err := fresh.Get(svc.server.URL + "/auth/corp/callback?code=x&state=y")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
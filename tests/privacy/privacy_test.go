@@ -0,0 +1,360 @@
+package privacy_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/application"
+	"github.com/Gsupakin/back_end_test_challeng/internal/auth"
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/internal/privacy"
+	"github.com/Gsupakin/back_end_test_challeng/internal/requestlog"
+	"github.com/Gsupakin/back_end_test_challeng/middleware"
+	appjwt "github.com/Gsupakin/back_end_test_challeng/pkg/jwt"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/redact"
+	"github.com/Gsupakin/back_end_test_challeng/tests/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type service struct {
+	router   *gin.Engine
+	users    *mocks.UserRepository
+	logs     *mocks.LogRepository
+	sessions *mocks.SessionRepository
+	tokens   *mocks.APITokenRepository
+	audit    *mocks.AuditRepository
+	eraser   *mocks.PersonalDataEraser
+}
+
+func setup(t *testing.T) *service {
+	t.Helper()
+	return setupWithRedactor(t, redact.New(redact.DefaultPolicy(), nil, 24*time.Hour))
+}
+
+// setupWithRedactor ให้ handler และ eraser ใช้ redactor เดียวกับที่ Writer ใช้บันทึก log
+func setupWithRedactor(t *testing.T, redactor *redact.Redactor) *service {
+	t.Helper()
+	appjwt.SetSecret("privacy-test-secret")
+	gin.SetMode(gin.TestMode)
+
+	s := &service{
+		users:    mocks.NewUserRepository(),
+		logs:     mocks.NewLogRepository(),
+		sessions: mocks.NewSessionRepository(),
+		tokens:   mocks.NewAPITokenRepository(),
+		audit:    mocks.NewAuditRepository(),
+	}
+	s.eraser = &mocks.PersonalDataEraser{Users: s.users, Logs: s.logs, Sessions: s.sessions, Tokens: s.tokens, Audit: s.audit, Redactor: redactor}
+	h := application.NewPrivacyHandler(s.users, s.logs, mocks.NewErasureRepository(), s.eraser).WithRedactor(redactor)
+
+	s.router = gin.New()
+	authed := s.router.Group("/", middleware.JWTAuth(auth.NewAuthenticator(s.tokens, s.sessions), auth.CookieConfig{}))
+	authed.GET("/me/data-export", h.DataExport)
+	authed.POST("/me/erasure", h.RequestErasure)
+	admin := authed.Group("/admin", middleware.RequireAdmin(s.users))
+	admin.POST("/users/:id/erasure", h.RequestUserErasure)
+	admin.GET("/erasures", h.ListErasures)
+	admin.GET("/erasures/:id", h.GetErasure)
+	admin.POST("/erasures/:id/approve", h.ApproveErasure)
+	admin.POST("/erasures/:id/reject", h.RejectErasure)
+	return s
+}
+
+// user สร้างผู้ใช้แล้วคืน ID กับ JWT ของ session ใหม่
+func (s *service) user(t *testing.T, name, role string) (primitive.ObjectID, string) {
+	t.Helper()
+	user := domain.NewUser(name, strings.ToLower(name)+"@example.com", "$2a$04$hash")
+	user.Role = role
+	id, err := s.users.Create(context.Background(), *user)
+	require.NoError(t, err)
+	token, err := appjwt.GenerateJWT(id.Hex(), s.sessions.StartSession(id))
+	require.NoError(t, err)
+	return id, token
+}
+
+func (s *service) do(method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func decode(t *testing.T, rec *httptest.ResponseRecorder) domain.ErasureRequest {
+	t.Helper()
+	var request domain.ErasureRequest
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &request), rec.Body.String())
+	return request
+}
+
+func readZip(t *testing.T, data []byte) map[string][]byte {
+	t.Helper()
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	files := map[string][]byte{}
+	for _, f := range archive.File {
+		r, err := f.Open()
+		require.NoError(t, err)
+		files[f.Name], err = io.ReadAll(r)
+		require.NoError(t, err)
+		r.Close()
+	}
+	return files
+}
+
+func TestDataExport(t *testing.T) {
+	s := setup(t)
+	aliceID, token := s.user(t, "Alice", domain.RoleUser)
+	bobID, _ := s.user(t, "Bob", domain.RoleUser)
+	now := time.Now()
+	require.NoError(t, s.logs.CreateMany(context.Background(), []domain.RequestLog{
+		{Path: "/users", UserID: aliceID.Hex(), Timestamp: now.Add(-2 * time.Minute)},
+		{Path: "/users", UserID: bobID.Hex(), Timestamp: now.Add(-time.Minute)},
+		{Path: "/users/" + bobID.Hex(), UserID: bobID.Hex(), ImpersonatorID: aliceID.Hex(), Timestamp: now},
+	}))
+
+	rec := s.do(http.MethodGet, "/me/data-export", token, "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "application/zip", rec.Header().Get("Content-Type"))
+	files := readZip(t, rec.Body.Bytes())
+	require.Len(t, files, 2)
+
+	var profile privacy.Profile
+	require.NoError(t, json.Unmarshal(files[privacy.ProfileFile], &profile))
+	assert.Equal(t, "alice@example.com", profile.Email)
+	assert.NotContains(t, string(files[privacy.ProfileFile]), "$2a$04$hash")
+	assert.NotContains(t, string(files[privacy.ProfileFile]), "password")
+
+	var logs []domain.RequestLog
+	require.NoError(t, json.Unmarshal(files[privacy.RequestLogsFile], &logs))
+	require.Len(t, logs, 2, "own requests and requests made while impersonating")
+	assert.Equal(t, aliceID.Hex(), logs[0].UserID)
+	assert.Equal(t, aliceID.Hex(), logs[1].ImpersonatorID)
+
+	// ผู้ใช้ที่ไม่มี log ก็ได้ JSON array ว่าง
+	_, token = s.user(t, "Carol", domain.RoleUser)
+	files = readZip(t, s.do(http.MethodGet, "/me/data-export", token, "").Body.Bytes())
+	require.NoError(t, json.Unmarshal(files[privacy.RequestLogsFile], &logs))
+	assert.Empty(t, logs)
+}
+
+func TestErasureWorkflow(t *testing.T) {
+	s := setup(t)
+	ctx := context.Background()
+	aliceID, aliceToken := s.user(t, "Alice", domain.RoleUser)
+	adminID, adminToken := s.user(t, "Root", domain.RoleAdmin)
+	_, otherAdminToken := s.user(t, "Ops", domain.RoleAdmin)
+
+	require.NoError(t, s.logs.Create(ctx, domain.RequestLog{Path: "/users/" + aliceID.Hex(), UserID: aliceID.Hex(), IP: "203.0.113.7", UserAgent: "curl", Timestamp: time.Now()}))
+	require.NoError(t, s.audit.Create(ctx, domain.AuditEvent{Action: domain.AuditUserUpdate, TargetUserID: aliceID.Hex(), ActorID: adminID.Hex(),
+		Changes: []domain.FieldChange{{Field: "email", Old: "alice@example.com", New: "alice@new.example.com"}}}))
+	_, err := s.tokens.Create(ctx, domain.APIToken{Kind: domain.TokenKindPersonal, UserID: aliceID, CreatedBy: aliceID})
+	require.NoError(t, err)
+
+	rec := s.do(http.MethodPost, "/me/erasure", aliceToken, "")
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	request := decode(t, rec)
+	assert.Equal(t, domain.ErasurePending, request.Status)
+	assert.Equal(t, http.StatusConflict, s.do(http.MethodPost, "/me/erasure", aliceToken, `{"reason":"again"}`).Code)
+
+	// ผู้ใช้ทั่วไปอนุมัติเองไม่ได้
+	assert.Equal(t, http.StatusForbidden, s.do(http.MethodPost, "/admin/erasures/"+request.ID.Hex()+"/approve", aliceToken, "").Code)
+
+	rec = s.do(http.MethodPost, "/admin/erasures/"+request.ID.Hex()+"/approve", adminToken, "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	request = decode(t, rec)
+	assert.Equal(t, domain.ErasureCompleted, request.Status)
+	require.NotNil(t, request.Receipt)
+	assert.Equal(t, map[string]int64{"users": 1, "sessions": 1, "api_tokens": 1, "request_logs": 1, "audit_log": 1}, request.Receipt.Collections)
+
+	// ผู้ใช้ถูกลบจริงและ token เดิมใช้ไม่ได้แล้ว
+	_, err = s.users.FindByEmail(ctx, "alice@example.com")
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, s.do(http.MethodGet, "/me/data-export", aliceToken, "").Code)
+
+	// log และ audit event ยังอยู่แต่ไม่มีอะไรโยงกลับไปหาผู้ใช้
+	entry := s.logs.Logs()[0]
+	assert.True(t, strings.HasPrefix(entry.UserID, "erased:"))
+	assert.Equal(t, "/users/"+entry.UserID, entry.Path)
+	assert.Empty(t, entry.IP)
+	assert.Empty(t, entry.UserAgent)
+	event := s.audit.Events()[0]
+	assert.Equal(t, entry.UserID, event.TargetUserID)
+	assert.Equal(t, adminID.Hex(), event.ActorID)
+	assert.Nil(t, event.Changes[0].Old)
+	assert.Nil(t, event.Changes[0].New)
+
+	// ใบยืนยันยังดูได้ภายหลัง และอนุมัติซ้ำไม่ได้
+	rec = s.do(http.MethodGet, "/admin/erasures/"+request.ID.Hex(), otherAdminToken, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotNil(t, decode(t, rec).Receipt)
+	assert.Equal(t, http.StatusConflict, s.do(http.MethodPost, "/admin/erasures/"+request.ID.Hex()+"/approve", otherAdminToken, "").Code)
+}
+
+func TestHashedUserIDLogs(t *testing.T) {
+	policy := redact.DefaultPolicy()
+	policy.UserID = redact.ModeHash
+	redactor := redact.New(policy, []byte("log-secret"), 24*time.Hour)
+	s := setupWithRedactor(t, redactor)
+	ctx := context.Background()
+	aliceID, aliceToken := s.user(t, "Alice", domain.RoleUser)
+	bobID, _ := s.user(t, "Bob", domain.RoleUser)
+	_, adminToken := s.user(t, "Root", domain.RoleAdmin)
+
+	cfg := requestlog.DefaultConfig()
+	cfg.Redactor = redactor
+	writer := requestlog.NewWriter(s.logs, cfg)
+	now := time.Now()
+	// log ที่บันทึกเมื่อสองวันก่อนถูกแฮชด้วย salt ของช่วงเวลาก่อนหน้า
+	redactor.SetClock(func() time.Time { return now.Add(-48 * time.Hour) })
+	require.True(t, writer.Write(domain.RequestLog{Path: "/old", UserID: aliceID.Hex(), Timestamp: now.Add(-48 * time.Hour)}))
+	redactor.SetClock(func() time.Time { return now })
+	require.True(t, writer.Write(domain.RequestLog{Path: "/users", UserID: aliceID.Hex(), IP: "203.0.113.7", Timestamp: now.Add(-time.Minute)}))
+	require.True(t, writer.Write(domain.RequestLog{Path: "/users", UserID: bobID.Hex(), ImpersonatorID: aliceID.Hex(), Timestamp: now}))
+	require.True(t, writer.Write(domain.RequestLog{Path: "/users", UserID: bobID.Hex(), Timestamp: now}))
+	require.NoError(t, writer.Close(ctx))
+	for _, entry := range s.logs.Logs() {
+		assert.NotEqual(t, aliceID.Hex(), entry.UserID, "writer stores the hash, not the ID")
+	}
+
+	files := readZip(t, s.do(http.MethodGet, "/me/data-export", aliceToken, "").Body.Bytes())
+	var logs []domain.RequestLog
+	require.NoError(t, json.Unmarshal(files[privacy.RequestLogsFile], &logs))
+	require.Len(t, logs, 2, "logs hashed with the current salt are exported")
+	assert.Equal(t, "/users", logs[0].Path)
+	assert.Equal(t, redactor.UserID(aliceID.Hex()), logs[1].ImpersonatorID)
+
+	rec := s.do(http.MethodPost, "/me/erasure", aliceToken, "")
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	rec = s.do(http.MethodPost, "/admin/erasures/"+decode(t, rec).ID.Hex()+"/approve", adminToken, "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	receipt := decode(t, rec).Receipt
+	require.NotNil(t, receipt)
+	assert.Equal(t, int64(2), receipt.Collections["request_logs"])
+	assert.Equal(t, []string{domain.ErasureHashedLogsLimitation}, receipt.Limitations)
+
+	erased := 0
+	for _, entry := range s.logs.Logs() {
+		switch {
+		case entry.Path == "/old":
+			assert.False(t, strings.HasPrefix(entry.UserID, "erased:"), "rows hashed with an older salt cannot be found")
+		case strings.HasPrefix(entry.UserID, "erased:"), strings.HasPrefix(entry.ImpersonatorID, "erased:"):
+			erased++
+		}
+	}
+	assert.Equal(t, 2, erased)
+}
+
+func TestAdminErasureNeedsSecondAdmin(t *testing.T) {
+	s := setup(t)
+	ctx := context.Background()
+	bobID, _ := s.user(t, "Bob", domain.RoleUser)
+	_, adminToken := s.user(t, "Root", domain.RoleAdmin)
+	_, otherAdminToken := s.user(t, "Ops", domain.RoleAdmin)
+	require.NoError(t, s.users.Delete(ctx, bobID))
+
+	assert.Equal(t, http.StatusBadRequest, s.do(http.MethodPost, "/admin/users/"+bobID.Hex()+"/erasure", adminToken, `{}`).Code)
+	assert.Equal(t, http.StatusNotFound, s.do(http.MethodPost, "/admin/users/"+primitive.NewObjectID().Hex()+"/erasure", adminToken, `{"reason":"ticket 42"}`).Code)
+
+	// ผู้ใช้ที่ถูก soft delete แล้วก็ขอลบถาวรได้
+	rec := s.do(http.MethodPost, "/admin/users/"+bobID.Hex()+"/erasure", adminToken, `{"reason":"ticket 42"}`)
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	request := decode(t, rec)
+	assert.Equal(t, "ticket 42", request.Reason)
+
+	approve := "/admin/erasures/" + request.ID.Hex() + "/approve"
+	assert.Equal(t, http.StatusForbidden, s.do(http.MethodPost, approve, adminToken, "").Code)
+
+	// ถ้าลบไม่สำเร็จคำขอยังรออยู่และอนุมัติซ้ำได้
+	s.eraser.Err = errors.New("database unavailable")
+	assert.Equal(t, http.StatusInternalServerError, s.do(http.MethodPost, approve, otherAdminToken, "").Code)
+	rec = s.do(http.MethodGet, "/admin/erasures?status=pending", otherAdminToken, "")
+	var pending []domain.ErasureRequest
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &pending))
+	require.Len(t, pending, 1)
+
+	s.eraser.Err = nil
+	require.Equal(t, http.StatusOK, s.do(http.MethodPost, approve, otherAdminToken, "").Code)
+	users, _ := s.users.Search(ctx, domain.UserFilter{ID: bobID, IncludeDeleted: true})
+	assert.Empty(t, users)
+}
+
+func TestRejectErasure(t *testing.T) {
+	s := setup(t)
+	_, aliceToken := s.user(t, "Alice", domain.RoleUser)
+	_, adminToken := s.user(t, "Root", domain.RoleAdmin)
+
+	request := decode(t, s.do(http.MethodPost, "/me/erasure", aliceToken, `{"reason":"leaving"}`))
+	reject := "/admin/erasures/" + request.ID.Hex() + "/reject"
+	assert.Equal(t, http.StatusBadRequest, s.do(http.MethodPost, reject, adminToken, `{"reason":" "}`).Code)
+	require.Equal(t, http.StatusOK, s.do(http.MethodPost, reject, adminToken, `{"reason":"open invoice"}`).Code)
+	assert.Equal(t, http.StatusConflict, s.do(http.MethodPost, reject, adminToken, `{"reason":"again"}`).Code)
+	assert.Equal(t, http.StatusNotFound, s.do(http.MethodPost, "/admin/erasures/"+primitive.NewObjectID().Hex()+"/reject", adminToken, `{"reason":"x"}`).Code)
+
+	rec := s.do(http.MethodGet, "/admin/erasures/"+request.ID.Hex(), adminToken, "")
+	request = decode(t, rec)
+	assert.Equal(t, domain.ErasureRejected, request.Status)
+	assert.Equal(t, "open invoice", request.Note)
+	assert.Equal(t, http.StatusBadRequest, s.do(http.MethodGet, "/admin/erasures?status=done", adminToken, "").Code)
+
+	// ยื่นคำขอใหม่ได้หลังถูกปฏิเสธ
+	assert.Equal(t, http.StatusAccepted, s.do(http.MethodPost, "/me/erasure", aliceToken, "").Code)
+}
+
+func TestPurgeDeletedUsers(t *testing.T) {
+	s := setup(t)
+	ctx := context.Background()
+	erasures := mocks.NewErasureRepository()
+	purger := privacy.NewPurger(s.users, erasures, s.eraser, 30*24*time.Hour)
+
+	gone, _ := s.user(t, "Gone", domain.RoleUser)
+	kept, _ := s.user(t, "Kept", domain.RoleUser)
+	require.NoError(t, s.users.Delete(ctx, gone))
+
+	// ยังไม่ครบระยะเวลาเก็บ
+	results, err := purger.Run(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, results)
+
+	s.eraser.Err = errors.New("mongo down")
+	_, err = purger.Run(ctx, time.Now().Add(31*24*time.Hour))
+	assert.Error(t, err)
+	users, err := s.users.Search(ctx, domain.UserFilter{ID: gone, IncludeDeleted: true})
+	require.NoError(t, err)
+	assert.Len(t, users, 1, "user stays until the purge succeeds")
+
+	s.eraser.Err = nil
+	results, err = purger.Run(ctx, time.Now().Add(31*24*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, gone, results[0].UserID)
+	assert.Equal(t, int64(1), results[0].Receipt.Collections["users"])
+
+	users, err = s.users.Search(ctx, domain.UserFilter{IncludeDeleted: true})
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, kept, users[0].ID)
+
+	requests, err := erasures.Find(ctx, domain.ErasureCompleted)
+	require.NoError(t, err)
+	require.Len(t, requests, 1)
+	assert.Equal(t, gone, requests[0].UserID)
+	assert.True(t, requests[0].RequestedBy.IsZero())
+	assert.Equal(t, privacy.PurgeReason, requests[0].Reason)
+	require.NotNil(t, requests[0].Receipt)
+}
@@ -0,0 +1,116 @@
+package requestlog_test
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/internal/requestlog"
+	"github.com/Gsupakin/back_end_test_challeng/tests/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readArchive(t *testing.T, path string) []domain.RequestLog {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+
+	var logs []domain.RequestLog
+	dec := json.NewDecoder(gz)
+	for dec.More() {
+		var l domain.RequestLog
+		require.NoError(t, dec.Decode(&l))
+		logs = append(logs, l)
+	}
+	return logs
+}
+
+func TestArchiveAndRestore(t *testing.T) {
+	repo := mocks.NewLogRepository()
+	dir := t.TempDir()
+	now := time.Date(2024, 3, 10, 15, 0, 0, 0, time.UTC)
+
+	var logs []domain.RequestLog
+	// 3 log ต่อวันตั้งแต่ 1 ถึง 9 มีนาคม
+	for d := 1; d <= 9; d++ {
+		for h := 0; h < 3; h++ {
+			logs = append(logs, domain.RequestLog{
+				Method:    "GET",
+				Path:      "/users",
+				Status:    200,
+				Timestamp: time.Date(2024, 3, d, h*8, 30, 0, 0, time.UTC),
+			})
+		}
+	}
+	require.NoError(t, repo.CreateMany(context.Background(), logs))
+
+	// เก่ากว่า 7 วัน: ทุกอย่างก่อนวันที่ 3 มีนาคม
+	archiver := requestlog.NewArchiver(repo, dir, 7*24*time.Hour)
+	results, err := archiver.Run(context.Background(), now)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, filepath.Join(dir, "request_logs-2024-03-01.ndjson.gz"), results[0].File)
+	assert.Equal(t, filepath.Join(dir, "request_logs-2024-03-02.ndjson.gz"), results[1].File)
+	assert.Equal(t, int64(3), results[0].Count)
+
+	remaining := repo.Logs()
+	assert.Len(t, remaining, 21)
+	for _, l := range remaining {
+		assert.False(t, l.Timestamp.Before(time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)))
+	}
+
+	archived := readArchive(t, results[0].File)
+	require.Len(t, archived, 3)
+	assert.Equal(t, logs[0].Timestamp, archived[0].Timestamp.UTC())
+	assert.False(t, archived[0].ID.IsZero())
+
+	// ไม่มีไฟล์ชั่วคราวค้าง
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	// รันซ้ำในวันเดียวกันไม่มีอะไรให้ archive
+	results, err = archiver.Run(context.Background(), now)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+
+	// restore แล้ว restore ซ้ำ: ครั้งที่สองต้องไม่เพิ่มซ้ำ
+	read, inserted, err := requestlog.RestoreFile(context.Background(), repo, filepath.Join(dir, "request_logs-2024-03-01.ndjson.gz"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), read)
+	assert.Equal(t, int64(3), inserted)
+	read, inserted, err = requestlog.RestoreFile(context.Background(), repo, filepath.Join(dir, "request_logs-2024-03-01.ndjson.gz"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), read)
+	assert.Zero(t, inserted)
+	assert.Len(t, repo.Logs(), 24)
+
+	// archive วันที่ restore มาอีกครั้งต้องไม่ทับไฟล์เดิม
+	results, err = archiver.Run(context.Background(), now)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, filepath.Join(dir, "request_logs-2024-03-01.1.ndjson.gz"), results[0].File)
+}
+
+func TestArchiveKeepsLogsWhenWriteFails(t *testing.T) {
+	repo := mocks.NewLogRepository()
+	require.NoError(t, repo.CreateMany(context.Background(), []domain.RequestLog{
+		{Method: "GET", Path: "/", Timestamp: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}))
+
+	// dir เป็นไฟล์ จึงสร้าง directory ไม่ได้
+	dir := filepath.Join(t.TempDir(), "not-a-dir")
+	require.NoError(t, os.WriteFile(dir, nil, 0o600))
+
+	_, err := requestlog.NewArchiver(repo, dir, 24*time.Hour).Run(context.Background(), time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))
+	assert.Error(t, err)
+	assert.Len(t, repo.Logs(), 1)
+}
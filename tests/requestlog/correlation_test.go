@@ -0,0 +1,196 @@
+package requestlog_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/auth"
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	grpcserver "github.com/Gsupakin/back_end_test_challeng/internal/grpc"
+	"github.com/Gsupakin/back_end_test_challeng/internal/requestlog"
+	"github.com/Gsupakin/back_end_test_challeng/middleware"
+	appjwt "github.com/Gsupakin/back_end_test_challeng/pkg/jwt"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/logger"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/requestid"
+	pb "github.com/Gsupakin/back_end_test_challeng/proto"
+	"github.com/Gsupakin/back_end_test_challeng/tests/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newHTTPRouter(w *requestlog.Writer) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.RequestID())
+	router.Use(middleware.RequestLogger(w))
+	router.GET("/users/:id", func(c *gin.Context) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	})
+	router.POST("/echo", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	return router
+}
+
+func TestHTTPRequestLogFields(t *testing.T) {
+	repo := mocks.NewLogRepository()
+	w := requestlog.NewWriter(repo, requestlog.DefaultConfig())
+	router := newHTTPRouter(w)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/abc?page=2&code=secret-code", nil)
+	req.Header.Set(requestid.Header, "client-id-123")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, "client-id-123", rec.Header().Get(requestid.Header))
+
+	req = httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(`{"hello":"world"}`))
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	require.NoError(t, w.Close(context.Background()))
+	logs := repo.Logs()
+	require.Len(t, logs, 2)
+
+	notFound := logs[0]
+	assert.Equal(t, "client-id-123", notFound.RequestID)
+	assert.Equal(t, domain.ProtocolHTTP, notFound.Protocol)
+	assert.Equal(t, "/users/abc", notFound.Path)
+	assert.Equal(t, "/users/:id", notFound.Route)
+	assert.Equal(t, http.StatusNotFound, notFound.Status)
+	assert.Equal(t, "User not found", notFound.Error)
+	assert.Positive(t, notFound.ResponseSize)
+	query, err := url.ParseQuery(notFound.Query)
+	require.NoError(t, err)
+	assert.Equal(t, "2", query.Get("page"))
+	assert.NotContains(t, notFound.Query, "secret-code")
+
+	echo := logs[1]
+	assert.Equal(t, int64(len(`{"hello":"world"}`)), echo.RequestSize)
+	assert.Equal(t, int64(2), echo.ResponseSize)
+	assert.Empty(t, echo.Error)
+	assert.Len(t, echo.RequestID, 32, "missing ID must be generated")
+}
+
+func TestHTTPRequestIDRejectsUnsafeValues(t *testing.T) {
+	w := requestlog.NewWriter(mocks.NewLogRepository(), requestlog.DefaultConfig())
+	defer w.Close(context.Background())
+	router := newHTTPRouter(w)
+
+	for _, incoming := range []string{"has space", strings.Repeat("a", 200)} {
+		req := httptest.NewRequest(http.MethodPost, "/echo", nil)
+		req.Header.Set(requestid.Header, incoming)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		got := rec.Header().Get(requestid.Header)
+		assert.NotEqual(t, incoming, got)
+		assert.True(t, requestid.Valid(got))
+	}
+}
+
+func TestGRPCRequestLogAndPropagation(t *testing.T) {
+	appjwt.SetSecret("requestlog-test-secret")
+
+	repo := mocks.NewLogRepository()
+	w := requestlog.NewWriter(repo, requestlog.DefaultConfig())
+
+	tokenRepo := mocks.NewAPITokenRepository()
+	authn := auth.NewAuthenticator(tokenRepo, mocks.NewSessionRepository())
+
+	userID := primitive.NewObjectID()
+	raw, prefix, hash, err := auth.NewAPIToken(domain.TokenKindPersonal)
+	require.NoError(t, err)
+	_, err = tokenRepo.Create(context.Background(), domain.APIToken{
+		Kind:      domain.TokenKindPersonal,
+		Prefix:    prefix,
+		Hash:      hash,
+		UserID:    userID,
+		Scopes:    []string{domain.ScopeUsersRead},
+		CreatedAt: time.Now(),
+	})
+	require.NoError(t, err)
+
+	var console bytes.Buffer
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		grpcserver.RequestIDInterceptor,
+		grpcserver.LoggingInterceptor(w, logger.New(&console, logger.DefaultConfig())),
+		grpcserver.AuditInterceptor,
+		grpcserver.AuthInterceptor(authn),
+	))
+	pb.RegisterUserServiceServer(server, grpcserver.NewUserServer(mocks.NewUserRepository()))
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(requestid.UnaryClientInterceptor()),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+	client := pb.NewUserServiceClient(conn)
+
+	// ไม่มี token: request ID จาก context ฝั่ง client ต้องถูกส่งต่อและสะท้อนกลับมา
+	var header metadata.MD
+	ctx := requestid.WithID(context.Background(), "rpc-unauth")
+	_, err = client.GetUser(ctx, &pb.GetUserRequest{Id: userID.Hex()}, grpc.Header(&header))
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.Equal(t, []string{"rpc-unauth"}, header.Get(requestid.MetadataKey))
+
+	ctx = metadata.AppendToOutgoingContext(requestid.WithID(context.Background(), "rpc-authed"), "authorization", "Bearer "+raw)
+	_, err = client.GetUser(ctx, &pb.GetUserRequest{Id: "not-an-id"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	server.GracefulStop()
+	require.NoError(t, w.Close(context.Background()))
+	logs := repo.Logs()
+	require.Len(t, logs, 2)
+
+	unauth := logs[0]
+	assert.Equal(t, "rpc-unauth", unauth.RequestID)
+	assert.Equal(t, domain.ProtocolGRPC, unauth.Protocol)
+	assert.Equal(t, "/user.UserService/GetUser", unauth.GRPCMethod)
+	assert.Equal(t, codes.Unauthenticated.String(), unauth.GRPCCode)
+	assert.Equal(t, http.StatusUnauthorized, unauth.Status)
+	assert.Empty(t, unauth.UserID)
+	assert.Positive(t, unauth.RequestSize)
+
+	authed := logs[1]
+	assert.Equal(t, "rpc-authed", authed.RequestID)
+	assert.Equal(t, codes.InvalidArgument.String(), authed.GRPCCode)
+	assert.Equal(t, http.StatusBadRequest, authed.Status)
+	assert.Equal(t, "invalid user ID", authed.Error)
+	assert.Equal(t, userID.Hex(), authed.UserID)
+
+	// console log ของแต่ละ RPC มี request_id และ user_id ของผู้เรียก
+	var records []map[string]any
+	dec := json.NewDecoder(&console)
+	for dec.More() {
+		var r map[string]any
+		require.NoError(t, dec.Decode(&r))
+		records = append(records, r)
+	}
+	require.Len(t, records, 2)
+	assert.Equal(t, "grpc request", records[0]["msg"])
+	assert.Equal(t, "WARN", records[0]["level"])
+	assert.Equal(t, "rpc-unauth", records[0]["request_id"])
+	assert.NotContains(t, records[0], "user_id")
+	assert.Equal(t, "rpc-authed", records[1]["request_id"])
+	assert.Equal(t, userID.Hex(), records[1]["user_id"])
+	assert.Equal(t, "InvalidArgument", records[1]["code"])
+}
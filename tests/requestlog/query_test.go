@@ -0,0 +1,183 @@
+package requestlog_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/application"
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/tests/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func seedLogs(t *testing.T) (*gin.Engine, time.Time) {
+	gin.SetMode(gin.TestMode)
+	repo := mocks.NewLogRepository()
+	base := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	var logs []domain.RequestLog
+	// GET /users/:id: 100 request ที่ latency 1..100ms ในชั่วโมงแรก มี 5xx 2 ครั้งและ 404 3 ครั้ง
+	for i := 1; i <= 100; i++ {
+		status := http.StatusOK
+		if i <= 2 {
+			status = http.StatusInternalServerError
+		} else if i <= 5 {
+			status = http.StatusNotFound
+		}
+		logs = append(logs, domain.RequestLog{
+			Method:    http.MethodGet,
+			Path:      fmt.Sprintf("/users/%d", i),
+			Route:     "/users/:id",
+			Status:    status,
+			LatencyMS: int64(i),
+			IP:        "10.0.0.1",
+			UserID:    "user-a",
+			Timestamp: base.Add(time.Duration(i) * time.Second),
+		})
+	}
+	// POST /login ในชั่วโมงถัดไป จาก IP อื่น
+	for i := 0; i < 4; i++ {
+		logs = append(logs, domain.RequestLog{
+			Method:    http.MethodPost,
+			Path:      "/login",
+			Route:     "/login",
+			Status:    http.StatusUnauthorized,
+			LatencyMS: 200,
+			IP:        "10.0.0.2",
+			RequestID: fmt.Sprintf("req-%d", i),
+			Timestamp: base.Add(time.Hour + time.Duration(i)*time.Minute),
+		})
+	}
+	require.NoError(t, repo.CreateMany(context.Background(), logs))
+
+	handler := application.NewLogHandler(repo)
+	router := gin.New()
+	router.GET("/admin/logs", handler.List)
+	router.GET("/admin/logs/stats", handler.Stats)
+	return router, base
+}
+
+func get(t *testing.T, router *gin.Engine, url string, out interface{}) int {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+	if out != nil && w.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), out))
+	}
+	return w.Code
+}
+
+func TestListLogs(t *testing.T) {
+	router, base := seedLogs(t)
+
+	type page struct {
+		Logs  []domain.RequestLog `json:"logs"`
+		Total int64               `json:"total"`
+		Page  int                 `json:"page"`
+		Limit int                 `json:"limit"`
+	}
+
+	var all page
+	require.Equal(t, http.StatusOK, get(t, router, "/admin/logs?limit=10&page=2", &all))
+	assert.Equal(t, int64(104), all.Total)
+	assert.Len(t, all.Logs, 10)
+	assert.Equal(t, 2, all.Page)
+
+	var errors page
+	require.Equal(t, http.StatusOK, get(t, router, "/admin/logs?path=/users/&status_min=400&status_max=599", &errors))
+	assert.Equal(t, int64(5), errors.Total)
+
+	var byIP page
+	require.Equal(t, http.StatusOK, get(t, router, "/admin/logs?ip=10.0.0.2", &byIP))
+	assert.Equal(t, int64(4), byIP.Total)
+	// ใหม่สุดก่อน
+	assert.Equal(t, "req-3", byIP.Logs[0].RequestID)
+
+	var byUser page
+	window := fmt.Sprintf("&from=%s&to=%s", base.Format(time.RFC3339), base.Add(10*time.Second).Format(time.RFC3339))
+	require.Equal(t, http.StatusOK, get(t, router, "/admin/logs?user=user-a"+window, &byUser))
+	assert.Equal(t, int64(9), byUser.Total)
+
+	var byRequest page
+	require.Equal(t, http.StatusOK, get(t, router, "/admin/logs?request_id=req-1", &byRequest))
+	require.Len(t, byRequest.Logs, 1)
+
+	assert.Equal(t, http.StatusBadRequest, get(t, router, "/admin/logs?status_min=abc", nil))
+	assert.Equal(t, http.StatusBadRequest, get(t, router, "/admin/logs?status_min=500&status_max=400", nil))
+	assert.Equal(t, http.StatusBadRequest, get(t, router, "/admin/logs?limit=1000", nil))
+	assert.Equal(t, http.StatusBadRequest, get(t, router, "/admin/logs?from=yesterday", nil))
+}
+
+func TestLogStats(t *testing.T) {
+	router, base := seedLogs(t)
+	window := fmt.Sprintf("from=%s&to=%s", base.Format(time.RFC3339), base.Add(3*time.Hour).Format(time.RFC3339))
+
+	var body struct {
+		Bucket string              `json:"bucket"`
+		Routes []domain.RouteStats `json:"routes"`
+	}
+	require.Equal(t, http.StatusOK, get(t, router, "/admin/logs/stats?"+window, &body))
+	assert.Equal(t, domain.LogBucketHour, body.Bucket)
+	require.Len(t, body.Routes, 2)
+
+	users := body.Routes[0]
+	assert.Equal(t, "/users/:id", users.Route)
+	assert.Equal(t, http.MethodGet, users.Method)
+	assert.True(t, users.BucketStart.Equal(base))
+	assert.Equal(t, int64(100), users.Count)
+	assert.Equal(t, int64(3), users.ClientErrors)
+	assert.Equal(t, int64(2), users.ServerErrors)
+	assert.InDelta(t, 0.02, users.ErrorRate, 1e-9)
+	assert.Equal(t, int64(50), users.P50)
+	assert.Equal(t, int64(95), users.P95)
+	assert.Equal(t, int64(99), users.P99)
+
+	login := body.Routes[1]
+	assert.Equal(t, "/login", login.Route)
+	assert.True(t, login.BucketStart.Equal(base.Add(time.Hour)))
+	assert.Equal(t, int64(4), login.ClientErrors)
+	assert.Equal(t, int64(200), login.P99)
+
+	// bucket รายวันรวมทั้งสอง route ไว้ในวันเดียวกัน
+	require.Equal(t, http.StatusOK, get(t, router, "/admin/logs/stats?bucket=day&"+window, &body))
+	require.Len(t, body.Routes, 2)
+	assert.True(t, body.Routes[0].BucketStart.Equal(body.Routes[1].BucketStart))
+
+	assert.Equal(t, http.StatusBadRequest, get(t, router, "/admin/logs/stats?bucket=week", nil))
+	assert.Equal(t, http.StatusBadRequest, get(t, router, "/admin/logs/stats?bucket=minute&from=2024-01-01T00:00:00Z&to=2024-01-05T00:00:00Z", nil))
+	assert.Equal(t, http.StatusBadRequest, get(t, router, "/admin/logs/stats?from=2024-01-05T00:00:00Z&to=2024-01-01T00:00:00Z", nil))
+}
+
+func TestLatencyPercentileFromHistogram(t *testing.T) {
+	histogram := map[int]*domain.LatencyBin{}
+	for ms := int64(1); ms <= 100000; ms++ {
+		n := domain.LatencyBinOf(ms)
+		if histogram[n] == nil {
+			histogram[n] = &domain.LatencyBin{Bin: n}
+		}
+		histogram[n].Count++
+		histogram[n].Max = ms
+	}
+	var bins []domain.LatencyBin
+	for _, b := range histogram {
+		bins = append(bins, *b)
+	}
+	assert.Less(t, len(bins), 1200, "histogram stays bounded")
+
+	// ค่าต่ำกว่า 100ms ตรงทุกค่า ค่าที่สูงกว่าคลาดเคลื่อนไม่เกิน 1%
+	assert.Equal(t, int64(1), domain.LatencyPercentile(bins, 0))
+	assert.InEpsilon(t, 50000, domain.LatencyPercentile(bins, 0.50), 0.01)
+	assert.InEpsilon(t, 99000, domain.LatencyPercentile(bins, 0.99), 0.01)
+	assert.Equal(t, int64(100000), domain.LatencyPercentile(bins, 1))
+	assert.Zero(t, domain.LatencyPercentile(nil, 0.5))
+
+	for ms := int64(0); ms < 100; ms++ {
+		assert.NotEqual(t, domain.LatencyBinOf(ms), domain.LatencyBinOf(ms+1), "%dms", ms)
+	}
+}
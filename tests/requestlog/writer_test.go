@@ -0,0 +1,160 @@
+package requestlog_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/internal/requestlog"
+	"github.com/Gsupakin/back_end_test_challeng/middleware"
+	"github.com/Gsupakin/back_end_test_challeng/tests/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func entry(i int) domain.RequestLog {
+	return domain.RequestLog{Method: "GET", Path: fmt.Sprintf("/users/%d", i), Status: http.StatusOK, Timestamp: time.Now()}
+}
+
+func TestWriterFlushesBySize(t *testing.T) {
+	repo := mocks.NewLogRepository()
+	w := requestlog.NewWriter(repo, requestlog.Config{QueueSize: 100, BatchSize: 10, FlushInterval: time.Hour})
+
+	for i := 0; i < 25; i++ {
+		require.True(t, w.Write(entry(i)))
+	}
+	assert.Eventually(t, func() bool { return len(repo.Logs()) == 20 }, time.Second, 5*time.Millisecond)
+
+	// ชุดที่ยังไม่ครบต้องถูกบันทึกตอนปิด
+	require.NoError(t, w.Close(context.Background()))
+	assert.Equal(t, []int{10, 10, 5}, repo.Batches())
+
+	stats := w.Stats()
+	assert.Equal(t, int64(25), stats.Enqueued)
+	assert.Equal(t, int64(25), stats.Written)
+	assert.Equal(t, int64(3), stats.Batches)
+	assert.Zero(t, stats.Dropped)
+}
+
+func TestWriterFlushesByInterval(t *testing.T) {
+	repo := mocks.NewLogRepository()
+	w := requestlog.NewWriter(repo, requestlog.Config{QueueSize: 100, BatchSize: 100, FlushInterval: 20 * time.Millisecond})
+	defer w.Close(context.Background())
+
+	w.Write(entry(1))
+	w.Write(entry(2))
+	assert.Eventually(t, func() bool { return len(repo.Logs()) == 2 }, time.Second, 5*time.Millisecond)
+}
+
+func TestWriterDropPolicy(t *testing.T) {
+	repo := mocks.NewLogRepository()
+	repo.Block = make(chan struct{})
+	w := requestlog.NewWriter(repo, requestlog.Config{QueueSize: 2, BatchSize: 1, FlushInterval: time.Hour, Policy: requestlog.PolicyDrop})
+
+	// รายการแรกค้างอยู่ที่ฐานข้อมูล สองรายการถัดไปเต็มคิว ที่เหลือต้องถูกทิ้งโดยไม่รอ
+	require.True(t, w.Write(entry(0)))
+	require.Eventually(t, func() bool { return w.Stats().Queued == 0 }, time.Second, time.Millisecond)
+	require.True(t, w.Write(entry(1)))
+	require.True(t, w.Write(entry(2)))
+
+	start := time.Now()
+	for i := 3; i < 10; i++ {
+		assert.False(t, w.Write(entry(i)))
+	}
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+	assert.Equal(t, int64(7), w.Stats().Dropped)
+
+	close(repo.Block)
+	require.NoError(t, w.Close(context.Background()))
+	assert.Len(t, repo.Logs(), 3)
+}
+
+func TestWriterBlockPolicy(t *testing.T) {
+	repo := mocks.NewLogRepository()
+	repo.Block = make(chan struct{})
+	w := requestlog.NewWriter(repo, requestlog.Config{
+		QueueSize:     1,
+		BatchSize:     1,
+		FlushInterval: time.Hour,
+		Policy:        requestlog.PolicyBlock,
+		BlockTimeout:  30 * time.Millisecond,
+	})
+
+	require.True(t, w.Write(entry(0)))
+	require.Eventually(t, func() bool { return w.Stats().Queued == 0 }, time.Second, time.Millisecond)
+	require.True(t, w.Write(entry(1)))
+
+	// คิวเต็ม: รอจนหมดเวลาแล้วจึงทิ้ง
+	start := time.Now()
+	assert.False(t, w.Write(entry(2)))
+	assert.GreaterOrEqual(t, time.Since(start), 30*time.Millisecond)
+
+	// เมื่อฐานข้อมูลกลับมา รายการที่รออยู่จะเข้าคิวได้
+	done := make(chan bool)
+	go func() { done <- w.Write(entry(3)) }()
+	repo.Block <- struct{}{}
+	assert.True(t, <-done)
+
+	close(repo.Block)
+	require.NoError(t, w.Close(context.Background()))
+	assert.Len(t, repo.Logs(), 3)
+	assert.Equal(t, int64(1), w.Stats().Dropped)
+}
+
+func TestWriterCountsFailures(t *testing.T) {
+	repo := mocks.NewLogRepository()
+	repo.Err = errors.New("mongo unavailable")
+	w := requestlog.NewWriter(repo, requestlog.Config{QueueSize: 10, BatchSize: 5, FlushInterval: time.Hour})
+
+	for i := 0; i < 3; i++ {
+		w.Write(entry(i))
+	}
+	require.NoError(t, w.Close(context.Background()))
+	assert.Equal(t, int64(3), w.Stats().Failed)
+	assert.Zero(t, w.Stats().Written)
+}
+
+func TestWriterCloseRespectsDeadline(t *testing.T) {
+	repo := mocks.NewLogRepository()
+	repo.Block = make(chan struct{})
+	w := requestlog.NewWriter(repo, requestlog.Config{QueueSize: 10, BatchSize: 1, FlushInterval: time.Hour})
+	w.Write(entry(0))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, w.Close(ctx), context.DeadlineExceeded)
+
+	// หลังปิดแล้วต้องไม่ panic และนับเป็น log ที่ถูกทิ้ง
+	assert.False(t, w.Write(entry(1)))
+	close(repo.Block)
+}
+
+func TestRequestLoggerMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := mocks.NewLogRepository()
+	w := requestlog.NewWriter(repo, requestlog.DefaultConfig())
+
+	router := gin.New()
+	router.Use(middleware.RequestLogger(w))
+	router.POST("/register", func(c *gin.Context) { c.Status(http.StatusBadRequest) })
+
+	for i := 0; i < 3; i++ {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/register", nil))
+	}
+	require.NoError(t, w.Close(context.Background()))
+
+	// หนึ่ง request ต้องได้ log เพียงรายการเดียว
+	logs := repo.Logs()
+	require.Len(t, logs, 3)
+	for _, l := range logs {
+		assert.Equal(t, "/register", l.Path)
+		assert.Equal(t, http.StatusBadRequest, l.Status)
+	}
+	assert.Equal(t, []int{3}, repo.Batches())
+}
@@ -0,0 +1,93 @@
+package totp_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/pkg/totp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret คือ "12345678901234567890" ในรูป base32 ซึ่งเป็น secret ของ test vector SHA-1 ใน RFC 6238
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestRFC6238Vectors(t *testing.T) {
+	// RFC 6238 Appendix B ให้รหัส 8 หลัก รหัส 6 หลักคือ 6 หลักท้าย
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, v := range vectors {
+		code, err := totp.Code(rfcSecret, totp.Step(time.Unix(v.unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, v.code[2:], code, "T=%d", v.unix)
+	}
+
+	// secret ตัวพิมพ์เล็กและมีช่องว่างก็ใช้ได้
+	code, err := totp.Code(" "+strings.ToLower(rfcSecret)+" ", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "287082", code)
+
+	_, err = totp.Code("not base32!", 1)
+	assert.Error(t, err)
+}
+
+func TestValidateSkewAndReplay(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := totp.Step(now)
+	codeAt := func(step int64) string {
+		code, err := totp.Code(rfcSecret, step)
+		require.NoError(t, err)
+		return code
+	}
+
+	step, ok := totp.Validate(rfcSecret, codeAt(current), now, 0)
+	require.True(t, ok)
+	assert.Equal(t, current, step)
+
+	// ยอมให้คลาดเคลื่อนได้ Skew step ทั้งก่อนและหลัง
+	for _, delta := range []int64{-totp.Skew, totp.Skew} {
+		step, ok := totp.Validate(rfcSecret, codeAt(current+delta), now, 0)
+		assert.True(t, ok, "delta %d", delta)
+		assert.Equal(t, current+delta, step)
+	}
+	for _, delta := range []int64{-totp.Skew - 1, totp.Skew + 1} {
+		_, ok := totp.Validate(rfcSecret, codeAt(current+delta), now, 0)
+		assert.False(t, ok, "delta %d", delta)
+	}
+
+	// รหัสของ step ที่ใช้ไปแล้วหรือเก่ากว่าถูกปฏิเสธ
+	_, ok = totp.Validate(rfcSecret, codeAt(current), now, current)
+	assert.False(t, ok)
+	_, ok = totp.Validate(rfcSecret, codeAt(current-1), now, current-1)
+	assert.False(t, ok)
+	step, ok = totp.Validate(rfcSecret, codeAt(current+1), now, current)
+	assert.True(t, ok)
+	assert.Equal(t, current+1, step)
+
+	_, ok = totp.Validate(rfcSecret, "12345", now, 0)
+	assert.False(t, ok)
+	_, ok = totp.Validate(rfcSecret, " "+codeAt(current)+" ", now, 0)
+	assert.True(t, ok)
+}
+
+func TestKeyURI(t *testing.T) {
+	uri := totp.KeyURI("Example App", "alice@example.com", rfcSecret)
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Example%20App:alice@example.com?"), uri)
+	assert.Contains(t, uri, "secret="+rfcSecret)
+	assert.Contains(t, uri, "digits=6")
+	assert.Contains(t, uri, "period=30")
+
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	assert.Len(t, secret, 32)
+}
@@ -0,0 +1,166 @@
+package tracing_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	grpcserver "github.com/Gsupakin/back_end_test_challeng/internal/grpc"
+	"github.com/Gsupakin/back_end_test_challeng/middleware"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/logger"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/requestid"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/tracing"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/utils"
+	pb "github.com/Gsupakin/back_end_test_challeng/proto"
+	"github.com/Gsupakin/back_end_test_challeng/tests/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newRecorder ติดตั้ง tracer provider ที่เก็บ span ไว้ในหน่วยความจำ
+func newRecorder(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	_, err := tracing.Setup(context.Background(), tracing.Config{Exporter: tracing.ExporterNone})
+	require.NoError(t, err)
+	return recorder
+}
+
+func spanByName(t *testing.T, spans []sdktrace.ReadOnlySpan, name string, kind trace.SpanKind) sdktrace.ReadOnlySpan {
+	for _, s := range spans {
+		if s.Name() == name && s.SpanKind() == kind {
+			return s
+		}
+	}
+	t.Fatalf("span %q (%v) not found", name, kind)
+	return nil
+}
+
+func TestTraceContextFromHTTPToGRPC(t *testing.T) {
+	recorder := newRecorder(t)
+	gin.SetMode(gin.TestMode)
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(tracing.ServerOption(), grpc.ChainUnaryInterceptor(grpcserver.RequestIDInterceptor))
+	pb.RegisterUserServiceServer(server, grpcserver.NewUserServer(mocks.NewUserRepository()))
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		tracing.DialOption(),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+	client := pb.NewUserServiceClient(conn)
+
+	var logs bytes.Buffer
+	log := logger.New(&logs, logger.DefaultConfig())
+
+	router := gin.New()
+	router.Use(middleware.Tracing("test-service"))
+	router.Use(middleware.RequestID())
+	router.GET("/users/:id", func(c *gin.Context) {
+		ctx := c.Request.Context()
+		utils.CheckPasswordHashContext(ctx, "password", "not-a-bcrypt-hash")
+		client.GetUser(ctx, &pb.GetUserRequest{Id: c.Param("id")})
+		log.InfoContext(ctx, "handled")
+		c.Status(http.StatusOK)
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/users/abc", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	req.Header.Set(requestid.Header, "req-trace")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	server.GracefulStop()
+
+	spans := recorder.Ended()
+	httpSpan := spanByName(t, spans, "/users/:id", trace.SpanKindServer)
+	hashSpan := spanByName(t, spans, "bcrypt.compare", trace.SpanKindInternal)
+	clientSpan := spanByName(t, spans, "user.UserService/GetUser", trace.SpanKindClient)
+	serverSpan := spanByName(t, spans, "user.UserService/GetUser", trace.SpanKindServer)
+
+	// ทุก span อยู่ใน trace ที่ client ส่งมา
+	for _, s := range spans {
+		assert.Equal(t, traceID, s.SpanContext().TraceID().String(), s.Name())
+	}
+	assert.Equal(t, "00f067aa0ba902b7", httpSpan.Parent().SpanID().String())
+	assert.Equal(t, httpSpan.SpanContext().SpanID(), hashSpan.Parent().SpanID())
+
+	// span ฝั่ง gRPC server เป็นลูกของ span ฝั่ง client ผ่าน traceparent ใน metadata
+	assert.Equal(t, httpSpan.SpanContext().SpanID(), clientSpan.Parent().SpanID())
+	assert.Equal(t, clientSpan.SpanContext().SpanID(), serverSpan.Parent().SpanID())
+
+	var requestIDs []string
+	for _, s := range []sdktrace.ReadOnlySpan{httpSpan, serverSpan} {
+		for _, attr := range s.Attributes() {
+			if attr.Key == "request.id" {
+				requestIDs = append(requestIDs, attr.Value.AsString())
+			}
+		}
+	}
+	assert.Len(t, requestIDs, 2, "both HTTP and gRPC spans carry a request ID")
+	assert.Equal(t, "req-trace", requestIDs[0])
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(logs.Bytes(), &record))
+	assert.Equal(t, traceID, record["trace_id"])
+	assert.Equal(t, httpSpan.SpanContext().SpanID().String(), record["span_id"])
+}
+
+func TestMetricsEndpointIsNotTraced(t *testing.T) {
+	recorder := newRecorder(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.Tracing("test-service"))
+	router.GET("/metrics", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Empty(t, recorder.Ended())
+}
+
+func TestFileExporter(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	file := filepath.Join(t.TempDir(), "traces.json")
+	shutdown, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    tracing.ExporterFile,
+		ServiceName: "offline",
+		File:        file,
+	})
+	require.NoError(t, err)
+
+	_, span := tracing.Start(context.Background(), "offline-span")
+	span.End()
+	require.NoError(t, shutdown(context.Background()))
+
+	f, err := os.Open(file)
+	require.NoError(t, err)
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1<<20), 1<<20)
+	require.True(t, scanner.Scan())
+	var exported map[string]any
+	require.NoError(t, json.Unmarshal(scanner.Bytes(), &exported))
+	assert.Equal(t, "offline-span", exported["Name"])
+}
@@ -0,0 +1,296 @@
+package useradmin_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/application"
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/utils"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/validator"
+	"github.com/Gsupakin/back_end_test_challeng/tests/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newAdmin(t *testing.T) (*application.UserAdmin, *mocks.UserRepository, *mocks.APITokenRepository) {
+	utils.BcryptCost = 4
+	users := mocks.NewUserRepository()
+	tokens := mocks.NewAPITokenRepository()
+	return application.NewUserAdmin(users, tokens), users, tokens
+}
+
+func TestCreateUsesAPIValidation(t *testing.T) {
+	admin, _, _ := newAdmin(t)
+	ctx := context.Background()
+
+	_, err := admin.Create(ctx, application.NewUser{Name: "Alice", Email: "not-an-email", Password: "Secret123"})
+	assert.ErrorIs(t, err, validator.ErrInvalidEmail)
+	_, err = admin.Create(ctx, application.NewUser{Name: "Alice", Email: "alice@example.com", Password: "123"})
+	assert.ErrorIs(t, err, validator.ErrInvalidPassword)
+	_, err = admin.Create(ctx, application.NewUser{Name: "Alice", Email: "alice@example.com", Password: "Secret123", Role: "root"})
+	assert.ErrorIs(t, err, domain.ErrInvalidInput)
+
+	user, err := admin.Create(ctx, application.NewUser{Name: "Alice", Email: "alice@example.com", Password: "Secret123", Role: domain.RoleAdmin})
+	require.NoError(t, err)
+	assert.Equal(t, domain.RoleAdmin, user.Role)
+	assert.Equal(t, domain.StatusActive, user.Status)
+	assert.True(t, utils.CheckPasswordHash("Secret123", user.Password))
+
+	_, err = admin.Create(ctx, application.NewUser{Name: "Alice", Email: "other@example.com", Password: "Secret123"})
+	assert.ErrorIs(t, err, domain.ErrUserAlreadyExists)
+	_, err = admin.Create(ctx, application.NewUser{Name: "Bob", Email: "alice@example.com", Password: "Secret123"})
+	assert.ErrorIs(t, err, domain.ErrUserAlreadyExists)
+}
+
+func TestRoleStatusAndPassword(t *testing.T) {
+	admin, _, _ := newAdmin(t)
+	ctx := context.Background()
+	user, err := admin.Create(ctx, application.NewUser{Name: "Alice", Email: "alice@example.com", Password: "Secret123"})
+	require.NoError(t, err)
+
+	// อ้างถึงผู้ใช้ได้ทั้ง ID และอีเมล
+	updated, err := admin.SetRole(ctx, user.ID.Hex(), domain.RoleAdmin)
+	require.NoError(t, err)
+	assert.True(t, updated.IsAdmin())
+
+	_, err = admin.SetStatus(ctx, "alice@example.com", "locked", "typo", nil)
+	assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	_, err = admin.SetStatus(ctx, "alice@example.com", domain.StatusPending, "back to pending", nil)
+	assert.ErrorIs(t, err, domain.ErrInvalidTransition)
+
+	_, err = admin.ResetPassword(ctx, "alice@example.com", "abc")
+	assert.ErrorIs(t, err, validator.ErrInvalidPassword)
+	updated, err = admin.ResetPassword(ctx, "alice@example.com", "NewSecret1")
+	require.NoError(t, err)
+	assert.True(t, utils.CheckPasswordHash("NewSecret1", updated.Password))
+
+	_, err = admin.SetRole(ctx, "nobody@example.com", domain.RoleAdmin)
+	assert.ErrorIs(t, err, domain.ErrUserNotFound)
+}
+
+func TestLastAdminIsProtected(t *testing.T) {
+	admin, _, _ := newAdmin(t)
+	ctx := context.Background()
+	_, err := admin.Create(ctx, application.NewUser{Name: "Root", Email: "root@example.com", Password: "Secret123", Role: domain.RoleAdmin})
+	require.NoError(t, err)
+
+	_, err = admin.SetRole(ctx, "root@example.com", domain.RoleUser)
+	assert.ErrorIs(t, err, application.ErrLastAdmin)
+	_, err = admin.SetStatus(ctx, "root@example.com", domain.StatusSuspended, "holiday", nil)
+	assert.ErrorIs(t, err, application.ErrLastAdmin)
+	_, err = admin.Transition(ctx, "root@example.com", domain.TransitionBan, "abuse", nil)
+	assert.ErrorIs(t, err, application.ErrLastAdmin)
+	_, err = admin.Delete(ctx, "root@example.com")
+	assert.ErrorIs(t, err, application.ErrLastAdmin)
+
+	_, err = admin.Create(ctx, application.NewUser{Name: "Second", Email: "second@example.com", Password: "Secret123", Role: domain.RoleAdmin})
+	require.NoError(t, err)
+	_, err = admin.SetRole(ctx, "root@example.com", domain.RoleUser)
+	assert.NoError(t, err)
+}
+
+func TestDeleteRestoreAndSearch(t *testing.T) {
+	admin, repo, _ := newAdmin(t)
+	ctx := context.Background()
+	for _, u := range []application.NewUser{
+		{Name: "Alice", Email: "alice@example.com", Password: "Secret123"},
+		{Name: "Bob", Email: "bob@example.org", Password: "Secret123"},
+		{Name: "Carol", Email: "carol@example.com", Password: "Secret123", Role: domain.RoleAdmin},
+	} {
+		_, err := admin.Create(ctx, u)
+		require.NoError(t, err)
+	}
+
+	deleted, err := admin.Delete(ctx, "bob@example.org")
+	require.NoError(t, err)
+	_, err = admin.Find(ctx, deleted.ID.Hex())
+	assert.ErrorIs(t, err, domain.ErrUserNotFound)
+
+	users, err := admin.Search(ctx, domain.UserFilter{})
+	require.NoError(t, err)
+	assert.Len(t, users, 2)
+	count, err := repo.Count(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count, "Count skips deleted users")
+	users, err = admin.Search(ctx, domain.UserFilter{IncludeDeleted: true})
+	require.NoError(t, err)
+	assert.Len(t, users, 3)
+	users, err = admin.Search(ctx, domain.UserFilter{Query: "EXAMPLE.COM", Role: domain.RoleAdmin})
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, "Carol", users[0].Name)
+	_, err = admin.Search(ctx, domain.UserFilter{Status: "gone"})
+	assert.ErrorIs(t, err, domain.ErrInvalidInput)
+
+	restored, err := admin.Restore(ctx, "bob@example.org")
+	require.NoError(t, err)
+	assert.Nil(t, restored.DeletedAt)
+	_, err = admin.Restore(ctx, deleted.ID.Hex())
+	assert.ErrorIs(t, err, domain.ErrUserNotFound, "restoring a user that is not deleted")
+}
+
+func TestIssueToken(t *testing.T) {
+	admin, _, tokens := newAdmin(t)
+	ctx := context.Background()
+	user, err := admin.Create(ctx, application.NewUser{Name: "Alice", Email: "alice@example.com", Password: "Secret123"})
+	require.NoError(t, err)
+
+	_, _, err = admin.IssueToken(ctx, "alice@example.com", "ci", []string{domain.ScopeAdmin}, 0)
+	assert.Error(t, err, "admin scope for a non-admin")
+	_, _, err = admin.IssueToken(ctx, "alice@example.com", "ci", []string{"users:delete"}, 0)
+	assert.EqualError(t, err, "Unsupported scope: users:delete")
+	_, _, err = admin.IssueToken(ctx, "alice@example.com", "ci", []string{domain.ScopeUsersRead}, -1)
+	assert.Error(t, err)
+
+	raw, token, err := admin.IssueToken(ctx, "alice@example.com", "ci", []string{domain.ScopeUsersRead}, 30)
+	require.NoError(t, err)
+	assert.NotEmpty(t, raw)
+	assert.Equal(t, user.ID, token.UserID)
+	assert.Equal(t, domain.TokenKindService, token.Kind)
+	require.NotNil(t, token.ExpiresAt)
+
+	stored, err := tokens.FindByID(ctx, token.ID)
+	require.NoError(t, err)
+	assert.NotContains(t, stored.Hash, raw)
+
+	_, err = admin.SetStatus(ctx, "alice@example.com", domain.StatusSuspended, "investigation", nil)
+	require.NoError(t, err)
+	_, _, err = admin.IssueToken(ctx, "alice@example.com", "ci", []string{domain.ScopeUsersRead}, 0)
+	assert.ErrorIs(t, err, domain.ErrUserInactive)
+}
+
+func TestDeletedUserFreesEmailAndName(t *testing.T) {
+	admin, _, _ := newAdmin(t)
+	ctx := context.Background()
+	old, err := admin.Create(ctx, application.NewUser{Name: "Alice", Email: "alice@example.com", Password: "Secret123"})
+	require.NoError(t, err)
+	_, err = admin.Delete(ctx, old.ID.Hex())
+	require.NoError(t, err)
+
+	// สมัครใหม่ด้วยอีเมลและชื่อเดิมได้ แต่กู้คืนคนเดิมไม่ได้จนกว่าคนใหม่จะถูกลบ
+	current, err := admin.Create(ctx, application.NewUser{Name: "Alice", Email: "alice@example.com", Password: "Secret123"})
+	require.NoError(t, err)
+	_, err = admin.Restore(ctx, old.ID.Hex())
+	assert.ErrorIs(t, err, domain.ErrUserAlreadyExists)
+	_, err = admin.Restore(ctx, "alice@example.com")
+	assert.ErrorIs(t, err, domain.ErrUserAlreadyExists)
+
+	_, err = admin.Delete(ctx, current.ID.Hex())
+	require.NoError(t, err)
+	_, err = admin.Restore(ctx, "alice@example.com")
+	assert.ErrorIs(t, err, domain.ErrInvalidInput, "two deleted users share the email")
+	restored, err := admin.Restore(ctx, old.ID.Hex())
+	require.NoError(t, err)
+	assert.Equal(t, old.ID, restored.ID)
+}
+
+func TestListAndRestoreEndpoints(t *testing.T) {
+	admin, _, _ := newAdmin(t)
+	ctx := context.Background()
+	gin.SetMode(gin.TestMode)
+	h := application.NewUserAdminHandler(admin)
+	router := gin.New()
+	router.GET("/admin/users", h.List)
+	router.POST("/admin/users/:id/restore", h.Restore)
+	do := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec
+	}
+	list := func(query string) []domain.User {
+		rec := do(http.MethodGet, "/admin/users"+query)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var users []domain.User
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &users))
+		return users
+	}
+
+	alice, err := admin.Create(ctx, application.NewUser{Name: "Alice", Email: "alice@example.com", Password: "Secret123"})
+	require.NoError(t, err)
+	_, err = admin.Create(ctx, application.NewUser{Name: "Bob", Email: "bob@example.com", Password: "Secret123"})
+	require.NoError(t, err)
+	_, err = admin.Delete(ctx, alice.ID.Hex())
+	require.NoError(t, err)
+
+	assert.Len(t, list(""), 1)
+	users := list("?deleted=true&q=alice")
+	require.Len(t, users, 1)
+	assert.NotNil(t, users[0].DeletedAt)
+	assert.Empty(t, users[0].Password)
+	assert.Len(t, list("?deleted=true&limit=1"), 1)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/admin/users?deleted=maybe").Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/admin/users?limit=0").Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/admin/users?role=root").Code)
+
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/admin/users/nope/restore").Code)
+	rec := do(http.MethodPost, "/admin/users/"+alice.ID.Hex()+"/restore")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var restored domain.User
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &restored))
+	assert.Nil(t, restored.DeletedAt)
+	assert.Empty(t, restored.Password)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, "/admin/users/"+alice.ID.Hex()+"/restore").Code)
+
+	_, err = admin.Delete(ctx, alice.ID.Hex())
+	require.NoError(t, err)
+	_, err = admin.Create(ctx, application.NewUser{Name: "Alice", Email: "alice@example.com", Password: "Secret123"})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, do(http.MethodPost, "/admin/users/"+alice.ID.Hex()+"/restore").Code)
+}
+
+func TestStatusTransitionEndpoints(t *testing.T) {
+	admin, users, _ := newAdmin(t)
+	ctx := context.Background()
+	gin.SetMode(gin.TestMode)
+	root, err := admin.Create(ctx, application.NewUser{Name: "Root", Email: "root@example.com", Password: "Secret123", Role: domain.RoleAdmin})
+	require.NoError(t, err)
+	alice, err := admin.Create(ctx, application.NewUser{Name: "Alice", Email: "alice@example.com", Password: "Secret123"})
+	require.NoError(t, err)
+
+	h := application.NewUserAdminHandler(admin)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("user_id", root.ID.Hex()) })
+	router.POST("/admin/users/:id/activate", h.Activate)
+	router.POST("/admin/users/:id/suspend", h.Suspend)
+	router.POST("/admin/users/:id/reactivate", h.Reactivate)
+	router.POST("/admin/users/:id/ban", h.Ban)
+	do := func(action string, id primitive.ObjectID, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/admin/users/"+id.Hex()+"/"+action, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusBadRequest, do("suspend", alice.ID, `{}`).Code, "reason is required")
+	assert.Equal(t, http.StatusBadRequest, do("suspend", alice.ID, `{"reason":"spam","until":"2000-01-01T00:00:00Z"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do("ban", alice.ID, `{"reason":"spam","until":"2999-01-01T00:00:00Z"}`).Code)
+	assert.Equal(t, http.StatusNotFound, do("suspend", primitive.NewObjectID(), `{"reason":"spam"}`).Code)
+	assert.Equal(t, http.StatusForbidden, do("suspend", root.ID, `{"reason":"spam"}`).Code)
+	assert.Equal(t, http.StatusConflict, do("activate", alice.ID, `{"reason":"welcome"}`).Code, "already active")
+
+	rec := do("suspend", alice.ID, `{"reason":"spam","until":"2999-01-01T00:00:00Z"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var suspended domain.User
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &suspended))
+	assert.Equal(t, domain.StatusSuspended, suspended.Status)
+	assert.Equal(t, "spam", suspended.StatusReason)
+	require.NotNil(t, suspended.StatusUntil)
+	assert.Equal(t, 2999, suspended.StatusUntil.Year())
+	assert.Empty(t, suspended.Password)
+
+	assert.Equal(t, http.StatusConflict, do("ban", alice.ID, `{"reason":"abuse"}`).Code, "suspended users must be reactivated first")
+	require.Equal(t, http.StatusOK, do("reactivate", alice.ID, `{"reason":"appeal accepted"}`).Code)
+	stored, err := users.FindByID(ctx, alice.ID)
+	require.NoError(t, err)
+	assert.Nil(t, stored.StatusUntil)
+	require.Equal(t, http.StatusOK, do("ban", alice.ID, `{"reason":"abuse"}`).Code)
+	assert.Equal(t, http.StatusConflict, do("reactivate", alice.ID, `{"reason":"oops"}`).Code, "bans are final")
+}
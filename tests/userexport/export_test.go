@@ -0,0 +1,165 @@
+package userexport_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/application"
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/internal/userexport"
+	"github.com/Gsupakin/back_end_test_challeng/tests/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var base = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// seed สร้างผู้ใช้สามคนที่ถูกสร้างห่างกันวันละคน คนสุดท้ายถูกลบแล้ว
+func seed(t *testing.T) *mocks.UserRepository {
+	t.Helper()
+	repo := mocks.NewUserRepository()
+	for i, name := range []string{"Alice", "Bob", "Carol"} {
+		user := domain.NewUser(name, strings.ToLower(name)+"@example.com", "$2a$04$secret-hash")
+		user.CreatedAt = base.AddDate(0, 0, i)
+		user.MFA.Secret = "MFA-SECRET"
+		switch name {
+		case "Alice":
+			user.Role = domain.RoleAdmin
+			user.MFA.Enabled = true
+			user.LinkedIdentities = []domain.LinkedIdentity{{Provider: "google"}}
+		case "Carol":
+			user.SoftDelete()
+		}
+		_, err := repo.Create(context.Background(), *user)
+		require.NoError(t, err)
+	}
+	return repo
+}
+
+func router(repo domain.UserRepository) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/admin/users/export", application.NewUserExportHandler(repo).Export)
+	return r
+}
+
+func get(r *gin.Engine, query string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/admin/users/export"+query, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCSVExport(t *testing.T) {
+	rec := get(router(seed(t)), "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "attachment; filename=users.csv", rec.Header().Get("Content-Disposition"))
+	assert.Equal(t, "complete", rec.Result().Trailer.Get("X-Export-Status"))
+	assert.Equal(t, "2", rec.Result().Trailer.Get("X-Export-Rows"))
+
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, userexport.Fields(), records[0])
+	assert.Equal(t, []string{"Alice", "alice@example.com", "admin", "active", "true", "google"}, records[1][1:7])
+	assert.Equal(t, "2024-03-01T12:00:00Z", records[1][8])
+	assert.Equal(t, "Bob", records[2][1])
+}
+
+func TestNDJSONExportWithFieldsAndFilters(t *testing.T) {
+	r := router(seed(t))
+
+	rec := get(r, "?format=ndjson&fields=email,role&deleted=true")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, `{"email":"alice@example.com","role":"admin"}`, lines[0])
+
+	// from รวม to ไม่รวม
+	rec = get(r, "?format=ndjson&fields=name&deleted=true&from=2024-03-02T12:00:00Z&to=2024-03-03T12:00:00Z")
+	assert.Equal(t, `{"name":"Bob"}`+"\n", rec.Body.String())
+
+	rec = get(r, "?format=ndjson&fields=name&role=admin")
+	assert.Equal(t, `{"name":"Alice"}`+"\n", rec.Body.String())
+
+	rec = get(r, "?format=ndjson&fields=name,deleted_at&status=active")
+	var user map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(strings.Split(rec.Body.String(), "\n")[0]), &user))
+	assert.Equal(t, map[string]interface{}{"name": "Alice", "deleted_at": nil}, user)
+}
+
+func TestExportRejectsInvalidQueries(t *testing.T) {
+	r := router(seed(t))
+	for _, query := range []string{
+		"?format=xlsx",
+		"?fields=password",
+		"?fields=email,email",
+		"?role=root",
+		"?status=gone",
+		"?from=yesterday",
+		"?deleted=maybe",
+	} {
+		rec := get(r, query)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+		assert.NotContains(t, rec.Header().Get("Content-Disposition"), "attachment", query)
+	}
+}
+
+func TestParquetExport(t *testing.T) {
+	rec := get(router(seed(t)), "?format=parquet&fields=email,mfa_enabled,created_at,deleted_at&deleted=true")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	type row struct {
+		Email      string    `parquet:"email"`
+		MFAEnabled bool      `parquet:"mfa_enabled"`
+		CreatedAt  time.Time `parquet:"created_at,timestamp(millisecond)"`
+		DeletedAt  *int64    `parquet:"deleted_at,optional"`
+	}
+	data := rec.Body.Bytes()
+	rows, err := parquet.Read[row](bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, "alice@example.com", rows[0].Email)
+	assert.True(t, rows[0].MFAEnabled)
+	assert.True(t, rows[0].CreatedAt.Equal(base))
+	assert.Nil(t, rows[0].DeletedAt)
+	assert.False(t, rows[1].MFAEnabled)
+	assert.NotNil(t, rows[2].DeletedAt)
+}
+
+func TestGzipExportNeverContainsSecrets(t *testing.T) {
+	rec := get(router(seed(t)), "?format=ndjson&deleted=true", "Accept-Encoding", "gzip, deflate")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+
+	gz, err := gzip.NewReader(rec.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, 3, strings.Count(string(body), "\n"))
+	assert.NotContains(t, string(body), "secret-hash")
+	assert.NotContains(t, string(body), "MFA-SECRET")
+	assert.NotContains(t, string(body), "password")
+}
+
+func TestEmptyExportStillHasHeader(t *testing.T) {
+	rec := get(router(mocks.NewUserRepository()), "?fields=id,email")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "id,email\n", rec.Body.String())
+	assert.Equal(t, "0", rec.Result().Trailer.Get("X-Export-Rows"))
+}
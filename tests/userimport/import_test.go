@@ -0,0 +1,199 @@
+package userimport_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Gsupakin/back_end_test_challeng/internal/application"
+	"github.com/Gsupakin/back_end_test_challeng/internal/domain"
+	"github.com/Gsupakin/back_end_test_challeng/internal/userimport"
+	"github.com/Gsupakin/back_end_test_challeng/pkg/utils"
+	"github.com/Gsupakin/back_end_test_challeng/tests/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func run(t *testing.T, repo domain.UserRepository, format, data string, opts userimport.Options) *userimport.Report {
+	t.Helper()
+	utils.BcryptCost = 4
+	rows, err := userimport.NewReader(strings.NewReader(data), format)
+	require.NoError(t, err)
+	report, err := userimport.NewImporter(repo).Run(context.Background(), rows, opts)
+	require.NoError(t, err)
+	return report
+}
+
+func actions(report *userimport.Report) map[int]string {
+	out := map[int]string{}
+	for _, r := range report.Results {
+		out[r.Line] = r.Action
+	}
+	return out
+}
+
+func TestCSVImportValidatesAndDetectsDuplicates(t *testing.T) {
+	repo := mocks.NewUserRepository()
+	_, err := repo.Create(context.Background(), *domain.NewUser("Existing", "existing@example.com", "hash"))
+	require.NoError(t, err)
+
+	hash, err := utils.HashPassword("Imported1")
+	require.NoError(t, err)
+	report := run(t, repo, userimport.FormatCSV, `name,email,password,password_hash,role
+Alice,alice@example.com,Secret123,,
+Bob,bad-email,Secret123,,
+Carol,carol@example.com,,`+hash+`,admin
+Alice Two,ALICE@example.com,Secret123,,
+Dave,dave@example.com,,,
+Existing,existing@example.com,Secret123,,
+Eve,eve@example.com,Secret123,,root
+Frank,frank@example.com,Secret123,`+hash+`,
+Existing,someone@example.com,Secret123,,
+`, userimport.Options{Workers: 3})
+
+	assert.Equal(t, map[int]string{
+		2:  userimport.ActionCreated,
+		3:  userimport.ActionFailed, // อีเมลผิดรูปแบบ
+		4:  userimport.ActionCreated,
+		5:  userimport.ActionFailed, // อีเมลซ้ำกับบรรทัด 2
+		6:  userimport.ActionFailed, // ไม่มีรหัสผ่าน
+		7:  userimport.ActionSkipped,
+		8:  userimport.ActionFailed, // role ไม่รู้จัก
+		9:  userimport.ActionFailed, // มีทั้ง password และ password_hash
+		10: userimport.ActionFailed, // ชื่อซ้ำกับผู้ใช้ในระบบ
+	}, actions(report))
+	assert.Equal(t, 9, report.Total)
+	assert.Equal(t, 2, report.Created)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Equal(t, 6, report.Failed)
+	assert.Equal(t, "duplicate email in file, first seen on line 2", report.Results[3].Error)
+
+	alice, err := repo.FindByEmail(context.Background(), "alice@example.com")
+	require.NoError(t, err)
+	assert.True(t, utils.CheckPasswordHash("Secret123", alice.Password))
+	assert.Equal(t, domain.RoleUser, alice.Role)
+
+	// hash ที่ให้มาถูกเก็บตามเดิม
+	carol, err := repo.FindByEmail(context.Background(), "carol@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, hash, carol.Password)
+	assert.True(t, carol.IsAdmin())
+}
+
+func TestNDJSONUpsertAndDryRun(t *testing.T) {
+	repo := mocks.NewUserRepository()
+	ctx := context.Background()
+	_, err := repo.Create(ctx, *domain.NewUser("Alice", "alice@example.com", "old-hash"))
+	require.NoError(t, err)
+
+	data := `{"name":"Alice","email":"alice@example.com","role":"admin"}
+
+{"name":"Bob","email":"bob@example.com","password":"Secret123"}
+{"name":"Carol","email":"carol@example.com","pasword":"typo"}
+{"name":"Alice","email":"alice@example.com"}
+`
+	report := run(t, repo, userimport.FormatNDJSON, data, userimport.Options{Mode: userimport.ModeUpsert, DryRun: true})
+	assert.True(t, report.DryRun)
+	assert.Equal(t, map[int]string{
+		1: userimport.ActionUpdated,
+		3: userimport.ActionCreated,
+		4: userimport.ActionFailed, // field ที่ไม่รู้จัก
+		5: userimport.ActionFailed, // ซ้ำกับบรรทัด 1
+	}, actions(report))
+	assert.Contains(t, report.Results[2].Error, "invalid JSON")
+
+	// dry run ไม่เขียนอะไรเลย
+	count, _ := repo.Count(ctx)
+	assert.Equal(t, int64(1), count)
+	alice, _ := repo.FindByEmail(ctx, "alice@example.com")
+	assert.Equal(t, domain.RoleUser, alice.Role)
+
+	report = run(t, repo, userimport.FormatNDJSON, data, userimport.Options{Mode: userimport.ModeUpsert})
+	assert.Equal(t, 1, report.Updated)
+	assert.Equal(t, 1, report.Created)
+	alice, _ = repo.FindByEmail(ctx, "alice@example.com")
+	assert.True(t, alice.IsAdmin())
+	assert.Equal(t, "old-hash", alice.Password, "upsert without a password keeps the old one")
+
+	// แถวที่ไม่มีอะไรเปลี่ยนถูกข้าม
+	report = run(t, repo, userimport.FormatNDJSON, `{"name":"Alice","email":"alice@example.com","role":"admin"}`, userimport.Options{Mode: userimport.ModeUpsert})
+	assert.Equal(t, 1, report.Skipped)
+}
+
+func TestCSVHeaderErrors(t *testing.T) {
+	for data, msg := range map[string]string{
+		"":                        "csv: file is empty",
+		"name,mail\n":             `unknown column "mail"`,
+		"name,password\n":         `missing column "email"`,
+		"name,email,name\n":       `duplicate column "name"`,
+		"\ufeffName,Email\nA,b\n": "",
+	} {
+		_, err := userimport.NewReader(strings.NewReader(data), userimport.FormatCSV)
+		if msg == "" {
+			assert.NoError(t, err)
+			continue
+		}
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), msg)
+	}
+
+	_, err := userimport.NewReader(strings.NewReader(""), "xlsx")
+	assert.Error(t, err)
+	assert.Equal(t, userimport.FormatNDJSON, userimport.FormatFromFilename("users.JSONL"))
+}
+
+func TestCSVWrongFieldCountFailsOnlyThatRow(t *testing.T) {
+	report := run(t, mocks.NewUserRepository(), userimport.FormatCSV, "name,email,password\nAlice,alice@example.com\nBob,bob@example.com,Secret123\n", userimport.Options{})
+	assert.Equal(t, map[int]string{2: userimport.ActionFailed, 3: userimport.ActionCreated}, actions(report))
+}
+
+func TestImportEndpoint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	utils.BcryptCost = 4
+	repo := mocks.NewUserRepository()
+	router := gin.New()
+	router.POST("/admin/users/import", application.NewUserImportHandler(userimport.NewImporter(repo), 2).Import)
+
+	upload := func(query, filename, content string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		part, _ := w.CreateFormFile("file", filename)
+		part.Write([]byte(content))
+		w.Close()
+		req := httptest.NewRequest(http.MethodPost, "/admin/users/import"+query, &body)
+		req.Header.Set("Content-Type", w.FormDataContentType())
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := upload("?dry_run=true", "users.csv", "name,email,password\nAlice,alice@example.com,Secret123\n")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var report userimport.Report
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.True(t, report.DryRun)
+	assert.Equal(t, 1, report.Created)
+	count, _ := repo.Count(context.Background())
+	assert.Zero(t, count)
+
+	rec = upload("", "users.ndjson", `{"name":"Alice","email":"alice@example.com","password":"Secret123"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	count, _ = repo.Count(context.Background())
+	assert.Equal(t, int64(1), count)
+
+	assert.Equal(t, http.StatusBadRequest, upload("", "users.xlsx", "").Code)
+	assert.Equal(t, http.StatusBadRequest, upload("?mode=replace", "users.csv", "name,email\n").Code)
+	assert.Equal(t, http.StatusBadRequest, upload("", "users.csv", "name\n").Code)
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/users/import", strings.NewReader("{}"))
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}